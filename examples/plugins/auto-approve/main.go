@@ -2,21 +2,35 @@
 //
 // This plugin demonstrates:
 // - Implementing permission hooks
-// - Auto-approving specific tools or patterns
-// - Reading configuration from plugin context
+// - Auto-approving tools whose names match glob patterns
+// - Reading settings from the plugin's configuration section
 //
 // To build this plugin:
-//   go build -buildmode=plugin -o auto-approve.so main.go
+//
+//	go build -buildmode=plugin -o auto-approve.so main.go
 //
 // To use this plugin, add to your crush config:
-//   {
-//     "plugins": ["./examples/plugins/auto-approve/auto-approve.so"]
-//   }
+//
+//	{
+//	  "plugins": ["./examples/plugins/auto-approve/auto-approve.so"],
+//	  "options": {
+//	    "plugins": {
+//	      "settings": {
+//	        "auto-approve": {
+//	          "tools": ["view", "glob", "grep", "ls", "read_*", "mcp_github_*"],
+//	          "read_actions": false
+//	        }
+//	      }
+//	    }
+//	  }
+//	}
 package main
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"regexp"
 	"strings"
 
 	"github.com/charmbracelet/crush/internal/permission"
@@ -26,27 +40,39 @@ import (
 // Plugin is the exported symbol that Crush will load
 var Plugin crushsdk.Plugin = &AutoApprovePlugin{}
 
-// AutoApprovePlugin automatically approves read-only tools
+// Settings are read from the plugin's configuration section
+type Settings struct {
+	// Tools are glob patterns of tool names to approve; * matches any
+	// characters and ? a single one
+	Tools []string `json:"tools"`
+
+	// ReadActions approves any tool whose action mentions "read"
+	ReadActions bool `json:"read_actions"`
+}
+
+// defaultSettings approve read-only tools when the plugin isn't configured
+func defaultSettings() Settings {
+	return Settings{
+		Tools:       []string{"view", "glob", "grep", "ls", "fetch"},
+		ReadActions: true,
+	}
+}
+
+// AutoApprovePlugin automatically approves matching tools
 type AutoApprovePlugin struct {
 	*crushsdk.SimplePlugin
-	readOnlyTools map[string]bool
+	patterns    []*regexp.Regexp
+	readActions bool
 }
 
 func init() {
 	plugin := &AutoApprovePlugin{
 		SimplePlugin: crushsdk.NewSimplePlugin(crushsdk.PluginInfo{
 			Name:        "auto-approve",
-			Version:     "1.0.0",
-			Description: "Automatically approves permission requests for read-only tools",
+			Version:     "1.1.0",
+			Description: "Automatically approves permission requests for configured tools",
 			Author:      "Crush Examples",
 		}),
-		readOnlyTools: map[string]bool{
-			"view":   true,
-			"glob":   true,
-			"grep":   true,
-			"ls":     true,
-			"fetch":  true,
-		},
 	}
 
 	// Set up custom hooks
@@ -57,15 +83,72 @@ func init() {
 	Plugin = plugin
 }
 
+func main() {}
+
 func (p *AutoApprovePlugin) Init(ctx context.Context, pluginCtx crushsdk.PluginContext) error {
+	settings := defaultSettings()
+	if err := crushsdk.DecodeSettings(pluginCtx, &settings); err != nil {
+		return err
+	}
+	if err := p.configure(settings); err != nil {
+		return err
+	}
 	slog.Info("Auto-approve plugin initialized",
-		"read_only_tools", len(p.readOnlyTools))
+		"tools", settings.Tools,
+		"read_actions", settings.ReadActions)
 	return p.SimplePlugin.Init(ctx, pluginCtx)
 }
 
+// configure compiles the tool patterns of settings
+func (p *AutoApprovePlugin) configure(settings Settings) error {
+	patterns := make([]*regexp.Regexp, 0, len(settings.Tools))
+	for _, glob := range settings.Tools {
+		pattern, err := compileGlob(glob)
+		if err != nil {
+			return fmt.Errorf("invalid tool pattern %q: %w", glob, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	p.patterns = patterns
+	p.readActions = settings.ReadActions
+	return nil
+}
+
+// matches reports whether toolName matches one of the tool patterns
+func (p *AutoApprovePlugin) matches(toolName string) bool {
+	for _, pattern := range p.patterns {
+		if pattern.MatchString(toolName) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileGlob turns a glob pattern into a regular expression matching whole
+// tool names
+func compileGlob(glob string) (*regexp.Regexp, error) {
+	if glob == "" {
+		return nil, fmt.Errorf("empty pattern")
+	}
+	var expr strings.Builder
+	expr.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.Compile(expr.String())
+}
+
 // autoApprovePermissionHook implements PermissionHook
 type autoApprovePermissionHook struct {
-	plugin *AutoApprovePlugin
+	plugin                     *AutoApprovePlugin
 	crushsdk.NilPermissionHook // Embed to get default implementations
 }
 
@@ -73,16 +156,16 @@ func (h *autoApprovePermissionHook) OnPermissionRequest(
 	ctx context.Context,
 	req permission.CreatePermissionRequest,
 ) (*bool, error) {
-	// Auto-approve read-only tools
-	if h.plugin.readOnlyTools[req.ToolName] {
-		slog.Debug("Auto-approving read-only tool",
+	// Auto-approve tools matching a pattern
+	if h.plugin.matches(req.ToolName) {
+		slog.Debug("Auto-approving matching tool",
 			"tool", req.ToolName,
 			"session", req.SessionID)
 		return crushsdk.Allow(), nil
 	}
 
 	// Auto-approve tools with "read" in their action
-	if strings.Contains(strings.ToLower(req.Action), "read") {
+	if h.plugin.readActions && strings.Contains(strings.ToLower(req.Action), "read") {
 		slog.Debug("Auto-approving read action",
 			"tool", req.ToolName,
 			"action", req.Action)
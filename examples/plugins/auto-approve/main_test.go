@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/pkg/crushsdk"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPlugin(t *testing.T, settings string) *AutoApprovePlugin {
+	t.Helper()
+	p := &AutoApprovePlugin{SimplePlugin: crushsdk.NewSimplePlugin(crushsdk.PluginInfo{Name: "auto-approve"})}
+	require.NoError(t, p.Init(t.Context(), crushsdk.PluginContext{Settings: json.RawMessage(settings)}))
+	return p
+}
+
+func TestAutoApproveGlobs(t *testing.T) {
+	t.Parallel()
+
+	p := newTestPlugin(t, `{"tools": ["view", "read_*", "mcp_github_*", "ls?"]}`)
+	hook := &autoApprovePermissionHook{plugin: p}
+
+	for tool, approved := range map[string]bool{
+		"view":                     true,
+		"read_file":                true,
+		"mcp_github_list_issues":   true,
+		"lsp":                      true,
+		"viewer":                   false,
+		"bash":                     false,
+		"mcp_gitlab_list_issues":   false,
+		"ls":                       false,
+		"unread_file":              false,
+		"mcp_github":               false,
+		"mcp_github_create_issue?": true,
+	} {
+		decision, err := hook.OnPermissionRequest(t.Context(), permission.CreatePermissionRequest{ToolName: tool})
+		require.NoError(t, err)
+		if approved {
+			require.Equal(t, crushsdk.Allow(), decision, tool)
+		} else {
+			require.Nil(t, decision, tool)
+		}
+	}
+}
+
+func TestAutoApproveReadActions(t *testing.T) {
+	t.Parallel()
+
+	req := permission.CreatePermissionRequest{ToolName: "mcp_docs_fetch", Action: "Read"}
+
+	// The read action heuristic can be turned off
+	hook := &autoApprovePermissionHook{plugin: newTestPlugin(t, `{"tools": ["view"], "read_actions": false}`)}
+	decision, err := hook.OnPermissionRequest(t.Context(), req)
+	require.NoError(t, err)
+	require.Nil(t, decision)
+
+	// Without settings read-only tools and read actions are approved
+	hook = &autoApprovePermissionHook{plugin: newTestPlugin(t, "")}
+	decision, err = hook.OnPermissionRequest(t.Context(), req)
+	require.NoError(t, err)
+	require.Equal(t, crushsdk.Allow(), decision)
+	decision, err = hook.OnPermissionRequest(t.Context(), permission.CreatePermissionRequest{ToolName: "grep"})
+	require.NoError(t, err)
+	require.Equal(t, crushsdk.Allow(), decision)
+}
+
+func TestAutoApproveInvalidSettings(t *testing.T) {
+	t.Parallel()
+
+	p := &AutoApprovePlugin{SimplePlugin: crushsdk.NewSimplePlugin(crushsdk.PluginInfo{Name: "auto-approve"})}
+	require.Error(t, p.Init(t.Context(), crushsdk.PluginContext{Settings: json.RawMessage(`{"tools": [""]}`)}))
+	require.Error(t, p.Init(t.Context(), crushsdk.PluginContext{Settings: json.RawMessage(`{"tools": "view"}`)}))
+}
@@ -4,19 +4,46 @@
 // - Subscribing to multiple hook types
 // - Collecting metrics across sessions, messages, and tool executions
 // - Implementing agent lifecycle hooks
+// - Counting how often session context is truncated
+// - Providing a /metrics slash command
+// - Broadcasting snapshots on the "metrics/snapshot" topic
+// - Reading settings from the plugin's configuration section
 //
 // To build this plugin:
-//   go build -buildmode=plugin -o metrics.so main.go
+//
+//	go build -buildmode=plugin -o metrics.so main.go
 //
 // To use this plugin, add to your crush config:
-//   {
-//     "plugins": ["./examples/plugins/metrics/metrics.so"]
-//   }
+//
+//	{
+//	  "plugins": ["./examples/plugins/metrics/metrics.so"]
+//	}
+//
+// Metrics are reported every 5 minutes and when Crush exits. To change
+// that, configure the plugin's settings; an interval of "0" reports on
+// shutdown only:
+//
+//	{
+//	  "options": {
+//	    "plugins": {
+//	      "settings": {
+//	        "metrics": {
+//	          "report_interval": "30s",
+//	          "report_on_shutdown": false
+//	        }
+//	      }
+//	    }
+//	  }
+//	}
 package main
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"log/slog"
+	"maps"
+	"slices"
 	"sync"
 	"time"
 
@@ -28,16 +55,45 @@ import (
 // Plugin is the exported symbol that Crush will load
 var Plugin crushsdk.Plugin = &MetricsPlugin{}
 
+// Settings are read from the plugin's configuration section
+type Settings struct {
+	// ReportInterval is how often metrics are reported, as a Go duration
+	// such as "30s" or "15m". Zero disables periodic reports.
+	ReportInterval string `json:"report_interval"`
+
+	// ReportOnShutdown reports the metrics when Crush exits
+	ReportOnShutdown bool `json:"report_on_shutdown"`
+}
+
+// defaultSettings report every 5 minutes and on shutdown
+func defaultSettings() Settings {
+	return Settings{
+		ReportInterval:   "5m",
+		ReportOnShutdown: true,
+	}
+}
+
 // MetricsPlugin collects and logs metrics about Crush usage
 type MetricsPlugin struct {
 	*crushsdk.SimplePlugin
 	metrics *Metrics
+	topics  crushsdk.TopicBus
+
+	interval         time.Duration
+	reportOnShutdown bool
+	stopReports      context.CancelFunc
 }
 
 // Metrics stores various usage statistics
 type Metrics struct {
 	mu sync.RWMutex
 
+	MetricsSnapshot
+}
+
+// MetricsSnapshot is a consistent copy of the collected metrics that is safe
+// to read without holding any lock
+type MetricsSnapshot struct {
 	// Session metrics
 	SessionsCreated int
 	SessionsActive  map[string]bool
@@ -47,18 +103,37 @@ type Metrics struct {
 	MessagesByRole  map[string]int
 
 	// Tool metrics
-	ToolExecutions  int
-	ToolsByName     map[string]int
-	ToolErrors      int
+	ToolExecutions int
+	ToolsByName    map[string]int
+	ToolErrors     int
+	ToolErrorKinds map[crushsdk.ToolErrorKind]int
 
 	// Agent metrics
-	AgentRuns       int
-	TotalSteps      int
-	AgentErrors     int
+	AgentRuns   int
+	TotalSteps  int
+	Retries     int
+	AgentErrors int
+
+	// Context metrics
+	ContextTruncations int
+	TruncatedTokens    int
 
 	// Timing
-	StartTime       time.Time
-	LastActivity    time.Time
+	StartTime    time.Time
+	LastActivity time.Time
+}
+
+// Snapshot returns a deep copy of the metrics taken under the read lock
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	snapshot := m.MetricsSnapshot
+	snapshot.SessionsActive = maps.Clone(m.SessionsActive)
+	snapshot.MessagesByRole = maps.Clone(m.MessagesByRole)
+	snapshot.ToolsByName = maps.Clone(m.ToolsByName)
+	snapshot.ToolErrorKinds = maps.Clone(m.ToolErrorKinds)
+	return snapshot
 }
 
 func init() {
@@ -69,13 +144,7 @@ func init() {
 			Description: "Collects and logs metrics about Crush usage patterns",
 			Author:      "Crush Examples",
 		}),
-		metrics: &Metrics{
-			SessionsActive: make(map[string]bool),
-			MessagesByRole: make(map[string]int),
-			ToolsByName:    make(map[string]int),
-			StartTime:      time.Now(),
-			LastActivity:   time.Now(),
-		},
+		metrics: newMetrics(),
 	}
 
 	// Set up custom hooks
@@ -89,22 +158,70 @@ func init() {
 	Plugin = plugin
 }
 
-func (p *MetricsPlugin) Init(ctx context.Context, pluginCtx crushsdk.PluginContext) error {
-	slog.Info("Metrics plugin initialized")
+func newMetrics() *Metrics {
+	return &Metrics{
+		MetricsSnapshot: MetricsSnapshot{
+			SessionsActive: make(map[string]bool),
+			MessagesByRole: make(map[string]int),
+			ToolsByName:    make(map[string]int),
+			ToolErrorKinds: make(map[crushsdk.ToolErrorKind]int),
+			StartTime:      time.Now(),
+			LastActivity:   time.Now(),
+		},
+	}
+}
+
+func main() {}
 
-	// Start periodic metrics reporting
-	go p.reportMetricsPeriodically(ctx)
+func (p *MetricsPlugin) Init(ctx context.Context, pluginCtx crushsdk.PluginContext) error {
+	settings := defaultSettings()
+	if err := crushsdk.DecodeSettings(pluginCtx, &settings); err != nil {
+		return err
+	}
+	if err := p.configure(settings); err != nil {
+		return err
+	}
+	slog.Info("Metrics plugin initialized",
+		"report_interval", p.interval,
+		"report_on_shutdown", p.reportOnShutdown)
+	p.topics = pluginCtx.Topics
+
+	// Start periodic metrics reporting, which runs until shutdown
+	if p.interval > 0 {
+		reportCtx, cancel := context.WithCancel(context.Background())
+		p.stopReports = cancel
+		go p.reportMetricsPeriodically(reportCtx)
+	}
 
 	return p.SimplePlugin.Init(ctx, pluginCtx)
 }
 
+// configure applies settings to the plugin
+func (p *MetricsPlugin) configure(settings Settings) error {
+	interval, err := time.ParseDuration(settings.ReportInterval)
+	if err != nil {
+		return fmt.Errorf("invalid report interval %q: %w", settings.ReportInterval, err)
+	}
+	if interval < 0 {
+		return fmt.Errorf("invalid report interval %q: must not be negative", settings.ReportInterval)
+	}
+	p.interval = interval
+	p.reportOnShutdown = settings.ReportOnShutdown
+	return nil
+}
+
 func (p *MetricsPlugin) Shutdown(ctx context.Context) error {
-	p.logMetrics()
+	if p.stopReports != nil {
+		p.stopReports()
+	}
+	if p.reportOnShutdown {
+		p.logMetrics()
+	}
 	return nil
 }
 
 func (p *MetricsPlugin) reportMetricsPeriodically(ctx context.Context) {
-	ticker := time.NewTicker(5 * time.Minute)
+	ticker := time.NewTicker(p.interval)
 	defer ticker.Stop()
 
 	for {
@@ -118,28 +235,67 @@ func (p *MetricsPlugin) reportMetricsPeriodically(ctx context.Context) {
 }
 
 func (p *MetricsPlugin) logMetrics() {
-	p.metrics.mu.RLock()
-	defer p.metrics.mu.RUnlock()
+	metrics := p.metrics.Snapshot()
 
-	uptime := time.Since(p.metrics.StartTime)
-	idleTime := time.Since(p.metrics.LastActivity)
+	// Let other plugins, e.g. one rendering a dashboard, see the snapshot
+	if p.topics != nil {
+		if err := p.topics.Publish("snapshot", metrics); err != nil {
+			slog.Warn("Failed to publish metrics snapshot", "error", err)
+		}
+	}
+
+	uptime := time.Since(metrics.StartTime)
+	idleTime := time.Since(metrics.LastActivity)
 
 	slog.Info("Crush Metrics Report",
 		"uptime", uptime.Round(time.Second),
 		"idle_time", idleTime.Round(time.Second),
-		"sessions_created", p.metrics.SessionsCreated,
-		"active_sessions", len(p.metrics.SessionsActive),
-		"messages_created", p.metrics.MessagesCreated,
-		"tool_executions", p.metrics.ToolExecutions,
-		"tool_errors", p.metrics.ToolErrors,
-		"agent_runs", p.metrics.AgentRuns,
-		"total_agent_steps", p.metrics.TotalSteps,
-		"agent_errors", p.metrics.AgentErrors,
+		"sessions_created", metrics.SessionsCreated,
+		"active_sessions", len(metrics.SessionsActive),
+		"messages_created", metrics.MessagesCreated,
+		"tool_executions", metrics.ToolExecutions,
+		"tool_errors", metrics.ToolErrors,
+		"tool_error_kinds", metrics.ToolErrorKinds,
+		"agent_runs", metrics.AgentRuns,
+		"total_agent_steps", metrics.TotalSteps,
+		"retries", metrics.Retries,
+		"agent_errors", metrics.AgentErrors,
+		"context_truncations", metrics.ContextTruncations,
+		"truncated_tokens", metrics.TruncatedTokens,
 	)
 
-	if len(p.metrics.ToolsByName) > 0 {
-		slog.Info("Top Tools", "tools", p.metrics.ToolsByName)
+	if len(metrics.ToolsByName) > 0 {
+		slog.Info("Top Tools", "tools", metrics.ToolsByName)
+	}
+}
+
+// GetCommands implements crushsdk.CommandProvider
+func (p *MetricsPlugin) GetCommands() []crushsdk.PluginCommand {
+	return []crushsdk.PluginCommand{{
+		Name:        "metrics",
+		Description: "Show the metrics report",
+		Handler:     p.writeReport,
+	}}
+}
+
+// writeReport writes a human-readable metrics report
+func (p *MetricsPlugin) writeReport(ctx context.Context, args []string, out io.Writer) error {
+	metrics := p.metrics.Snapshot()
+
+	fmt.Fprintf(out, "Uptime:           %s\n", time.Since(metrics.StartTime).Round(time.Second))
+	fmt.Fprintf(out, "Sessions created: %d (%d active)\n", metrics.SessionsCreated, len(metrics.SessionsActive))
+	fmt.Fprintf(out, "Messages created: %d\n", metrics.MessagesCreated)
+	fmt.Fprintf(out, "Agent runs:       %d (%d steps, %d retries, %d errors)\n",
+		metrics.AgentRuns, metrics.TotalSteps, metrics.Retries, metrics.AgentErrors)
+	fmt.Fprintf(out, "Truncations:      %d (%d tokens)\n", metrics.ContextTruncations, metrics.TruncatedTokens)
+	fmt.Fprintf(out, "Tool executions:  %d (%d errors)\n", metrics.ToolExecutions, metrics.ToolErrors)
+	for _, kind := range slices.Sorted(maps.Keys(metrics.ToolErrorKinds)) {
+		fmt.Fprintf(out, "  %-16s%d\n", kind+" errors", metrics.ToolErrorKinds[kind])
 	}
+	for _, name := range slices.Sorted(maps.Keys(metrics.ToolsByName)) {
+		fmt.Fprintf(out, "  %-16s%d\n", name, metrics.ToolsByName[name])
+	}
+	return nil
 }
 
 // Session Hook Implementation
@@ -170,6 +326,17 @@ func (h *metricsSessionHook) OnSessionDeleted(ctx context.Context, sessionID str
 	return nil
 }
 
+// OnContextTruncated implements crushsdk.ContextTruncatedHook
+func (h *metricsSessionHook) OnContextTruncated(ctx context.Context, sessionID string, droppedTokens int, droppedMessageIDs []string) error {
+	h.plugin.metrics.mu.Lock()
+	defer h.plugin.metrics.mu.Unlock()
+
+	h.plugin.metrics.ContextTruncations++
+	h.plugin.metrics.TruncatedTokens += droppedTokens
+
+	return nil
+}
+
 // Message Hook Implementation
 
 type metricsMessageHook struct {
@@ -182,7 +349,7 @@ func (h *metricsMessageHook) OnMessageCreated(ctx context.Context, msg message.M
 	defer h.plugin.metrics.mu.Unlock()
 
 	h.plugin.metrics.MessagesCreated++
-	h.plugin.metrics.MessagesByRole[msg.Role]++
+	h.plugin.metrics.MessagesByRole[string(msg.Role)]++
 	h.plugin.metrics.LastActivity = time.Now()
 
 	return nil
@@ -210,6 +377,7 @@ func (h *metricsToolHook) OnToolExecuteAfter(ctx context.Context, input crushsdk
 	if result.Error != nil {
 		h.plugin.metrics.mu.Lock()
 		h.plugin.metrics.ToolErrors++
+		h.plugin.metrics.ToolErrorKinds[crushsdk.ToolErrorKindOf(result.Error)]++
 		h.plugin.metrics.mu.Unlock()
 	}
 	return nil, nil
@@ -242,6 +410,16 @@ func (h *metricsAgentHook) OnAgentStep(ctx context.Context, input crushsdk.Agent
 	return nil
 }
 
+func (h *metricsAgentHook) OnAgentRetry(ctx context.Context, input crushsdk.AgentRetryInput) error {
+	h.plugin.metrics.mu.Lock()
+	defer h.plugin.metrics.mu.Unlock()
+
+	h.plugin.metrics.Retries++
+	h.plugin.metrics.LastActivity = time.Now()
+
+	return nil
+}
+
 func (h *metricsAgentHook) OnAgentFinish(ctx context.Context, input crushsdk.AgentFinishInput) error {
 	if input.Error != nil {
 		h.plugin.metrics.mu.Lock()
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/charmbracelet/crush/pkg/crushsdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsSnapshotConcurrent(t *testing.T) {
+	t.Parallel()
+
+	p := &MetricsPlugin{metrics: newMetrics()}
+	sessions := &metricsSessionHook{plugin: p}
+	messages := &metricsMessageHook{plugin: p}
+	tools := &metricsToolHook{plugin: p}
+
+	const writers, iterations = 8, 200
+	var wg sync.WaitGroup
+	for range writers {
+		wg.Go(func() {
+			for i := range iterations {
+				_ = sessions.OnSessionCreated(t.Context(), session.Session{ID: string(rune('a' + i%26))})
+				_ = messages.OnMessageCreated(t.Context(), message.Message{Role: message.User})
+				_, _ = tools.OnToolExecuteBefore(t.Context(), crushsdk.ToolExecuteInput{ToolName: "view"})
+			}
+		})
+	}
+	for range writers {
+		wg.Go(func() {
+			for range iterations {
+				snapshot := p.metrics.Snapshot()
+				// Mutating the copy must not race with or affect the hooks
+				snapshot.ToolsByName["view"] = -1
+				snapshot.SessionsActive["snapshot"] = true
+				assert.Equal(t, snapshot.MessagesCreated, snapshot.MessagesByRole[string(message.User)])
+			}
+		})
+	}
+	wg.Wait()
+
+	snapshot := p.metrics.Snapshot()
+	require.Equal(t, writers*iterations, snapshot.SessionsCreated)
+	require.Equal(t, writers*iterations, snapshot.ToolsByName["view"])
+	require.NotContains(t, snapshot.SessionsActive, "snapshot")
+}
+
+func TestMetricsSettings(t *testing.T) {
+	t.Parallel()
+
+	initPlugin := func(t *testing.T, settings string) (*MetricsPlugin, error) {
+		p := &MetricsPlugin{
+			SimplePlugin: crushsdk.NewSimplePlugin(crushsdk.PluginInfo{Name: "metrics"}),
+			metrics:      newMetrics(),
+		}
+		pluginCtx := crushsdk.PluginContext{}
+		if settings != "" {
+			pluginCtx.Settings = json.RawMessage(settings)
+		}
+		err := p.Init(t.Context(), pluginCtx)
+		if err == nil {
+			t.Cleanup(func() { _ = p.Shutdown(t.Context()) })
+		}
+		return p, err
+	}
+
+	p, err := initPlugin(t, "")
+	require.NoError(t, err)
+	require.Equal(t, 5*time.Minute, p.interval)
+	require.True(t, p.reportOnShutdown)
+	require.NotNil(t, p.stopReports)
+
+	p, err = initPlugin(t, `{"report_interval": "30s", "report_on_shutdown": false}`)
+	require.NoError(t, err)
+	require.Equal(t, 30*time.Second, p.interval)
+	require.False(t, p.reportOnShutdown)
+
+	// A zero interval only reports on shutdown
+	p, err = initPlugin(t, `{"report_interval": "0"}`)
+	require.NoError(t, err)
+	require.Zero(t, p.interval)
+	require.True(t, p.reportOnShutdown)
+	require.Nil(t, p.stopReports, "no periodic reports must be scheduled")
+
+	_, err = initPlugin(t, `{"report_interval": "soon"}`)
+	require.ErrorContains(t, err, `invalid report interval "soon"`)
+	_, err = initPlugin(t, `{"report_interval": "-1m"}`)
+	require.ErrorContains(t, err, "must not be negative")
+}
@@ -0,0 +1,138 @@
+// Package main provides an orchestrator plugin example for Crush.
+//
+// This plugin demonstrates:
+// - Listing the tools offered to the agent through Services.Tools
+// - Running several other tools, built-in or from plugins, in one step
+// - Handling the reentrancy limits of nested tool calls
+//
+// To build this plugin:
+//
+//	go build -buildmode=plugin -o orchestrator.so main.go
+//
+// To use this plugin, add to your crush config:
+//
+//	{
+//	  "plugins": ["./examples/plugins/orchestrator/orchestrator.so"]
+//	}
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/pkg/crushsdk"
+)
+
+// Plugin is the exported symbol that Crush will load
+var Plugin crushsdk.Plugin = &OrchestratorPlugin{}
+
+// OrchestratorPlugin offers tools that list and run the agent's other tools
+type OrchestratorPlugin struct {
+	*crushsdk.SimplePlugin
+	tools crushsdk.ToolCaller
+}
+
+// step is one tool call of a run_tools pipeline
+type step struct {
+	Tool string          `json:"tool"`
+	Args json.RawMessage `json:"args"`
+}
+
+func init() {
+	plugin := &OrchestratorPlugin{
+		SimplePlugin: crushsdk.NewSimplePlugin(crushsdk.PluginInfo{
+			Name:        "orchestrator",
+			Version:     "1.0.0",
+			Description: "Lists the agent's tools and runs several of them in one step",
+			Author:      "Crush Examples",
+		}),
+	}
+
+	plugin.AddTool(crushsdk.NewSimpleTool(
+		"list_tools",
+		"Lists the tools that run_tools can call, with their descriptions",
+		map[string]any{},
+		nil,
+		plugin.listTools,
+	))
+	plugin.AddTool(crushsdk.NewSimpleTool(
+		"run_tools",
+		"Runs a sequence of tools in order and returns each result. Stops at the first tool that fails.",
+		map[string]any{
+			"steps": map[string]any{
+				"type":        "array",
+				"description": "The tool calls to run, in order",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"tool": map[string]any{"type": "string", "description": "Name of the tool"},
+						"args": map[string]any{"type": "object", "description": "Arguments of the tool"},
+					},
+					"required": []string{"tool"},
+				},
+			},
+		},
+		[]string{"steps"},
+		plugin.runTools,
+	))
+
+	Plugin = plugin
+}
+
+func main() {}
+
+func (p *OrchestratorPlugin) Init(ctx context.Context, pluginCtx crushsdk.PluginContext) error {
+	p.tools = pluginCtx.Services.Tools
+	if p.tools == nil {
+		return errors.New("orchestrator requires Services.Tools")
+	}
+	slog.Info("Orchestrator plugin initialized")
+	return p.SimplePlugin.Init(ctx, pluginCtx)
+}
+
+func (p *OrchestratorPlugin) listTools(ctx context.Context, params fantasy.ToolCall) (fantasy.ToolResponse, error) {
+	infos, err := p.tools.ListTools(ctx)
+	if err != nil {
+		return fantasy.ToolResponse{}, err
+	}
+	var b strings.Builder
+	for _, info := range infos {
+		fmt.Fprintf(&b, "- %s: %s\n", info.Name, info.Description)
+	}
+	return fantasy.NewTextResponse(b.String()), nil
+}
+
+func (p *OrchestratorPlugin) runTools(ctx context.Context, params fantasy.ToolCall) (fantasy.ToolResponse, error) {
+	var input struct {
+		Steps []step `json:"steps"`
+	}
+	if err := json.Unmarshal([]byte(params.Input), &input); err != nil {
+		return fantasy.NewTextErrorResponse(fmt.Sprintf("invalid input: %v", err)), nil
+	}
+	if len(input.Steps) == 0 {
+		return fantasy.NewTextErrorResponse("no steps given"), nil
+	}
+
+	var b strings.Builder
+	for i, step := range input.Steps {
+		resp, err := p.tools.CallTool(ctx, step.Tool, step.Args)
+		if errors.Is(err, crushsdk.ErrToolRecursion) {
+			fmt.Fprintf(&b, "step %d (%s) refused: %v\n", i+1, step.Tool, err)
+			return fantasy.NewTextErrorResponse(b.String()), nil
+		}
+		if err != nil {
+			// Cancellation and denied permissions end the whole run
+			return fantasy.ToolResponse{}, err
+		}
+		fmt.Fprintf(&b, "step %d (%s):\n%s\n", i+1, step.Tool, resp.Content)
+		if resp.IsError {
+			return fantasy.NewTextErrorResponse(b.String()), nil
+		}
+	}
+	return fantasy.NewTextResponse(b.String()), nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/pkg/crushsdk"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTools runs tools by echoing their arguments
+type fakeTools struct {
+	calls []string
+}
+
+func (f *fakeTools) CallTool(ctx context.Context, name string, args json.RawMessage) (fantasy.ToolResponse, error) {
+	f.calls = append(f.calls, name)
+	switch name {
+	case "run_tools":
+		return fantasy.ToolResponse{}, fmt.Errorf("%w: run_tools calls itself", crushsdk.ErrToolRecursion)
+	case "missing":
+		return fantasy.NewTextErrorResponse("unknown tool"), nil
+	}
+	return fantasy.NewTextResponse(name + " " + string(args)), nil
+}
+
+func (f *fakeTools) ListTools(ctx context.Context) ([]fantasy.ToolInfo, error) {
+	return []fantasy.ToolInfo{{Name: "ls", Description: "Lists files"}}, nil
+}
+
+func TestRunTools(t *testing.T) {
+	t.Parallel()
+
+	tools := &fakeTools{}
+	p := &OrchestratorPlugin{tools: tools}
+
+	resp, err := p.listTools(t.Context(), fantasy.ToolCall{})
+	require.NoError(t, err)
+	require.Equal(t, "- ls: Lists files\n", resp.Content)
+
+	resp, err = p.runTools(t.Context(), fantasy.ToolCall{Input: `{"steps": [{"tool": "ls", "args": {"path": "."}}, {"tool": "view"}]}`})
+	require.NoError(t, err)
+	require.False(t, resp.IsError)
+	require.Contains(t, resp.Content, `ls {"path": "."}`)
+	require.Equal(t, []string{"ls", "view"}, tools.calls)
+
+	tools.calls = nil
+	resp, err = p.runTools(t.Context(), fantasy.ToolCall{Input: `{"steps": [{"tool": "missing"}, {"tool": "ls"}]}`})
+	require.NoError(t, err)
+	require.True(t, resp.IsError)
+	require.Equal(t, []string{"missing"}, tools.calls, "later steps must not run after a failure")
+
+	resp, err = p.runTools(t.Context(), fantasy.ToolCall{Input: `{"steps": [{"tool": "run_tools"}]}`})
+	require.NoError(t, err)
+	require.True(t, resp.IsError)
+	require.Contains(t, resp.Content, "refused")
+}
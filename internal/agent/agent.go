@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -24,6 +25,7 @@ import (
 	"github.com/charmbracelet/crush/internal/csync"
 	"github.com/charmbracelet/crush/internal/message"
 	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/plugin"
 	"github.com/charmbracelet/crush/internal/session"
 )
 
@@ -44,6 +46,7 @@ type SessionAgentCall struct {
 	TopK             *int64
 	FrequencyPenalty *float64
 	PresencePenalty  *float64
+	MaxRetries       *int
 }
 
 type SessionAgent interface {
@@ -58,6 +61,7 @@ type SessionAgent interface {
 	ClearQueue(sessionID string)
 	Summarize(context.Context, string, fantasy.ProviderOptions) error
 	Model() Model
+	SmallModel() Model
 }
 
 type Model struct {
@@ -71,14 +75,21 @@ type sessionAgent struct {
 	smallModel           Model
 	systemPromptPrefix   string
 	systemPrompt         string
-	tools                []fantasy.AgentTool
+	tools                []fantasy.AgentTool // guarded by toolsMu
+	toolsMu              sync.RWMutex
 	sessions             session.Service
 	messages             message.Service
 	disableAutoSummarize bool
 	isYolo               bool
+	plugins              *plugin.Registry
+	disabledTools        []string
 
 	messageQueue   *csync.Map[string, []SessionAgentCall]
 	activeRequests *csync.Map[string, context.CancelFunc]
+	abortReasons   *csync.Map[string, string]
+	// truncated holds the IDs of the messages per session already reported
+	// as dropped by before model call hooks
+	truncated *csync.Map[string, map[string]bool]
 }
 
 type SessionAgentOptions struct {
@@ -91,6 +102,10 @@ type SessionAgentOptions struct {
 	Sessions             session.Service
 	Messages             message.Service
 	Tools                []fantasy.AgentTool
+	Plugins              *plugin.Registry
+	// DisabledTools are built-in tools left out by configuration; calls to
+	// them fail with ErrToolDisabled
+	DisabledTools []string
 }
 
 func NewSessionAgent(
@@ -106,8 +121,12 @@ func NewSessionAgent(
 		disableAutoSummarize: opts.DisableAutoSummarize,
 		tools:                opts.Tools,
 		isYolo:               opts.IsYolo,
+		plugins:              opts.Plugins,
+		disabledTools:        opts.DisabledTools,
 		messageQueue:         csync.NewMap[string, []SessionAgentCall](),
 		activeRequests:       csync.NewMap[string, context.CancelFunc](),
+		abortReasons:         csync.NewMap[string, string](),
+		truncated:            csync.NewMap[string, map[string]bool](),
 	}
 }
 
@@ -130,15 +149,23 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 		return nil, nil
 	}
 
-	if len(a.tools) > 0 {
+	agentTools := a.currentTools()
+	offered := make([]string, 0, len(agentTools))
+	for _, tool := range agentTools {
+		offered = append(offered, tool.Info().Name)
+	}
+	if len(agentTools) > 0 {
 		// add anthropic caching to the last tool
-		a.tools[len(a.tools)-1].SetProviderOptions(a.getCacheControlOptions())
+		agentTools[len(agentTools)-1].SetProviderOptions(a.getCacheControlOptions())
 	}
+	// Calls to disabled tools run stubs explaining why they fail; the stubs
+	// are never offered to the model
+	stubs := a.disabledToolStubs(offered)
 
 	agent := fantasy.NewAgent(
 		a.largeModel.Model,
-		fantasy.WithSystemPrompt(a.systemPrompt),
-		fantasy.WithTools(a.tools...),
+		fantasy.WithSystemPrompt(a.runSystemPrompt(ctx, call.SessionID)),
+		fantasy.WithTools(slices.Concat(agentTools, stubs)...),
 	)
 
 	sessionLock := sync.Mutex{}
@@ -151,6 +178,9 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 	if err != nil {
 		return nil, fmt.Errorf("failed to get session messages: %w", err)
 	}
+	// runSession is not changed by title generation, so plugin hooks can
+	// read it without waiting for the title
+	runSession := currentSession
 
 	var wg sync.WaitGroup
 	// Generate title if first message
@@ -171,19 +201,45 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 	// add the session to the context
 	ctx = context.WithValue(ctx, tools.SessionIDContextKey, call.SessionID)
 
+	// let tool hooks show the user different output than the model sees
+	displayOutputs := csync.NewMap[string, string]()
+	ctx = withDisplayOutputs(ctx, displayOutputs)
+
+	// let plugins abort this run, but not later runs of the session that
+	// a context kept from this one would otherwise reach
+	a.abortReasons.Del(call.SessionID)
+	var abortMu sync.Mutex
+	abortable := true
+	endAbort := func() {
+		abortMu.Lock()
+		abortable = false
+		abortMu.Unlock()
+	}
+	ctx = plugin.WithAbort(ctx, func(reason string) bool {
+		abortMu.Lock()
+		defer abortMu.Unlock()
+		return abortable && a.abort(call.SessionID, reason)
+	})
+
 	genCtx, cancel := context.WithCancel(ctx)
 	a.activeRequests.Set(call.SessionID, cancel)
 
 	defer cancel()
 	defer a.activeRequests.Del(call.SessionID)
+	defer endAbort()
 
 	history, files := a.preparePrompt(msgs, call.Attachments...)
 
 	startTime := time.Now()
 	a.eventPromptSent(call.SessionID)
+	a.triggerAgentStart(ctx, call, offered)
 
 	var currentAssistant *message.Message
 	var shouldSummarize bool
+	var stepNumber, retryAttempt int
+	// runUsage and runCost total the steps of this run for plugins
+	var runUsage fantasy.Usage
+	var runCost float64
 	result, err := agent.Stream(genCtx, fantasy.AgentStreamCall{
 		Prompt:           call.Prompt,
 		Files:            files,
@@ -195,9 +251,19 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 		PresencePenalty:  call.PresencePenalty,
 		TopK:             call.TopK,
 		FrequencyPenalty: call.FrequencyPenalty,
+		MaxRetries:       call.MaxRetries,
 		// Before each step create the new assistant message
 		PrepareStep: func(callContext context.Context, options fantasy.PrepareStepFunctionOptions) (_ context.Context, prepared fantasy.PrepareStepResult, err error) {
 			prepared.Messages = options.Messages
+			if len(stubs) > 0 {
+				prepared.ActiveTools = offered
+				prepared.DisableAllTools = len(offered) == 0
+			}
+			retryAttempt = 0
+			prepared.Model = withRetries(options.Model, call.MaxRetries, func(err *fantasy.APICallError, delay time.Duration) {
+				retryAttempt++
+				a.triggerAgentRetry(genCtx, call.SessionID, stepNumber+1, retryAttempt, err, delay)
+			})
 			// reset all cached items
 			for i := range prepared.Messages {
 				prepared.Messages[i].ProviderOptions = nil
@@ -213,6 +279,8 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 				prepared.Messages = append(prepared.Messages, userMessage.ToAIMessage()...)
 			}
 
+			prepared.Messages = a.runBeforeModelCall(callContext, runSession, prepared.Messages)
+
 			lastSystemRoleInx := 0
 			systemMessageUpdated := false
 			for i, msg := range prepared.Messages {
@@ -289,9 +357,6 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 			currentAssistant.AddToolCall(toolCall)
 			return a.messages.Update(genCtx, *currentAssistant)
 		},
-		OnRetry: func(err *fantasy.APICallError, delay time.Duration) {
-			// TODO: implement
-		},
 		OnToolCall: func(tc fantasy.ToolCallContent) error {
 			toolCall := message.ToolCall{
 				ID:               tc.ToolCallID,
@@ -321,12 +386,14 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 			case fantasy.ToolResultContentTypeMedia:
 				// TODO: handle this message type
 			}
+			displayContent, _ := displayOutputs.Take(result.ToolCallID)
 			toolResult := message.ToolResult{
-				ToolCallID: result.ToolCallID,
-				Name:       result.ToolName,
-				Content:    resultContent,
-				IsError:    isError,
-				Metadata:   result.ClientMetadata,
+				ToolCallID:     result.ToolCallID,
+				Name:           result.ToolName,
+				Content:        resultContent,
+				IsError:        isError,
+				Metadata:       result.ClientMetadata,
+				DisplayContent: displayContent,
 			}
 			_, createMsgErr := a.messages.Create(genCtx, currentAssistant.SessionID, message.CreateMessageParams{
 				Role: message.Tool,
@@ -350,13 +417,20 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 				finishReason = message.FinishReasonToolUse
 			}
 			currentAssistant.AddFinish(finishReason, "", "")
-			a.updateSessionUsage(a.largeModel, &currentSession, stepResult.Usage, a.openrouterCost(stepResult.ProviderMetadata))
+			stepNumber++
+			stepCost := a.updateSessionUsage(a.largeModel, &currentSession, stepResult.Usage, a.openrouterCost(stepResult.ProviderMetadata))
+			runUsage = addUsage(runUsage, stepResult.Usage)
+			runCost += stepCost
+			a.triggerAgentStep(genCtx, call.SessionID, stepNumber, stepResult, stepCost)
 			sessionLock.Lock()
 			_, sessionErr := a.sessions.Save(genCtx, currentSession)
 			sessionLock.Unlock()
 			if sessionErr != nil {
 				return sessionErr
 			}
+			if stepResult.FinishReason != fantasy.FinishReasonToolCalls {
+				a.triggerAgentFinalMessage(genCtx, currentAssistant)
+			}
 			return a.messages.Update(genCtx, *currentAssistant)
 		},
 		StopWhen: []fantasy.StopCondition{
@@ -380,10 +454,16 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 	})
 
 	a.eventPromptResponded(call.SessionID, time.Since(startTime).Truncate(time.Second))
+	a.triggerAgentFinish(ctx, call.SessionID, stepNumber, runUsage, runCost, result, err)
+
+	abortReason, isAborted := a.abortReasons.Take(call.SessionID)
 
 	if err != nil {
 		isCancelErr := errors.Is(err, context.Canceled)
 		isPermissionErr := errors.Is(err, permission.ErrorPermissionDenied)
+		if isCancelErr && isAborted {
+			err = fmt.Errorf("%w: %s", ErrRequestAborted, abortReason)
+		}
 		if currentAssistant == nil {
 			return result, err
 		}
@@ -424,7 +504,9 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 				continue
 			}
 			content := "There was an error while executing the tool"
-			if isCancelErr {
+			if isCancelErr && isAborted {
+				content = "Tool execution aborted by plugin: " + abortReason
+			} else if isCancelErr {
 				content = "Tool execution canceled by user"
 			} else if isPermissionErr {
 				content = "Permission denied"
@@ -445,7 +527,9 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 				return nil, createErr
 			}
 		}
-		if isCancelErr {
+		if isCancelErr && isAborted {
+			currentAssistant.AddFinish(message.FinishReasonCanceled, "Request aborted by plugin", abortReason)
+		} else if isCancelErr {
 			currentAssistant.AddFinish(message.FinishReasonCanceled, "Request cancelled", "")
 		} else if isPermissionErr {
 			currentAssistant.AddFinish(message.FinishReasonPermissionDenied, "Permission denied", "")
@@ -479,6 +563,7 @@ func (a *sessionAgent) Run(ctx context.Context, call SessionAgentCall) (*fantasy
 	}
 
 	// release active request before processing queued messages
+	endAbort()
 	a.activeRequests.Del(call.SessionID)
 	cancel()
 
@@ -588,6 +673,8 @@ func (a *sessionAgent) Summarize(ctx context.Context, sessionID string, opts fan
 		}
 	}
 
+	// The context the summary replaces, as last reported by the provider
+	droppedTokens := currentSession.PromptTokens + currentSession.CompletionTokens
 	a.updateSessionUsage(a.largeModel, &currentSession, resp.TotalUsage, openrouterCost)
 
 	// just in case get just the last usage
@@ -596,7 +683,15 @@ func (a *sessionAgent) Summarize(ctx context.Context, sessionID string, opts fan
 	currentSession.CompletionTokens = usage.OutputTokens
 	currentSession.PromptTokens = 0
 	_, err = a.sessions.Save(genCtx, currentSession)
-	return err
+	if err != nil {
+		return err
+	}
+
+	a.triggerSessionCompacted(ctx, sessionID, summaryMessage, msgs)
+	a.triggerContextTruncated(ctx, sessionID, int(droppedTokens), msgs)
+	// The summarized messages are never sent again
+	a.truncated.Del(sessionID)
+	return nil
 }
 
 func (a *sessionAgent) getCacheControlOptions() fantasy.ProviderOptions {
@@ -757,7 +852,8 @@ func (a *sessionAgent) openrouterCost(metadata fantasy.ProviderMetadata) *float6
 	return &opts.Usage.Cost
 }
 
-func (a *sessionAgent) updateSessionUsage(model Model, session *session.Session, usage fantasy.Usage, overrideCost *float64) {
+// updateSessionUsage adds usage to the session, returning the cost it added
+func (a *sessionAgent) updateSessionUsage(model Model, session *session.Session, usage fantasy.Usage, overrideCost *float64) float64 {
 	modelConfig := model.CatwalkCfg
 	cost := modelConfig.CostPer1MInCached/1e6*float64(usage.CacheCreationTokens) +
 		modelConfig.CostPer1MOutCached/1e6*float64(usage.CacheReadTokens) +
@@ -767,13 +863,24 @@ func (a *sessionAgent) updateSessionUsage(model Model, session *session.Session,
 	a.eventTokensUsed(session.ID, model, usage, cost)
 
 	if overrideCost != nil {
-		session.Cost += *overrideCost
-	} else {
-		session.Cost += cost
+		cost = *overrideCost
 	}
+	session.Cost += cost
 
 	session.CompletionTokens = usage.OutputTokens + usage.CacheReadTokens
 	session.PromptTokens = usage.InputTokens + usage.CacheCreationTokens
+	return cost
+}
+
+func addUsage(a, b fantasy.Usage) fantasy.Usage {
+	return fantasy.Usage{
+		InputTokens:         a.InputTokens + b.InputTokens,
+		OutputTokens:        a.OutputTokens + b.OutputTokens,
+		TotalTokens:         a.TotalTokens + b.TotalTokens,
+		ReasoningTokens:     a.ReasoningTokens + b.ReasoningTokens,
+		CacheCreationTokens: a.CacheCreationTokens + b.CacheCreationTokens,
+		CacheReadTokens:     a.CacheReadTokens + b.CacheReadTokens,
+	}
 }
 
 func (a *sessionAgent) Cancel(sessionID string) {
@@ -795,6 +902,224 @@ func (a *sessionAgent) Cancel(sessionID string) {
 	}
 }
 
+// abort cancels the active run for sessionID on behalf of a plugin, recording
+// the reason so it can be surfaced to the user. It reports false, doing
+// nothing, if the session has no active run.
+func (a *sessionAgent) abort(sessionID, reason string) bool {
+	if !a.IsSessionBusy(sessionID) {
+		return false
+	}
+	slog.Info("Request abort initiated by plugin", "session_id", sessionID, "reason", reason)
+	a.abortReasons.Set(sessionID, reason)
+	a.Cancel(sessionID)
+	return true
+}
+
+func (a *sessionAgent) triggerSessionCompacted(ctx context.Context, sessionID string, summary message.Message, dropped []message.Message) {
+	if a.plugins == nil {
+		return
+	}
+	if err := a.plugins.TriggerSessionCompacted(ctx, sessionID, summary, messageIDs(dropped)); err != nil {
+		slog.Error("Plugin session compacted hook failed", "error", err)
+	}
+}
+
+func (a *sessionAgent) triggerContextTruncated(ctx context.Context, sessionID string, droppedTokens int, dropped []message.Message) {
+	if a.plugins == nil || len(dropped) == 0 {
+		return
+	}
+	if err := a.plugins.TriggerContextTruncated(ctx, sessionID, droppedTokens, messageIDs(dropped)); err != nil {
+		slog.Error("Plugin context truncated hook failed", "error", err)
+	}
+}
+
+func messageIDs(msgs []message.Message) []string {
+	ids := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		ids = append(ids, msg.ID)
+	}
+	return ids
+}
+
+// newlyDropped returns the dropped messages not reported for the session
+// before, and remembers them as reported. Hooks keeping a sliding window
+// drop the same messages on every step.
+func (a *sessionAgent) newlyDropped(sessionID string, dropped []message.Message) []message.Message {
+	reported := a.truncated.GetOrSet(sessionID, func() map[string]bool {
+		return map[string]bool{}
+	})
+	var fresh []message.Message
+	for _, msg := range dropped {
+		if !reported[msg.ID] {
+			reported[msg.ID] = true
+			fresh = append(fresh, msg)
+		}
+	}
+	return fresh
+}
+
+// droppedMessages returns the messages of before that are missing from after
+func droppedMessages(before, after []message.Message) []message.Message {
+	kept := make(map[string]bool, len(after))
+	for _, msg := range after {
+		kept[msg.ID] = true
+	}
+	var dropped []message.Message
+	for _, msg := range before {
+		if !kept[msg.ID] {
+			dropped = append(dropped, msg)
+		}
+	}
+	return dropped
+}
+
+// estimateTokens roughly estimates the tokens the messages take up in the
+// context, at about four bytes of content per token
+func estimateTokens(msgs []message.Message) int {
+	var size int
+	for _, msg := range msgs {
+		size += len(msg.Content().Text) + len(msg.ReasoningContent().Thinking)
+		for _, call := range msg.ToolCalls() {
+			size += len(call.Name) + len(call.Input)
+		}
+		for _, result := range msg.ToolResults() {
+			size += len(result.Content)
+		}
+	}
+	return (size + 3) / 4
+}
+
+// runSystemPrompt returns the system prompt for a run, as changed by plugin
+// hooks. If a hook fails, the unchanged prompt is used.
+func (a *sessionAgent) runSystemPrompt(ctx context.Context, sessionID string) string {
+	if a.plugins == nil {
+		return a.systemPrompt
+	}
+	prompt, err := a.plugins.TriggerSystemPrompt(ctx, sessionID, a.systemPrompt)
+	if err != nil {
+		slog.Error("Plugin system prompt hook failed", "error", err)
+		return a.systemPrompt
+	}
+	return prompt
+}
+
+// runBeforeModelCall returns the messages for a provider call, as changed by
+// plugin hooks. The hooks see the session's messages, which include those of
+// earlier steps; the leading system messages are kept. Messages the hooks
+// leave out are reported to context truncated hooks. If no hook is
+// registered or a hook fails, messages are returned unchanged.
+func (a *sessionAgent) runBeforeModelCall(ctx context.Context, sess session.Session, messages []fantasy.Message) []fantasy.Message {
+	if a.plugins == nil || !a.plugins.HasBeforeModelCallHooks() {
+		return messages
+	}
+	msgs, err := a.getSessionMessages(ctx, sess)
+	if err != nil {
+		slog.Error("Failed to get session messages for plugin hooks", "error", err)
+		return messages
+	}
+	modified, err := a.plugins.TriggerBeforeModelCall(ctx, sess.ID, msgs)
+	if err != nil {
+		slog.Error("Plugin before model call hook failed", "error", err)
+		return messages
+	}
+	if dropped := a.newlyDropped(sess.ID, droppedMessages(msgs, modified)); len(dropped) > 0 {
+		a.triggerContextTruncated(ctx, sess.ID, estimateTokens(dropped), dropped)
+	}
+
+	var prepared []fantasy.Message
+	for _, msg := range messages {
+		if msg.Role != fantasy.MessageRoleSystem {
+			break
+		}
+		prepared = append(prepared, msg)
+	}
+	history, _ := a.preparePrompt(modified)
+	return append(prepared, history...)
+}
+
+func (a *sessionAgent) triggerAgentStart(ctx context.Context, call SessionAgentCall, offered []string) {
+	if a.plugins == nil {
+		return
+	}
+	if err := a.plugins.TriggerAgentStart(ctx, plugin.AgentStartInput{
+		SessionID:      call.SessionID,
+		Prompt:         call.Prompt,
+		Model:          a.largeModel.ModelCfg.Model,
+		Provider:       a.largeModel.ModelCfg.Provider,
+		AvailableTools: slices.Clone(offered),
+	}); err != nil {
+		slog.Error("Plugin agent start hook failed", "error", err)
+	}
+}
+
+func (a *sessionAgent) triggerAgentStep(ctx context.Context, sessionID string, stepNumber int, stepResult fantasy.StepResult, cost float64) {
+	if a.plugins == nil {
+		return
+	}
+	toolCalls := stepResult.Content.ToolCalls()
+	toolCallIDs := make([]string, 0, len(toolCalls))
+	for _, tc := range toolCalls {
+		toolCallIDs = append(toolCallIDs, tc.ToolCallID)
+	}
+	if err := a.plugins.TriggerAgentStep(ctx, plugin.AgentStepInput{
+		SessionID:   sessionID,
+		StepNumber:  stepNumber,
+		ToolCalls:   toolCalls,
+		ToolCallIDs: toolCallIDs,
+		Response:    stepResult.Content.Text(),
+		Usage:       stepResult.Usage,
+		Cost:        cost,
+	}); err != nil {
+		slog.Error("Plugin agent step hook failed", "error", err)
+	}
+}
+
+func (a *sessionAgent) triggerAgentRetry(ctx context.Context, sessionID string, stepNumber, attempt int, err error, delay time.Duration) {
+	if a.plugins == nil {
+		return
+	}
+	if hookErr := a.plugins.TriggerAgentRetry(ctx, plugin.AgentRetryInput{
+		SessionID:  sessionID,
+		StepNumber: stepNumber,
+		Attempt:    attempt,
+		Error:      err,
+		Delay:      delay,
+	}); hookErr != nil {
+		slog.Error("Plugin agent retry hook failed", "error", hookErr)
+	}
+}
+
+// triggerAgentFinalMessage lets plugins rewrite the final assistant message
+// of a run in place, before the last step saves it
+func (a *sessionAgent) triggerAgentFinalMessage(ctx context.Context, msg *message.Message) {
+	if a.plugins == nil || msg == nil {
+		return
+	}
+	rewritten, err := a.plugins.TriggerAgentFinalMessage(ctx, msg.SessionID, *msg)
+	if err != nil {
+		slog.Error("Plugin agent final message hook failed", "error", err)
+	}
+	if rewritten != nil {
+		*msg = *rewritten
+	}
+}
+
+func (a *sessionAgent) triggerAgentFinish(ctx context.Context, sessionID string, totalSteps int, usage fantasy.Usage, cost float64, result *fantasy.AgentResult, err error) {
+	if a.plugins == nil {
+		return
+	}
+	if hookErr := a.plugins.TriggerAgentFinish(ctx, plugin.AgentFinishInput{
+		SessionID:  sessionID,
+		TotalSteps: totalSteps,
+		Usage:      usage,
+		Cost:       cost,
+		Result:     result,
+		Error:      err,
+	}); hookErr != nil {
+		slog.Error("Plugin agent finish hook failed", "error", hookErr)
+	}
+}
+
 func (a *sessionAgent) ClearQueue(sessionID string) {
 	if a.QueuedPrompts(sessionID) > 0 {
 		slog.Info("Clearing queued prompts", "session_id", sessionID)
@@ -850,10 +1175,40 @@ func (a *sessionAgent) SetModels(large Model, small Model) {
 	a.smallModel = small
 }
 
+// disabledToolStubs returns a tool failing with ErrToolDisabled for each
+// tool disabled by configuration that isn't offered
+func (a *sessionAgent) disabledToolStubs(offered []string) []fantasy.AgentTool {
+	var stubs []fantasy.AgentTool
+	for _, name := range a.disabledTools {
+		if slices.Contains(offered, name) {
+			continue
+		}
+		stubs = append(stubs, fantasy.NewAgentTool(name, "Disabled by configuration", func(ctx context.Context, input struct{}, call fantasy.ToolCall) (fantasy.ToolResponse, error) {
+			return fantasy.NewTextErrorResponse(fmt.Errorf("%w: %s", ErrToolDisabled, call.Name).Error()), nil
+		}))
+	}
+	return stubs
+}
+
+// SetTools replaces the tools offered to runs that start afterwards. It is
+// safe to call while runs are in progress.
 func (a *sessionAgent) SetTools(tools []fantasy.AgentTool) {
+	a.toolsMu.Lock()
+	defer a.toolsMu.Unlock()
 	a.tools = tools
 }
 
+// currentTools returns the tools offered to a run starting now
+func (a *sessionAgent) currentTools() []fantasy.AgentTool {
+	a.toolsMu.RLock()
+	defer a.toolsMu.RUnlock()
+	return a.tools
+}
+
 func (a *sessionAgent) Model() Model {
 	return a.largeModel
 }
+
+func (a *sessionAgent) SmallModel() Model {
+	return a.smallModel
+}
@@ -0,0 +1,461 @@
+package agent
+
+import (
+	"context"
+	"iter"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/plugin"
+	"github.com/stretchr/testify/require"
+)
+
+// scriptedModel streams one scripted step per call and records the calls.
+// Streams fail with the scripted failures first. Once the script runs out it
+// answers "done".
+type scriptedModel struct {
+	fakeModel
+	mu       sync.Mutex
+	failures []error
+	steps    [][]fantasy.StreamPart
+	calls    []fantasy.Call
+}
+
+func (m *scriptedModel) Stream(ctx context.Context, call fantasy.Call) (fantasy.StreamResponse, error) {
+	m.mu.Lock()
+	m.calls = append(m.calls, call)
+	parts := textStep("done")
+	switch {
+	case len(m.failures) > 0:
+		parts = []fantasy.StreamPart{{Type: fantasy.StreamPartTypeError, Error: m.failures[0]}}
+		m.failures = m.failures[1:]
+	case len(m.steps) > 0:
+		parts, m.steps = m.steps[0], m.steps[1:]
+	}
+	m.mu.Unlock()
+	return fantasy.StreamResponse(iter.Seq[fantasy.StreamPart](func(yield func(fantasy.StreamPart) bool) {
+		for _, part := range parts {
+			if !yield(part) {
+				return
+			}
+		}
+	})), nil
+}
+
+func (m *scriptedModel) recordedCalls() []fantasy.Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.calls
+}
+
+// textStep answers with text and ends the run
+func textStep(text string) []fantasy.StreamPart {
+	return []fantasy.StreamPart{
+		{Type: fantasy.StreamPartTypeTextStart, ID: "0"},
+		{Type: fantasy.StreamPartTypeTextDelta, ID: "0", Delta: text},
+		{Type: fantasy.StreamPartTypeTextEnd, ID: "0"},
+		{Type: fantasy.StreamPartTypeFinish, FinishReason: fantasy.FinishReasonStop, Usage: fantasy.Usage{InputTokens: 10, OutputTokens: 2}},
+	}
+}
+
+// toolCallStep calls a tool and continues the run
+func toolCallStep(id, name, input string) []fantasy.StreamPart {
+	return []fantasy.StreamPart{
+		{Type: fantasy.StreamPartTypeToolInputStart, ID: id, ToolCallName: name},
+		{Type: fantasy.StreamPartTypeToolInputEnd, ID: id},
+		{Type: fantasy.StreamPartTypeToolCall, ID: id, ToolCallName: name, ToolCallInput: input},
+		{Type: fantasy.StreamPartTypeFinish, FinishReason: fantasy.FinishReasonToolCalls, Usage: fantasy.Usage{InputTokens: 10, OutputTokens: 2}},
+	}
+}
+
+// scriptedAgent returns an agent using the scripted model
+func scriptedAgent(env env, model *scriptedModel, registry *plugin.Registry, tools ...fantasy.AgentTool) *sessionAgent {
+	a := testSessionAgent(env, model, fakeModel{}, "You are a test agent", tools...).(*sessionAgent)
+	a.plugins = registry
+	return a
+}
+
+// runScripted runs a prompt in a new session of a and returns the session ID
+func runScripted(t *testing.T, env env, a *sessionAgent) string {
+	t.Helper()
+
+	sess, err := env.sessions.Create(t.Context(), "scripted")
+	require.NoError(t, err)
+	_, err = a.Run(t.Context(), SessionAgentCall{SessionID: sess.ID, Prompt: "hi", MaxOutputTokens: 100})
+	require.NoError(t, err)
+	return sess.ID
+}
+
+// toolResult returns the text of the result of a tool call in a prompt
+func toolResult(prompt fantasy.Prompt, toolCallID string) (string, bool) {
+	for _, msg := range prompt {
+		for _, part := range msg.Content {
+			result, ok := fantasy.AsMessagePart[fantasy.ToolResultPart](part)
+			if !ok || result.ToolCallID != toolCallID {
+				continue
+			}
+			switch output := result.Output.(type) {
+			case fantasy.ToolResultOutputContentText:
+				return output.Text, true
+			case fantasy.ToolResultOutputContentError:
+				return output.Error.Error(), true
+			}
+		}
+	}
+	return "", false
+}
+
+func noopTool(name string) fantasy.AgentTool {
+	return fantasy.NewAgentTool(name, "Does nothing", func(ctx context.Context, input struct{}, call fantasy.ToolCall) (fantasy.ToolResponse, error) {
+		return fantasy.NewTextResponse("ok"), nil
+	})
+}
+
+// finalMessageHook appends a citation to the final message and records the
+// saved messages when the run finishes
+type finalMessageHook struct {
+	plugin.NilAgentHook
+	messages message.Service
+	events   []string
+	finished []message.Message
+}
+
+func (h *finalMessageHook) OnAgentFinalMessage(ctx context.Context, sessionID string, msg message.Message) (*message.Message, error) {
+	h.events = append(h.events, "final message: "+msg.Content().Text)
+	msg.AppendContent(" [1]")
+	return &msg, nil
+}
+
+func (h *finalMessageHook) OnAgentFinish(ctx context.Context, input plugin.AgentFinishInput) error {
+	h.events = append(h.events, "finish")
+	msgs, err := h.messages.List(ctx, input.SessionID)
+	h.finished = msgs
+	return err
+}
+
+func TestAgentFinalMessage(t *testing.T) {
+	env := testEnv(t)
+	registry := plugin.NewRegistry()
+	hook := &finalMessageHook{messages: env.messages}
+	require.NoError(t, registry.LoadPlugin(t.Context(), &agentHookPlugin{
+		assemblePlugin: assemblePlugin{name: "citations"},
+		hook:           hook,
+	}, plugin.PluginContext{}))
+
+	model := &scriptedModel{steps: [][]fantasy.StreamPart{
+		toolCallStep("call-1", "lookup", "{}"),
+		textStep("the answer"),
+	}}
+	sessionID := runScripted(t, env, scriptedAgent(env, model, registry, noopTool("lookup")))
+
+	// Only the final answer is rewritten, before the run finishes
+	require.Equal(t, []string{"final message: the answer", "finish"}, hook.events)
+	require.NotEmpty(t, hook.finished)
+	require.Equal(t, "the answer [1]", hook.finished[len(hook.finished)-1].Content().Text)
+
+	msgs, err := env.messages.List(t.Context(), sessionID)
+	require.NoError(t, err)
+	require.Equal(t, "the answer [1]", msgs[len(msgs)-1].Content().Text)
+}
+
+// windowPlugin sends only the last two messages to the model and records the
+// messages reported as truncated
+type windowPlugin struct {
+	assemblePlugin
+	agentHook   windowHook
+	sessionHook truncationRecorder
+}
+
+func (p *windowPlugin) Hooks() plugin.Hooks {
+	hooks := plugin.NewBaseHooks()
+	hooks.AgentHook = &p.agentHook
+	hooks.SessionHook = &p.sessionHook
+	return hooks
+}
+
+type windowHook struct{ plugin.NilAgentHook }
+
+func (h *windowHook) OnBeforeModelCall(ctx context.Context, sessionID string, messages []message.Message) ([]message.Message, error) {
+	return messages[max(len(messages)-2, 0):], nil
+}
+
+type truncationRecorder struct {
+	plugin.NilSessionHook
+	reports [][]string
+}
+
+func (h *truncationRecorder) OnContextTruncated(ctx context.Context, sessionID string, droppedTokens int, droppedMessageIDs []string) error {
+	h.reports = append(h.reports, droppedMessageIDs)
+	return nil
+}
+
+func TestContextTruncatedReportsNewDrops(t *testing.T) {
+	env := testEnv(t)
+	registry := plugin.NewRegistry()
+	p := &windowPlugin{assemblePlugin: assemblePlugin{name: "window"}}
+	require.NoError(t, registry.LoadPlugin(t.Context(), p, plugin.PluginContext{}))
+
+	model := &scriptedModel{steps: [][]fantasy.StreamPart{
+		toolCallStep("call-1", "lookup", "{}"),
+		toolCallStep("call-2", "lookup", "{}"),
+		toolCallStep("call-3", "lookup", "{}"),
+		textStep("the answer"),
+	}}
+	runScripted(t, env, scriptedAgent(env, model, registry, noopTool("lookup")))
+
+	// Every step drops more messages, and each is reported only once
+	require.Greater(t, len(p.sessionHook.reports), 1)
+	seen := map[string]bool{}
+	for _, ids := range p.sessionHook.reports {
+		require.NotEmpty(t, ids)
+		for _, id := range ids {
+			require.False(t, seen[id], "message %s reported twice", id)
+			seen[id] = true
+		}
+	}
+}
+
+// passThroughHook sends the messages unchanged
+type passThroughHook struct{ plugin.NilAgentHook }
+
+func (passThroughHook) OnBeforeModelCall(ctx context.Context, sessionID string, messages []message.Message) ([]message.Message, error) {
+	return messages, nil
+}
+
+func TestBeforeModelCallKeepsInFlightSteps(t *testing.T) {
+	env := testEnv(t)
+	// run returns the prompts of a run calling two tools, one at a time
+	run := func(registry *plugin.Registry) []fantasy.Prompt {
+		model := &scriptedModel{steps: [][]fantasy.StreamPart{
+			toolCallStep("call-1", "lookup", `{"q":"a"}`),
+			toolCallStep("call-2", "lookup", `{"q":"b"}`),
+			textStep("the answer"),
+		}}
+		runScripted(t, env, scriptedAgent(env, model, registry, noopTool("lookup")))
+		var prompts []fantasy.Prompt
+		for _, call := range model.recordedCalls() {
+			prompt := slices.Clone(call.Prompt)
+			for i := range prompt {
+				prompt[i].ProviderOptions = nil
+			}
+			prompts = append(prompts, prompt)
+		}
+		return prompts
+	}
+
+	registry := plugin.NewRegistry()
+	require.NoError(t, registry.LoadPlugin(t.Context(), &agentHookPlugin{
+		assemblePlugin: assemblePlugin{name: "pass-through"},
+		hook:           passThroughHook{},
+	}, plugin.PluginContext{}))
+
+	want := run(nil)
+	got := run(registry)
+	require.Len(t, got, 3)
+	// The last step sees both tool calls and their results
+	for _, id := range []string{"call-1", "call-2"} {
+		result, ok := toolResult(got[2], id)
+		require.True(t, ok, id)
+		require.Equal(t, "ok", result)
+	}
+	// and every step sees what it would without the hook
+	require.Equal(t, want, got)
+}
+
+func TestPluginAbort(t *testing.T) {
+	env := testEnv(t)
+
+	var kept context.Context
+	var staleAborted bool
+	abortTool := fantasy.NewAgentTool("guard", "Aborts the run", func(ctx context.Context, input struct{}, call fantasy.ToolCall) (fantasy.ToolResponse, error) {
+		kept = ctx
+		require.True(t, plugin.Abort(ctx, "policy violation"))
+		return fantasy.NewTextErrorResponse("aborted"), nil
+	})
+	staleTool := fantasy.NewAgentTool("stale", "Aborts with a kept context", func(ctx context.Context, input struct{}, call fantasy.ToolCall) (fantasy.ToolResponse, error) {
+		staleAborted = plugin.Abort(kept, "late")
+		return fantasy.NewTextResponse("ok"), nil
+	})
+
+	model := &scriptedModel{steps: [][]fantasy.StreamPart{
+		toolCallStep("call-1", "guard", "{}"),
+	}}
+	a := scriptedAgent(env, model, nil, abortTool, staleTool)
+	sess, err := env.sessions.Create(t.Context(), "abort")
+	require.NoError(t, err)
+	_, err = a.Run(t.Context(), SessionAgentCall{SessionID: sess.ID, Prompt: "hi", MaxOutputTokens: 100})
+	require.ErrorIs(t, err, ErrRequestAborted)
+	require.ErrorContains(t, err, "policy violation")
+
+	// A context kept from the aborted run can't abort the next one
+	model.steps = [][]fantasy.StreamPart{
+		toolCallStep("call-2", "stale", "{}"),
+		textStep("done"),
+	}
+	_, err = a.Run(t.Context(), SessionAgentCall{SessionID: sess.ID, Prompt: "again", MaxOutputTokens: 100})
+	require.NoError(t, err)
+	require.False(t, staleAborted)
+	require.False(t, plugin.Abort(kept, "after the run"))
+}
+
+// correlationPlugin records the tool call IDs of steps and tool executions
+type correlationPlugin struct {
+	assemblePlugin
+	agentHook stepRecorder
+	toolHook  executionRecorder
+}
+
+func (p *correlationPlugin) Hooks() plugin.Hooks {
+	hooks := plugin.NewBaseHooks()
+	hooks.AgentHook = &p.agentHook
+	hooks.ToolHook = &p.toolHook
+	return hooks
+}
+
+type stepRecorder struct {
+	plugin.NilAgentHook
+	steps [][]string
+}
+
+func (h *stepRecorder) OnAgentStep(ctx context.Context, input plugin.AgentStepInput) error {
+	h.steps = append(h.steps, input.ToolCallIDs)
+	return nil
+}
+
+type executionRecorder struct {
+	plugin.NilToolHook
+	mu         sync.Mutex
+	executions []string
+}
+
+func (h *executionRecorder) OnToolExecuteBefore(ctx context.Context, input plugin.ToolExecuteInput) (map[string]any, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.executions = append(h.executions, input.ToolCallID)
+	return nil, nil
+}
+
+func TestAgentStepToolCallIDs(t *testing.T) {
+	env := testEnv(t)
+	registry := plugin.NewRegistry()
+	p := &correlationPlugin{assemblePlugin: assemblePlugin{name: "correlation"}}
+	require.NoError(t, registry.LoadPlugin(t.Context(), p, plugin.PluginContext{}))
+
+	twoCalls := slices.Concat(toolCallStep("call-1", "lookup", "{}")[:3], toolCallStep("call-2", "lookup", "{}"))
+	model := &scriptedModel{steps: [][]fantasy.StreamPart{
+		twoCalls,
+		toolCallStep("call-3", "lookup", "{}"),
+		textStep("the answer"),
+	}}
+	tools := withToolHooks([]fantasy.AgentTool{noopTool("lookup")}, registry)
+	runScripted(t, env, scriptedAgent(env, model, registry, tools...))
+
+	// Each step lists the IDs its tool executions are reported with
+	require.Equal(t, [][]string{{"call-1", "call-2"}, {"call-3"}, {}}, p.agentHook.steps)
+	require.ElementsMatch(t, []string{"call-1", "call-2", "call-3"}, p.toolHook.executions)
+}
+
+// retryPlugin records the retries and steps of runs
+type retryPlugin struct {
+	assemblePlugin
+	agentHook retryRecorder
+}
+
+func (p *retryPlugin) Hooks() plugin.Hooks {
+	hooks := plugin.NewBaseHooks()
+	hooks.AgentHook = &p.agentHook
+	return hooks
+}
+
+type retryRecorder struct {
+	plugin.NilAgentHook
+	retries []plugin.AgentRetryInput
+	steps   int
+}
+
+func (h *retryRecorder) OnAgentRetry(ctx context.Context, input plugin.AgentRetryInput) error {
+	h.retries = append(h.retries, input)
+	return nil
+}
+
+func (h *retryRecorder) OnAgentStep(ctx context.Context, input plugin.AgentStepInput) error {
+	h.steps++
+	return nil
+}
+
+func TestAgentRetry(t *testing.T) {
+	env := testEnv(t)
+	registry := plugin.NewRegistry()
+	p := &retryPlugin{assemblePlugin: assemblePlugin{name: "retries"}}
+	require.NoError(t, registry.LoadPlugin(t.Context(), p, plugin.PluginContext{}))
+
+	// overloaded fails retryably, asking to retry after a millisecond
+	overloaded := func() error {
+		return fantasy.NewAPICallError("overloaded", "", "", 529, map[string]string{"retry-after-ms": "1"}, "", nil, true)
+	}
+	first, second := overloaded(), overloaded()
+	model := &scriptedModel{
+		failures: []error{first, second},
+		steps:    [][]fantasy.StreamPart{textStep("the answer")},
+	}
+	sessionID := runScripted(t, env, scriptedAgent(env, model, registry))
+
+	require.Len(t, model.recordedCalls(), 3)
+	require.Len(t, p.agentHook.retries, 2)
+	for i, err := range []error{first, second} {
+		retry := p.agentHook.retries[i]
+		require.Equal(t, sessionID, retry.SessionID)
+		require.Equal(t, 1, retry.StepNumber)
+		require.Equal(t, i+1, retry.Attempt)
+		require.Same(t, err, retry.Error)
+		require.Equal(t, time.Millisecond, retry.Delay)
+	}
+	require.Equal(t, 1, p.agentHook.steps, "retries aren't steps")
+
+	msgs, err := env.messages.List(t.Context(), sessionID)
+	require.NoError(t, err)
+	require.Equal(t, "the answer", msgs[len(msgs)-1].Content().Text)
+}
+
+// modelChangePlugin records the model changes it is notified of
+type modelChangePlugin struct {
+	assemblePlugin
+	agentHook modelChangeRecorder
+}
+
+func (p *modelChangePlugin) Hooks() plugin.Hooks {
+	hooks := plugin.NewBaseHooks()
+	hooks.AgentHook = &p.agentHook
+	return hooks
+}
+
+type modelChangeRecorder struct {
+	plugin.NilAgentHook
+	changes []string
+}
+
+func (h *modelChangeRecorder) OnModelChanged(ctx context.Context, sessionID string, modelType config.SelectedModelType, oldModel, newModel, provider string) error {
+	h.changes = append(h.changes, string(modelType)+": "+oldModel+" -> "+provider+"/"+newModel)
+	return nil
+}
+
+func TestTriggerModelChanged(t *testing.T) {
+	t.Parallel()
+
+	registry := plugin.NewRegistry()
+	p := &modelChangePlugin{assemblePlugin: assemblePlugin{name: "models"}}
+	require.NoError(t, registry.LoadPlugin(t.Context(), p, plugin.PluginContext{}))
+	c := &coordinator{pluginRegistry: registry}
+
+	model := func(provider, id string) Model {
+		return Model{ModelCfg: config.SelectedModel{Provider: provider, Model: id}}
+	}
+	c.triggerModelChanged(t.Context(), "", config.SelectedModelTypeLarge, model("openai", "gpt-4o"), model("openai", "gpt-4o"))
+	c.triggerModelChanged(t.Context(), "", config.SelectedModelTypeSmall, model("openai", "gpt-4o-mini"), model("anthropic", "claude-3-5-haiku"))
+	require.Equal(t, []string{"small: gpt-4o-mini -> anthropic/claude-3-5-haiku"}, p.agentHook.changes)
+}
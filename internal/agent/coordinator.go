@@ -26,6 +26,7 @@ import (
 	"github.com/charmbracelet/crush/internal/message"
 	"github.com/charmbracelet/crush/internal/permission"
 	"github.com/charmbracelet/crush/internal/plugin"
+	"github.com/charmbracelet/crush/internal/pubsub"
 	"github.com/charmbracelet/crush/internal/session"
 	"golang.org/x/sync/errgroup"
 
@@ -51,6 +52,14 @@ type Coordinator interface {
 	QueuedPrompts(sessionID string) int
 	ClearQueue(sessionID string)
 	Summarize(context.Context, string) error
+	// RunTool runs a single tool by name with JSON arguments, without the
+	// model
+	RunTool(ctx context.Context, sessionID, name string, args json.RawMessage) (fantasy.ToolResponse, error)
+	// ToolInfos describes the tools offered to the coder agent
+	ToolInfos(ctx context.Context) ([]fantasy.ToolInfo, error)
+	// RunSubAgent runs the agent on a new child session of the given
+	// session and returns its final response
+	RunSubAgent(ctx context.Context, parentSessionID, prompt string) (string, error)
 	Model() Model
 	UpdateModels(ctx context.Context) error
 }
@@ -66,6 +75,8 @@ type coordinator struct {
 
 	currentAgent SessionAgent
 	agents       map[string]SessionAgent
+	// subAgents maps the sessions of running sub-agents to their parent
+	subAgents *csync.Map[string, string]
 
 	readyWg errgroup.Group
 }
@@ -89,12 +100,14 @@ func NewCoordinator(
 		lspClients:     lspClients,
 		pluginRegistry: pluginRegistry,
 		agents:         make(map[string]SessionAgent),
+		subAgents:      csync.NewMap[string, string](),
 	}
 
 	agentCfg, ok := cfg.Agents[config.AgentCoder]
 	if !ok {
 		return nil, errors.New("coder agent not configured")
 	}
+	logDisabledTools(cfg.DisabledTools())
 
 	// TODO: make this dynamic when we support multiple agents
 	prompt, err := coderPrompt(prompt.WithWorkingDir(c.cfg.WorkingDir()))
@@ -108,9 +121,28 @@ func NewCoordinator(
 	}
 	c.currentAgent = agent
 	c.agents[config.AgentCoder] = agent
+
+	if pluginRegistry != nil {
+		go c.watchPlugins(ctx, c.pluginRegistry.Subscribe(ctx))
+	}
 	return c, nil
 }
 
+// watchPlugins rebuilds the agent's tools whenever a plugin is loaded or
+// unloaded, so the model is only offered tools whose plugin is loaded. The
+// agent configuration is read again for each rebuild, as it may have been
+// reloaded since.
+func (c *coordinator) watchPlugins(ctx context.Context, events <-chan pubsub.Event[plugin.PluginEvent]) {
+	for event := range events {
+		tools, err := c.coderTools(ctx)
+		if err != nil {
+			slog.Error("Failed to rebuild tools after plugin change", "plugin", event.Payload.Info.Name, "error", err)
+			continue
+		}
+		c.currentAgent.SetTools(tools)
+	}
+}
+
 // Run implements Coordinator.
 func (c *coordinator) Run(ctx context.Context, sessionID string, prompt string, attachments ...message.Attachment) (*fantasy.AgentResult, error) {
 	if err := c.readyWg.Wait(); err != nil {
@@ -145,6 +177,7 @@ func (c *coordinator) Run(ctx context.Context, sessionID string, prompt string,
 		TopK:             topK,
 		FrequencyPenalty: freqPenalty,
 		PresencePenalty:  presPenalty,
+		MaxRetries:       model.ModelCfg.MaxRetries,
 	})
 }
 
@@ -310,6 +343,8 @@ func (c *coordinator) buildAgent(ctx context.Context, prompt *prompt.Prompt, age
 		c.sessions,
 		c.messages,
 		nil,
+		c.pluginRegistry,
+		c.cfg.DisabledTools(),
 	})
 	c.readyWg.Go(func() error {
 		tools, err := c.buildTools(ctx, agent)
@@ -394,6 +429,11 @@ func (c *coordinator) buildTools(ctx context.Context, agent config.Agent) ([]fan
 	slices.SortFunc(filteredTools, func(a, b fantasy.AgentTool) int {
 		return strings.Compare(a.Info().Name, b.Info().Name)
 	})
+
+	if c.pluginRegistry != nil {
+		filteredTools = assembleTools(ctx, filteredTools, c.pluginRegistry)
+		filteredTools = withToolHooks(filteredTools, c.pluginRegistry)
+	}
 	return filteredTools, nil
 }
 
@@ -470,6 +510,11 @@ func (c *coordinator) buildAgentModels(ctx context.Context) (Model, Model, error
 		return Model{}, Model{}, err
 	}
 
+	if c.pluginRegistry != nil && c.cfg.Options.Plugins != nil && c.cfg.Options.Plugins.ProviderHooks {
+		largeModel = withProviderHooks(largeModel, c.pluginRegistry, c.providerSecrets(largeProviderCfg))
+		smallModel = withProviderHooks(smallModel, c.pluginRegistry, c.providerSecrets(smallProviderCfg))
+	}
+
 	return Model{
 			Model:      largeModel,
 			CatwalkCfg: *largeCatwalkModel,
@@ -645,6 +690,25 @@ func (c *coordinator) buildGoogleVertexProvider(headers map[string]string, optio
 	return google.New(opts...)
 }
 
+// providerSecrets returns the credentials of a provider that must not appear
+// in provider hook payloads
+func (c *coordinator) providerSecrets(providerCfg config.ProviderConfig) []string {
+	var secrets []string
+	if apiKey, err := c.cfg.Resolve(providerCfg.APIKey); err == nil && apiKey != "" {
+		secrets = append(secrets, apiKey, strings.TrimPrefix(apiKey, "Bearer "))
+	}
+	for key, value := range providerCfg.ExtraHeaders {
+		lowerKey := strings.ToLower(key)
+		if strings.Contains(lowerKey, "authorization") ||
+			strings.Contains(lowerKey, "api-key") ||
+			strings.Contains(lowerKey, "token") ||
+			strings.Contains(lowerKey, "secret") {
+			secrets = append(secrets, value, strings.TrimPrefix(value, "Bearer "))
+		}
+	}
+	return secrets
+}
+
 func (c *coordinator) isAnthropicThinking(model config.SelectedModel) bool {
 	if model.Think {
 		return true
@@ -715,6 +779,7 @@ func isExactoSupported(modelID string) bool {
 
 func (c *coordinator) Cancel(sessionID string) {
 	c.currentAgent.Cancel(sessionID)
+	c.cancelSubAgents(sessionID)
 }
 
 func (c *coordinator) CancelAll() {
@@ -743,8 +808,13 @@ func (c *coordinator) UpdateModels(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	previousLarge, previousSmall := c.currentAgent.Model(), c.currentAgent.SmallModel()
 	c.currentAgent.SetModels(large, small)
 
+	sessionID := tools.GetSessionFromContext(ctx)
+	c.triggerModelChanged(ctx, sessionID, config.SelectedModelTypeLarge, previousLarge, large)
+	c.triggerModelChanged(ctx, sessionID, config.SelectedModelTypeSmall, previousSmall, small)
+
 	agentCfg, ok := c.cfg.Agents[config.AgentCoder]
 	if !ok {
 		return errors.New("coder agent not configured")
@@ -758,6 +828,17 @@ func (c *coordinator) UpdateModels(ctx context.Context) error {
 	return nil
 }
 
+// triggerModelChanged lets plugins know if the model of modelType changed
+func (c *coordinator) triggerModelChanged(ctx context.Context, sessionID string, modelType config.SelectedModelType, previous, current Model) {
+	if c.pluginRegistry == nil ||
+		(previous.ModelCfg.Model == current.ModelCfg.Model && previous.ModelCfg.Provider == current.ModelCfg.Provider) {
+		return
+	}
+	if err := c.pluginRegistry.TriggerModelChanged(ctx, sessionID, modelType, previous.ModelCfg.Model, current.ModelCfg.Model, current.ModelCfg.Provider); err != nil {
+		slog.Error("Plugin model changed hook failed", "model_type", modelType, "error", err)
+	}
+}
+
 func (c *coordinator) QueuedPrompts(sessionID string) int {
 	return c.currentAgent.QueuedPrompts(sessionID)
 }
@@ -7,9 +7,13 @@ import (
 
 var (
 	ErrRequestCancelled = errors.New("request canceled by user")
+	ErrRequestAborted   = errors.New("request aborted by plugin")
 	ErrSessionBusy      = errors.New("session is currently processing another request")
 	ErrEmptyPrompt      = errors.New("prompt is empty")
 	ErrSessionMissing   = errors.New("session id is missing")
+	ErrUnknownTool      = errors.New("unknown tool")
+	ErrToolDisabled     = errors.New("tool is disabled by configuration")
+	ErrSubAgentDepth    = errors.New("sub-agents are nested too deeply")
 )
 
 func isCancelledErr(err error) bool {
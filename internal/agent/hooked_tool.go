@@ -0,0 +1,203 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/agent/tools"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/plugin"
+)
+
+type displayOutputsContextKey string
+
+// displayOutputsKey is the context key of the outputs tool hooks chose to
+// show the user in place of what the model sees, by tool call ID
+const displayOutputsKey displayOutputsContextKey = "tool_display_outputs"
+
+// withDisplayOutputs returns ctx carrying outputs for hooked tools to record
+// display outputs in
+func withDisplayOutputs(ctx context.Context, outputs *csync.Map[string, string]) context.Context {
+	return context.WithValue(ctx, displayOutputsKey, outputs)
+}
+
+// hookedTool runs the plugin tool hooks around a tool. A failing before hook
+// denies the call and its error is returned to the model.
+type hookedTool struct {
+	fantasy.AgentTool
+	registry *plugin.Registry
+}
+
+func withToolHooks(agentTools []fantasy.AgentTool, registry *plugin.Registry) []fantasy.AgentTool {
+	hooked := make([]fantasy.AgentTool, 0, len(agentTools))
+	for _, tool := range agentTools {
+		hooked = append(hooked, &hookedTool{AgentTool: tool, registry: registry})
+	}
+	return hooked
+}
+
+// describedTool overrides a tool's info with one returned by a plugin
+type describedTool struct {
+	fantasy.AgentTool
+	info fantasy.ToolInfo
+}
+
+func (t *describedTool) Info() fantasy.ToolInfo {
+	return t.info
+}
+
+// assembleTools lets plugins filter, reorder, and re-describe tools. Tools
+// are matched by name; if a hook fails the tools are left unchanged.
+func assembleTools(ctx context.Context, agentTools []fantasy.AgentTool, registry *plugin.Registry) []fantasy.AgentTool {
+	byName := make(map[string]fantasy.AgentTool, len(agentTools))
+	infos := make([]fantasy.ToolInfo, 0, len(agentTools))
+	for _, tool := range agentTools {
+		info := tool.Info()
+		byName[info.Name] = tool
+		infos = append(infos, info)
+	}
+
+	assembled, err := registry.TriggerToolsAssemble(ctx, infos)
+	if err != nil {
+		slog.Error("Plugin tools assemble hook failed", "error", err)
+		return agentTools
+	}
+
+	result := make([]fantasy.AgentTool, 0, len(assembled))
+	for _, info := range assembled {
+		tool, ok := byName[info.Name]
+		if !ok {
+			slog.Warn("Plugin returned unknown tool, ignoring", "tool", info.Name)
+			continue
+		}
+		// Guard against a hook listing the same tool twice
+		delete(byName, info.Name)
+		if !reflect.DeepEqual(info, tool.Info()) {
+			tool = &describedTool{AgentTool: tool, info: info}
+		}
+		result = append(result, tool)
+	}
+	return result
+}
+
+func (t *hookedTool) Run(ctx context.Context, call fantasy.ToolCall) (fantasy.ToolResponse, error) {
+	// Skip decoding and re-encoding the input when no plugin would see it
+	if !t.registry.HasToolHooks() {
+		return t.AgentTool.Run(ctx, call)
+	}
+
+	input := plugin.ToolExecuteInput{
+		ToolName:   call.Name,
+		SessionID:  tools.GetSessionFromContext(ctx),
+		MessageID:  tools.GetMessageFromContext(ctx),
+		ToolCallID: call.ID,
+	}
+	if call.Input != "" {
+		input.RawArguments = json.RawMessage(call.Input)
+		if err := json.Unmarshal([]byte(call.Input), &input.Arguments); err != nil {
+			slog.Debug("Could not decode tool input for plugin hooks", "tool", call.Name, "error", err)
+		}
+	}
+
+	input, err := t.registry.TriggerToolExecuteBefore(ctx, input)
+	if err != nil {
+		return fantasy.NewTextErrorResponse(err.Error()), nil
+	}
+	call.Input = string(input.RawArguments)
+
+	start := time.Now()
+	resp, runErr := t.AgentTool.Run(ctx, call)
+
+	metadata := resultMetadata(resp, runErr, time.Since(start))
+	kind := toolErrorKind(resp, runErr, metadata)
+	if kind != "" {
+		metadata[plugin.MetadataErrorKind] = string(kind)
+	}
+	// Hooks only see the tool's own errors; error responses are part of the
+	// output the model sees
+	var toolErr error
+	if runErr != nil {
+		toolErr = &plugin.ToolError{Kind: kind, Err: runErr}
+	}
+	result, err := t.registry.TriggerToolExecuteAfter(ctx, input, plugin.ToolExecuteResult{
+		Output:   resp.Content,
+		Error:    toolErr,
+		Metadata: metadata,
+	})
+	if err != nil {
+		slog.Error("Plugin tool execute after hook failed", "tool", call.Name, "error", err)
+		return resp, runErr
+	}
+	resp.Content = result.Output
+	if outputs, ok := ctx.Value(displayOutputsKey).(*csync.Map[string, string]); ok && result.DisplayOutput != "" {
+		outputs.Set(call.ID, result.DisplayOutput)
+	}
+	// The classification is only for hooks. A hook may replace or clear an
+	// error the tool returned, but can't fail a call that succeeded.
+	if runErr == nil || result.Error == toolErr {
+		return resp, runErr
+	}
+	return resp, result.Error
+}
+
+// invalidArgsPrefixes start the error responses of tools that could not
+// decode their input
+var invalidArgsPrefixes = []string{
+	"invalid parameters:",       // fantasy.NewAgentTool
+	"error parsing parameters:", // MCP tools
+}
+
+// toolErrorKind classifies a failed tool execution, whether the tool
+// returned an error or an error response. It returns "" if the tool
+// succeeded.
+func toolErrorKind(resp fantasy.ToolResponse, runErr error, metadata map[string]any) plugin.ToolErrorKind {
+	if runErr != nil {
+		switch {
+		case errors.Is(runErr, permission.ErrorPermissionDenied):
+			return plugin.ToolErrorPermission
+		case errors.Is(runErr, context.Canceled):
+			return plugin.ToolErrorCancelled
+		case errors.Is(runErr, context.DeadlineExceeded):
+			return plugin.ToolErrorTimeout
+		}
+		return plugin.ToolErrorExecution
+	}
+	if !resp.IsError {
+		return ""
+	}
+
+	toolMetadata, _ := metadata[plugin.MetadataTool].(map[string]any)
+	if declared, ok := toolMetadata[plugin.MetadataErrorKind].(string); ok && declared != "" {
+		return plugin.ToolErrorKind(declared)
+	}
+	if slices.ContainsFunc(invalidArgsPrefixes, func(prefix string) bool {
+		return strings.HasPrefix(resp.Content, prefix)
+	}) {
+		return plugin.ToolErrorInvalidArgs
+	}
+	return plugin.ToolErrorExecution
+}
+
+// resultMetadata builds the standard metadata passed to tool after hooks
+func resultMetadata(resp fantasy.ToolResponse, runErr error, duration time.Duration) map[string]any {
+	metadata := map[string]any{
+		plugin.MetadataDurationMS: duration.Milliseconds(),
+		plugin.MetadataBytesOut:   len(resp.Content),
+		plugin.MetadataIsError:    resp.IsError || runErr != nil,
+	}
+	if resp.Metadata != "" {
+		var toolMetadata map[string]any
+		if err := json.Unmarshal([]byte(resp.Metadata), &toolMetadata); err == nil {
+			metadata[plugin.MetadataTool] = toolMetadata
+		}
+	}
+	return metadata
+}
@@ -0,0 +1,320 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/plugin"
+	"github.com/stretchr/testify/require"
+)
+
+type assemblePlugin struct {
+	name     string
+	assemble func([]fantasy.ToolInfo) []fantasy.ToolInfo
+}
+
+func (p *assemblePlugin) Info() plugin.PluginInfo                          { return plugin.PluginInfo{Name: p.name} }
+func (p *assemblePlugin) Init(context.Context, plugin.PluginContext) error { return nil }
+func (p *assemblePlugin) Shutdown(context.Context) error                   { return nil }
+
+func (p *assemblePlugin) Hooks() plugin.Hooks {
+	hooks := plugin.NewBaseHooks()
+	hooks.ToolHook = &assembleHook{assemble: p.assemble}
+	return hooks
+}
+
+type assembleHook struct {
+	plugin.NilToolHook
+	assemble func([]fantasy.ToolInfo) []fantasy.ToolInfo
+}
+
+func (h *assembleHook) OnToolsAssemble(ctx context.Context, tools []fantasy.ToolInfo) ([]fantasy.ToolInfo, error) {
+	return h.assemble(tools), nil
+}
+
+// startRecorder records the input of the runs that start
+type startRecorder struct {
+	plugin.NilAgentHook
+	inputs []plugin.AgentStartInput
+}
+
+func (h *startRecorder) OnAgentStart(ctx context.Context, input plugin.AgentStartInput) error {
+	h.inputs = append(h.inputs, input)
+	return nil
+}
+
+type agentHookPlugin struct {
+	assemblePlugin
+	hook plugin.AgentHook
+}
+
+func (p *agentHookPlugin) Hooks() plugin.Hooks {
+	hooks := plugin.NewBaseHooks()
+	hooks.AgentHook = p.hook
+	return hooks
+}
+
+func TestAgentStartAvailableTools(t *testing.T) {
+	env := testEnv(t)
+	noop := func(ctx context.Context, input struct{}, call fantasy.ToolCall) (fantasy.ToolResponse, error) {
+		return fantasy.NewTextResponse("ok"), nil
+	}
+	registry := plugin.NewRegistry()
+	require.NoError(t, registry.LoadPlugin(t.Context(), &assemblePlugin{
+		name: "hide-bash",
+		assemble: func(tools []fantasy.ToolInfo) []fantasy.ToolInfo {
+			return slices.DeleteFunc(tools, func(info fantasy.ToolInfo) bool { return info.Name == "bash" })
+		},
+	}, plugin.PluginContext{}))
+	recorder := &startRecorder{}
+	require.NoError(t, registry.LoadPlugin(t.Context(), &agentHookPlugin{
+		assemblePlugin: assemblePlugin{name: "governance"},
+		hook:           recorder,
+	}, plugin.PluginContext{}))
+
+	model := &scriptedModel{}
+	a := scriptedAgent(env, model, registry)
+	a.SetTools(assembleTools(t.Context(), []fantasy.AgentTool{
+		fantasy.NewAgentTool("bash", "Runs commands", noop),
+		fantasy.NewAgentTool("skill_review", "A plugin tool", noop),
+		fantasy.NewAgentTool("view", "Views files", noop),
+	}, registry))
+	sessionID := runScripted(t, env, a)
+
+	require.Len(t, recorder.inputs, 1)
+	require.Equal(t, sessionID, recorder.inputs[0].SessionID)
+	require.Equal(t, []string{"skill_review", "view"}, recorder.inputs[0].AvailableTools)
+
+	// The hook sees the tools offered to the model
+	var offered []string
+	for _, tool := range model.recordedCalls()[0].Tools {
+		offered = append(offered, tool.GetName())
+	}
+	require.Equal(t, recorder.inputs[0].AvailableTools, offered)
+}
+
+func TestDroppedMessages(t *testing.T) {
+	t.Parallel()
+
+	text := func(id, content string) message.Message {
+		return message.Message{ID: id, Parts: []message.ContentPart{message.TextContent{Text: content}}}
+	}
+	before := []message.Message{text("m1", "first question"), text("m2", "a long answer"), text("m3", "follow-up")}
+
+	require.Empty(t, droppedMessages(before, before))
+	dropped := droppedMessages(before, []message.Message{before[2]})
+	require.Equal(t, []string{"m1", "m2"}, messageIDs(dropped))
+	require.Equal(t, 7, estimateTokens(dropped), "27 bytes of content")
+}
+
+func TestAssembleTools(t *testing.T) {
+	t.Parallel()
+
+	noop := func(ctx context.Context, input struct{}, call fantasy.ToolCall) (fantasy.ToolResponse, error) {
+		return fantasy.NewTextResponse("ok"), nil
+	}
+	agentTools := []fantasy.AgentTool{
+		fantasy.NewAgentTool("bash", "Runs commands", noop),
+		fantasy.NewAgentTool("grep", "Searches files", noop),
+		fantasy.NewAgentTool("view", "Views files", noop),
+	}
+
+	registry := plugin.NewRegistry()
+	// The first plugin hides bash and puts view first
+	require.NoError(t, registry.LoadPlugin(t.Context(), &assemblePlugin{
+		name: "hide-bash",
+		assemble: func(tools []fantasy.ToolInfo) []fantasy.ToolInfo {
+			tools = slices.DeleteFunc(tools, func(info fantasy.ToolInfo) bool { return info.Name == "bash" })
+			slices.Reverse(tools)
+			return tools
+		},
+	}, plugin.PluginContext{}))
+	// The second plugin sees the first plugin's result and re-describes view
+	require.NoError(t, registry.LoadPlugin(t.Context(), &assemblePlugin{
+		name: "describe-view",
+		assemble: func(tools []fantasy.ToolInfo) []fantasy.ToolInfo {
+			require.Len(t, tools, 2)
+			tools[0].Description = "Views project files"
+			return append(tools, fantasy.ToolInfo{Name: "unknown"})
+		},
+	}, plugin.PluginContext{}))
+
+	assembled := assembleTools(t.Context(), agentTools, registry)
+	require.Len(t, assembled, 2)
+	require.Equal(t, "view", assembled[0].Info().Name)
+	require.Equal(t, "Views project files", assembled[0].Info().Description)
+	require.Equal(t, "grep", assembled[1].Info().Name)
+	require.Same(t, agentTools[1], assembled[1], "unchanged tools must not be wrapped")
+
+	resp, err := assembled[0].Run(t.Context(), fantasy.ToolCall{Input: "{}"})
+	require.NoError(t, err)
+	require.Equal(t, "ok", resp.Content)
+}
+
+// summarizingPlugin gives the model a summary of tool output and the user the
+// full output
+type summarizingPlugin struct {
+	assemblePlugin
+}
+
+func (p *summarizingPlugin) Hooks() plugin.Hooks {
+	hooks := plugin.NewBaseHooks()
+	hooks.ToolHook = &summarizingHook{}
+	return hooks
+}
+
+type summarizingHook struct {
+	plugin.NilToolHook
+}
+
+func (h *summarizingHook) OnToolExecuteAfter(ctx context.Context, input plugin.ToolExecuteInput, result plugin.ToolExecuteResult) (*plugin.ToolExecuteResult, error) {
+	result.DisplayOutput = result.Output
+	result.Output = "3 files"
+	return &result, nil
+}
+
+func TestHookedToolDisplayOutput(t *testing.T) {
+	t.Parallel()
+
+	ls := func(ctx context.Context, input struct{}, call fantasy.ToolCall) (fantasy.ToolResponse, error) {
+		return fantasy.NewTextResponse("a.go\nb.go\nc.go"), nil
+	}
+	registry := plugin.NewRegistry()
+	require.NoError(t, registry.LoadPlugin(t.Context(), &summarizingPlugin{assemblePlugin{name: "summarize"}}, plugin.PluginContext{}))
+	tool := withToolHooks([]fantasy.AgentTool{fantasy.NewAgentTool("ls", "Lists files", ls)}, registry)[0]
+
+	outputs := csync.NewMap[string, string]()
+	resp, err := tool.Run(withDisplayOutputs(t.Context(), outputs), fantasy.ToolCall{ID: "call-1", Name: "ls", Input: "{}"})
+	require.NoError(t, err)
+	require.Equal(t, "3 files", resp.Content)
+	display, ok := outputs.Get("call-1")
+	require.True(t, ok)
+	require.Equal(t, "a.go\nb.go\nc.go", display)
+}
+
+type baseHooksPlugin struct{}
+
+func (p *baseHooksPlugin) Info() plugin.PluginInfo                          { return plugin.PluginInfo{Name: "base"} }
+func (p *baseHooksPlugin) Init(context.Context, plugin.PluginContext) error { return nil }
+func (p *baseHooksPlugin) Shutdown(context.Context) error                   { return nil }
+func (p *baseHooksPlugin) Hooks() plugin.Hooks                              { return plugin.NewBaseHooks() }
+
+func BenchmarkHookedToolWithoutHooks(b *testing.B) {
+	noop := func(ctx context.Context, input struct {
+		Path string `json:"path"`
+	}, call fantasy.ToolCall,
+	) (fantasy.ToolResponse, error) {
+		return fantasy.NewTextResponse("ok"), nil
+	}
+	// Plugins built on BaseHooks register no-op hooks for everything
+	registry := plugin.NewRegistry()
+	require.NoError(b, registry.LoadPlugin(b.Context(), &baseHooksPlugin{}, plugin.PluginContext{}))
+	tool := withToolHooks([]fantasy.AgentTool{fantasy.NewAgentTool("view", "Views files", noop)}, registry)[0]
+	call := fantasy.ToolCall{ID: "call-1", Name: "view", Input: `{"path":"main.go"}`}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := tool.Run(b.Context(), call); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestToolErrorKind(t *testing.T) {
+	t.Parallel()
+
+	timedOut := fantasy.WithResponseMetadata(fantasy.NewTextErrorResponse("tool fetch timed out after 1s"), map[string]any{plugin.MetadataErrorKind: plugin.ToolErrorTimeout})
+	tests := []struct {
+		name   string
+		resp   fantasy.ToolResponse
+		runErr error
+		kind   plugin.ToolErrorKind
+	}{
+		{"success", fantasy.NewTextResponse("ok"), nil, ""},
+		{"permission", fantasy.ToolResponse{}, fmt.Errorf("edit: %w", permission.ErrorPermissionDenied), plugin.ToolErrorPermission},
+		{"cancelled", fantasy.ToolResponse{}, context.Canceled, plugin.ToolErrorCancelled},
+		{"deadline", fantasy.ToolResponse{}, context.DeadlineExceeded, plugin.ToolErrorTimeout},
+		{"crash", fantasy.ToolResponse{}, errors.New("boom"), plugin.ToolErrorExecution},
+		{"invalid args", fantasy.NewTextErrorResponse("invalid parameters: unexpected end of JSON input"), nil, plugin.ToolErrorInvalidArgs},
+		{"declared by tool", timedOut, nil, plugin.ToolErrorTimeout},
+		{"error response", fantasy.NewTextErrorResponse("file not found"), nil, plugin.ToolErrorExecution},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tt.kind, toolErrorKind(tt.resp, tt.runErr, resultMetadata(tt.resp, tt.runErr, 0)))
+		})
+	}
+}
+
+// kindRecordingHook records the error after hooks see and the error kind
+// in the metadata, optionally replacing the error
+type kindRecordingHook struct {
+	plugin.NilToolHook
+	err     error
+	kind    any
+	replace error
+}
+
+func (h *kindRecordingHook) OnToolExecuteAfter(ctx context.Context, input plugin.ToolExecuteInput, result plugin.ToolExecuteResult) (*plugin.ToolExecuteResult, error) {
+	h.err = result.Error
+	h.kind = result.Metadata[plugin.MetadataErrorKind]
+	if h.replace != nil {
+		result.Error = h.replace
+	}
+	return &result, nil
+}
+
+type kindRecordingPlugin struct {
+	assemblePlugin
+	hook *kindRecordingHook
+}
+
+func (p *kindRecordingPlugin) Hooks() plugin.Hooks {
+	hooks := plugin.NewBaseHooks()
+	hooks.ToolHook = p.hook
+	return hooks
+}
+
+func TestHookedToolErrorKind(t *testing.T) {
+	t.Parallel()
+
+	edit := func(ctx context.Context, input struct{}, call fantasy.ToolCall) (fantasy.ToolResponse, error) {
+		return fantasy.ToolResponse{}, permission.ErrorPermissionDenied
+	}
+	hook := &kindRecordingHook{}
+	registry := plugin.NewRegistry()
+	require.NoError(t, registry.LoadPlugin(t.Context(), &kindRecordingPlugin{assemblePlugin{name: "kinds"}, hook}, plugin.PluginContext{}))
+	tools := withToolHooks([]fantasy.AgentTool{fantasy.NewAgentTool("edit", "Edits files", edit)}, registry)
+
+	// The tool's own error is returned, not the classification
+	_, err := tools[0].Run(t.Context(), fantasy.ToolCall{ID: "call-1", Name: "edit", Input: "{}"})
+	require.Equal(t, permission.ErrorPermissionDenied, err)
+	require.Equal(t, plugin.ToolErrorPermission, plugin.ToolErrorKindOf(hook.err))
+	require.ErrorIs(t, hook.err, permission.ErrorPermissionDenied)
+	require.Equal(t, string(plugin.ToolErrorPermission), hook.kind)
+
+	// Error responses are output, not errors, and don't fail the run
+	resp, err := tools[0].Run(t.Context(), fantasy.ToolCall{ID: "call-2", Name: "edit", Input: "{"})
+	require.NoError(t, err)
+	require.True(t, resp.IsError)
+	require.NoError(t, hook.err)
+	require.Equal(t, string(plugin.ToolErrorInvalidArgs), hook.kind)
+
+	// Hooks can replace the tool's error, but can't turn an error response
+	// into a failure
+	hook.replace = errors.New("replaced")
+	_, err = tools[0].Run(t.Context(), fantasy.ToolCall{ID: "call-3", Name: "edit", Input: "{}"})
+	require.EqualError(t, err, "replaced")
+	resp, err = tools[0].Run(t.Context(), fantasy.ToolCall{ID: "call-4", Name: "edit", Input: "{"})
+	require.NoError(t, err)
+	require.True(t, resp.IsError)
+}
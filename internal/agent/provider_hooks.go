@@ -0,0 +1,133 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/agent/tools"
+	"github.com/charmbracelet/crush/internal/plugin"
+	"github.com/google/uuid"
+)
+
+// redacted replaces credentials in provider hook payloads
+const redacted = "[REDACTED]"
+
+// hookedModel runs the plugin provider hooks around each call to a language
+// model. Hook errors are logged and never affect the call.
+type hookedModel struct {
+	fantasy.LanguageModel
+	registry *plugin.Registry
+	secrets  []string // credentials to redact from payloads
+}
+
+func withProviderHooks(model fantasy.LanguageModel, registry *plugin.Registry, secrets []string) fantasy.LanguageModel {
+	return &hookedModel{LanguageModel: model, registry: registry, secrets: secrets}
+}
+
+func (m *hookedModel) Generate(ctx context.Context, call fantasy.Call) (*fantasy.Response, error) {
+	if !m.registry.HasProviderHooks() {
+		return m.LanguageModel.Generate(ctx, call)
+	}
+
+	req := m.request(ctx, call, false)
+	start := time.Now()
+	resp, err := m.LanguageModel.Generate(ctx, call)
+	result := m.response(req, start, err)
+	if resp != nil {
+		result.Usage = resp.Usage
+		result.FinishReason = resp.FinishReason
+		result.Payload = m.payload(resp)
+	}
+	m.finish(ctx, result)
+	return resp, err
+}
+
+func (m *hookedModel) Stream(ctx context.Context, call fantasy.Call) (fantasy.StreamResponse, error) {
+	if !m.registry.HasProviderHooks() {
+		return m.LanguageModel.Stream(ctx, call)
+	}
+
+	req := m.request(ctx, call, true)
+	start := time.Now()
+	stream, err := m.LanguageModel.Stream(ctx, call)
+	if err != nil {
+		m.finish(ctx, m.response(req, start, err))
+		return stream, err
+	}
+
+	return func(yield func(fantasy.StreamPart) bool) {
+		var parts []fantasy.StreamPart
+		result := m.response(req, start, nil)
+		defer func() {
+			result.Latency = time.Since(start)
+			result.Payload = m.payload(parts)
+			m.finish(ctx, result)
+		}()
+
+		for part := range stream {
+			parts = append(parts, part)
+			switch part.Type {
+			case fantasy.StreamPartTypeFinish:
+				result.Usage = part.Usage
+				result.FinishReason = part.FinishReason
+			case fantasy.StreamPartTypeError:
+				result.Error = part.Error
+			}
+			if !yield(part) {
+				return
+			}
+		}
+	}, nil
+}
+
+// request builds the provider request for call and runs the request hooks
+func (m *hookedModel) request(ctx context.Context, call fantasy.Call, stream bool) plugin.ProviderRequest {
+	req := plugin.ProviderRequest{
+		ID:        uuid.NewString(),
+		SessionID: tools.GetSessionFromContext(ctx),
+		Provider:  m.Provider(),
+		Model:     m.Model(),
+		Stream:    stream,
+		Payload:   m.payload(call),
+	}
+	if err := m.registry.TriggerProviderRequest(ctx, req); err != nil {
+		slog.Error("Plugin provider request hook failed", "error", err)
+	}
+	return req
+}
+
+func (m *hookedModel) response(req plugin.ProviderRequest, start time.Time, err error) plugin.ProviderResponse {
+	return plugin.ProviderResponse{
+		ID:        req.ID,
+		SessionID: req.SessionID,
+		Provider:  req.Provider,
+		Model:     req.Model,
+		Latency:   time.Since(start),
+		Error:     err,
+	}
+}
+
+func (m *hookedModel) finish(ctx context.Context, resp plugin.ProviderResponse) {
+	if err := m.registry.TriggerProviderResponse(ctx, resp); err != nil {
+		slog.Error("Plugin provider response hook failed", "error", err)
+	}
+}
+
+// payload serializes v as JSON with the model's credentials redacted
+func (m *hookedModel) payload(v any) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		slog.Warn("Failed to serialize provider hook payload", "error", err)
+		return nil
+	}
+	for _, secret := range m.secrets {
+		if secret != "" {
+			data = bytes.ReplaceAll(data, []byte(secret), []byte(redacted))
+		}
+	}
+	return data
+}
@@ -0,0 +1,113 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/agent/tools"
+	"github.com/charmbracelet/crush/internal/plugin"
+	"github.com/stretchr/testify/require"
+)
+
+type providerPlugin struct {
+	hook *recordingProviderHook
+}
+
+func (p *providerPlugin) Info() plugin.PluginInfo                          { return plugin.PluginInfo{Name: "provider"} }
+func (p *providerPlugin) Init(context.Context, plugin.PluginContext) error { return nil }
+func (p *providerPlugin) Shutdown(context.Context) error                   { return nil }
+
+func (p *providerPlugin) Hooks() plugin.Hooks {
+	hooks := plugin.NewBaseHooks()
+	hooks.ProviderHook = p.hook
+	return hooks
+}
+
+type recordingProviderHook struct {
+	requests  []plugin.ProviderRequest
+	responses []plugin.ProviderResponse
+}
+
+func (h *recordingProviderHook) OnProviderRequest(ctx context.Context, req plugin.ProviderRequest) error {
+	h.requests = append(h.requests, req)
+	return errors.New("hook errors must not affect the call")
+}
+
+func (h *recordingProviderHook) OnProviderResponse(ctx context.Context, resp plugin.ProviderResponse) error {
+	h.responses = append(h.responses, resp)
+	return nil
+}
+
+type fakeModel struct{}
+
+func (fakeModel) Provider() string { return "fake" }
+func (fakeModel) Model() string    { return "fake-model" }
+
+func (fakeModel) Generate(ctx context.Context, call fantasy.Call) (*fantasy.Response, error) {
+	return &fantasy.Response{
+		Content:      fantasy.ResponseContent{fantasy.TextContent{Text: "hi"}},
+		FinishReason: fantasy.FinishReasonStop,
+		Usage:        fantasy.Usage{InputTokens: 10, OutputTokens: 2},
+	}, nil
+}
+
+func (fakeModel) Stream(ctx context.Context, call fantasy.Call) (fantasy.StreamResponse, error) {
+	return func(yield func(fantasy.StreamPart) bool) {
+		parts := []fantasy.StreamPart{
+			{Type: fantasy.StreamPartTypeTextDelta, ID: "0", Delta: "hi"},
+			{Type: fantasy.StreamPartTypeFinish, FinishReason: fantasy.FinishReasonStop, Usage: fantasy.Usage{InputTokens: 10, OutputTokens: 2}},
+		}
+		for _, part := range parts {
+			if !yield(part) {
+				return
+			}
+		}
+	}, nil
+}
+
+func TestProviderHooks(t *testing.T) {
+	t.Parallel()
+
+	registry := plugin.NewRegistry()
+	model := withProviderHooks(fakeModel{}, registry, []string{"sk-secret"})
+	call := fantasy.Call{Prompt: fantasy.Prompt{fantasy.NewUserMessage("my key is sk-secret")}}
+
+	// Without provider hooks nothing is recorded
+	_, err := model.Generate(t.Context(), call)
+	require.NoError(t, err)
+
+	hook := &recordingProviderHook{}
+	require.NoError(t, registry.LoadPlugin(t.Context(), &providerPlugin{hook: hook}, plugin.PluginContext{}))
+
+	ctx := context.WithValue(t.Context(), tools.SessionIDContextKey, "session")
+	resp, err := model.Generate(ctx, call)
+	require.NoError(t, err)
+	require.Equal(t, "hi", resp.Content.Text())
+
+	stream, err := model.Stream(ctx, call)
+	require.NoError(t, err)
+	var text string
+	for part := range stream {
+		text += part.Delta
+	}
+	require.Equal(t, "hi", text)
+
+	require.Len(t, hook.requests, 2)
+	require.Len(t, hook.responses, 2)
+	for i, req := range hook.requests {
+		require.Equal(t, "session", req.SessionID)
+		require.Equal(t, "fake-model", req.Model)
+		require.Equal(t, i == 1, req.Stream)
+		require.Contains(t, string(req.Payload), "my key is [REDACTED]")
+		require.NotContains(t, string(req.Payload), "sk-secret")
+
+		resp := hook.responses[i]
+		require.Equal(t, req.ID, resp.ID)
+		require.Equal(t, fantasy.FinishReasonStop, resp.FinishReason)
+		require.Equal(t, int64(10), resp.Usage.InputTokens)
+		require.NoError(t, resp.Error)
+		require.NotEmpty(t, resp.Payload)
+	}
+}
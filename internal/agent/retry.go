@@ -0,0 +1,82 @@
+package agent
+
+import (
+	"context"
+	"iter"
+	"sync"
+
+	"charm.land/fantasy"
+)
+
+// retryingModel retries streams that fail with a retryable provider error
+// before producing any output. fantasy only retries generated responses, so
+// streamed steps would otherwise fail on the first transient error.
+type retryingModel struct {
+	fantasy.LanguageModel
+	options fantasy.RetryOptions
+}
+
+// withRetries wraps model to retry streams up to maxRetries times, or
+// fantasy's default when nil. Zero disables retries.
+func withRetries(model fantasy.LanguageModel, maxRetries *int, onRetry fantasy.OnRetryCallback) fantasy.LanguageModel {
+	options := fantasy.DefaultRetryOptions()
+	if maxRetries != nil {
+		options.MaxRetries = *maxRetries
+	}
+	if options.MaxRetries <= 0 {
+		return model
+	}
+	options.OnRetry = onRetry
+	return &retryingModel{LanguageModel: model, options: options}
+}
+
+func (m *retryingModel) Stream(ctx context.Context, call fantasy.Call) (fantasy.StreamResponse, error) {
+	retry := fantasy.RetryWithExponentialBackoffRespectingRetryHeaders[fantasy.StreamResponse](m.options)
+	return retry(ctx, func() (fantasy.StreamResponse, error) {
+		return m.startStream(ctx, call)
+	})
+}
+
+// startStream starts a stream and reads its first part. It returns the error
+// of a stream failing before anything else, so that it can be retried.
+func (m *retryingModel) startStream(ctx context.Context, call fantasy.Call) (fantasy.StreamResponse, error) {
+	stream, err := m.LanguageModel.Stream(ctx, call)
+	if err != nil {
+		return nil, err
+	}
+
+	// next and stop must not run concurrently. The stream is also released
+	// when ctx ends, in case the response is never consumed.
+	var mu sync.Mutex
+	next, stop := iter.Pull(iter.Seq[fantasy.StreamPart](stream))
+	pull := func() (fantasy.StreamPart, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		return next()
+	}
+	release := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		stop()
+	}
+	stopAfter := context.AfterFunc(ctx, release)
+	done := func() {
+		stopAfter()
+		release()
+	}
+
+	first, ok := pull()
+	if ok && first.Type == fantasy.StreamPartTypeError {
+		done()
+		return nil, first.Error
+	}
+
+	return func(yield func(fantasy.StreamPart) bool) {
+		defer done()
+		for part := first; ok; part, ok = pull() {
+			if !yield(part) {
+				return
+			}
+		}
+	}, nil
+}
@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"charm.land/fantasy"
+	"github.com/stretchr/testify/require"
+)
+
+// endlessModel streams text until the consumer stops, recording when the
+// stream is released
+type endlessModel struct {
+	fakeModel
+	released atomic.Bool
+}
+
+func (m *endlessModel) Stream(ctx context.Context, call fantasy.Call) (fantasy.StreamResponse, error) {
+	return func(yield func(fantasy.StreamPart) bool) {
+		defer m.released.Store(true)
+		for yield(fantasy.StreamPart{Type: fantasy.StreamPartTypeTextDelta, ID: "0", Delta: "."}) {
+		}
+	}, nil
+}
+
+func TestWithRetries(t *testing.T) {
+	t.Parallel()
+
+	t.Run("disabled", func(t *testing.T) {
+		t.Parallel()
+
+		model := &endlessModel{}
+		none := 0
+		require.Same(t, model, withRetries(model, &none, nil))
+		require.IsType(t, &retryingModel{}, withRetries(model, nil, nil), "retries default to fantasy's")
+	})
+
+	t.Run("unconsumed stream is released", func(t *testing.T) {
+		t.Parallel()
+
+		model := &endlessModel{}
+		ctx, cancel := context.WithCancel(t.Context())
+		_, err := withRetries(model, nil, nil).Stream(ctx, fantasy.Call{})
+		require.NoError(t, err)
+		require.False(t, model.released.Load())
+
+		cancel()
+		require.Eventually(t, model.released.Load, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("consumed stream is released", func(t *testing.T) {
+		t.Parallel()
+
+		model := &endlessModel{}
+		stream, err := withRetries(model, nil, nil).Stream(t.Context(), fantasy.Call{})
+		require.NoError(t, err)
+		for range stream {
+			break
+		}
+		require.True(t, model.released.Load())
+	})
+}
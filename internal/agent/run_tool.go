@@ -0,0 +1,98 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/agent/tools"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/google/uuid"
+)
+
+// RunTool implements Coordinator. The tool is looked up among the tools the
+// coder agent would be offered, so plugin tool hooks run around it and it
+// requests permissions as usual.
+func (c *coordinator) RunTool(ctx context.Context, sessionID, name string, args json.RawMessage) (fantasy.ToolResponse, error) {
+	if sessionID == "" {
+		return fantasy.ToolResponse{}, ErrSessionMissing
+	}
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+	if !json.Valid(args) {
+		return fantasy.ToolResponse{}, fmt.Errorf("invalid arguments for tool %s: not valid JSON", name)
+	}
+	agentTools, err := c.coderTools(ctx)
+	if err != nil {
+		return fantasy.ToolResponse{}, err
+	}
+	i := slices.IndexFunc(agentTools, func(tool fantasy.AgentTool) bool {
+		return tool.Info().Name == name
+	})
+	if i < 0 && slices.Contains(c.cfg.DisabledTools(), name) {
+		return fantasy.ToolResponse{}, fmt.Errorf("%w: %s", ErrToolDisabled, name)
+	}
+	if i < 0 {
+		names := make([]string, 0, len(agentTools))
+		for _, tool := range agentTools {
+			names = append(names, tool.Info().Name)
+		}
+		return fantasy.ToolResponse{}, fmt.Errorf("%w: %q; available tools: %s", ErrUnknownTool, name, strings.Join(names, ", "))
+	}
+
+	ctx = context.WithValue(ctx, tools.SessionIDContextKey, sessionID)
+	return agentTools[i].Run(ctx, fantasy.ToolCall{
+		ID:    "tool-" + uuid.NewString(),
+		Name:  name,
+		Input: string(args),
+	})
+}
+
+// logDisabledTools logs the built-in tools disabled by configuration, warning
+// about names that aren't built-in tools
+func logDisabledTools(disabled []string) {
+	if len(disabled) == 0 {
+		return
+	}
+	slog.Info("Built-in tools disabled by configuration", "tools", disabled)
+	for _, name := range disabled {
+		if !config.IsBuiltinTool(name) {
+			slog.Warn("Ignoring unknown tool in disabled tools", "tool", name)
+		}
+	}
+}
+
+// ToolInfos implements Coordinator
+func (c *coordinator) ToolInfos(ctx context.Context) ([]fantasy.ToolInfo, error) {
+	agentTools, err := c.coderTools(ctx)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]fantasy.ToolInfo, 0, len(agentTools))
+	for _, tool := range agentTools {
+		infos = append(infos, tool.Info())
+	}
+	return infos, nil
+}
+
+// coderTools builds the tools the coder agent would be offered
+func (c *coordinator) coderTools(ctx context.Context) ([]fantasy.AgentTool, error) {
+	if err := c.readyWg.Wait(); err != nil {
+		return nil, err
+	}
+	agentCfg, ok := c.cfg.Agents[config.AgentCoder]
+	if !ok {
+		return nil, errors.New("coder agent not configured")
+	}
+	agentTools, err := c.buildTools(ctx, agentCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build tools: %w", err)
+	}
+	return agentTools, nil
+}
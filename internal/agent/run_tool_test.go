@@ -0,0 +1,166 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/plugin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunTool(t *testing.T) {
+	env := testEnv(t)
+	require.NoError(t, os.WriteFile(filepath.Join(env.workingDir, "notes.txt"), nil, 0o644))
+	cfg, err := config.Init(env.workingDir, "", false)
+	require.NoError(t, err)
+
+	c := &coordinator{
+		cfg:            cfg,
+		sessions:       env.sessions,
+		messages:       env.messages,
+		permissions:    env.permissions,
+		history:        env.history,
+		lspClients:     env.lspClients,
+		pluginRegistry: plugin.NewRegistry(),
+	}
+
+	resp, err := c.RunTool(t.Context(), "session-1", "ls", []byte(`{"path": "`+env.workingDir+`"}`))
+	require.NoError(t, err)
+	require.False(t, resp.IsError)
+	require.Contains(t, resp.Content, "notes.txt")
+
+	_, err = c.RunTool(t.Context(), "session-1", "nope", nil)
+	require.ErrorIs(t, err, ErrUnknownTool)
+	require.ErrorContains(t, err, `"nope"; available tools: `)
+
+	_, err = c.RunTool(t.Context(), "session-1", "ls", []byte(`{"path":`))
+	require.ErrorContains(t, err, "not valid JSON")
+
+	_, err = c.RunTool(t.Context(), "", "ls", nil)
+	require.ErrorIs(t, err, ErrSessionMissing)
+
+	// Disabled tools aren't offered, and calling them fails clearly
+	cfg.Tools.Disabled = []string{"fetch"}
+	cfg.SetupAgents()
+	infos, err := c.ToolInfos(t.Context())
+	require.NoError(t, err)
+	require.NotContains(t, toolNames(infos), "fetch")
+	require.Contains(t, toolNames(infos), "ls")
+
+	_, err = c.RunTool(t.Context(), "session-1", "fetch", []byte(`{"url": "https://example.com"}`))
+	require.ErrorIs(t, err, ErrToolDisabled)
+}
+
+// deployTool is a plugin tool
+type deployTool struct{}
+
+func (deployTool) Info() fantasy.ToolInfo { return fantasy.ToolInfo{Name: "deploy"} }
+
+func (deployTool) Run(ctx context.Context, params fantasy.ToolCall) (fantasy.ToolResponse, error) {
+	return fantasy.NewTextResponse("deployed"), nil
+}
+
+type toolPlugin struct {
+	assemblePlugin
+}
+
+func (p *toolPlugin) Hooks() plugin.Hooks { return plugin.NewBaseHooks() }
+
+func (p *toolPlugin) GetTools() []plugin.PluginTool { return []plugin.PluginTool{deployTool{}} }
+
+func TestWatchPluginsRebuildsTools(t *testing.T) {
+	env := testEnv(t)
+	cfg, err := config.Init(env.workingDir, "", false)
+	require.NoError(t, err)
+
+	registry := plugin.NewRegistry()
+	a := &sessionAgent{}
+	c := &coordinator{
+		cfg:            cfg,
+		sessions:       env.sessions,
+		messages:       env.messages,
+		permissions:    env.permissions,
+		history:        env.history,
+		lspClients:     env.lspClients,
+		pluginRegistry: registry,
+		currentAgent:   a,
+	}
+	go c.watchPlugins(t.Context(), registry.Subscribe(t.Context()))
+	offered := func(name string) func() bool {
+		return func() bool {
+			return slices.ContainsFunc(a.currentTools(), func(tool fantasy.AgentTool) bool {
+				return tool.Info().Name == name
+			})
+		}
+	}
+
+	require.NoError(t, registry.LoadPlugin(t.Context(), &toolPlugin{assemblePlugin{name: "deployer"}}, plugin.PluginContext{}))
+	require.Eventually(t, offered("deploy"), 5*time.Second, 10*time.Millisecond)
+	require.True(t, offered("fetch")())
+
+	// Rebuilds use the configuration as it is then, e.g. after a reload
+	cfg.Tools.Disabled = []string{"fetch"}
+	cfg.SetupAgents()
+	require.NoError(t, registry.UnloadPlugin(t.Context(), "deployer"))
+	require.Eventually(t, func() bool { return !offered("deploy")() && !offered("fetch")() }, 5*time.Second, 10*time.Millisecond)
+	require.True(t, offered("ls")())
+}
+
+func TestDisabledToolCalls(t *testing.T) {
+	env := testEnv(t)
+	model := &scriptedModel{steps: [][]fantasy.StreamPart{
+		toolCallStep("call-1", "bash", "{}"),
+		toolCallStep("call-2", "fetch", "{}"),
+		toolCallStep("call-3", "view", "{}"),
+	}}
+	a := scriptedAgent(env, model, nil, noopTool("fetch"))
+	a.disabledTools = []string{"bash", "fetch"}
+	sessionID := runScripted(t, env, a)
+
+	calls := model.recordedCalls()
+	require.Len(t, calls, 4)
+	for _, call := range calls {
+		var offered []string
+		for _, tool := range call.Tools {
+			offered = append(offered, tool.GetName())
+		}
+		require.Equal(t, []string{"fetch"}, offered, "disabled tools must not be offered")
+	}
+
+	// The model learns why the disabled tool failed
+	result, ok := toolResult(calls[1].Prompt, "call-1")
+	require.True(t, ok)
+	require.Equal(t, "tool is disabled by configuration: bash", result)
+	// An offered tool of the same name runs as usual
+	result, ok = toolResult(calls[2].Prompt, "call-2")
+	require.True(t, ok)
+	require.Equal(t, "ok", result)
+	// Other unknown tools fail as before
+	result, ok = toolResult(calls[3].Prompt, "call-3")
+	require.True(t, ok)
+	require.Contains(t, result, "tool not found: view")
+
+	msgs, err := env.messages.List(t.Context(), sessionID)
+	require.NoError(t, err)
+	var saved []string
+	for _, msg := range msgs {
+		for _, tr := range msg.ToolResults() {
+			saved = append(saved, tr.Content)
+		}
+	}
+	require.Equal(t, "tool is disabled by configuration: bash", saved[0])
+}
+
+func toolNames(infos []fantasy.ToolInfo) []string {
+	names := make([]string, 0, len(infos))
+	for _, info := range infos {
+		names = append(names, info.Name)
+	}
+	return names
+}
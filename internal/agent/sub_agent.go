@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// DefaultMaxSubAgentDepth is the default number of levels of child sessions
+// RunSubAgent may create below a top-level session
+const DefaultMaxSubAgentDepth = 3
+
+// RunSubAgent implements Coordinator. It creates a child session of
+// parentSessionID, runs the coder agent on it with prompt, and returns the
+// final response. The child's cost is added to the parent session, and it
+// auto-approves the permissions the parent does. The run is canceled along
+// with ctx and whenever the parent session is canceled.
+func (c *coordinator) RunSubAgent(ctx context.Context, parentSessionID, prompt string) (string, error) {
+	if parentSessionID == "" {
+		return "", ErrSessionMissing
+	}
+	if strings.TrimSpace(prompt) == "" {
+		return "", ErrEmptyPrompt
+	}
+	if err := c.checkSubAgentDepth(ctx, parentSessionID); err != nil {
+		return "", err
+	}
+
+	child, err := c.sessions.CreateTaskSession(ctx, "sub-agent-"+uuid.NewString(), parentSessionID, "Sub-agent Session")
+	if err != nil {
+		return "", fmt.Errorf("failed to create child session: %w", err)
+	}
+	c.subAgents.Set(child.ID, parentSessionID)
+	// Nobody may be around to answer prompts the parent doesn't need
+	c.permissions.InheritSession(child.ID, parentSessionID)
+	defer c.subAgents.Del(child.ID)
+
+	result, err := c.Run(ctx, child.ID, prompt)
+	if err != nil {
+		return "", fmt.Errorf("sub-agent run failed: %w", err)
+	}
+
+	child, err = c.sessions.Get(ctx, child.ID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get child session: %w", err)
+	}
+	parent, err := c.sessions.Get(ctx, parentSessionID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get parent session: %w", err)
+	}
+	parent.Cost += child.Cost
+	if _, err := c.sessions.Save(ctx, parent); err != nil {
+		return "", fmt.Errorf("failed to save parent session: %w", err)
+	}
+	return result.Response.Content.Text(), nil
+}
+
+// checkSubAgentDepth fails with ErrSubAgentDepth if a child of the session
+// would be nested deeper than allowed. The depth is counted along the
+// session's ancestors, so it also holds when plugins don't pass on the
+// context of the run that called them.
+func (c *coordinator) checkSubAgentDepth(ctx context.Context, sessionID string) error {
+	maxDepth := DefaultMaxSubAgentDepth
+	if opts := c.cfg.Options; opts != nil && opts.Plugins != nil && opts.Plugins.MaxSubAgentDepth > 0 {
+		maxDepth = opts.Plugins.MaxSubAgentDepth
+	}
+	for depth := 1; sessionID != ""; depth++ {
+		if depth > maxDepth {
+			return fmt.Errorf("%w: the limit is %d", ErrSubAgentDepth, maxDepth)
+		}
+		sess, err := c.sessions.Get(ctx, sessionID)
+		if err != nil {
+			return fmt.Errorf("failed to get session %s: %w", sessionID, err)
+		}
+		sessionID = sess.ParentSessionID
+	}
+	return nil
+}
+
+// cancelSubAgents cancels the runs of the session's sub-agents and of their
+// own sub-agents
+func (c *coordinator) cancelSubAgents(sessionID string) {
+	for child, parent := range c.subAgents.Seq2() {
+		if parent == sessionID {
+			c.Cancel(child)
+		}
+	}
+}
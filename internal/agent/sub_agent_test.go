@@ -0,0 +1,39 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunSubAgentLimits(t *testing.T) {
+	env := testEnv(t)
+	cfg, err := config.Init(env.workingDir, "", false)
+	require.NoError(t, err)
+	cfg.Options.Plugins = &config.PluginOptions{MaxSubAgentDepth: 2}
+
+	c := &coordinator{
+		cfg:       cfg,
+		sessions:  env.sessions,
+		subAgents: csync.NewMap[string, string](),
+	}
+
+	root, err := env.sessions.Create(t.Context(), "root")
+	require.NoError(t, err)
+	child, err := env.sessions.CreateTaskSession(t.Context(), "call-1", root.ID, "child")
+	require.NoError(t, err)
+	grandchild, err := env.sessions.CreateTaskSession(t.Context(), "call-2", child.ID, "grandchild")
+	require.NoError(t, err)
+
+	require.NoError(t, c.checkSubAgentDepth(t.Context(), root.ID))
+	require.NoError(t, c.checkSubAgentDepth(t.Context(), child.ID))
+	_, err = c.RunSubAgent(t.Context(), grandchild.ID, "summarize the diff")
+	require.ErrorIs(t, err, ErrSubAgentDepth)
+
+	_, err = c.RunSubAgent(t.Context(), "", "summarize the diff")
+	require.ErrorIs(t, err, ErrSessionMissing)
+	_, err = c.RunSubAgent(t.Context(), root.ID, "  ")
+	require.ErrorIs(t, err, ErrEmptyPrompt)
+}
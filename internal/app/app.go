@@ -1,11 +1,19 @@
 package app
 
 import (
+	"bytes"
+	"cmp"
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -13,20 +21,25 @@ import (
 	tea "github.com/charmbracelet/bubbletea/v2"
 	"github.com/charmbracelet/crush/internal/agent"
 	"github.com/charmbracelet/crush/internal/agent/tools"
+	"github.com/charmbracelet/crush/internal/audit"
+	"github.com/charmbracelet/crush/internal/budget"
 	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/csync"
 	"github.com/charmbracelet/crush/internal/db"
 	"github.com/charmbracelet/crush/internal/format"
 	"github.com/charmbracelet/crush/internal/history"
+	"github.com/charmbracelet/crush/internal/injector"
 	"github.com/charmbracelet/crush/internal/log"
 	"github.com/charmbracelet/crush/internal/lsp"
 	"github.com/charmbracelet/crush/internal/message"
 	"github.com/charmbracelet/crush/internal/permission"
 	"github.com/charmbracelet/crush/internal/plugin"
+	"github.com/charmbracelet/crush/internal/policy"
 	"github.com/charmbracelet/crush/internal/pubsub"
 	"github.com/charmbracelet/crush/internal/session"
 	"github.com/charmbracelet/crush/internal/skills"
 	"github.com/charmbracelet/x/ansi"
+	"github.com/charmbracelet/x/term"
 )
 
 type App struct {
@@ -40,7 +53,10 @@ type App struct {
 	LSPClients     *csync.Map[string, *lsp.Client]
 	PluginRegistry *plugin.Registry
 
-	config *config.Config
+	skillDiagnostics []skills.Diagnostic
+
+	config  *config.Config
+	queries db.Querier
 
 	serviceEventsWG *sync.WaitGroup
 	eventsCtx       context.Context
@@ -60,21 +76,35 @@ func New(ctx context.Context, conn *sql.DB, cfg *config.Config) (*App, error) {
 	files := history.NewService(q, conn)
 	skipPermissionsRequests := cfg.Permissions != nil && cfg.Permissions.SkipRequests
 	allowedTools := []string{}
-	if cfg.Permissions != nil && cfg.Permissions.AllowedTools != nil {
-		allowedTools = cfg.Permissions.AllowedTools
+	var permissionOpts []permission.Option
+	if cfg.Permissions != nil {
+		if cfg.Permissions.AllowedTools != nil {
+			allowedTools = cfg.Permissions.AllowedTools
+		}
+		if cfg.Permissions.PromptTimeout > 0 {
+			permissionOpts = append(permissionOpts, permission.WithPromptTimeout(
+				time.Duration(cfg.Permissions.PromptTimeout)*time.Second,
+				cfg.Permissions.PromptTimeoutDecision == "allow",
+			))
+		}
 	}
 
+	pluginRegistry := plugin.NewRegistry()
+	pluginRegistry.SetStorage(q)
+	permissions := permission.NewPermissionService(cfg.WorkingDir(), skipPermissionsRequests, allowedTools, permissionOpts...)
+
 	app := &App{
 		Sessions:       sessions,
 		Messages:       messages,
 		History:        files,
-		Permissions:    permission.NewPermissionService(cfg.WorkingDir(), skipPermissionsRequests, allowedTools),
+		Permissions:    plugin.WithPermissionHooks(permissions, pluginRegistry),
 		LSPClients:     csync.NewMap[string, *lsp.Client](),
-		PluginRegistry: plugin.NewRegistry(),
+		PluginRegistry: pluginRegistry,
 
 		globalCtx: ctx,
 
-		config: cfg,
+		config:  cfg,
+		queries: q,
 
 		events:          make(chan tea.Msg, 100),
 		serviceEventsWG: &sync.WaitGroup{},
@@ -88,8 +118,19 @@ func New(ctx context.Context, conn *sql.DB, cfg *config.Config) (*App, error) {
 
 	// Initialize plugins
 	if err := app.initPlugins(ctx); err != nil {
+		// A config hook failure means the configuration can't be trusted,
+		// required plugins and skills asked to fail fast on duplicate names
+		// must not be skipped, and an invalid permission policy must not
+		// fail open
+		if errors.Is(err, plugin.ErrConfigHookFailed) || errors.Is(err, plugin.ErrInvalidConfig) ||
+			errors.Is(err, plugin.ErrRequiredPlugin) || errors.Is(err, skills.ErrDuplicateSkillName) ||
+			errors.Is(err, policy.ErrInvalidRule) {
+			app.Shutdown()
+			return nil, err
+		}
 		slog.Warn("Failed to initialize plugins", "error", err)
 	}
+	app.setupPluginEventForwarding(app.eventsCtx)
 
 	// cleanup database upon app shutdown
 	app.cleanupFuncs = append(app.cleanupFuncs, conn.Close)
@@ -110,17 +151,71 @@ func (app *App) Config() *config.Config {
 	return app.config
 }
 
+// NonInteractiveOptions configures RunNonInteractive.
+type NonInteractiveOptions struct {
+	// Quiet hides the spinner and tool notices
+	Quiet bool
+
+	// AllowedTools restricts auto-approval to these tools (or tool:action
+	// pairs) and denies other permission requests. When empty, all requests
+	// are approved.
+	AllowedTools []string
+
+	// ShowTools prints a notice to stderr for each tool the agent runs. It
+	// only applies to the default sink.
+	ShowTools bool
+
+	// Sink receives the run's output. When nil, assistant text is written
+	// to stdout as plain text, followed by a summary on stderr unless Quiet
+	// or NoSummary is set.
+	Sink OutputSink
+
+	// NoSummary leaves the token usage and timing summary out of the output
+	NoSummary bool
+}
+
+// progressOutput returns where a run with opts shows the terminal's
+// progress bar, or nil to hide it. It is only shown with the default text
+// output and on a terminal, so its escapes can't corrupt piped or JSON
+// output.
+func progressOutput(opts NonInteractiveOptions, stderr *os.File) io.Writer {
+	if opts.Sink != nil || opts.Quiet || !term.IsTerminal(stderr.Fd()) {
+		return nil
+	}
+	return stderr
+}
+
 // RunNonInteractive handles the execution flow when a prompt is provided via
 // CLI flag.
-func (app *App) RunNonInteractive(ctx context.Context, prompt string, quiet bool) error {
+func (app *App) RunNonInteractive(ctx context.Context, prompt string, opts NonInteractiveOptions) error {
 	slog.Info("Running in non-interactive mode")
+	start := time.Now()
 
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	const spinnerLabel = "Generating"
+	currentLabel := spinnerLabel
+
+	quiet := opts.Quiet
+	showTools := opts.ShowTools && !quiet
+	noticed := make(map[string]bool) // tool call IDs already reported
+
+	sink := opts.Sink
+	if sink == nil {
+		var notices io.Writer
+		if showTools {
+			notices = os.Stderr
+		}
+		sink = NewTextSink(os.Stdout, notices)
+		if !quiet && !opts.NoSummary {
+			sink = NewTeeSink(sink, NewSummarySink(os.Stderr))
+		}
+	}
+
 	var spinner *format.Spinner
 	if !quiet {
-		spinner = format.NewSpinner(ctx, cancel, "Generating")
+		spinner = format.NewSpinner(ctx, cancel, spinnerLabel)
 		spinner.Start()
 	}
 
@@ -133,25 +228,32 @@ func (app *App) RunNonInteractive(ctx context.Context, prompt string, quiet bool
 	}
 	defer stopSpinner()
 
-	const maxPromptLengthForTitle = 100
-	titlePrefix := "Non-interactive: "
-	var titleSuffix string
-
-	if len(prompt) > maxPromptLengthForTitle {
-		titleSuffix = prompt[:maxPromptLengthForTitle] + "..."
-	} else {
-		titleSuffix = prompt
+	var sessionID string
+	var summary *RunSummary
+	finish := func(err error) error {
+		stopSpinner()
+		if sinkErr := sink.Finish(RunResult{SessionID: sessionID, Err: err, Summary: summary}); sinkErr != nil && err == nil {
+			return fmt.Errorf("failed to write output: %w", sinkErr)
+		}
+		return err
 	}
-	title := titlePrefix + titleSuffix
 
-	sess, err := app.Sessions.Create(ctx, title)
+	sess, err := app.Sessions.Create(ctx, nonInteractiveTitle("Non-interactive: ", prompt))
 	if err != nil {
-		return fmt.Errorf("failed to create session for non-interactive mode: %w", err)
+		return finish(fmt.Errorf("failed to create session for non-interactive mode: %w", err))
 	}
+	sessionID = sess.ID
 	slog.Info("Created session for non-interactive run", "session_id", sess.ID)
 
-	// Automatically approve all permission requests for this non-interactive session
-	app.Permissions.AutoApproveSession(sess.ID)
+	if len(opts.AllowedTools) == 0 {
+		// Automatically approve all permission requests for this non-interactive session
+		app.Permissions.AutoApproveSession(sess.ID)
+	} else {
+		// Approve only the allowed tools; nobody can answer a prompt, so deny
+		// the rest
+		app.Permissions.AutoApproveSessionTools(sess.ID, opts.AllowedTools)
+		go app.denyPermissionRequests(ctx, app.Permissions.Subscribe(ctx), sess.ID)
+	}
 
 	type response struct {
 		result *fantasy.AgentResult
@@ -174,48 +276,95 @@ func (app *App) RunNonInteractive(ctx context.Context, prompt string, quiet bool
 	messageEvents := app.Messages.Subscribe(ctx)
 	messageReadBytes := make(map[string]int)
 
-	defer fmt.Printf(ansi.ResetProgressBar)
+	progress := progressOutput(opts, os.Stderr)
+	if progress != nil {
+		defer fmt.Fprint(progress, ansi.ResetProgressBar)
+	}
 	for {
 		// HACK: add it again on every iteration so it doesn't get hidden by
 		// the terminal due to inactivity.
-		fmt.Printf(ansi.SetIndeterminateProgressBar)
+		if progress != nil {
+			fmt.Fprint(progress, ansi.SetIndeterminateProgressBar)
+		}
 		select {
 		case result := <-done:
-			stopSpinner()
 			if result.err != nil {
 				if errors.Is(result.err, context.Canceled) || errors.Is(result.err, agent.ErrRequestCancelled) {
 					slog.Info("Non-interactive: agent processing cancelled", "session_id", sess.ID)
-					return nil
+					return finish(nil)
 				}
-				return fmt.Errorf("agent processing failed: %w", result.err)
+				return finish(fmt.Errorf("agent processing failed: %w", result.err))
 			}
-			return nil
+			if !opts.NoSummary {
+				summary = app.runSummary(ctx, sess.ID, result.result, start)
+			}
+			return finish(nil)
 
 		case event := <-messageEvents:
 			msg := event.Payload
 			if msg.SessionID == sess.ID && msg.Role == message.Assistant && len(msg.Parts) > 0 {
+				content := msg.Content().String()
+
+				for _, call := range msg.ToolCalls() {
+					if !call.Finished || noticed[call.ID] {
+						continue
+					}
+					// Notices replace the spinner so they don't garble it
+					if showTools {
+						stopSpinner()
+					}
+					if err := sink.WriteToolCall(call); err != nil {
+						return finish(fmt.Errorf("failed to write output: %w", err))
+					}
+					noticed[call.ID] = true
+				}
+
+				// Keep the spinner until the first non-empty text so that
+				// tool-only steps don't make it flicker
+				if spinner != nil && strings.TrimSpace(content) == "" {
+					label := spinnerLabel
+					if toolCalls := msg.ToolCalls(); len(toolCalls) > 0 {
+						label = "Running " + toolCalls[len(toolCalls)-1].Name
+					}
+					if label != currentLabel {
+						spinner.SetLabel(label)
+						currentLabel = label
+					}
+					continue
+				}
 				stopSpinner()
 
-				content := msg.Content().String()
 				readBytes := messageReadBytes[msg.ID]
 
 				if len(content) < readBytes {
 					slog.Error("Non-interactive: message content is shorter than read bytes", "message_length", len(content), "read_bytes", readBytes)
-					return fmt.Errorf("message content is shorter than read bytes: %d < %d", len(content), readBytes)
+					return finish(fmt.Errorf("message content is shorter than read bytes: %d < %d", len(content), readBytes))
 				}
 
-				part := content[readBytes:]
-				fmt.Print(part)
+				if part := content[readBytes:]; part != "" {
+					if err := sink.WriteAssistantDelta(part); err != nil {
+						return finish(fmt.Errorf("failed to write output: %w", err))
+					}
+				}
 				messageReadBytes[msg.ID] = len(content)
 			}
 
 		case <-ctx.Done():
-			stopSpinner()
-			return ctx.Err()
+			return finish(ctx.Err())
 		}
 	}
 }
 
+// nonInteractiveTitle builds a session title from a prefix and a prompt,
+// truncating long prompts.
+func nonInteractiveTitle(prefix, prompt string) string {
+	const maxPromptLengthForTitle = 100
+	if len(prompt) > maxPromptLengthForTitle {
+		return prefix + prompt[:maxPromptLengthForTitle] + "..."
+	}
+	return prefix + prompt
+}
+
 func (app *App) UpdateAgentModel(ctx context.Context) error {
 	return app.AgentCoordinator.UpdateModels(ctx)
 }
@@ -230,9 +379,8 @@ func (app *App) setupEvents() {
 	setupSubscriber(ctx, app.serviceEventsWG, "history", app.History.Subscribe, app.events)
 	setupSubscriber(ctx, app.serviceEventsWG, "mcp", tools.SubscribeMCPEvents, app.events)
 	setupSubscriber(ctx, app.serviceEventsWG, "lsp", SubscribeLSPEvents, app.events)
-
-	// Setup plugin event forwarding
-	app.setupPluginEventForwarding(ctx)
+	setupSubscriber(ctx, app.serviceEventsWG, "plugins", app.PluginRegistry.Subscribe, app.events)
+	setupSubscriber(ctx, app.serviceEventsWG, "plugin-tool-progress", app.PluginRegistry.SubscribeToolProgress, app.events)
 
 	cleanupFunc := func() error {
 		cancel()
@@ -242,101 +390,558 @@ func (app *App) setupEvents() {
 	app.cleanupFuncs = append(app.cleanupFuncs, cleanupFunc)
 }
 
-// initPlugins initializes all plugins from configuration
-func (app *App) initPlugins(ctx context.Context) error {
-	pluginCtx := plugin.PluginContext{
+// defaultPluginShutdownTimeout bounds how long plugins may take to shut down
+const defaultPluginShutdownTimeout = 10 * time.Second
+
+// loaderOptions returns the plugin loader options set by the configuration
+func loaderOptions(cfg *config.Config) []plugin.LoaderOption {
+	opts := cfg.Options.Plugins
+	if opts == nil {
+		return nil
+	}
+	return []plugin.LoaderOption{
+		plugin.WithAllowedRoots(opts.AllowedRoots...),
+		plugin.WithDeniedPlugins(opts.Denylist...),
+		plugin.WithOffline(opts.Offline),
+		plugin.WithAllowedHosts(opts.AllowedHosts...),
+		plugin.WithRetryPolicy(plugin.RetryPolicy{
+			MaxAttempts:    opts.InitRetries + 1,
+			InitialBackoff: 500 * time.Millisecond,
+			MaxBackoff:     5 * time.Second,
+		}),
+	}
+}
+
+// ValidatePlugins checks the configured plugins and skills without
+// initializing any of them or starting the agent, so configuration problems
+// can be caught before Crush runs
+func ValidatePlugins(ctx context.Context, cfg *config.Config) []plugin.ValidationResult {
+	loader := plugin.NewLoader(plugin.NewRegistry(), loaderOptions(cfg)...)
+	results := loader.ValidateConfig(ctx, cfg)
+	return append(results, skills.Validate(cfg.WorkingDir(), cfg)...)
+}
+
+// pluginContext returns the context plugins are initialized with
+func (app *App) pluginContext() plugin.PluginContext {
+	return plugin.PluginContext{
 		Config: app.config,
 		Services: plugin.Services{
 			Session:    app.Sessions,
 			Message:    app.Messages,
 			Permission: app.Permissions,
+			Plugins:    app.PluginRegistry,
+			Exporter:   app,
+			Agent:      app,
+			Tools:      app.PluginRegistry,
 		},
 		WorkingDir: app.config.WorkingDir(),
 	}
+}
+
+// RegisterPlugin initializes p and registers it with the app, for programs
+// embedding Crush that provide plugins in-process rather than as .so files.
+// The plugin's tools and hooks take effect immediately, but its config hook
+// isn't run because the configuration is already loaded.
+func (app *App) RegisterPlugin(ctx context.Context, p plugin.Plugin) error {
+	return app.PluginRegistry.LoadPlugin(ctx, p, app.pluginContext())
+}
+
+// initPlugins initializes all plugins from configuration. A built-in plugin
+// failing to load doesn't stop the others from loading, so that required
+// plugins are always tried. Its failure is fatal if the config requires
+// plugins, since Crush must then not run degraded.
+func (app *App) initPlugins(ctx context.Context) error {
+	start := time.Now()
+	app.PluginRegistry.SetToolRunner(app)
+	pluginCtx := app.pluginContext()
+
+	// builtinErrs are the failures of built-in plugins, and errs all others
+	var builtinErrs, errs []error
 
 	// Register built-in skills plugin
 	skillsPlugin := skills.NewPlugin()
 	if err := app.PluginRegistry.LoadPlugin(ctx, skillsPlugin, pluginCtx); err != nil {
-		return fmt.Errorf("failed to load skills plugin: %w", err)
+		builtinErrs = append(builtinErrs, fmt.Errorf("failed to load skills plugin: %w", err))
+	}
+	app.skillDiagnostics = skillsPlugin.Diagnostics()
+	for _, d := range app.skillDiagnostics {
+		slog.Warn("Skipped skill", "path", d.Path, "reason", d.Reason)
+	}
+
+	// Register built-in permission policy plugin
+	if perms := app.config.Permissions; perms != nil && perms.Policy != nil {
+		if policyPlugin, err := policy.NewPlugin(*perms.Policy); err != nil {
+			builtinErrs = append(builtinErrs, fmt.Errorf("failed to load permission policy: %w", err))
+		} else if err := app.PluginRegistry.LoadPlugin(ctx, policyPlugin, pluginCtx); err != nil {
+			builtinErrs = append(builtinErrs, fmt.Errorf("failed to load permission policy plugin: %w", err))
+		}
+	}
+
+	// Register built-in tool audit plugin
+	if opts := app.config.Options.ToolAudit; opts != nil && opts.Enabled {
+		if err := app.PluginRegistry.LoadPlugin(ctx, audit.NewPlugin(app.queries, *opts), pluginCtx); err != nil {
+			builtinErrs = append(builtinErrs, fmt.Errorf("failed to load tool audit plugin: %w", err))
+		}
+	}
+
+	// Register built-in context injector plugin
+	if opts := app.config.Options.ContextInjector; opts != nil && len(opts.Files) > 0 {
+		if err := app.PluginRegistry.LoadPlugin(ctx, injector.NewPlugin(*opts), pluginCtx); err != nil {
+			builtinErrs = append(builtinErrs, fmt.Errorf("failed to load context injector plugin: %w", err))
+		}
+	}
+
+	// Register built-in cost budget plugin
+	if opts := app.config.Options.CostBudget; opts != nil {
+		if err := app.PluginRegistry.LoadPlugin(ctx, budget.NewPlugin(*opts), pluginCtx); err != nil {
+			builtinErrs = append(builtinErrs, fmt.Errorf("failed to load cost budget plugin: %w", err))
+		}
 	}
 
 	// Load plugins from config
-	loader := plugin.NewLoader(app.PluginRegistry)
-	if err := loader.LoadFromConfig(ctx, app.config, pluginCtx); err != nil {
-		return fmt.Errorf("failed to load plugins from config: %w", err)
+	requiresPlugins := false
+	if app.config.Options.SafeMode {
+		slog.Warn("Safe mode is active, not loading plugins from config")
+	} else {
+		requiresPlugins = slices.ContainsFunc(app.config.GetPluginEntries(), func(entry config.PluginEntry) bool {
+			return entry.Required && entry.IsEnabled()
+		})
+		loader := plugin.NewLoader(app.PluginRegistry, loaderOptions(app.config)...)
+		if err := loader.LoadFromConfig(ctx, app.config, pluginCtx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to load plugins from config: %w", err))
+		}
+	}
+	if builtinErr := errors.Join(builtinErrs...); builtinErr != nil && requiresPlugins {
+		errs = append(errs, fmt.Errorf("%w: %w", plugin.ErrRequiredPlugin, builtinErr))
+	} else if builtinErr != nil {
+		errs = append(errs, builtinErr)
+	}
+
+	// Run hooks in the configured order now that all plugins are known
+	if opts := app.config.Options.Plugins; opts != nil && len(opts.Order) > 0 {
+		for _, name := range opts.Order {
+			if _, ok := app.PluginRegistry.GetPlugin(name); !ok {
+				slog.Warn("Plugin in plugin order is not loaded", "plugin", name)
+			}
+		}
+		app.PluginRegistry.SetPluginOrder(opts.Order)
+	}
+
+	// Protect slow permission plugins from bursts of tool calls
+	if opts := app.config.Options.Plugins; opts != nil && opts.MaxPermissionRequests > 0 {
+		app.PluginRegistry.SetMaxPermissionRequests(opts.MaxPermissionRequests)
+	}
+
+	// Unload plugins whose hooks keep panicking
+	if opts := app.config.Options.Plugins; opts != nil && opts.PanicThreshold > 0 {
+		app.PluginRegistry.SetQuarantinePolicy(plugin.QuarantinePolicy{
+			Threshold: opts.PanicThreshold,
+			Cooldown:  time.Duration(opts.QuarantineCooldown) * time.Second,
+		})
+	}
+
+	// Periodically probe plugins that implement health checks
+	if opts := app.config.Options.Plugins; opts != nil && opts.HealthCheckInterval > 0 {
+		healthCtx, cancel := context.WithCancel(ctx)
+		app.PluginRegistry.StartHealthChecks(healthCtx, time.Duration(opts.HealthCheckInterval)*time.Second)
+		app.cleanupFuncs = append(app.cleanupFuncs, func() error {
+			cancel()
+			return nil
+		})
+	}
+
+	// Add plugin shutdown to cleanup functions, bounded so that a stuck
+	// plugin can't prevent the app from exiting
+	shutdownTimeout := defaultPluginShutdownTimeout
+	if opts := app.config.Options.Plugins; opts != nil && opts.ShutdownTimeout > 0 {
+		shutdownTimeout = time.Duration(opts.ShutdownTimeout) * time.Second
 	}
+	app.cleanupFuncs = append(app.cleanupFuncs, func() error {
+		shutdownCtx, cancel := context.WithTimeout(ctx, shutdownTimeout)
+		defer cancel()
+		return app.PluginRegistry.Shutdown(shutdownCtx)
+	})
 
 	// Trigger config hooks after plugins are loaded
 	if err := app.PluginRegistry.TriggerConfigHooks(ctx, app.config); err != nil {
-		return fmt.Errorf("failed to trigger config hooks: %w", err)
+		errs = append(errs, fmt.Errorf("failed to trigger config hooks: %w", err))
 	}
 
-	// Add plugin shutdown to cleanup functions
-	app.cleanupFuncs = append(app.cleanupFuncs, func() error {
-		return app.PluginRegistry.Shutdown(ctx)
+	app.logPluginLoadTimes()
+	app.logPluginHooks()
+	slog.Info("Plugins initialized", "count", len(app.PluginRegistry.ListPlugins()), "duration", time.Since(start))
+	return errors.Join(errs...)
+}
+
+// logPluginLoadTimes logs how long each plugin took to load, slowest first
+func (app *App) logPluginLoadTimes() {
+	loadTimes := app.PluginRegistry.LoadTimes()
+	names := slices.SortedFunc(maps.Keys(loadTimes), func(a, b string) int {
+		return cmp.Compare(loadTimes[b].Total(), loadTimes[a].Total())
 	})
+	for _, name := range names {
+		t := loadTimes[name]
+		slog.Debug("Plugin loaded", "plugin", name, "open", t.Open, "init", t.Init)
+	}
+	if len(names) > 0 {
+		slog.Info("Slowest plugin", "plugin", names[0], "duration", loadTimes[names[0]].Total())
+	}
+}
 
-	slog.Info("Plugins initialized", "count", len(app.PluginRegistry.ListPlugins()))
-	return nil
+// logPluginHooks logs which plugins contribute each hook type
+func (app *App) logPluginHooks() {
+	for _, hookType := range plugin.HookTypes {
+		if plugins := app.PluginRegistry.PluginsImplementing(hookType); len(plugins) > 0 {
+			slog.Debug("Plugin hooks", "type", hookType, "plugins", plugins)
+		}
+	}
+}
+
+// DenyPermissionRequests denies the permission requests of the session and
+// its child sessions that would prompt the user until ctx is done, for when
+// nobody can answer them
+func (app *App) DenyPermissionRequests(ctx context.Context, sessionID string) {
+	go app.denyPermissionRequests(ctx, app.Permissions.Subscribe(ctx), sessionID)
 }
 
-// setupPluginEventForwarding forwards service events to plugin hooks
+// denyPermissionRequests denies every permission request for the session and
+// its child sessions until events is closed
+func (app *App) denyPermissionRequests(ctx context.Context, events <-chan pubsub.Event[permission.PermissionRequest], sessionID string) {
+	for event := range events {
+		if !app.inSessionTree(ctx, event.Payload.SessionID, sessionID) {
+			continue
+		}
+		slog.Warn("Denying permission request in non-interactive mode", "tool", event.Payload.ToolName, "action", event.Payload.Action)
+		app.Permissions.Deny(event.Payload)
+	}
+}
+
+// inSessionTree reports whether sessionID is rootID or one of its child
+// sessions, at any depth
+func (app *App) inSessionTree(ctx context.Context, sessionID, rootID string) bool {
+	seen := map[string]bool{}
+	for sessionID != "" && !seen[sessionID] {
+		seen[sessionID] = true
+		if sessionID == rootID {
+			return true
+		}
+		sess, err := app.Sessions.Get(ctx, sessionID)
+		if err != nil {
+			return false
+		}
+		sessionID = sess.ParentSessionID
+	}
+	return false
+}
+
+// RunTool runs a single tool, built-in or from a plugin, by name with JSON
+// arguments and without the model. Plugin tool hooks run around it and it
+// requests permissions like it would in an agent run.
+func (app *App) RunTool(ctx context.Context, sessionID, name string, args json.RawMessage) (fantasy.ToolResponse, error) {
+	if app.AgentCoordinator == nil {
+		return fantasy.ToolResponse{}, errors.New("coder agent is not initialized")
+	}
+	return app.AgentCoordinator.RunTool(ctx, sessionID, name, args)
+}
+
+// ToolInfos describes the tools offered to the coder agent, built-in and
+// from plugins
+func (app *App) ToolInfos(ctx context.Context) ([]fantasy.ToolInfo, error) {
+	if app.AgentCoordinator == nil {
+		return nil, errors.New("coder agent is not initialized")
+	}
+	return app.AgentCoordinator.ToolInfos(ctx)
+}
+
+// RunSubAgent runs the coder agent on a new child session of parentSessionID
+// and returns its final response
+func (app *App) RunSubAgent(ctx context.Context, parentSessionID, prompt string) (string, error) {
+	if app.AgentCoordinator == nil {
+		return "", errors.New("coder agent is not initialized")
+	}
+	return app.AgentCoordinator.RunSubAgent(ctx, parentSessionID, prompt)
+}
+
+// ExportSession renders a session's messages, including tool calls and their
+// results, as a Markdown or HTML transcript.
+func (app *App) ExportSession(ctx context.Context, sessionID, exportFormat string) ([]byte, error) {
+	sess, err := app.Sessions.Get(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session %s: %w", sessionID, err)
+	}
+	msgs, err := app.Messages.List(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list messages for session %s: %w", sessionID, err)
+	}
+
+	var buf bytes.Buffer
+	if err := format.WriteTranscript(&buf, sess, msgs, format.ExportFormat(exportFormat)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SkillDiagnostics returns the skills and skills paths that were skipped
+// during discovery, along with the reason for each.
+func (app *App) SkillDiagnostics() []skills.Diagnostic {
+	return app.skillDiagnostics
+}
+
+// PluginsJSON returns a JSON description of every loaded plugin, including
+// the hooks it implements and the tools it contributes.
+func (app *App) PluginsJSON() ([]byte, error) {
+	bts, err := json.MarshalIndent(app.PluginRegistry.DescribePlugins(), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal plugins: %w", err)
+	}
+	return bts, nil
+}
+
+// setupPluginEventForwarding forwards service events to plugin hooks. Each
+// kind of event is only forwarded once a plugin has hooks for it, so there is
+// no per-event overhead without plugins.
 func (app *App) setupPluginEventForwarding(ctx context.Context) {
-	// Forward session events to plugins
-	app.serviceEventsWG.Go(func() {
-		ch := app.Sessions.Subscribe(ctx)
-		for {
-			select {
-			case event, ok := <-ch:
-				if !ok {
+	var sessions, messages, lspClients sync.Once
+	start := func() {
+		if app.PluginRegistry.HasSessionHooks() {
+			sessions.Do(func() { app.serviceEventsWG.Go(func() { app.forwardSessionEvents(ctx) }) })
+		}
+		if app.PluginRegistry.HasMessageHooks() {
+			messages.Do(func() {
+				if window := app.pluginEventBatchWindow(); window > 0 {
+					app.serviceEventsWG.Go(func() { app.forwardMessageEventBatches(ctx, window) })
 					return
 				}
-				switch event.Type {
-				case pubsub.CreatedEvent:
-					if err := app.PluginRegistry.TriggerSessionCreated(ctx, event.Payload); err != nil {
-						slog.Error("Plugin session created hook failed", "error", err)
-					}
-				case pubsub.UpdatedEvent:
-					if err := app.PluginRegistry.TriggerSessionUpdated(ctx, event.Payload); err != nil {
-						slog.Error("Plugin session updated hook failed", "error", err)
-					}
-				case pubsub.DeletedEvent:
-					if err := app.PluginRegistry.TriggerSessionDeleted(ctx, event.Payload.ID); err != nil {
-						slog.Error("Plugin session deleted hook failed", "error", err)
-					}
-				}
-			case <-ctx.Done():
-				return
+				app.serviceEventsWG.Go(func() { app.forwardMessageEvents(ctx) })
+			})
+		}
+		if app.PluginRegistry.HasLSPHooks() {
+			lspClients.Do(func() { app.serviceEventsWG.Go(func() { app.forwardLSPEvents(ctx) }) })
+		}
+	}
+	start()
+
+	// Plugins loaded later may need forwarding that isn't running yet
+	app.serviceEventsWG.Go(func() {
+		for event := range app.PluginRegistry.Subscribe(ctx) {
+			if event.Payload.Type == plugin.PluginLoaded {
+				start()
 			}
 		}
 	})
+}
 
-	// Forward message events to plugins
-	app.serviceEventsWG.Go(func() {
-		ch := app.Messages.Subscribe(ctx)
-		for {
-			select {
-			case event, ok := <-ch:
-				if !ok {
-					return
+// forwardSessionEvents forwards session events to plugin hooks
+func (app *App) forwardSessionEvents(ctx context.Context) {
+	ch := app.Sessions.Subscribe(ctx)
+	for {
+		select {
+		case event, ok := <-ch:
+			if !ok {
+				return
+			}
+			switch event.Type {
+			case pubsub.CreatedEvent:
+				if err := app.PluginRegistry.TriggerSessionCreated(ctx, event.Payload); err != nil {
+					slog.Error("Plugin session created hook failed", "error", err)
 				}
-				switch event.Type {
-				case pubsub.CreatedEvent:
-					if err := app.PluginRegistry.TriggerMessageCreated(ctx, event.Payload); err != nil {
-						slog.Error("Plugin message created hook failed", "error", err)
-					}
-				case pubsub.UpdatedEvent:
-					if err := app.PluginRegistry.TriggerMessageUpdated(ctx, event.Payload); err != nil {
-						slog.Error("Plugin message updated hook failed", "error", err)
-					}
+			case pubsub.UpdatedEvent:
+				if err := app.PluginRegistry.TriggerSessionUpdated(ctx, event.Payload); err != nil {
+					slog.Error("Plugin session updated hook failed", "error", err)
 				}
-			case <-ctx.Done():
+			case pubsub.DeletedEvent:
+				if err := app.PluginRegistry.TriggerSessionDeleted(ctx, event.Payload.ID); err != nil {
+					slog.Error("Plugin session deleted hook failed", "error", err)
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// forwardMessageEvents forwards message events to plugin hooks
+func (app *App) forwardMessageEvents(ctx context.Context) {
+	ch := app.Messages.Subscribe(ctx)
+	for {
+		select {
+		case event, ok := <-ch:
+			if !ok {
 				return
 			}
+			switch event.Type {
+			case pubsub.CreatedEvent:
+				if err := app.PluginRegistry.TriggerMessageCreated(ctx, event.Payload); err != nil {
+					slog.Error("Plugin message created hook failed", "error", err)
+				}
+			case pubsub.UpdatedEvent:
+				if err := app.PluginRegistry.TriggerMessageUpdated(ctx, event.Payload); err != nil {
+					slog.Error("Plugin message updated hook failed", "error", err)
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runSummary returns the usage of a finished non-interactive run
+func (app *App) runSummary(ctx context.Context, sessionID string, result *fantasy.AgentResult, start time.Time) *RunSummary {
+	summary := &RunSummary{Duration: time.Since(start)}
+	if result != nil {
+		usage := result.TotalUsage
+		summary.InputTokens = usage.InputTokens + usage.CacheCreationTokens + usage.CacheReadTokens
+		summary.OutputTokens = usage.OutputTokens
+		summary.Steps = len(result.Steps)
+	}
+	if sess, err := app.Sessions.Get(ctx, sessionID); err == nil {
+		summary.Cost = sess.Cost
+	} else {
+		slog.Warn("Failed to get session for run summary", "session_id", sessionID, "error", err)
+	}
+	return summary
+}
+
+// forwardLSPEvents forwards LSP client state changes to plugin hooks. LSP
+// clients start in the background, so the states they reached before
+// forwarding started are delivered first.
+func (app *App) forwardLSPEvents(ctx context.Context) {
+	ch := SubscribeLSPEvents(ctx)
+	delivered := make(map[string]plugin.LSPState)
+	deliver := func(name string, state lsp.ServerState) {
+		pluginState := pluginLSPState(state)
+		if delivered[name] == pluginState {
+			return
 		}
+		delivered[name] = pluginState
+		if err := app.PluginRegistry.TriggerLSPClientStateChanged(ctx, name, pluginState); err != nil {
+			slog.Error("Plugin LSP client state hook failed", "error", err)
+		}
+	}
+
+	for name, info := range GetLSPStates() {
+		deliver(name, info.State)
+	}
+	for {
+		select {
+		case event, ok := <-ch:
+			if !ok {
+				return
+			}
+			if event.Payload.Type == LSPEventStateChanged {
+				deliver(event.Payload.Name, event.Payload.State)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// pluginLSPState returns the plugin API's name for an LSP client state
+func pluginLSPState(state lsp.ServerState) plugin.LSPState {
+	switch state {
+	case lsp.StateReady:
+		return plugin.LSPStateReady
+	case lsp.StateError:
+		return plugin.LSPStateError
+	case lsp.StateDisabled:
+		return plugin.LSPStateDisabled
+	default:
+		return plugin.LSPStateStarting
+	}
+}
+
+// pluginEventBatchWindow returns how long message events are collected for
+// before they are forwarded together, or zero to forward each event
+func (app *App) pluginEventBatchWindow() time.Duration {
+	if opts := app.config.Options.Plugins; opts != nil && opts.EventBatchWindow > 0 {
+		return time.Duration(opts.EventBatchWindow) * time.Millisecond
+	}
+	return 0
+}
+
+// maxMessageEventBatch is the most message events delivered together; a
+// full batch is delivered without waiting for the batch window to end
+const maxMessageEventBatch = 256
+
+// forwardMessageEventBatches forwards message events to plugin hooks in
+// batches of the events that arrive within window of the first
+func (app *App) forwardMessageEventBatches(ctx context.Context, window time.Duration) {
+	batchMessageEvents(ctx, app.Messages.Subscribe(ctx), window, maxMessageEventBatch, func(events []pubsub.Event[message.Message]) {
+		app.deliverMessageEvents(ctx, events)
 	})
 }
 
+// batchMessageEvents collects the created and updated message events of ch
+// into batches passed to deliver. A batch is delivered window after its
+// first event arrived, once it holds maxSize events, or when ch is closed.
+// Events arriving while deliver runs are collected into the next batch.
+func batchMessageEvents(ctx context.Context, ch <-chan pubsub.Event[message.Message], window time.Duration, maxSize int, deliver func([]pubsub.Event[message.Message])) {
+	timer := time.NewTimer(window)
+	timer.Stop()
+	defer timer.Stop()
+
+	var batch []pubsub.Event[message.Message]
+	flush := func() {
+		timer.Stop()
+		if len(batch) > 0 {
+			deliver(batch)
+		}
+		batch = nil
+	}
+	for {
+		select {
+		case event, ok := <-ch:
+			if !ok {
+				flush()
+				return
+			}
+			if event.Type != pubsub.CreatedEvent && event.Type != pubsub.UpdatedEvent {
+				continue
+			}
+			if len(batch) == 0 {
+				timer.Reset(window)
+			}
+			batch = append(batch, event)
+			if len(batch) >= maxSize {
+				flush()
+			}
+		case <-timer.C:
+			flush()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// deliverMessageEvents triggers the hooks for a batch of message events.
+// Consecutive events of the same type are delivered together, so events
+// reach the hooks in the order they happened.
+func (app *App) deliverMessageEvents(ctx context.Context, events []pubsub.Event[message.Message]) {
+	for len(events) > 0 {
+		n := 1
+		for n < len(events) && events[n].Type == events[0].Type {
+			n++
+		}
+		msgs := make([]message.Message, 0, n)
+		for _, event := range events[:n] {
+			msgs = append(msgs, event.Payload)
+		}
+
+		switch events[0].Type {
+		case pubsub.CreatedEvent:
+			if err := app.PluginRegistry.TriggerMessagesCreated(ctx, msgs); err != nil {
+				slog.Error("Plugin message created hook failed", "error", err)
+			}
+		case pubsub.UpdatedEvent:
+			if err := app.PluginRegistry.TriggerMessagesUpdated(ctx, msgs); err != nil {
+				slog.Error("Plugin message updated hook failed", "error", err)
+			}
+		}
+		events = events[n:]
+	}
+}
+
 func setupSubscriber[T any](
 	ctx context.Context,
 	wg *sync.WaitGroup,
@@ -0,0 +1,308 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/plugin"
+	"github.com/charmbracelet/crush/internal/policy"
+	"github.com/charmbracelet/crush/internal/pubsub"
+	"github.com/charmbracelet/crush/internal/skills"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestApp creates an app with a fresh database and no providers
+func newTestApp(t *testing.T, cfg *config.Config) (*App, error) {
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	cfg.Providers = csync.NewMap[string, config.ProviderConfig]()
+	return New(t.Context(), conn, cfg)
+}
+
+func TestNewRequiredPlugin(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "broken.so")
+	require.NoError(t, os.WriteFile(path, []byte("not a plugin"), 0o644))
+	cfg := &config.Config{
+		Options: &config.Options{SkillsProjectOnly: true},
+		Plugins: []config.PluginEntry{{Path: path, Required: true}},
+	}
+
+	app, err := newTestApp(t, cfg)
+	require.ErrorIs(t, err, plugin.ErrRequiredPlugin)
+	require.Nil(t, app, "startup must abort when a required plugin fails to load")
+}
+
+func TestNewBuiltinPluginFailure(t *testing.T) {
+	t.Parallel()
+
+	// A broken built-in plugin doesn't keep required plugins from being
+	// tried, and both failures are reported
+	path := filepath.Join(t.TempDir(), "broken.so")
+	require.NoError(t, os.WriteFile(path, []byte("not a plugin"), 0o644))
+	app, err := newTestApp(t, &config.Config{
+		Options: &config.Options{
+			SkillsProjectOnly: true,
+			ContextInjector:   &config.ContextInjector{Files: []string{"docs/[*.md"}},
+		},
+		Plugins: []config.PluginEntry{{Path: path, Required: true}},
+	})
+	require.ErrorIs(t, err, plugin.ErrRequiredPlugin)
+	require.ErrorContains(t, err, "failed to load context injector plugin")
+	require.ErrorContains(t, err, "failed to load plugins from config")
+	require.Nil(t, app)
+
+	// Without required plugins Crush starts without the broken one
+	app, err = newTestApp(t, &config.Config{
+		Options: &config.Options{
+			SkillsProjectOnly: true,
+			ContextInjector:   &config.ContextInjector{Files: []string{"docs/[*.md"}},
+		},
+	})
+	require.NoError(t, err)
+	t.Cleanup(app.Shutdown)
+	_, loaded := app.PluginRegistry.GetPlugin("crush-context-injector")
+	require.False(t, loaded)
+}
+
+func TestNewInvalidPermissionPolicy(t *testing.T) {
+	t.Parallel()
+
+	app, err := newTestApp(t, &config.Config{
+		Options: &config.Options{SkillsProjectOnly: true},
+		Permissions: &config.Permissions{Policy: &config.PermissionPolicy{
+			Rules: []config.PermissionRule{{Tool: "bash", Decision: "block"}},
+		}},
+	})
+	require.ErrorIs(t, err, policy.ErrInvalidRule)
+	require.Nil(t, app, "startup must abort rather than run without the policy")
+}
+
+func TestNewDuplicateSkillNames(t *testing.T) {
+	t.Parallel()
+
+	skillsDir := t.TempDir()
+	for _, dir := range []string{"a", "b"} {
+		skillDir := filepath.Join(skillsDir, dir, "review")
+		require.NoError(t, os.MkdirAll(skillDir, 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(skillDir, "SKILL.md"), []byte("---\nname: review\ndescription: Reviews code for correctness\n---\nReview the code.\n"), 0o644))
+	}
+	cfg := &config.Config{Options: &config.Options{
+		SkillsProjectOnly:   true,
+		SkillsPaths:         []string{skillsDir},
+		SkillNameCollisions: config.SkillCollisionsError,
+	}}
+
+	app, err := newTestApp(t, cfg)
+	require.ErrorIs(t, err, skills.ErrDuplicateSkillName)
+	require.ErrorContains(t, err, filepath.Join(skillsDir, "a", "review"))
+	require.Nil(t, app)
+}
+
+func TestNonInteractiveChildSessionPermissions(t *testing.T) {
+	t.Parallel()
+
+	app, err := newTestApp(t, &config.Config{Options: &config.Options{SkillsProjectOnly: true}})
+	require.NoError(t, err)
+
+	// The sessions of a non-interactive run with allowed tools, and of a
+	// sub-agent it started
+	parent, err := app.Sessions.Create(t.Context(), "Non-interactive")
+	require.NoError(t, err)
+	child, err := app.Sessions.CreateTaskSession(t.Context(), "sub-agent", parent.ID, "Sub-agent Session")
+	require.NoError(t, err)
+	app.Permissions.AutoApproveSessionTools(parent.ID, []string{"view"})
+	app.Permissions.InheritSession(child.ID, parent.ID)
+	app.DenyPermissionRequests(t.Context(), parent.ID)
+
+	request := func(toolName string) bool {
+		done := make(chan bool, 1)
+		go func() {
+			done <- app.Permissions.Request(permission.CreatePermissionRequest{
+				SessionID: child.ID,
+				ToolName:  toolName,
+				Action:    "execute",
+			})
+		}()
+		select {
+		case granted := <-done:
+			return granted
+		case <-time.After(5 * time.Second):
+			t.Fatalf("permission request for %s is waiting for an answer", toolName)
+			return false
+		}
+	}
+	require.True(t, request("view"), "the child inherits the parent's allowed tools")
+	require.False(t, request("bash"), "other requests of the child are denied")
+}
+
+// messageEvents returns a created event for each message ID
+func messageEvents(ids ...string) []pubsub.Event[message.Message] {
+	events := make([]pubsub.Event[message.Message], 0, len(ids))
+	for _, id := range ids {
+		events = append(events, pubsub.Event[message.Message]{Type: pubsub.CreatedEvent, Payload: message.Message{ID: id}})
+	}
+	return events
+}
+
+// eventIDs returns the IDs of the messages of events
+func eventIDs(events []pubsub.Event[message.Message]) []string {
+	ids := make([]string, 0, len(events))
+	for _, event := range events {
+		ids = append(ids, event.Payload.ID)
+	}
+	return ids
+}
+
+func TestBatchMessageEvents(t *testing.T) {
+	t.Parallel()
+
+	// batch runs batchMessageEvents over events and returns the message IDs
+	// of each delivered batch
+	batch := func(t *testing.T, window time.Duration, maxSize int, events []pubsub.Event[message.Message], deliver func([]pubsub.Event[message.Message])) [][]string {
+		ch := make(chan pubsub.Event[message.Message], len(events))
+		for _, event := range events {
+			ch <- event
+		}
+		close(ch)
+		var batches [][]string
+		batchMessageEvents(t.Context(), ch, window, maxSize, func(events []pubsub.Event[message.Message]) {
+			batches = append(batches, eventIDs(events))
+			if deliver != nil {
+				deliver(events)
+			}
+		})
+		return batches
+	}
+
+	t.Run("flushes full batches", func(t *testing.T) {
+		t.Parallel()
+
+		events := messageEvents("1", "2", "3", "4", "5", "6", "7")
+		events = slices.Insert(events, 2, pubsub.Event[message.Message]{Type: pubsub.DeletedEvent, Payload: message.Message{ID: "deleted"}})
+		require.Equal(t, [][]string{{"1", "2", "3"}, {"4", "5", "6"}, {"7"}}, batch(t, time.Hour, 3, events, nil))
+	})
+
+	t.Run("flushes after the window", func(t *testing.T) {
+		t.Parallel()
+
+		ch := make(chan pubsub.Event[message.Message], 2)
+		delivered := make(chan []string, 1)
+		go batchMessageEvents(t.Context(), ch, 10*time.Millisecond, 100, func(events []pubsub.Event[message.Message]) {
+			delivered <- eventIDs(events)
+		})
+		for _, event := range messageEvents("1", "2") {
+			ch <- event
+		}
+		select {
+		case ids := <-delivered:
+			require.Equal(t, []string{"1", "2"}, ids)
+		case <-time.After(5 * time.Second):
+			t.Fatal("batch was not delivered after the window")
+		}
+	})
+
+	t.Run("collects events during slow deliveries", func(t *testing.T) {
+		t.Parallel()
+
+		ch := make(chan pubsub.Event[message.Message], 8)
+		started := make(chan struct{})
+		release := make(chan struct{})
+		var batches [][]string
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			batchMessageEvents(t.Context(), ch, 50*time.Millisecond, 100, func(events []pubsub.Event[message.Message]) {
+				batches = append(batches, eventIDs(events))
+				if len(batches) == 1 {
+					close(started)
+					<-release
+				}
+			})
+		}()
+
+		ch <- messageEvents("1")[0]
+		<-started
+		// The first delivery is still running
+		for _, event := range messageEvents("2", "3", "4") {
+			ch <- event
+		}
+		close(ch)
+		close(release)
+		<-done
+		require.Equal(t, [][]string{{"1"}, {"2", "3", "4"}}, batches)
+	})
+}
+
+// batchRecorder records the message hook calls it receives
+type batchRecorder struct {
+	calls []string
+}
+
+func (h *batchRecorder) OnMessageCreated(ctx context.Context, msg message.Message) error {
+	return errors.New("batched hooks receive batches")
+}
+
+func (h *batchRecorder) OnMessageUpdated(ctx context.Context, msg message.Message) error {
+	return errors.New("batched hooks receive batches")
+}
+
+func (h *batchRecorder) OnMessagesCreated(ctx context.Context, msgs []message.Message) error {
+	h.calls = append(h.calls, "created "+strings.Join(messageIDs(msgs), ","))
+	return nil
+}
+
+func (h *batchRecorder) OnMessagesUpdated(ctx context.Context, msgs []message.Message) error {
+	h.calls = append(h.calls, "updated "+strings.Join(messageIDs(msgs), ","))
+	return nil
+}
+
+func messageIDs(msgs []message.Message) []string {
+	ids := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		ids = append(ids, msg.ID)
+	}
+	return ids
+}
+
+type batchPlugin struct{ hook *batchRecorder }
+
+func (p *batchPlugin) Info() plugin.PluginInfo                          { return plugin.PluginInfo{Name: "batches"} }
+func (p *batchPlugin) Init(context.Context, plugin.PluginContext) error { return nil }
+func (p *batchPlugin) Shutdown(context.Context) error                   { return nil }
+
+func (p *batchPlugin) Hooks() plugin.Hooks {
+	hooks := plugin.NewBaseHooks()
+	hooks.MessageHook = p.hook
+	return hooks
+}
+
+func TestDeliverMessageEvents(t *testing.T) {
+	t.Parallel()
+
+	hook := &batchRecorder{}
+	registry := plugin.NewRegistry()
+	require.NoError(t, registry.LoadPlugin(t.Context(), &batchPlugin{hook: hook}, plugin.PluginContext{}))
+	app := &App{PluginRegistry: registry}
+
+	events := messageEvents("a", "b")
+	events = append(events, pubsub.Event[message.Message]{Type: pubsub.UpdatedEvent, Payload: message.Message{ID: "a"}})
+	events = append(events, messageEvents("c")...)
+	app.deliverMessageEvents(t.Context(), events)
+
+	// Runs of the same event type are delivered together, in order
+	require.Equal(t, []string{"created a,b", "updated a", "created c"}, hook.calls)
+}
@@ -0,0 +1,176 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// BatchInput is a single line of JSONL input for RunBatch.
+type BatchInput struct {
+	// ID is an optional caller-provided identifier echoed in the result
+	ID string `json:"id,omitempty"`
+
+	// Session groups prompts into the same conversation. Inputs sharing a
+	// session label run sequentially in the same Crush session; inputs
+	// without one each get a fresh session.
+	Session string `json:"session,omitempty"`
+
+	// Prompt is the prompt to send to the agent
+	Prompt string `json:"prompt"`
+}
+
+// BatchResult is a single line of JSONL output from RunBatch.
+type BatchResult struct {
+	ID        string `json:"id,omitempty"`
+	Session   string `json:"session,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+	Output    string `json:"output,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// BatchOptions configures RunBatch.
+type BatchOptions struct {
+	// Concurrency is the maximum number of distinct sessions processed at
+	// the same time. Values below 1 mean sequential processing.
+	Concurrency int
+
+	// StopOnError stops processing further inputs after the first failure.
+	StopOnError bool
+
+	// AllowedTools restricts auto-approval to these tools (or tool:action
+	// pairs), like NonInteractiveOptions.AllowedTools. Other permission
+	// requests are denied. Empty approves every request.
+	AllowedTools []string
+}
+
+// RunBatch reads prompts as JSONL from r, runs them through the agent, and
+// writes one JSONL BatchResult per input to w.
+func (app *App) RunBatch(ctx context.Context, r io.Reader, w io.Writer, opts BatchOptions) error {
+	slog.Info("Running in batch mode")
+
+	groups, order, err := readBatchInputs(r)
+	if err != nil {
+		return err
+	}
+
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	emit := func(result BatchResult) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return enc.Encode(result)
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(opts.Concurrency, 1))
+
+	for _, key := range order {
+		inputs := groups[key]
+		g.Go(func() error {
+			var sessionID string
+			for _, input := range inputs {
+				if gctx.Err() != nil {
+					return gctx.Err()
+				}
+
+				result := BatchResult{ID: input.ID, Session: input.Session}
+				output, sid, runErr := app.runBatchPrompt(gctx, sessionID, input.Prompt, opts.AllowedTools)
+				sessionID = sid
+				result.SessionID = sid
+				result.Output = output
+				if runErr != nil {
+					result.Error = runErr.Error()
+				}
+				if err := emit(result); err != nil {
+					return fmt.Errorf("failed to write batch result: %w", err)
+				}
+				if runErr != nil && opts.StopOnError {
+					return runErr
+				}
+			}
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil && !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("batch processing failed: %w", err)
+	}
+	return ctx.Err()
+}
+
+// runBatchPrompt runs a single prompt, creating a session if sessionID is
+// empty. It returns the assistant output and the session ID used.
+func (app *App) runBatchPrompt(ctx context.Context, sessionID, prompt string, allowedTools []string) (string, string, error) {
+	if sessionID == "" {
+		sess, err := app.Sessions.Create(ctx, nonInteractiveTitle("Batch: ", prompt))
+		if err != nil {
+			return "", "", fmt.Errorf("failed to create session for batch prompt: %w", err)
+		}
+		sessionID = sess.ID
+		if len(allowedTools) == 0 {
+			app.Permissions.AutoApproveSession(sessionID)
+		} else {
+			// Nobody can answer a prompt, so deny what isn't allowed
+			app.Permissions.AutoApproveSessionTools(sessionID, allowedTools)
+			app.DenyPermissionRequests(ctx, sessionID)
+		}
+	}
+
+	result, err := app.AgentCoordinator.Run(ctx, sessionID, prompt)
+	if err != nil {
+		return "", sessionID, err
+	}
+	if result == nil {
+		return "", sessionID, nil
+	}
+	return result.Response.Content.Text(), sessionID, nil
+}
+
+// readBatchInputs parses JSONL inputs, grouping them by session label while
+// keeping the order in which groups first appeared.
+func readBatchInputs(r io.Reader) (map[string][]BatchInput, []string, error) {
+	groups := make(map[string][]BatchInput)
+	var order []string
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var input BatchInput
+		if err := json.Unmarshal([]byte(text), &input); err != nil {
+			return nil, nil, fmt.Errorf("invalid batch input on line %d: %w", line, err)
+		}
+		if input.Prompt == "" {
+			return nil, nil, fmt.Errorf("invalid batch input on line %d: prompt is empty", line)
+		}
+
+		// Inputs without a session label each run in their own session.
+		key := "session:" + input.Session
+		if input.Session == "" {
+			key = fmt.Sprintf("line:%d", line)
+		}
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], input)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read batch input: %w", err)
+	}
+	return groups, order, nil
+}
@@ -0,0 +1,174 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/agent"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/stretchr/testify/require"
+)
+
+// echoCoordinator answers each prompt with the prompt itself and fails
+// prompts starting with "fail"
+type echoCoordinator struct {
+	agent.Coordinator
+	mu      sync.Mutex
+	prompts []string
+}
+
+func (c *echoCoordinator) Run(ctx context.Context, sessionID, prompt string, attachments ...message.Attachment) (*fantasy.AgentResult, error) {
+	c.mu.Lock()
+	c.prompts = append(c.prompts, prompt)
+	c.mu.Unlock()
+	if strings.HasPrefix(prompt, "fail") {
+		return nil, errors.New("agent failed")
+	}
+	return &fantasy.AgentResult{Response: fantasy.Response{
+		Content: fantasy.ResponseContent{fantasy.TextContent{Text: "echo: " + prompt}},
+	}}, nil
+}
+
+// permissionCoordinator asks for permission to run view and bash and answers
+// with whether each was granted
+type permissionCoordinator struct {
+	agent.Coordinator
+	permissions permission.Service
+}
+
+func (c *permissionCoordinator) Run(ctx context.Context, sessionID, prompt string, attachments ...message.Attachment) (*fantasy.AgentResult, error) {
+	var granted []string
+	for _, toolName := range []string{"view", "bash"} {
+		done := make(chan bool, 1)
+		go func() {
+			done <- c.permissions.Request(permission.CreatePermissionRequest{
+				SessionID: sessionID,
+				ToolName:  toolName,
+				Action:    "execute",
+			})
+		}()
+		select {
+		case ok := <-done:
+			if ok {
+				granted = append(granted, toolName)
+			}
+		case <-time.After(5 * time.Second):
+			return nil, errors.New("permission request for " + toolName + " is waiting for an answer")
+		}
+	}
+	return &fantasy.AgentResult{Response: fantasy.Response{
+		Content: fantasy.ResponseContent{fantasy.TextContent{Text: strings.Join(granted, ",")}},
+	}}, nil
+}
+
+func TestReadBatchInputs(t *testing.T) {
+	t.Parallel()
+
+	groups, order, err := readBatchInputs(strings.NewReader(`{"id": "1", "session": "a", "prompt": "first"}
+
+{"id": "2", "prompt": "alone"}
+{"id": "3", "session": "a", "prompt": "second"}
+`))
+	require.NoError(t, err)
+	require.Equal(t, []string{"session:a", "line:3"}, order)
+	require.Equal(t, []BatchInput{{ID: "1", Session: "a", Prompt: "first"}, {ID: "3", Session: "a", Prompt: "second"}}, groups["session:a"])
+	require.Equal(t, []BatchInput{{ID: "2", Prompt: "alone"}}, groups["line:3"])
+
+	_, _, err = readBatchInputs(strings.NewReader("{\"prompt\": \"ok\"}\nnot json\n"))
+	require.ErrorContains(t, err, "invalid batch input on line 2")
+
+	_, _, err = readBatchInputs(strings.NewReader(`{"id": "1"}`))
+	require.ErrorContains(t, err, "prompt is empty")
+}
+
+func TestRunBatch(t *testing.T) {
+	t.Parallel()
+
+	// run runs the inputs and returns the results by ID
+	run := func(t *testing.T, input string, opts BatchOptions) (map[string]BatchResult, []string, error) {
+		app, err := newTestApp(t, &config.Config{Options: &config.Options{SkillsProjectOnly: true}})
+		require.NoError(t, err)
+		coordinator := &echoCoordinator{}
+		app.AgentCoordinator = coordinator
+
+		var out bytes.Buffer
+		runErr := app.RunBatch(t.Context(), strings.NewReader(input), &out, opts)
+		results := map[string]BatchResult{}
+		dec := json.NewDecoder(&out)
+		for dec.More() {
+			var result BatchResult
+			require.NoError(t, dec.Decode(&result))
+			results[result.ID] = result
+		}
+		return results, coordinator.prompts, runErr
+	}
+
+	input := `{"id": "1", "session": "a", "prompt": "first"}
+{"id": "2", "prompt": "alone"}
+{"id": "3", "session": "a", "prompt": "second"}
+{"id": "4", "prompt": "fail please"}
+`
+
+	t.Run("runs every input", func(t *testing.T) {
+		t.Parallel()
+
+		results, _, err := run(t, input, BatchOptions{Concurrency: 2})
+		require.NoError(t, err)
+		require.Len(t, results, 4)
+		require.Equal(t, "echo: first", results["1"].Output)
+		require.Equal(t, "a", results["1"].Session)
+		require.Equal(t, results["1"].SessionID, results["3"].SessionID, "inputs of a session share it")
+		require.NotEqual(t, results["1"].SessionID, results["2"].SessionID)
+		require.NotEqual(t, results["2"].SessionID, results["4"].SessionID, "unlabeled inputs get their own session")
+		require.Equal(t, "agent failed", results["4"].Error)
+	})
+
+	t.Run("stops on error", func(t *testing.T) {
+		t.Parallel()
+
+		results, prompts, err := run(t, `{"id": "1", "prompt": "fail first"}
+{"id": "2", "prompt": "never"}
+`, BatchOptions{StopOnError: true})
+		require.ErrorContains(t, err, "agent failed")
+		require.Equal(t, []string{"fail first"}, prompts)
+		require.Len(t, results, 1)
+		require.Equal(t, "agent failed", results["1"].Error)
+	})
+}
+
+func TestRunBatchPermissions(t *testing.T) {
+	t.Parallel()
+
+	// run runs a single input and returns the tools it was allowed to use
+	run := func(t *testing.T, opts BatchOptions) string {
+		app, err := newTestApp(t, &config.Config{Options: &config.Options{SkillsProjectOnly: true}})
+		require.NoError(t, err)
+		app.AgentCoordinator = &permissionCoordinator{permissions: app.Permissions}
+
+		var out bytes.Buffer
+		require.NoError(t, app.RunBatch(t.Context(), strings.NewReader(`{"id": "1", "prompt": "tools"}`), &out, opts))
+		var result BatchResult
+		require.NoError(t, json.Unmarshal(out.Bytes(), &result))
+		require.Empty(t, result.Error)
+		return result.Output
+	}
+
+	t.Run("approves everything", func(t *testing.T) {
+		t.Parallel()
+		require.Equal(t, "view,bash", run(t, BatchOptions{}))
+	})
+
+	t.Run("allowed tools", func(t *testing.T) {
+		t.Parallel()
+		require.Equal(t, "view", run(t, BatchOptions{AllowedTools: []string{"view"}}), "other requests are denied")
+	})
+}
@@ -0,0 +1,247 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/message"
+)
+
+// OutputSink receives the output of a non-interactive run as it streams.
+// Methods are called from a single goroutine.
+type OutputSink interface {
+	// WriteAssistantDelta writes assistant text generated since the last
+	// call
+	WriteAssistantDelta(text string) error
+
+	// WriteToolCall reports a tool call once the model has finished
+	// generating it
+	WriteToolCall(call message.ToolCall) error
+
+	// Finish is called once when the run ends, successfully or not
+	Finish(result RunResult) error
+}
+
+// RunResult describes how a non-interactive run ended.
+type RunResult struct {
+	// SessionID is the session the run used; it is empty if the session
+	// couldn't be created
+	SessionID string
+
+	// Err is the error the run failed with, if any. Cancelled runs have no
+	// error.
+	Err error
+
+	// Summary describes the run's usage. It is nil if the agent didn't
+	// finish or the summary is disabled.
+	Summary *RunSummary
+}
+
+// RunSummary describes the usage of a finished non-interactive run.
+type RunSummary struct {
+	// InputTokens includes tokens read from and written to the prompt cache
+	InputTokens  int64
+	OutputTokens int64
+
+	// Cost is the estimated cost of the session in USD
+	Cost     float64
+	Steps    int
+	Duration time.Duration
+}
+
+// String returns a one-line summary, e.g. "1200 input + 300 output tokens,
+// $0.0120, 3 steps, 12.3s"
+func (s RunSummary) String() string {
+	steps := "steps"
+	if s.Steps == 1 {
+		steps = "step"
+	}
+	return fmt.Sprintf("%d input + %d output tokens, $%.4f, %d %s, %s",
+		s.InputTokens, s.OutputTokens, s.Cost, s.Steps, steps, s.Duration.Round(100*time.Millisecond))
+}
+
+// textSink writes assistant text as is and, optionally, a one-line notice
+// for each tool call
+type textSink struct {
+	out     io.Writer
+	notices io.Writer
+}
+
+// NewTextSink returns a sink that writes assistant text to out. If notices
+// isn't nil, a short notice is written to it for each tool call.
+func NewTextSink(out, notices io.Writer) OutputSink {
+	return &textSink{out: out, notices: notices}
+}
+
+func (s *textSink) WriteAssistantDelta(text string) error {
+	_, err := io.WriteString(s.out, text)
+	return err
+}
+
+func (s *textSink) WriteToolCall(call message.ToolCall) error {
+	if s.notices == nil {
+		return nil
+	}
+	_, err := fmt.Fprintln(s.notices, toolNotice(call))
+	return err
+}
+
+func (s *textSink) Finish(result RunResult) error {
+	return nil
+}
+
+// Types of JSON sink events
+const (
+	OutputEventText     = "text"
+	OutputEventToolCall = "tool_call"
+	OutputEventFinish   = "finish"
+)
+
+// OutputEvent is a single line written by the JSON sink.
+type OutputEvent struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ToolCall  *OutputToolCall `json:"tool_call,omitempty"`
+	SessionID string          `json:"session_id,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	Summary   *OutputSummary  `json:"summary,omitempty"`
+}
+
+// OutputSummary describes the usage of a run in a finish OutputEvent.
+type OutputSummary struct {
+	InputTokens  int64   `json:"input_tokens"`
+	OutputTokens int64   `json:"output_tokens"`
+	TotalTokens  int64   `json:"total_tokens"`
+	Cost         float64 `json:"cost"`
+	Steps        int     `json:"steps"`
+	DurationMS   int64   `json:"duration_ms"`
+}
+
+// OutputToolCall describes a tool call in an OutputEvent.
+type OutputToolCall struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Input string `json:"input"`
+}
+
+// jsonSink writes each piece of output as a JSONL OutputEvent
+type jsonSink struct {
+	enc *json.Encoder
+}
+
+// NewJSONSink returns a sink that writes one JSON OutputEvent per line to w.
+func NewJSONSink(w io.Writer) OutputSink {
+	return &jsonSink{enc: json.NewEncoder(w)}
+}
+
+func (s *jsonSink) WriteAssistantDelta(text string) error {
+	return s.enc.Encode(OutputEvent{Type: OutputEventText, Text: text})
+}
+
+func (s *jsonSink) WriteToolCall(call message.ToolCall) error {
+	return s.enc.Encode(OutputEvent{
+		Type:     OutputEventToolCall,
+		ToolCall: &OutputToolCall{ID: call.ID, Name: call.Name, Input: call.Input},
+	})
+}
+
+func (s *jsonSink) Finish(result RunResult) error {
+	event := OutputEvent{Type: OutputEventFinish, SessionID: result.SessionID}
+	if result.Err != nil {
+		event.Error = result.Err.Error()
+	}
+	if summary := result.Summary; summary != nil {
+		event.Summary = &OutputSummary{
+			InputTokens:  summary.InputTokens,
+			OutputTokens: summary.OutputTokens,
+			TotalTokens:  summary.InputTokens + summary.OutputTokens,
+			Cost:         summary.Cost,
+			Steps:        summary.Steps,
+			DurationMS:   summary.Duration.Milliseconds(),
+		}
+	}
+	return s.enc.Encode(event)
+}
+
+// summarySink writes a footer with the run's summary when it finishes
+type summarySink struct {
+	out io.Writer
+}
+
+// NewSummarySink returns a sink that ignores the output and writes the
+// run's summary, if any, to w when it finishes.
+func NewSummarySink(w io.Writer) OutputSink {
+	return &summarySink{out: w}
+}
+
+func (s *summarySink) WriteAssistantDelta(text string) error { return nil }
+
+func (s *summarySink) WriteToolCall(call message.ToolCall) error { return nil }
+
+func (s *summarySink) Finish(result RunResult) error {
+	if result.Summary == nil {
+		return nil
+	}
+	_, err := fmt.Fprintf(s.out, "\n%s\n", result.Summary)
+	return err
+}
+
+// teeSink forwards output to several sinks
+type teeSink []OutputSink
+
+// NewTeeSink returns a sink that forwards all output to each of sinks. Every
+// sink receives every call even if an earlier one fails; the errors are
+// joined.
+func NewTeeSink(sinks ...OutputSink) OutputSink {
+	return teeSink(sinks)
+}
+
+func (t teeSink) WriteAssistantDelta(text string) error {
+	return t.each(func(s OutputSink) error { return s.WriteAssistantDelta(text) })
+}
+
+func (t teeSink) WriteToolCall(call message.ToolCall) error {
+	return t.each(func(s OutputSink) error { return s.WriteToolCall(call) })
+}
+
+func (t teeSink) Finish(result RunResult) error {
+	return t.each(func(s OutputSink) error { return s.Finish(result) })
+}
+
+func (t teeSink) each(fn func(OutputSink) error) error {
+	var errs []error
+	for _, sink := range t {
+		if err := fn(sink); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// toolNotice summarizes a tool call for non-interactive output, e.g.
+// "→ running grep path=. pattern=TODO"
+func toolNotice(call message.ToolCall) string {
+	const maxLength = 100
+
+	notice := "→ running " + call.Name
+	var params map[string]any
+	if err := json.Unmarshal([]byte(call.Input), &params); err == nil {
+		for _, key := range slices.Sorted(maps.Keys(params)) {
+			switch value := params[key].(type) {
+			case string, float64, bool:
+				notice += fmt.Sprintf(" %s=%v", key, value)
+			}
+		}
+	}
+	notice = strings.ReplaceAll(notice, "\n", " ")
+	if runes := []rune(notice); len(runes) > maxLength {
+		notice = string(runes[:maxLength]) + " …"
+	}
+	return notice
+}
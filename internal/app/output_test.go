@@ -0,0 +1,67 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputSinks(t *testing.T) {
+	t.Parallel()
+
+	var text, notices, jsonl bytes.Buffer
+	sink := NewTeeSink(NewTextSink(&text, &notices), NewJSONSink(&jsonl))
+
+	require.NoError(t, sink.WriteAssistantDelta("Hello, "))
+	require.NoError(t, sink.WriteToolCall(message.ToolCall{ID: "call-1", Name: "grep", Input: `{"pattern":"TODO"}`}))
+	require.NoError(t, sink.WriteAssistantDelta("world"))
+	require.NoError(t, sink.Finish(RunResult{SessionID: "session", Err: errors.New("boom")}))
+
+	require.Equal(t, "Hello, world", text.String())
+	require.Equal(t, "→ running grep pattern=TODO\n", notices.String())
+	require.Equal(t, `{"type":"text","text":"Hello, "}
+{"type":"tool_call","tool_call":{"id":"call-1","name":"grep","input":"{\"pattern\":\"TODO\"}"}}
+{"type":"text","text":"world"}
+{"type":"finish","session_id":"session","error":"boom"}
+`, jsonl.String())
+}
+
+func TestRunSummary(t *testing.T) {
+	t.Parallel()
+
+	var footer, jsonl bytes.Buffer
+	sink := NewTeeSink(NewSummarySink(&footer), NewJSONSink(&jsonl))
+
+	summary := &RunSummary{InputTokens: 1200, OutputTokens: 300, Cost: 0.012, Steps: 3, Duration: 12345 * time.Millisecond}
+	require.NoError(t, sink.WriteAssistantDelta("Done"))
+	require.NoError(t, sink.Finish(RunResult{SessionID: "session", Summary: summary}))
+
+	require.Equal(t, "\n1200 input + 300 output tokens, $0.0120, 3 steps, 12.3s\n", footer.String())
+	require.Equal(t, `{"type":"text","text":"Done"}
+{"type":"finish","session_id":"session","summary":{"input_tokens":1200,"output_tokens":300,"total_tokens":1500,"cost":0.012,"steps":3,"duration_ms":12345}}
+`, jsonl.String())
+
+	// Without a summary, nothing is written
+	footer.Reset()
+	require.NoError(t, NewSummarySink(&footer).Finish(RunResult{SessionID: "session"}))
+	require.Empty(t, footer.String())
+}
+
+func TestProgressOutput(t *testing.T) {
+	t.Parallel()
+
+	// Progress escapes must never reach piped output, JSON output included
+	piped, err := os.Create(filepath.Join(t.TempDir(), "stderr"))
+	require.NoError(t, err)
+	t.Cleanup(func() { piped.Close() })
+
+	require.Nil(t, progressOutput(NonInteractiveOptions{}, piped))
+	require.Nil(t, progressOutput(NonInteractiveOptions{Sink: NewJSONSink(piped)}, piped))
+	require.Nil(t, progressOutput(NonInteractiveOptions{Quiet: true}, piped))
+}
@@ -0,0 +1,140 @@
+// Package audit implements a built-in plugin that records every tool
+// execution, including its full input and output, in the database.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/plugin"
+)
+
+// store is the subset of db.Querier used by the audit plugin
+type store interface {
+	CreateToolExecution(ctx context.Context, arg db.CreateToolExecutionParams) error
+	DeleteToolExecutionsBefore(ctx context.Context, createdAt int64) (int64, error)
+	ListToolExecutionsBySession(ctx context.Context, sessionID string) ([]db.ToolExecution, error)
+}
+
+// Plugin implements the Crush plugin interface for the tool audit
+type Plugin struct {
+	plugin.NilToolHook
+
+	info      plugin.PluginInfo
+	hooks     *plugin.BaseHooks
+	store     store
+	retention time.Duration
+}
+
+// NewPlugin returns a plugin that records tool executions in store.
+func NewPlugin(store store, opts config.ToolAudit) *Plugin {
+	p := &Plugin{
+		info: plugin.PluginInfo{
+			Name:        "crush-tool-audit",
+			Version:     "1.0.0",
+			Description: "Records tool executions with their arguments and results",
+			Author:      "Crush Team",
+			Homepage:    "https://github.com/charmbracelet/crush",
+			License:     "FSL-1.1-MIT",
+			Tags:        []string{"audit", "builtin"},
+		},
+		hooks:     plugin.NewBaseHooks(),
+		store:     store,
+		retention: time.Duration(opts.RetentionDays) * 24 * time.Hour,
+	}
+	p.hooks.ToolHook = p
+	return p
+}
+
+// Info returns metadata about the plugin
+func (p *Plugin) Info() plugin.PluginInfo {
+	return p.info
+}
+
+// Init is called when the plugin is loaded. Records older than the
+// retention period are pruned.
+func (p *Plugin) Init(ctx context.Context, pluginCtx plugin.PluginContext) error {
+	if p.retention <= 0 {
+		return nil
+	}
+	pruned, err := p.Prune(ctx, time.Now().Add(-p.retention))
+	if err != nil {
+		return err
+	}
+	if pruned > 0 {
+		slog.Info("Pruned tool execution records", "count", pruned)
+	}
+	return nil
+}
+
+// Hooks returns the hook implementations provided by this plugin
+func (p *Plugin) Hooks() plugin.Hooks {
+	return p.hooks
+}
+
+// Shutdown is called when the application is shutting down
+func (p *Plugin) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// Prune deletes records created before cutoff and returns how many were
+// deleted.
+func (p *Plugin) Prune(ctx context.Context, cutoff time.Time) (int64, error) {
+	pruned, err := p.store.DeleteToolExecutionsBefore(ctx, cutoff.Unix())
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune tool executions: %w", err)
+	}
+	return pruned, nil
+}
+
+// List returns the recorded tool executions of a session, oldest first.
+func (p *Plugin) List(ctx context.Context, sessionID string) ([]db.ToolExecution, error) {
+	executions, err := p.store.ListToolExecutionsBySession(ctx, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tool executions for session %s: %w", sessionID, err)
+	}
+	return executions, nil
+}
+
+// OnToolExecuteAfter records the execution. Recording failures are logged
+// rather than returned so that they never affect the tool result.
+func (p *Plugin) OnToolExecuteAfter(ctx context.Context, input plugin.ToolExecuteInput, result plugin.ToolExecuteResult) (*plugin.ToolExecuteResult, error) {
+	params := db.CreateToolExecutionParams{
+		SessionID:  input.SessionID,
+		MessageID:  input.MessageID,
+		ToolCallID: input.ToolCallID,
+		ToolName:   input.ToolName,
+		Arguments:  arguments(input),
+		Output:     result.Output,
+	}
+	if result.Error != nil {
+		params.Error = result.Error.Error()
+	}
+	if isError, _ := result.Metadata[plugin.MetadataIsError].(bool); isError || result.Error != nil {
+		params.IsError = 1
+	}
+	params.DurationMs, _ = result.Metadata[plugin.MetadataDurationMS].(int64)
+
+	if err := p.store.CreateToolExecution(ctx, params); err != nil {
+		slog.Error("Failed to record tool execution", "tool", input.ToolName, "error", err)
+	}
+	return nil, nil
+}
+
+// arguments returns the JSON input the tool ran with
+func arguments(input plugin.ToolExecuteInput) string {
+	if len(input.RawArguments) > 0 {
+		return string(input.RawArguments)
+	}
+	if input.Arguments != nil {
+		if encoded, err := json.Marshal(input.Arguments); err == nil {
+			return string(encoded)
+		}
+	}
+	return "{}"
+}
@@ -0,0 +1,66 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/charmbracelet/crush/internal/plugin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolAudit(t *testing.T) {
+	t.Parallel()
+
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	q := db.New(conn)
+	_, err = q.CreateSession(t.Context(), db.CreateSessionParams{ID: "session", Title: "audit"})
+	require.NoError(t, err)
+
+	p := NewPlugin(q, config.ToolAudit{Enabled: true, RetentionDays: 7})
+	require.NoError(t, p.Init(t.Context(), plugin.PluginContext{}))
+
+	input := plugin.ToolExecuteInput{
+		ToolName:     "view",
+		SessionID:    "session",
+		MessageID:    "message",
+		ToolCallID:   "call-1",
+		RawArguments: json.RawMessage(`{"file_path":"main.go"}`),
+	}
+	result, err := p.OnToolExecuteAfter(t.Context(), input, plugin.ToolExecuteResult{
+		Output:   "package main",
+		Metadata: map[string]any{plugin.MetadataDurationMS: int64(12), plugin.MetadataIsError: false},
+	})
+	require.NoError(t, err)
+	require.Nil(t, result, "auditing must not modify the result")
+
+	input.ToolCallID = "call-2"
+	input.RawArguments = nil
+	input.Arguments = map[string]any{"command": "false"}
+	_, err = p.OnToolExecuteAfter(t.Context(), input, plugin.ToolExecuteResult{Error: errors.New("exit status 1")})
+	require.NoError(t, err)
+
+	executions, err := p.List(t.Context(), "session")
+	require.NoError(t, err)
+	require.Len(t, executions, 2)
+	require.Equal(t, "call-1", executions[0].ToolCallID)
+	require.Equal(t, `{"file_path":"main.go"}`, executions[0].Arguments)
+	require.Equal(t, "package main", executions[0].Output)
+	require.Equal(t, int64(12), executions[0].DurationMs)
+	require.Zero(t, executions[0].IsError)
+	require.JSONEq(t, `{"command":"false"}`, executions[1].Arguments)
+	require.Equal(t, "exit status 1", executions[1].Error)
+	require.Equal(t, int64(1), executions[1].IsError)
+
+	pruned, err := p.Prune(t.Context(), time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	require.Zero(t, pruned)
+	pruned, err = p.Prune(t.Context(), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, int64(2), pruned)
+}
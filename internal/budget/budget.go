@@ -0,0 +1,290 @@
+// Package budget implements a built-in plugin that stops agent runs once a
+// session has spent its cost budget.
+package budget
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/plugin"
+	"github.com/charmbracelet/crush/internal/session"
+)
+
+// sessionKeyPrefix prefixes the store keys of session state
+const sessionKeyPrefix = "session:"
+
+// sessionState is what the plugin persists about a session
+type sessionState struct {
+	// Spent is the cost of the session's agent steps in USD
+	Spent float64 `json:"spent"`
+
+	// Budget is the session's budget set with the budget command, if any;
+	// it replaces the configured budgets
+	Budget *float64 `json:"budget,omitempty"`
+}
+
+// Plugin implements the Crush plugin interface for the cost budget
+type Plugin struct {
+	plugin.NilAgentHook
+
+	info    plugin.PluginInfo
+	hooks   *plugin.BaseHooks
+	maxCost float64
+	budgets map[string]float64
+
+	mu       sync.Mutex
+	store    plugin.KVStore           // nil if state isn't persisted
+	states   map[string]*sessionState // loaded or updated state by session ID
+	sessions session.Service          // nil if child sessions aren't resolved
+	roots    map[string]string        // top-level session by child session ID
+}
+
+// NewPlugin returns a plugin that enforces the budgets of opts.
+func NewPlugin(opts config.CostBudget) *Plugin {
+	p := &Plugin{
+		info: plugin.PluginInfo{
+			Name:        "crush-cost-budget",
+			Version:     "1.0.0",
+			Description: "Stops agent runs once a session has spent its cost budget",
+			Author:      "Crush Team",
+			Homepage:    "https://github.com/charmbracelet/crush",
+			License:     "FSL-1.1-MIT",
+			Tags:        []string{"cost", "builtin"},
+		},
+		hooks:   plugin.NewBaseHooks(),
+		maxCost: opts.MaxCost,
+		budgets: opts.Sessions,
+		states:  make(map[string]*sessionState),
+		roots:   make(map[string]string),
+	}
+	p.hooks.AgentHook = p
+	return p
+}
+
+// Info returns metadata about the plugin
+func (p *Plugin) Info() plugin.PluginInfo {
+	return p.info
+}
+
+// Init is called when the plugin is loaded
+func (p *Plugin) Init(ctx context.Context, pluginCtx plugin.PluginContext) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.store = pluginCtx.Store
+	p.sessions = pluginCtx.Services.Session
+	if p.store == nil {
+		slog.Warn("Cost budget state is not persisted; spending starts over on restart")
+	}
+	return nil
+}
+
+// Hooks returns the hook implementations provided by this plugin
+func (p *Plugin) Hooks() plugin.Hooks {
+	return p.hooks
+}
+
+// Shutdown is called when the application is shutting down
+func (p *Plugin) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// GetCommands implements plugin.CommandProvider
+func (p *Plugin) GetCommands() []plugin.PluginCommand {
+	return []plugin.PluginCommand{{
+		Name:        "budget",
+		Description: "Show what the session has spent, or set its cost budget in USD (0 resets it)",
+		Args:        []string{"BUDGET"},
+		Handler:     p.runCommand,
+	}}
+}
+
+// OnAgentStart implements plugin.AgentHook, refusing runs of sessions that
+// have spent their budget
+func (p *Plugin) OnAgentStart(ctx context.Context, input plugin.AgentStartInput) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	sessionID, err := p.root(ctx, input.SessionID)
+	if err != nil {
+		return err
+	}
+	state, err := p.state(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	p.enforce(ctx, sessionID, state)
+	return nil
+}
+
+// OnAgentStep implements plugin.AgentHook, adding the step's cost to the
+// session and stopping the run once the budget is spent. Steps of child
+// sessions, like those of sub-agents, are charged to their top-level
+// session.
+func (p *Plugin) OnAgentStep(ctx context.Context, input plugin.AgentStepInput) error {
+	if input.Cost <= 0 {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	sessionID, err := p.root(ctx, input.SessionID)
+	if err != nil {
+		return err
+	}
+	state, err := p.state(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	state.Spent += input.Cost
+	if err := p.save(ctx, sessionID, state); err != nil {
+		return err
+	}
+	p.enforce(ctx, sessionID, state)
+	return nil
+}
+
+// Spent returns what the session has spent in USD and its budget, which is
+// 0 if it has none. For child sessions, those of their top-level session are
+// returned.
+func (p *Plugin) Spent(ctx context.Context, sessionID string) (spent, budget float64, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	sessionID, err = p.root(ctx, sessionID)
+	if err != nil {
+		return 0, 0, err
+	}
+	state, err := p.state(ctx, sessionID)
+	if err != nil {
+		return 0, 0, err
+	}
+	return state.Spent, p.budget(sessionID, state), nil
+}
+
+// SetBudget sets the budget in USD of the session, or of its top-level
+// session, replacing the configured budgets. A budget of 0 resets it to the
+// configured budget.
+func (p *Plugin) SetBudget(ctx context.Context, sessionID string, budget float64) error {
+	if budget < 0 {
+		return fmt.Errorf("budget must not be negative: %v", budget)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	sessionID, err := p.root(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	state, err := p.state(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	state.Budget = nil
+	if budget > 0 {
+		state.Budget = &budget
+	}
+	return p.save(ctx, sessionID, state)
+}
+
+// enforce aborts the run of ctx if the session has spent its budget. The
+// caller must hold p.mu.
+func (p *Plugin) enforce(ctx context.Context, sessionID string, state *sessionState) {
+	budget := p.budget(sessionID, state)
+	if budget <= 0 || state.Spent < budget {
+		return
+	}
+	slog.Info("Session cost budget exceeded", "session_id", sessionID, "spent", state.Spent, "budget", budget)
+	plugin.Abort(ctx, fmt.Sprintf("session has spent $%.2f of its $%.2f cost budget; raise it with /budget to continue", state.Spent, budget))
+}
+
+// budget returns the session's budget, or 0 if it has none
+func (p *Plugin) budget(sessionID string, state *sessionState) float64 {
+	if state.Budget != nil {
+		return *state.Budget
+	}
+	if budget, ok := p.budgets[sessionID]; ok {
+		return budget
+	}
+	return p.maxCost
+}
+
+// root returns the top-level session of a child session, or the session
+// itself if it has no parent. The caller must hold p.mu.
+func (p *Plugin) root(ctx context.Context, sessionID string) (string, error) {
+	if p.sessions == nil {
+		return sessionID, nil
+	}
+	if root, ok := p.roots[sessionID]; ok {
+		return root, nil
+	}
+	root := sessionID
+	for seen := map[string]bool{}; !seen[root]; {
+		seen[root] = true
+		sess, err := p.sessions.Get(ctx, root)
+		if err != nil {
+			return "", fmt.Errorf("failed to get session %s: %w", root, err)
+		}
+		if sess.ParentSessionID == "" {
+			break
+		}
+		root = sess.ParentSessionID
+	}
+	p.roots[sessionID] = root
+	return root, nil
+}
+
+// state returns the session's state, loading it from the store the first
+// time. The caller must hold p.mu.
+func (p *Plugin) state(ctx context.Context, sessionID string) (*sessionState, error) {
+	if state, ok := p.states[sessionID]; ok {
+		return state, nil
+	}
+	state := &sessionState{}
+	if p.store != nil {
+		if _, err := p.store.Get(ctx, sessionKeyPrefix+sessionID, state); err != nil {
+			return nil, fmt.Errorf("failed to load cost budget of session %s: %w", sessionID, err)
+		}
+	}
+	p.states[sessionID] = state
+	return state, nil
+}
+
+// save persists the session's state. The caller must hold p.mu.
+func (p *Plugin) save(ctx context.Context, sessionID string, state *sessionState) error {
+	if p.store == nil {
+		return nil
+	}
+	if err := p.store.Set(ctx, sessionKeyPrefix+sessionID, state); err != nil {
+		return fmt.Errorf("failed to save cost budget of session %s: %w", sessionID, err)
+	}
+	return nil
+}
+
+// runCommand shows or sets the budget of the session the command was run in
+func (p *Plugin) runCommand(ctx context.Context, args []string, out io.Writer) error {
+	sessionID := plugin.SessionID(ctx)
+	if sessionID == "" {
+		return fmt.Errorf("no session selected")
+	}
+	if len(args) > 0 && strings.TrimSpace(args[0]) != "" {
+		budget, err := strconv.ParseFloat(strings.TrimPrefix(strings.TrimSpace(args[0]), "$"), 64)
+		if err != nil {
+			return fmt.Errorf("invalid budget %q: %w", args[0], err)
+		}
+		if err := p.SetBudget(ctx, sessionID, budget); err != nil {
+			return err
+		}
+	}
+	spent, budget, err := p.Spent(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if budget <= 0 {
+		fmt.Fprintf(out, "Spent $%.2f; this session has no cost budget\n", spent)
+		return nil
+	}
+	fmt.Fprintf(out, "Spent $%.2f of the $%.2f cost budget\n", spent, budget)
+	return nil
+}
@@ -0,0 +1,157 @@
+package budget
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/agent/tools"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/plugin"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore is a plugin.KVStore kept in memory
+type memoryStore struct {
+	mu     sync.Mutex
+	values map[string][]byte
+}
+
+func (s *memoryStore) Get(ctx context.Context, key string, v any) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.values[key]
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+func (s *memoryStore) Set(ctx context.Context, key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.values == nil {
+		s.values = make(map[string][]byte)
+	}
+	s.values[key] = data
+	return nil
+}
+
+func (s *memoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.values, key)
+	return nil
+}
+
+// runContext returns a context of an agent run whose abort reason is
+// recorded in reason
+func runContext(t *testing.T, reason *string) context.Context {
+	return plugin.WithAbort(t.Context(), func(r string) bool {
+		*reason = r
+		return true
+	})
+}
+
+func TestCostBudget(t *testing.T) {
+	t.Parallel()
+
+	store := &memoryStore{}
+	opts := config.CostBudget{MaxCost: 1, Sessions: map[string]float64{"big": 10}}
+	p := NewPlugin(opts)
+	require.NoError(t, p.Init(t.Context(), plugin.PluginContext{Store: store}))
+
+	var reason string
+	ctx := runContext(t, &reason)
+	require.NoError(t, p.OnAgentStart(ctx, plugin.AgentStartInput{SessionID: "small"}))
+	require.NoError(t, p.OnAgentStep(ctx, plugin.AgentStepInput{SessionID: "small", Cost: 0.6}))
+	require.Empty(t, reason)
+	require.NoError(t, p.OnAgentStep(ctx, plugin.AgentStepInput{SessionID: "small", Cost: 0.6}))
+	require.Contains(t, reason, "$1.20 of its $1.00 cost budget")
+
+	// Sessions with their own budget aren't limited by max_cost
+	reason = ""
+	require.NoError(t, p.OnAgentStep(ctx, plugin.AgentStepInput{SessionID: "big", Cost: 2}))
+	require.Empty(t, reason)
+
+	// Spending survives a restart, so the next run is refused right away
+	p = NewPlugin(opts)
+	require.NoError(t, p.Init(t.Context(), plugin.PluginContext{Store: store}))
+	require.NoError(t, p.OnAgentStart(ctx, plugin.AgentStartInput{SessionID: "small"}))
+	require.Contains(t, reason, "cost budget")
+	spent, budget, err := p.Spent(t.Context(), "small")
+	require.NoError(t, err)
+	require.InDelta(t, 1.2, spent, 1e-9)
+	require.Equal(t, 1.0, budget)
+
+	// Raising the budget lets the session continue
+	reason = ""
+	require.NoError(t, p.SetBudget(t.Context(), "small", 5))
+	require.NoError(t, p.OnAgentStart(ctx, plugin.AgentStartInput{SessionID: "small"}))
+	require.Empty(t, reason)
+	require.Error(t, p.SetBudget(t.Context(), "small", -1))
+
+	p = NewPlugin(opts)
+	require.NoError(t, p.Init(t.Context(), plugin.PluginContext{Store: store}))
+	_, budget, err = p.Spent(t.Context(), "small")
+	require.NoError(t, err)
+	require.Equal(t, 5.0, budget, "budgets set for a session must be persisted")
+}
+
+// sessionTree is a session.Service that only knows sessions' parents
+type sessionTree struct {
+	session.Service
+	parents map[string]string
+}
+
+func (s *sessionTree) Get(ctx context.Context, id string) (session.Session, error) {
+	return session.Session{ID: id, ParentSessionID: s.parents[id]}, nil
+}
+
+func TestCostBudgetChildSessions(t *testing.T) {
+	t.Parallel()
+
+	p := NewPlugin(config.CostBudget{MaxCost: 1})
+	tree := &sessionTree{parents: map[string]string{"child": "parent", "grandchild": "child"}}
+	require.NoError(t, p.Init(t.Context(), plugin.PluginContext{Services: plugin.Services{Session: tree}}))
+
+	var reason string
+	ctx := runContext(t, &reason)
+	require.NoError(t, p.OnAgentStep(ctx, plugin.AgentStepInput{SessionID: "parent", Cost: 0.5}))
+	require.NoError(t, p.OnAgentStep(ctx, plugin.AgentStepInput{SessionID: "grandchild", Cost: 0.6}))
+	require.Contains(t, reason, "$1.10 of its $1.00 cost budget", "child sessions are charged to the top-level session")
+
+	spent, _, err := p.Spent(t.Context(), "child")
+	require.NoError(t, err)
+	require.InDelta(t, 1.1, spent, 1e-9)
+}
+
+func TestBudgetCommand(t *testing.T) {
+	t.Parallel()
+
+	p := NewPlugin(config.CostBudget{})
+	require.NoError(t, p.Init(t.Context(), plugin.PluginContext{}))
+	commands := p.GetCommands()
+	require.Len(t, commands, 1)
+	run := commands[0].Handler
+
+	var out strings.Builder
+	require.Error(t, run(t.Context(), nil, &out), "the command needs a session")
+
+	ctx := context.WithValue(t.Context(), tools.SessionIDContextKey, "session")
+	require.NoError(t, run(ctx, []string{""}, &out))
+	require.Equal(t, "Spent $0.00; this session has no cost budget\n", out.String())
+
+	out.Reset()
+	require.NoError(t, run(ctx, []string{"$2.50"}, &out))
+	require.Equal(t, "Spent $0.00 of the $2.50 cost budget\n", out.String())
+
+	require.Error(t, run(ctx, []string{"lots"}, &out))
+}
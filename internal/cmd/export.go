@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/crush/internal/format"
+	"github.com/spf13/cobra"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export [session-id]",
+	Short: "Export a session transcript as Markdown or HTML",
+	Long: `Export a session's conversation, including tool calls and their results,
+as a human-readable Markdown or HTML transcript. Without a session ID, the most
+recent session is exported.`,
+	Example: `
+# Print the most recent session as Markdown
+crush export
+
+# Save a session as HTML
+crush export 1f2e3d4c --format html --output session.html
+  `,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		exportFormat, _ := cmd.Flags().GetString("format")
+		output, _ := cmd.Flags().GetString("output")
+
+		app, err := setupApp(cmd)
+		if err != nil {
+			return err
+		}
+		defer app.Shutdown()
+
+		var sessionID string
+		if len(args) > 0 {
+			sessionID = args[0]
+		} else {
+			sessions, err := app.Sessions.List(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("failed to list sessions: %w", err)
+			}
+			if len(sessions) == 0 {
+				return fmt.Errorf("no sessions to export")
+			}
+			sessionID = sessions[0].ID
+		}
+
+		bts, err := app.ExportSession(cmd.Context(), sessionID, exportFormat)
+		if err != nil {
+			return err
+		}
+
+		if output == "" {
+			_, err = os.Stdout.Write(bts)
+			return err
+		}
+		if err := os.WriteFile(output, bts, 0o644); err != nil {
+			return fmt.Errorf("failed to write transcript: %w", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	exportCmd.Flags().StringP("format", "f", string(format.Markdown), "Transcript format (markdown or html)")
+	exportCmd.Flags().StringP("output", "o", "", "Write the transcript to a file instead of stdout")
+}
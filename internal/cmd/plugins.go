@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/charmbracelet/crush/internal/app"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var pluginsCmd = &cobra.Command{
+	Use:   "plugins",
+	Short: "Inspect Crush plugins",
+	Long:  `Inspect the plugins Crush loads, including the hooks they implement and the tools they contribute.`,
+	Example: `
+# List loaded plugins as JSON
+crush plugins list
+
+# Check plugins and skills before deploying
+crush plugins validate
+  `,
+}
+
+var pluginsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List loaded plugins as JSON",
+	Long: `Load all configured plugins and print, as JSON, each plugin's metadata,
+the hooks it implements, the tools it contributes and their annotations, and
+its health.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		app, err := setupApp(cmd)
+		if err != nil {
+			return err
+		}
+		defer app.Shutdown()
+
+		bts, err := app.PluginsJSON()
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(bts))
+		return nil
+	},
+}
+
+var pluginsValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check configured plugins and skills without running them",
+	Long: `Check that every configured plugin can be opened, exports a Plugin symbol,
+and matches its manifest and SDK version, and that every SKILL.md parses.
+Plugins are not initialized and the agent is not started. Exits with an error
+if any item is invalid, so it can be used as a pre-flight check in CI.`,
+	Example: `
+# Validate and print a table
+crush plugins validate
+
+# Validate and print the results as JSON
+crush plugins validate --json
+  `,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, _ := cmd.Flags().GetBool("json")
+		dataDir, _ := cmd.Flags().GetString("data-dir")
+
+		cwd, err := ResolveCwd(cmd)
+		if err != nil {
+			return err
+		}
+		cfg, err := config.Load(cwd, dataDir, false)
+		if err != nil {
+			return fmt.Errorf("failed to load configuration: %w", err)
+		}
+
+		results := app.ValidatePlugins(cmd.Context(), cfg)
+
+		if asJSON {
+			bts, err := json.MarshalIndent(results, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(bts))
+		} else {
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			for _, result := range results {
+				status := "OK"
+				if !result.OK() {
+					status = "FAIL"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", status, result.Kind, result.Name, result.Path)
+				if !result.OK() {
+					fmt.Fprintf(w, "\t\t\t%s\n", result.Error)
+				}
+			}
+			w.Flush()
+		}
+
+		var failed int
+		for _, result := range results {
+			if !result.OK() {
+				failed++
+			}
+		}
+		if failed > 0 {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("%d of %d item(s) failed validation", failed, len(results))
+		}
+		return nil
+	},
+}
+
+func init() {
+	pluginsValidateCmd.Flags().Bool("json", false, "Print the results as JSON")
+	pluginsCmd.AddCommand(pluginsListCmd, pluginsValidateCmd)
+}
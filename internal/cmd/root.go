@@ -32,6 +32,8 @@ func init() {
 	rootCmd.PersistentFlags().StringP("cwd", "c", "", "Current working directory")
 	rootCmd.PersistentFlags().StringP("data-dir", "D", "", "Custom crush data directory")
 	rootCmd.PersistentFlags().BoolP("debug", "d", false, "Debug")
+	rootCmd.PersistentFlags().String("plugin-profile", "", "Plugin profile to load, overriding options.plugins.profile")
+	rootCmd.PersistentFlags().Bool("safe-mode", false, "Start without skills and configured plugins (also CRUSH_SAFE_MODE=1)")
 
 	rootCmd.Flags().BoolP("help", "h", false, "Help")
 	rootCmd.Flags().BoolP("yolo", "y", false, "Automatically accept all permissions (dangerous mode)")
@@ -42,6 +44,9 @@ func init() {
 		updateProvidersCmd,
 		logsCmd,
 		schemaCmd,
+		pluginsCmd,
+		exportCmd,
+		toolCmd,
 	)
 }
 
@@ -84,7 +89,7 @@ crush -y
 
 		// Set up the TUI.
 		var env uv.Environ = os.Environ()
-		ui := tui.New(app)
+		ui := tui.New(cmd.Context(), app)
 		ui.QueryVersion = shouldQueryTerminalVersion(env)
 
 		program := tea.NewProgram(
@@ -156,6 +161,11 @@ func setupApp(cmd *cobra.Command) (*app.App, error) {
 	debug, _ := cmd.Flags().GetBool("debug")
 	yolo, _ := cmd.Flags().GetBool("yolo")
 	dataDir, _ := cmd.Flags().GetString("data-dir")
+	pluginProfile, _ := cmd.Flags().GetString("plugin-profile")
+	safeMode, _ := cmd.Flags().GetBool("safe-mode")
+	if v, _ := strconv.ParseBool(os.Getenv("CRUSH_SAFE_MODE")); v {
+		safeMode = true
+	}
 	ctx := cmd.Context()
 
 	cwd, err := ResolveCwd(cmd)
@@ -173,6 +183,15 @@ func setupApp(cmd *cobra.Command) (*app.App, error) {
 	}
 	cfg.Permissions.SkipRequests = yolo
 
+	cfg.Options.SafeMode = safeMode
+
+	if pluginProfile != "" {
+		if cfg.Options.Plugins == nil {
+			cfg.Options.Plugins = &config.PluginOptions{}
+		}
+		cfg.Options.Plugins.Profile = pluginProfile
+	}
+
 	if err := createDotCrushDir(cfg.Options.DataDirectory); err != nil {
 		return nil, err
 	}
@@ -2,9 +2,12 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"log/slog"
+	"os"
 	"strings"
 
+	appPkg "github.com/charmbracelet/crush/internal/app"
 	"github.com/spf13/cobra"
 )
 
@@ -12,7 +15,12 @@ var runCmd = &cobra.Command{
 	Use:   "run [prompt...]",
 	Short: "Run a single non-interactive prompt",
 	Long: `Run a single prompt in non-interactive mode and exit.
-The prompt can be provided as arguments or piped from stdin.`,
+The prompt can be provided as arguments or piped from stdin.
+
+With --batch, prompts are read from stdin as JSONL, one object per line:
+  {"id": "1", "session": "optional-label", "prompt": "..."}
+Inputs sharing a session label run sequentially in the same session. One JSONL
+result is written to stdout per input, echoing its id.`,
 	Example: `
 # Run a simple prompt
 crush run Explain the use of context in Go
@@ -22,9 +30,28 @@ echo "What is this code doing?" | crush run
 
 # Run with quiet mode (no spinner)
 crush run -q "Generate a README for this project"
+
+# Print the response without the usage summary
+crush run --no-summary "Summarize CHANGELOG.md"
+
+# Show the tools the agent runs on stderr
+crush run --show-tools "Find all TODOs in this project"
+
+# Stream the response, tool calls, and summary as JSONL events
+crush run --json "List the exported functions in main.go"
+
+# Allow file edits but deny everything else that needs permission, e.g. bash
+crush run --allow-tools edit,write "Fix the typo in README.md"
+
+# Run many prompts from JSONL on stdin, emitting JSONL results
+cat prompts.jsonl | crush run --batch --concurrency 4
   `,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		quiet, _ := cmd.Flags().GetBool("quiet")
+		allowTools, _ := cmd.Flags().GetStringSlice("allow-tools")
+		showTools, _ := cmd.Flags().GetBool("show-tools")
+		batch, _ := cmd.Flags().GetBool("batch")
+		noSummary, _ := cmd.Flags().GetBool("no-summary")
 
 		app, err := setupApp(cmd)
 		if err != nil {
@@ -36,6 +63,16 @@ crush run -q "Generate a README for this project"
 			return fmt.Errorf("no providers configured - please run 'crush' to set up a provider interactively")
 		}
 
+		if batch {
+			concurrency, _ := cmd.Flags().GetInt("concurrency")
+			stopOnError, _ := cmd.Flags().GetBool("stop-on-error")
+			return app.RunBatch(cmd.Context(), os.Stdin, os.Stdout, appPkg.BatchOptions{
+				Concurrency:  concurrency,
+				StopOnError:  stopOnError,
+				AllowedTools: allowTools,
+			})
+		}
+
 		prompt := strings.Join(args, " ")
 
 		prompt, err = MaybePrependStdin(prompt)
@@ -49,10 +86,32 @@ crush run -q "Generate a README for this project"
 		}
 
 		// Run non-interactive flow using the App method
-		return app.RunNonInteractive(cmd.Context(), prompt, quiet)
+		return app.RunNonInteractive(cmd.Context(), prompt, appPkg.NonInteractiveOptions{
+			Quiet:        quiet,
+			AllowedTools: allowTools,
+			ShowTools:    showTools,
+			NoSummary:    noSummary,
+			Sink:         outputSink(cmd, os.Stdout),
+		})
 	},
 }
 
+// outputSink returns the sink selected by the run command's flags, or nil
+// for the default text output
+func outputSink(cmd *cobra.Command, stdout io.Writer) appPkg.OutputSink {
+	if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+		return appPkg.NewJSONSink(stdout)
+	}
+	return nil
+}
+
 func init() {
 	runCmd.Flags().BoolP("quiet", "q", false, "Hide spinner")
+	runCmd.Flags().Bool("show-tools", false, "Print a notice to stderr for each tool the agent runs")
+	runCmd.Flags().Bool("no-summary", false, "Don't print the token usage, cost, and timing summary to stderr")
+	runCmd.Flags().Bool("json", false, "Write the output to stdout as JSONL events instead of plain text")
+	runCmd.Flags().StringSlice("allow-tools", nil, "Only auto-approve these tools (or tool:action pairs) and deny other permission requests")
+	runCmd.Flags().Bool("batch", false, "Read prompts as JSONL from stdin and write JSONL results")
+	runCmd.Flags().Int("concurrency", 1, "Maximum number of sessions to run in parallel in batch mode")
+	runCmd.Flags().Bool("stop-on-error", false, "Stop batch processing after the first failed prompt")
 }
@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	appPkg "github.com/charmbracelet/crush/internal/app"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunOutputSink(t *testing.T) {
+	var b bytes.Buffer
+	require.Nil(t, outputSink(runCmd, &b), "text output uses the default sink")
+
+	require.NoError(t, runCmd.Flags().Set("json", "true"))
+	t.Cleanup(func() { runCmd.Flags().Set("json", "false") })
+	sink := outputSink(runCmd, &b)
+	require.NotNil(t, sink)
+	require.NoError(t, sink.WriteAssistantDelta("hi"))
+	require.NoError(t, sink.Finish(appPkg.RunResult{SessionID: "session"}))
+	require.Equal(t, `{"type":"text","text":"hi"}
+{"type":"finish","session_id":"session"}
+`, b.String())
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var toolCmd = &cobra.Command{
+	Use:   "tool <name> [json-arguments]",
+	Short: "Run a single tool without the model",
+	Long: `Run a built-in or plugin tool by name with JSON arguments and print its output,
+without involving the model. Useful for debugging tools and for scripting.
+
+The arguments can be given as the second argument or piped from stdin. Plugin
+tool hooks and permission policies apply as in an agent run. Like crush run,
+permission requests are approved unless --allow-tools restricts them.`,
+	Example: `
+# List the files in the current directory
+crush tool ls '{"path": "."}'
+
+# Pipe the arguments from stdin
+echo '{"pattern": "TODO"}' | crush tool grep
+
+# Run a plugin tool in an existing session, denying requests to edit files
+crush tool deploy '{"env": "staging"}' --session 1f2e3d4c --allow-tools view
+  `,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sessionID, _ := cmd.Flags().GetString("session")
+		allowTools, _ := cmd.Flags().GetStringSlice("allow-tools")
+
+		name := args[0]
+		var input string
+		if len(args) > 1 {
+			input = args[1]
+		} else {
+			stdin, err := MaybePrependStdin("")
+			if err != nil {
+				return fmt.Errorf("failed to read from stdin: %w", err)
+			}
+			input = strings.TrimSpace(stdin)
+		}
+		if input != "" && !json.Valid([]byte(input)) {
+			return fmt.Errorf("arguments for tool %s are not valid JSON", name)
+		}
+
+		app, err := setupApp(cmd)
+		if err != nil {
+			return err
+		}
+		defer app.Shutdown()
+
+		if !app.Config().IsConfigured() {
+			return fmt.Errorf("no providers configured - please run 'crush' to set up a provider interactively")
+		}
+
+		if sessionID == "" {
+			sess, err := app.Sessions.Create(cmd.Context(), "Tool: "+name)
+			if err != nil {
+				return fmt.Errorf("failed to create session: %w", err)
+			}
+			sessionID = sess.ID
+		}
+		if len(allowTools) == 0 {
+			app.Permissions.AutoApproveSession(sessionID)
+		} else {
+			app.Permissions.AutoApproveSessionTools(sessionID, allowTools)
+			app.DenyPermissionRequests(cmd.Context(), sessionID)
+		}
+
+		resp, err := app.RunTool(cmd.Context(), sessionID, name, json.RawMessage(input))
+		if err != nil {
+			return err
+		}
+		if resp.IsError {
+			return fmt.Errorf("tool %s failed: %s", name, resp.Content)
+		}
+		fmt.Fprintln(os.Stdout, resp.Content)
+		return nil
+	},
+}
+
+func init() {
+	toolCmd.Flags().String("session", "", "Run the tool in this session instead of a new one")
+	toolCmd.Flags().StringSlice("allow-tools", nil, "Only auto-approve these tools (or tool:action pairs) and deny other permission requests")
+}
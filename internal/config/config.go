@@ -1,12 +1,16 @@
 package config
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"maps"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"slices"
 	"strings"
 	"time"
@@ -14,6 +18,7 @@ import (
 	"github.com/charmbracelet/catwalk/pkg/catwalk"
 	"github.com/charmbracelet/crush/internal/csync"
 	"github.com/charmbracelet/crush/internal/env"
+	"github.com/invopop/jsonschema"
 	"github.com/tidwall/sjson"
 )
 
@@ -75,6 +80,10 @@ type SelectedModel struct {
 	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty" jsonschema:"description=Frequency penalty to reduce repetition"`
 	PresencePenalty  *float64 `json:"presence_penalty,omitempty" jsonschema:"description=Presence penalty to increase topic diversity"`
 
+	// Retries of requests failing with a transient error before the
+	// response starts streaming. Defaults to 2.
+	MaxRetries *int `json:"max_retries,omitempty" jsonschema:"description=Number of times to retry a request that fails with a transient error before the response starts streaming,minimum=0,default=2,example=0"`
+
 	// Override provider specific options.
 	ProviderOptions map[string]any `json:"provider_options,omitempty" jsonschema:"description=Additional provider-specific options for the model"`
 }
@@ -162,8 +171,27 @@ func (c Completions) Limits() (depth, items int) {
 }
 
 type Permissions struct {
-	AllowedTools []string `json:"allowed_tools,omitempty" jsonschema:"description=List of tools that don't require permission prompts,example=bash,example=view"` // Tools that don't require permission prompts
-	SkipRequests bool     `json:"-"`                                                                                                                              // Automatically accept all permissions (YOLO mode)
+	AllowedTools          []string          `json:"allowed_tools,omitempty" jsonschema:"description=List of tools that don't require permission prompts,example=bash,example=view"` // Tools that don't require permission prompts
+	Policy                *PermissionPolicy `json:"policy,omitempty" jsonschema:"description=Rules that allow or deny permission requests without prompting"`
+	PromptTimeout         int               `json:"prompt_timeout,omitempty" jsonschema:"description=Seconds to wait for an answer to a permission prompt before prompt_timeout_decision applies; 0 waits forever,minimum=0,default=0"`
+	PromptTimeoutDecision string            `json:"prompt_timeout_decision,omitempty" jsonschema:"description=Outcome of permission prompts that time out,enum=allow,enum=deny,default=deny"`
+	SkipRequests          bool              `json:"-"` // Automatically accept all permissions (YOLO mode)
+}
+
+// PermissionPolicy is evaluated by the built-in permission policy plugin.
+// A matching deny rule always wins; otherwise the first matching rule
+// decides, and Default applies when no rule matches.
+type PermissionPolicy struct {
+	Rules   []PermissionRule `json:"rules,omitempty" jsonschema:"description=Rules evaluated in order"`
+	Default string           `json:"default,omitempty" jsonschema:"description=Outcome when no rule matches,enum=allow,enum=deny,enum=prompt,default=prompt"`
+}
+
+// PermissionRule matches permission requests. Empty fields match anything.
+type PermissionRule struct {
+	Tool     string `json:"tool,omitempty" jsonschema:"description=Glob matched against the tool name,example=mcp_*"`
+	Action   string `json:"action,omitempty" jsonschema:"description=Substring matched against the requested action,example=read"`
+	Path     string `json:"path,omitempty" jsonschema:"description=Path prefix the request must be under; ~ and environment variables are expanded,example=~/src"`
+	Decision string `json:"decision" jsonschema:"description=Outcome when the rule matches,enum=allow,enum=deny,enum=prompt"`
 }
 
 type Attribution struct {
@@ -172,16 +200,200 @@ type Attribution struct {
 }
 
 type Options struct {
-	ContextPaths              []string     `json:"context_paths,omitempty" jsonschema:"description=Paths to files containing context information for the AI,example=.cursorrules,example=CRUSH.md"`
-	TUI                       *TUIOptions  `json:"tui,omitempty" jsonschema:"description=Terminal user interface options"`
-	Debug                     bool         `json:"debug,omitempty" jsonschema:"description=Enable debug logging,default=false"`
-	DebugLSP                  bool         `json:"debug_lsp,omitempty" jsonschema:"description=Enable debug logging for LSP servers,default=false"`
-	DisableAutoSummarize      bool         `json:"disable_auto_summarize,omitempty" jsonschema:"description=Disable automatic conversation summarization,default=false"`
-	DataDirectory             string       `json:"data_directory,omitempty" jsonschema:"description=Directory for storing application data (relative to working directory),default=.crush,example=.crush"` // Relative to the cwd
-	DisabledTools             []string     `json:"disabled_tools" jsonschema:"description=Tools to disable"`
-	DisableProviderAutoUpdate bool         `json:"disable_provider_auto_update,omitempty" jsonschema:"description=Disable providers auto-update,default=false"`
-	Attribution               *Attribution `json:"attribution,omitempty" jsonschema:"description=Attribution settings for generated content"`
-	DisableMetrics            bool         `json:"disable_metrics,omitempty" jsonschema:"description=Disable sending metrics,default=false"`
+	ContextPaths              []string         `json:"context_paths,omitempty" jsonschema:"description=Paths to files containing context information for the AI,example=.cursorrules,example=CRUSH.md"`
+	TUI                       *TUIOptions      `json:"tui,omitempty" jsonschema:"description=Terminal user interface options"`
+	Debug                     bool             `json:"debug,omitempty" jsonschema:"description=Enable debug logging,default=false"`
+	DebugLSP                  bool             `json:"debug_lsp,omitempty" jsonschema:"description=Enable debug logging for LSP servers,default=false"`
+	DisableAutoSummarize      bool             `json:"disable_auto_summarize,omitempty" jsonschema:"description=Disable automatic conversation summarization,default=false"`
+	DataDirectory             string           `json:"data_directory,omitempty" jsonschema:"description=Directory for storing application data (relative to working directory),default=.crush,example=.crush"` // Relative to the cwd
+	DisabledTools             []string         `json:"disabled_tools" jsonschema:"description=Tools to disable"`
+	DisableProviderAutoUpdate bool             `json:"disable_provider_auto_update,omitempty" jsonschema:"description=Disable providers auto-update,default=false"`
+	Attribution               *Attribution     `json:"attribution,omitempty" jsonschema:"description=Attribution settings for generated content"`
+	DisableMetrics            bool             `json:"disable_metrics,omitempty" jsonschema:"description=Disable sending metrics,default=false"`
+	Plugins                   *PluginOptions   `json:"plugins,omitempty" jsonschema:"description=Plugin loading options"`
+	SkillsPaths               []string         `json:"skills_paths,omitempty" jsonschema:"description=Additional directories to search for skills; ~ and environment variables are expanded,example=$HOME/shared/skills"`
+	DisabledSkills            []string         `json:"disabled_skills,omitempty" jsonschema:"description=Names of skills to skip during discovery,example=brand-guidelines"`
+	EnabledSkills             []string         `json:"enabled_skills,omitempty" jsonschema:"description=Names of the only skills to load; all discovered skills load if empty and disabled_skills takes precedence,example=code-review"`
+	SandboxSkills             bool             `json:"sandbox_skills,omitempty" jsonschema:"description=Confine the view, glob, and grep tools to the skill and working directories while a skill is active,default=false"`
+	SkillBundles              []SkillBundle    `json:"skill_bundles,omitempty" jsonschema:"description=Archives of skills to extract and search for skills"`
+	SkillDefaults             SkillDefaults    `json:"skill_defaults,omitempty" jsonschema:"description=Default parameter values by skill name; values passed by the model take precedence"`
+	EagerSkills               bool             `json:"eager_skills,omitempty" jsonschema:"description=Return the full skill content when a skill is invoked instead of a table of contents to read sections from,default=false"`
+	SkillNameCollisions       string           `json:"skill_name_collisions,omitempty" jsonschema:"description=How to handle skills that map to the same tool name: last_wins keeps the skill with the highest precedence; keep_both also registers the others under names suffixed with a hash of their path; error fails loading skills when two skills declare the same name,enum=last_wins,enum=keep_both,enum=error,default=last_wins"`
+	SkillsProjectOnly         bool             `json:"skills_project_only,omitempty" jsonschema:"description=Only discover skills in the project's .crush/skills directory and configured skills paths and bundles; skills in the user's home and XDG config directories are ignored,default=false"`
+	SkillsMaxDepth            int              `json:"skills_max_depth,omitempty" jsonschema:"description=Maximum number of directory levels below each skills directory searched for skills,default=8,example=4"`
+	SkillsInjectMaxBytes      int              `json:"skills_inject_max_bytes,omitempty" jsonschema:"description=Maximum number of bytes of auto-injected skill content added to the system prompt; skills beyond the budget are truncated or left out,default=16384,example=32768"`
+	SkillsStrictFrontmatter   bool             `json:"skills_strict_frontmatter,omitempty" jsonschema:"description=Skip skills whose frontmatter has unknown keys instead of logging a warning,default=false"`
+	ToolAudit                 *ToolAudit       `json:"tool_audit,omitempty" jsonschema:"description=Record every tool execution with its full input and output in the database"`
+	ContextInjector           *ContextInjector `json:"context_injector,omitempty" jsonschema:"description=Add the contents of project files to the system prompt of every agent run"`
+	CostBudget                *CostBudget      `json:"cost_budget,omitempty" jsonschema:"description=Stop agent runs once a session has spent its cost budget"`
+	SafeMode                  bool             `json:"-"` // Skip loading skills and plugins (--safe-mode)
+}
+
+// SkillBundle is an archive of skills that is extracted to a cache directory
+// and searched like a skills directory.
+type SkillBundle struct {
+	Path   string `json:"path" jsonschema:"description=Path or https URL of a .tar.gz; .tgz; or .zip archive of skills; URLs must set sha256; ~ and environment variables are expanded,example=~/shared/team-skills.tar.gz"`
+	SHA256 string `json:"sha256,omitempty" jsonschema:"description=SHA-256 checksum the archive must match"`
+}
+
+// Ways of handling skills that map to the same tool name
+const (
+	SkillCollisionsLastWins = "last_wins"
+	SkillCollisionsKeepBoth = "keep_both"
+	SkillCollisionsError    = "error"
+)
+
+// SkillDefaults maps skill names to default values of their parameters.
+type SkillDefaults map[string]map[string]any
+
+// ToolAudit configures the built-in plugin that records tool executions.
+type ToolAudit struct {
+	Enabled       bool `json:"enabled,omitempty" jsonschema:"description=Record tool executions,default=false"`
+	RetentionDays int  `json:"retention_days,omitempty" jsonschema:"description=Days to keep tool execution records; older records are deleted on startup. 0 keeps them forever,default=0,example=30"`
+}
+
+// ContextInjector configures the built-in plugin that adds files to the
+// system prompt.
+type ContextInjector struct {
+	Files    []string `json:"files,omitempty" jsonschema:"description=Glob patterns of files to add to the system prompt; relative patterns are resolved against the working directory and ** matches any number of directories,example=CONVENTIONS.md,example=docs/**/*.md"`
+	MaxBytes int      `json:"max_bytes,omitempty" jsonschema:"description=Maximum number of bytes of file content added to the system prompt; files beyond the budget are truncated or left out,default=32768,example=65536"`
+}
+
+// CostBudget configures the built-in plugin that limits what a session may
+// spend on agent runs.
+type CostBudget struct {
+	MaxCost  float64            `json:"max_cost,omitempty" jsonschema:"description=Cost in USD a session may spend on agent runs before further runs are refused; 0 means no limit,default=0,example=5"`
+	Sessions map[string]float64 `json:"sessions,omitempty" jsonschema:"description=Cost budgets in USD of individual sessions by session ID; they replace max_cost"`
+}
+
+// PluginOptions controls which plugins may be loaded.
+type PluginOptions struct {
+	AllowedRoots []string `json:"allowed_roots,omitempty" jsonschema:"description=Directories plugins must be loaded from; plugins outside these directories are refused,example=/opt/crush/plugins"`
+	Denylist     []string `json:"denylist,omitempty" jsonschema:"description=Names of plugins that must never be loaded,example=metrics"`
+	InitRetries  int      `json:"init_retries,omitempty" jsonschema:"description=Number of times to retry a plugin whose initialization fails with a temporary error,default=0,example=3"`
+	Offline      bool     `json:"offline,omitempty" jsonschema:"description=Load remote plugins from the local cache only without network access,default=false"`
+	// AllowedHosts are the hosts remote plugins may be downloaded from
+	// without a pinned checksum
+	AllowedHosts []string `json:"allowed_hosts,omitempty" jsonschema:"description=Hosts remote plugins may be downloaded from without a pinned sha256 checksum,example=plugins.example.com"`
+	// Directories are plugin directories: every .so file directly inside
+	// them is loaded as a separate plugin
+	Directories []string `json:"directories,omitempty" jsonschema:"description=Directories whose .so files are each loaded as a separate plugin; ~ and environment variables are expanded,example=~/.config/crush/plugins"`
+	// Order lists plugin names whose hooks run first, in this order,
+	// regardless of how the plugins were found
+	Order []string `json:"order,omitempty" jsonschema:"description=Names of plugins whose hooks run first and in this order; other plugins follow in load order,example=policy"`
+	// StrictConfigHooks aborts startup when a plugin's config hook fails
+	// instead of logging a warning
+	StrictConfigHooks bool `json:"strict_config_hooks,omitempty" jsonschema:"description=Abort startup when a plugin config hook fails instead of logging a warning,default=false"`
+	// ShutdownTimeout is the number of seconds plugins may take to shut down
+	// when Crush exits.
+	ShutdownTimeout int `json:"shutdown_timeout,omitempty" jsonschema:"description=Seconds plugins may take to shut down before Crush exits without them,default=10,example=5"`
+	// HealthCheckInterval is the number of seconds between plugin health
+	// probes. Zero disables health checks.
+	HealthCheckInterval int `json:"health_check_interval,omitempty" jsonschema:"description=Seconds between plugin health checks; 0 disables them,default=0,example=30"`
+	// MaxPermissionRequests is the number of permission requests plugin
+	// hooks handle at once. Zero means no limit.
+	MaxPermissionRequests int `json:"max_permission_requests,omitempty" jsonschema:"description=Permission requests plugin hooks handle at once; further requests wait in arrival order. 0 means no limit,default=0,example=1"`
+	// ProviderHooks lets plugin provider hooks observe the requests sent to
+	// the model provider and their responses
+	ProviderHooks bool `json:"provider_hooks,omitempty" jsonschema:"description=Call plugin provider hooks with each provider request and response; payloads include the whole conversation,default=false"`
+	// EventBatchWindow is the number of milliseconds message events are
+	// collected for before they are delivered to plugin hooks together.
+	// Zero delivers each event as it happens.
+	EventBatchWindow int `json:"event_batch_window,omitempty" jsonschema:"description=Milliseconds to collect message events for before delivering them to plugin hooks together; 0 delivers each event immediately,default=0,example=50"`
+	// Profiles are named sets of plugins, e.g. per project type
+	Profiles map[string]PluginProfile `json:"profiles,omitempty" jsonschema:"description=Named sets of plugins; the active profile's plugins are loaded after the plugins list"`
+	// Profile selects the active profile. When empty, the first profile (by
+	// name) with a marker file in the working directory is used.
+	Profile string `json:"profile,omitempty" jsonschema:"description=Name of the plugin profile to load; by default the profile is selected by its marker files,example=go"`
+	// PanicThreshold is the number of hook panics after which a plugin is
+	// quarantined (unloaded). Zero keeps plugins loaded however often their
+	// hooks panic.
+	PanicThreshold int `json:"panic_threshold,omitempty" jsonschema:"description=Number of hook panics after which a plugin is unloaded; 0 never unloads plugins for panicking,default=0,example=3"`
+	// QuarantineCooldown is the number of seconds after which a quarantined
+	// plugin loaded from a .so file is initialized again. Zero leaves it
+	// unloaded until Crush restarts.
+	QuarantineCooldown int `json:"quarantine_cooldown,omitempty" jsonschema:"description=Seconds after which a plugin unloaded for panicking is initialized again; 0 leaves it unloaded,default=0,example=300"`
+	// MaxSubAgentDepth is the number of levels of child sessions plugins
+	// may create below a top-level session with sub-agent runs
+	MaxSubAgentDepth int `json:"max_sub_agent_depth,omitempty" jsonschema:"description=Number of levels of child sessions plugin sub-agent runs may create below a top-level session,default=3,example=1"`
+	// Settings holds plugin-specific settings by plugin name. Each plugin
+	// receives its own section as PluginContext.Settings.
+	Settings map[string]json.RawMessage `json:"settings,omitempty" jsonschema:"description=Settings of individual plugins by plugin name; each plugin defines the settings it reads"`
+}
+
+// PluginProfile is a named set of plugins.
+type PluginProfile struct {
+	Plugins []PluginEntry `json:"plugins" jsonschema:"description=Plugins to load when the profile is active"`
+	// Markers are files whose presence in the working directory selects the
+	// profile, e.g. go.mod for Go projects
+	Markers []string `json:"markers,omitempty" jsonschema:"description=Files whose presence in the working directory selects the profile,example=go.mod"`
+}
+
+// Plugin kinds
+const (
+	PluginKindSO   = "so"
+	PluginKindGRPC = "grpc"
+	PluginKindWASM = "wasm"
+)
+
+// PluginEntry is a plugin to load. In configuration it is either a bare
+// path or an object with per-plugin options.
+type PluginEntry struct {
+	Path     string `json:"path" jsonschema:"description=Path or https URL of the plugin; ~ and environment variables are expanded,example=~/.config/crush/plugins/metrics.so"`
+	Symbol   string `json:"symbol,omitempty" jsonschema:"description=Name of the symbol the plugin exports,default=Plugin"`
+	SHA256   string `json:"sha256,omitempty" jsonschema:"description=SHA-256 checksum the plugin file must match"`
+	Kind     string `json:"kind,omitempty" jsonschema:"description=How the plugin is loaded,enum=so,enum=grpc,enum=wasm,default=so"`
+	Enabled  *bool  `json:"enabled,omitempty" jsonschema:"description=Whether to load the plugin,default=true"`
+	Priority int    `json:"priority,omitempty" jsonschema:"description=Plugins with a higher priority are loaded first,default=0"`
+	// Required aborts startup when the plugin fails to load or initialize,
+	// for plugins Crush must not run without, like permission enforcers
+	Required bool `json:"required,omitempty" jsonschema:"description=Abort startup when the plugin fails to load or initialize instead of logging a warning,default=false"`
+}
+
+// IsEnabled reports whether the plugin should be loaded
+func (e PluginEntry) IsEnabled() bool {
+	return e.Enabled == nil || *e.Enabled
+}
+
+// GetKind returns the plugin kind, defaulting to a Go plugin
+func (e PluginEntry) GetKind() string {
+	return cmp.Or(e.Kind, PluginKindSO)
+}
+
+// GetSymbol returns the name of the symbol the plugin exports
+func (e PluginEntry) GetSymbol() string {
+	return cmp.Or(e.Symbol, "Plugin")
+}
+
+// UnmarshalJSON accepts either a path or an object
+func (e *PluginEntry) UnmarshalJSON(data []byte) error {
+	var path string
+	if err := json.Unmarshal(data, &path); err == nil {
+		*e = PluginEntry{Path: path}
+		return nil
+	}
+	type entry PluginEntry
+	return json.Unmarshal(data, (*entry)(e))
+}
+
+// MarshalJSON writes entries that only set a path as a bare path
+func (e PluginEntry) MarshalJSON() ([]byte, error) {
+	if e == (PluginEntry{Path: e.Path}) {
+		return json.Marshal(e.Path)
+	}
+	type entry PluginEntry
+	return json.Marshal(entry(e))
+}
+
+// JSONSchemaExtend allows plugin entries to be given as a bare path
+func (PluginEntry) JSONSchemaExtend(schema *jsonschema.Schema) {
+	object := *schema
+	*schema = jsonschema.Schema{
+		OneOf: []*jsonschema.Schema{
+			{Type: "string", Description: "Path or http(s) URL of the plugin"},
+			&object,
+		},
+	}
 }
 
 type MCPs map[string]MCPConfig
@@ -272,6 +484,9 @@ type Agent struct {
 
 type Tools struct {
 	Ls ToolLs `json:"ls,omitzero"`
+
+	// Disabled built-in tools are left out of the agents' tool sets
+	Disabled []string `json:"disabled,omitempty" jsonschema:"description=Built-in tools to disable,example=bash,example=fetch"`
 }
 
 type ToolLs struct {
@@ -303,7 +518,7 @@ type Config struct {
 
 	Tools Tools `json:"tools,omitzero" jsonschema:"description=Tool configurations"`
 
-	Plugins []string `json:"plugins,omitempty" jsonschema:"description=Plugin paths to load (.so files or directories containing plugins)"`
+	Plugins []PluginEntry `json:"plugins,omitempty" jsonschema:"description=Plugins to load: paths of .so files or directories containing plugins; or objects with per-plugin options"`
 
 	Agents map[string]Agent `json:"-"`
 
@@ -329,12 +544,70 @@ func (c *Config) EnabledProviders() []ProviderConfig {
 	return enabled
 }
 
-// GetPluginPaths returns the list of plugin paths from configuration
+// GetPluginPaths returns the paths of the enabled plugin entries, in load
+// order
 func (c *Config) GetPluginPaths() []string {
-	if c.Plugins == nil {
-		return []string{}
+	paths := []string{}
+	for _, entry := range c.GetPluginEntries() {
+		paths = append(paths, entry.Path)
+	}
+	return paths
+}
+
+// GetPluginEntries returns the enabled plugin entries, in load order:
+// higher priorities first, then in the order they are configured. The
+// plugins list comes before those of the active profile, and an entry in the
+// list overrides a profile entry with the same path, e.g. to disable it.
+func (c *Config) GetPluginEntries() []PluginEntry {
+	configured := slices.Clone(c.Plugins)
+	if name := c.ActivePluginProfile(); name != "" {
+		for _, entry := range c.Options.Plugins.Profiles[name].Plugins {
+			if !slices.ContainsFunc(c.Plugins, func(e PluginEntry) bool { return e.Path == entry.Path }) {
+				configured = append(configured, entry)
+			}
+		}
+	}
+
+	entries := []PluginEntry{}
+	for _, entry := range configured {
+		if entry.IsEnabled() {
+			entries = append(entries, entry)
+		}
+	}
+	slices.SortStableFunc(entries, func(a, b PluginEntry) int {
+		return cmp.Compare(b.Priority, a.Priority)
+	})
+	return entries
+}
+
+// ActivePluginProfile returns the name of the selected plugin profile: the
+// one set in options.plugins.profile or, if none is, the first profile by
+// name that has a marker file in the working directory. It returns "" if no
+// profile is selected. The name may not match a configured profile.
+func (c *Config) ActivePluginProfile() string {
+	if c.Options == nil || c.Options.Plugins == nil {
+		return ""
+	}
+	opts := c.Options.Plugins
+	if opts.Profile != "" {
+		return opts.Profile
+	}
+	for _, name := range slices.Sorted(maps.Keys(opts.Profiles)) {
+		for _, marker := range opts.Profiles[name].Markers {
+			if _, err := os.Stat(filepath.Join(c.workingDir, marker)); err == nil {
+				return name
+			}
+		}
 	}
-	return c.Plugins
+	return ""
+}
+
+// GetPluginDirs returns the plugin directories from configuration
+func (c *Config) GetPluginDirs() []string {
+	if c.Options == nil || c.Options.Plugins == nil {
+		return nil
+	}
+	return c.Options.Plugins.Directories
 }
 
 // IsConfigured  return true if at least one provider is configured
@@ -494,6 +767,26 @@ func allToolNames() []string {
 	}
 }
 
+// DisabledTools returns the built-in tools disabled by tools.disabled or
+// options.disabled_tools
+func (c *Config) DisabledTools() []string {
+	var disabled []string
+	if c.Options != nil {
+		disabled = append(disabled, c.Options.DisabledTools...)
+	}
+	for _, name := range c.Tools.Disabled {
+		if !slices.Contains(disabled, name) {
+			disabled = append(disabled, name)
+		}
+	}
+	return disabled
+}
+
+// IsBuiltinTool reports whether name is the name of a built-in tool
+func IsBuiltinTool(name string) bool {
+	return slices.Contains(allToolNames(), name)
+}
+
 func resolveAllowedTools(allTools []string, disabledTools []string) []string {
 	if disabledTools == nil {
 		return allTools
@@ -521,7 +814,7 @@ func filterSlice(data []string, mask []string, include bool) []string {
 }
 
 func (c *Config) SetupAgents() {
-	allowedTools := resolveAllowedTools(allToolNames(), c.Options.DisabledTools)
+	allowedTools := resolveAllowedTools(allToolNames(), c.DisabledTools())
 
 	agents := map[string]Agent{
 		AgentCoder: {
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"encoding/json"
 	"io"
 	"log/slog"
 	"os"
@@ -37,6 +38,62 @@ func TestConfig_LoadFromReaders(t *testing.T) {
 	require.Equal(t, "https://api.openai.com/v2", pc.BaseURL)
 }
 
+func TestConfig_PluginEntries(t *testing.T) {
+	t.Parallel()
+
+	data := strings.NewReader(`{"plugins": [
+		"./first.so",
+		{"path": "./disabled.so", "enabled": false},
+		{"path": "./urgent.so", "priority": 10, "symbol": "Metrics", "sha256": "abc"},
+		{"path": "./last.so", "enabled": true}
+	]}`)
+	cfg, err := loadFromReaders([]io.Reader{data})
+	require.NoError(t, err)
+
+	entries := cfg.GetPluginEntries()
+	require.Len(t, entries, 3)
+	require.Equal(t, PluginEntry{Path: "./urgent.so", Priority: 10, Symbol: "Metrics", SHA256: "abc"}, entries[0])
+	require.Equal(t, "./first.so", entries[1].Path)
+	require.Equal(t, PluginKindSO, entries[1].GetKind())
+	require.Equal(t, "Plugin", entries[1].GetSymbol())
+	require.Equal(t, []string{"./urgent.so", "./first.so", "./last.so"}, cfg.GetPluginPaths())
+
+	bts, err := json.Marshal(cfg.Plugins[:2])
+	require.NoError(t, err)
+	require.JSONEq(t, `["./first.so", {"path": "./disabled.so", "enabled": false}]`, string(bts))
+}
+
+func TestConfig_PluginProfiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), nil, 0o644))
+
+	data := strings.NewReader(`{
+		"plugins": ["./audit.so", {"path": "./lint.so", "enabled": false}],
+		"options": {"plugins": {"profiles": {
+			"go": {"plugins": ["./gopls.so", "./lint.so"], "markers": ["go.mod"]},
+			"web": {"plugins": ["./eslint.so"], "markers": ["package.json"]}
+		}}}
+	}`)
+	cfg, err := loadFromReaders([]io.Reader{data})
+	require.NoError(t, err)
+
+	// Without a matching marker, only the plugins list is loaded
+	cfg.workingDir = t.TempDir()
+	require.Empty(t, cfg.ActivePluginProfile())
+	require.Equal(t, []string{"./audit.so"}, cfg.GetPluginPaths())
+
+	// The list's entry for lint.so overrides the profile's
+	cfg.workingDir = dir
+	require.Equal(t, "go", cfg.ActivePluginProfile())
+	require.Equal(t, []string{"./audit.so", "./gopls.so"}, cfg.GetPluginPaths())
+
+	// An explicit profile wins over markers
+	cfg.Options.Plugins.Profile = "web"
+	require.Equal(t, []string{"./audit.so", "./eslint.so"}, cfg.GetPluginPaths())
+}
+
 func TestConfig_setDefaults(t *testing.T) {
 	cfg := &Config{}
 
@@ -492,6 +549,23 @@ func TestConfig_setupAgentsWithDisabledTools(t *testing.T) {
 	assert.Equal(t, []string{"glob", "ls", "sourcegraph", "view"}, taskAgent.AllowedTools)
 }
 
+func TestConfig_setupAgentsWithToolsDisabled(t *testing.T) {
+	cfg := &Config{
+		Options: &Options{
+			DisabledTools: []string{"edit"},
+		},
+		Tools: Tools{
+			Disabled: []string{"bash", "fetch", "edit"},
+		},
+	}
+
+	assert.Equal(t, []string{"edit", "bash", "fetch"}, cfg.DisabledTools())
+	cfg.SetupAgents()
+	coderAgent, ok := cfg.Agents[AgentCoder]
+	require.True(t, ok)
+	assert.Equal(t, []string{"agent", "download", "multiedit", "lsp_diagnostics", "lsp_references", "glob", "grep", "ls", "sourcegraph", "view", "write"}, coderAgent.AllowedTools)
+}
+
 func TestConfig_setupAgentsWithEveryReadOnlyToolDisabled(t *testing.T) {
 	cfg := &Config{
 		Options: &Options{
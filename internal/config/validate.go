@@ -0,0 +1,51 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Validate checks the invariants the rest of Crush relies on: options and
+// providers are set, selected models refer to configured providers and
+// models, and MCP and LSP servers can be started. It reports every problem
+// found.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Options == nil {
+		errs = append(errs, errors.New("options are not set"))
+	}
+	if c.Providers == nil {
+		errs = append(errs, errors.New("providers are not set"))
+	} else {
+		for modelType, selected := range c.Models {
+			if _, ok := c.Providers.Get(selected.Provider); !ok {
+				errs = append(errs, fmt.Errorf("%s model: provider %q is not configured", modelType, selected.Provider))
+				continue
+			}
+			if c.GetModel(selected.Provider, selected.Model) == nil {
+				errs = append(errs, fmt.Errorf("%s model: model %q not found for provider %s", modelType, selected.Model, selected.Provider))
+			}
+		}
+	}
+
+	for name, mcp := range c.MCP {
+		switch mcp.Type {
+		case MCPStdio:
+			if mcp.Command == "" {
+				errs = append(errs, fmt.Errorf("mcp %s: command is required for stdio servers", name))
+			}
+		case MCPSSE, MCPHttp:
+			if mcp.URL == "" {
+				errs = append(errs, fmt.Errorf("mcp %s: url is required for %s servers", name, mcp.Type))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("mcp %s: unsupported type %q", name, mcp.Type))
+		}
+	}
+	for name, lsp := range c.LSP {
+		if !lsp.Disabled && lsp.Command == "" {
+			errs = append(errs, fmt.Errorf("lsp %s: command is required", name))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/catwalk/pkg/catwalk"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	newConfig := func() *Config {
+		providers := csync.NewMap[string, ProviderConfig]()
+		providers.Set("openai", ProviderConfig{ID: "openai", Models: []catwalk.Model{{ID: "gpt-4o"}}})
+		return &Config{
+			Options:   &Options{},
+			Providers: providers,
+			Models:    map[SelectedModelType]SelectedModel{SelectedModelTypeLarge: {Provider: "openai", Model: "gpt-4o"}},
+			MCP:       MCPs{"docs": {Type: MCPHttp, URL: "https://example.com/mcp"}},
+			LSP:       LSPs{"go": {Command: "gopls"}, "off": {Disabled: true}},
+		}
+	}
+	require.NoError(t, newConfig().Validate())
+
+	for name, corrupt := range map[string]func(*Config){
+		"no options":   func(c *Config) { c.Options = nil },
+		"no providers": func(c *Config) { c.Providers = nil },
+		"unknown provider": func(c *Config) {
+			c.Models[SelectedModelTypeSmall] = SelectedModel{Provider: "missing", Model: "gpt-4o"}
+		},
+		"unknown model": func(c *Config) {
+			c.Models[SelectedModelTypeLarge] = SelectedModel{Provider: "openai", Model: "missing"}
+		},
+		"stdio without command": func(c *Config) { c.MCP["local"] = MCPConfig{Type: MCPStdio} },
+		"unknown mcp type":      func(c *Config) { c.MCP["odd"] = MCPConfig{Type: "pipe", Command: "x"} },
+		"lsp without command":   func(c *Config) { c.LSP["rust"] = LSPConfig{} },
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			cfg := newConfig()
+			corrupt(cfg)
+			require.Error(t, cfg.Validate())
+		})
+	}
+}
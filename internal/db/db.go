@@ -33,12 +33,18 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.createSessionStmt, err = db.PrepareContext(ctx, createSession); err != nil {
 		return nil, fmt.Errorf("error preparing query CreateSession: %w", err)
 	}
+	if q.createToolExecutionStmt, err = db.PrepareContext(ctx, createToolExecution); err != nil {
+		return nil, fmt.Errorf("error preparing query CreateToolExecution: %w", err)
+	}
 	if q.deleteFileStmt, err = db.PrepareContext(ctx, deleteFile); err != nil {
 		return nil, fmt.Errorf("error preparing query DeleteFile: %w", err)
 	}
 	if q.deleteMessageStmt, err = db.PrepareContext(ctx, deleteMessage); err != nil {
 		return nil, fmt.Errorf("error preparing query DeleteMessage: %w", err)
 	}
+	if q.deletePluginValueStmt, err = db.PrepareContext(ctx, deletePluginValue); err != nil {
+		return nil, fmt.Errorf("error preparing query DeletePluginValue: %w", err)
+	}
 	if q.deleteSessionStmt, err = db.PrepareContext(ctx, deleteSession); err != nil {
 		return nil, fmt.Errorf("error preparing query DeleteSession: %w", err)
 	}
@@ -48,6 +54,9 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.deleteSessionMessagesStmt, err = db.PrepareContext(ctx, deleteSessionMessages); err != nil {
 		return nil, fmt.Errorf("error preparing query DeleteSessionMessages: %w", err)
 	}
+	if q.deleteToolExecutionsBeforeStmt, err = db.PrepareContext(ctx, deleteToolExecutionsBefore); err != nil {
+		return nil, fmt.Errorf("error preparing query DeleteToolExecutionsBefore: %w", err)
+	}
 	if q.getFileStmt, err = db.PrepareContext(ctx, getFile); err != nil {
 		return nil, fmt.Errorf("error preparing query GetFile: %w", err)
 	}
@@ -57,6 +66,9 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.getMessageStmt, err = db.PrepareContext(ctx, getMessage); err != nil {
 		return nil, fmt.Errorf("error preparing query GetMessage: %w", err)
 	}
+	if q.getPluginValueStmt, err = db.PrepareContext(ctx, getPluginValue); err != nil {
+		return nil, fmt.Errorf("error preparing query GetPluginValue: %w", err)
+	}
 	if q.getSessionByIDStmt, err = db.PrepareContext(ctx, getSessionByID); err != nil {
 		return nil, fmt.Errorf("error preparing query GetSessionByID: %w", err)
 	}
@@ -78,6 +90,12 @@ func Prepare(ctx context.Context, db DBTX) (*Queries, error) {
 	if q.listSessionsStmt, err = db.PrepareContext(ctx, listSessions); err != nil {
 		return nil, fmt.Errorf("error preparing query ListSessions: %w", err)
 	}
+	if q.listToolExecutionsBySessionStmt, err = db.PrepareContext(ctx, listToolExecutionsBySession); err != nil {
+		return nil, fmt.Errorf("error preparing query ListToolExecutionsBySession: %w", err)
+	}
+	if q.setPluginValueStmt, err = db.PrepareContext(ctx, setPluginValue); err != nil {
+		return nil, fmt.Errorf("error preparing query SetPluginValue: %w", err)
+	}
 	if q.updateMessageStmt, err = db.PrepareContext(ctx, updateMessage); err != nil {
 		return nil, fmt.Errorf("error preparing query UpdateMessage: %w", err)
 	}
@@ -104,6 +122,11 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing createSessionStmt: %w", cerr)
 		}
 	}
+	if q.createToolExecutionStmt != nil {
+		if cerr := q.createToolExecutionStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing createToolExecutionStmt: %w", cerr)
+		}
+	}
 	if q.deleteFileStmt != nil {
 		if cerr := q.deleteFileStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing deleteFileStmt: %w", cerr)
@@ -114,6 +137,11 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing deleteMessageStmt: %w", cerr)
 		}
 	}
+	if q.deletePluginValueStmt != nil {
+		if cerr := q.deletePluginValueStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deletePluginValueStmt: %w", cerr)
+		}
+	}
 	if q.deleteSessionStmt != nil {
 		if cerr := q.deleteSessionStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing deleteSessionStmt: %w", cerr)
@@ -129,6 +157,11 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing deleteSessionMessagesStmt: %w", cerr)
 		}
 	}
+	if q.deleteToolExecutionsBeforeStmt != nil {
+		if cerr := q.deleteToolExecutionsBeforeStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing deleteToolExecutionsBeforeStmt: %w", cerr)
+		}
+	}
 	if q.getFileStmt != nil {
 		if cerr := q.getFileStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing getFileStmt: %w", cerr)
@@ -144,6 +177,11 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing getMessageStmt: %w", cerr)
 		}
 	}
+	if q.getPluginValueStmt != nil {
+		if cerr := q.getPluginValueStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing getPluginValueStmt: %w", cerr)
+		}
+	}
 	if q.getSessionByIDStmt != nil {
 		if cerr := q.getSessionByIDStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing getSessionByIDStmt: %w", cerr)
@@ -179,6 +217,16 @@ func (q *Queries) Close() error {
 			err = fmt.Errorf("error closing listSessionsStmt: %w", cerr)
 		}
 	}
+	if q.listToolExecutionsBySessionStmt != nil {
+		if cerr := q.listToolExecutionsBySessionStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing listToolExecutionsBySessionStmt: %w", cerr)
+		}
+	}
+	if q.setPluginValueStmt != nil {
+		if cerr := q.setPluginValueStmt.Close(); cerr != nil {
+			err = fmt.Errorf("error closing setPluginValueStmt: %w", cerr)
+		}
+	}
 	if q.updateMessageStmt != nil {
 		if cerr := q.updateMessageStmt.Close(); cerr != nil {
 			err = fmt.Errorf("error closing updateMessageStmt: %w", cerr)
@@ -226,53 +274,65 @@ func (q *Queries) queryRow(ctx context.Context, stmt *sql.Stmt, query string, ar
 }
 
 type Queries struct {
-	db                          DBTX
-	tx                          *sql.Tx
-	createFileStmt              *sql.Stmt
-	createMessageStmt           *sql.Stmt
-	createSessionStmt           *sql.Stmt
-	deleteFileStmt              *sql.Stmt
-	deleteMessageStmt           *sql.Stmt
-	deleteSessionStmt           *sql.Stmt
-	deleteSessionFilesStmt      *sql.Stmt
-	deleteSessionMessagesStmt   *sql.Stmt
-	getFileStmt                 *sql.Stmt
-	getFileByPathAndSessionStmt *sql.Stmt
-	getMessageStmt              *sql.Stmt
-	getSessionByIDStmt          *sql.Stmt
-	listFilesByPathStmt         *sql.Stmt
-	listFilesBySessionStmt      *sql.Stmt
-	listLatestSessionFilesStmt  *sql.Stmt
-	listMessagesBySessionStmt   *sql.Stmt
-	listNewFilesStmt            *sql.Stmt
-	listSessionsStmt            *sql.Stmt
-	updateMessageStmt           *sql.Stmt
-	updateSessionStmt           *sql.Stmt
+	db                              DBTX
+	tx                              *sql.Tx
+	createFileStmt                  *sql.Stmt
+	createMessageStmt               *sql.Stmt
+	createSessionStmt               *sql.Stmt
+	createToolExecutionStmt         *sql.Stmt
+	deleteFileStmt                  *sql.Stmt
+	deleteMessageStmt               *sql.Stmt
+	deletePluginValueStmt           *sql.Stmt
+	deleteSessionStmt               *sql.Stmt
+	deleteSessionFilesStmt          *sql.Stmt
+	deleteSessionMessagesStmt       *sql.Stmt
+	deleteToolExecutionsBeforeStmt  *sql.Stmt
+	getFileStmt                     *sql.Stmt
+	getFileByPathAndSessionStmt     *sql.Stmt
+	getMessageStmt                  *sql.Stmt
+	getPluginValueStmt              *sql.Stmt
+	getSessionByIDStmt              *sql.Stmt
+	listFilesByPathStmt             *sql.Stmt
+	listFilesBySessionStmt          *sql.Stmt
+	listLatestSessionFilesStmt      *sql.Stmt
+	listMessagesBySessionStmt       *sql.Stmt
+	listNewFilesStmt                *sql.Stmt
+	listSessionsStmt                *sql.Stmt
+	listToolExecutionsBySessionStmt *sql.Stmt
+	setPluginValueStmt              *sql.Stmt
+	updateMessageStmt               *sql.Stmt
+	updateSessionStmt               *sql.Stmt
 }
 
 func (q *Queries) WithTx(tx *sql.Tx) *Queries {
 	return &Queries{
-		db:                          tx,
-		tx:                          tx,
-		createFileStmt:              q.createFileStmt,
-		createMessageStmt:           q.createMessageStmt,
-		createSessionStmt:           q.createSessionStmt,
-		deleteFileStmt:              q.deleteFileStmt,
-		deleteMessageStmt:           q.deleteMessageStmt,
-		deleteSessionStmt:           q.deleteSessionStmt,
-		deleteSessionFilesStmt:      q.deleteSessionFilesStmt,
-		deleteSessionMessagesStmt:   q.deleteSessionMessagesStmt,
-		getFileStmt:                 q.getFileStmt,
-		getFileByPathAndSessionStmt: q.getFileByPathAndSessionStmt,
-		getMessageStmt:              q.getMessageStmt,
-		getSessionByIDStmt:          q.getSessionByIDStmt,
-		listFilesByPathStmt:         q.listFilesByPathStmt,
-		listFilesBySessionStmt:      q.listFilesBySessionStmt,
-		listLatestSessionFilesStmt:  q.listLatestSessionFilesStmt,
-		listMessagesBySessionStmt:   q.listMessagesBySessionStmt,
-		listNewFilesStmt:            q.listNewFilesStmt,
-		listSessionsStmt:            q.listSessionsStmt,
-		updateMessageStmt:           q.updateMessageStmt,
-		updateSessionStmt:           q.updateSessionStmt,
+		db:                              tx,
+		tx:                              tx,
+		createFileStmt:                  q.createFileStmt,
+		createMessageStmt:               q.createMessageStmt,
+		createSessionStmt:               q.createSessionStmt,
+		createToolExecutionStmt:         q.createToolExecutionStmt,
+		deleteFileStmt:                  q.deleteFileStmt,
+		deleteMessageStmt:               q.deleteMessageStmt,
+		deletePluginValueStmt:           q.deletePluginValueStmt,
+		deleteSessionStmt:               q.deleteSessionStmt,
+		deleteSessionFilesStmt:          q.deleteSessionFilesStmt,
+		deleteSessionMessagesStmt:       q.deleteSessionMessagesStmt,
+		deleteToolExecutionsBeforeStmt:  q.deleteToolExecutionsBeforeStmt,
+		getFileStmt:                     q.getFileStmt,
+		getFileByPathAndSessionStmt:     q.getFileByPathAndSessionStmt,
+		getMessageStmt:                  q.getMessageStmt,
+		getPluginValueStmt:              q.getPluginValueStmt,
+		getSessionByIDStmt:              q.getSessionByIDStmt,
+		listFilesByPathStmt:             q.listFilesByPathStmt,
+		listFilesBySessionStmt:          q.listFilesBySessionStmt,
+		listLatestSessionFilesStmt:      q.listLatestSessionFilesStmt,
+		listMessagesBySessionStmt:       q.listMessagesBySessionStmt,
+		listNewFilesStmt:                q.listNewFilesStmt,
+		listSessionsStmt:                q.listSessionsStmt,
+		listToolExecutionsBySessionStmt: q.listToolExecutionsBySessionStmt,
+		setPluginValueStmt:              q.setPluginValueStmt,
+		updateMessageStmt:               q.updateMessageStmt,
+		updateSessionStmt:               q.updateSessionStmt,
 	}
 }
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS tool_executions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id TEXT NOT NULL,
+    message_id TEXT NOT NULL,
+    tool_call_id TEXT NOT NULL,
+    tool_name TEXT NOT NULL,
+    arguments TEXT NOT NULL,  -- JSON encoded
+    output TEXT NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    is_error INTEGER NOT NULL DEFAULT 0,
+    duration_ms INTEGER NOT NULL,
+    created_at INTEGER NOT NULL,  -- Unix timestamp in seconds
+    FOREIGN KEY (session_id) REFERENCES sessions (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_tool_executions_session_id ON tool_executions (session_id);
+CREATE INDEX IF NOT EXISTS idx_tool_executions_created_at ON tool_executions (created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_tool_executions_created_at;
+DROP INDEX IF EXISTS idx_tool_executions_session_id;
+DROP TABLE IF EXISTS tool_executions;
+-- +goose StatementEnd
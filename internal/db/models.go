@@ -31,6 +31,14 @@ type Message struct {
 	IsSummaryMessage int64          `json:"is_summary_message"`
 }
 
+type PluginKv struct {
+	Plugin    string `json:"plugin"`
+	Key       string `json:"key"`
+	Value     string `json:"value"`
+	CreatedAt int64  `json:"created_at"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
 type Session struct {
 	ID               string         `json:"id"`
 	ParentSessionID  sql.NullString `json:"parent_session_id"`
@@ -43,3 +51,17 @@ type Session struct {
 	CreatedAt        int64          `json:"created_at"`
 	SummaryMessageID sql.NullString `json:"summary_message_id"`
 }
+
+type ToolExecution struct {
+	ID         int64  `json:"id"`
+	SessionID  string `json:"session_id"`
+	MessageID  string `json:"message_id"`
+	ToolCallID string `json:"tool_call_id"`
+	ToolName   string `json:"tool_name"`
+	Arguments  string `json:"arguments"`
+	Output     string `json:"output"`
+	Error      string `json:"error"`
+	IsError    int64  `json:"is_error"`
+	DurationMs int64  `json:"duration_ms"`
+	CreatedAt  int64  `json:"created_at"`
+}
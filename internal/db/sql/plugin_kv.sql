@@ -0,0 +1,23 @@
+-- name: GetPluginValue :one
+SELECT value
+FROM plugin_kv
+WHERE plugin = ? AND key = ?
+LIMIT 1;
+
+-- name: SetPluginValue :exec
+INSERT INTO plugin_kv (
+    plugin,
+    key,
+    value,
+    created_at,
+    updated_at
+) VALUES (
+    ?, ?, ?, strftime('%s', 'now'), strftime('%s', 'now')
+)
+ON CONFLICT (plugin, key) DO UPDATE SET
+    value = excluded.value,
+    updated_at = strftime('%s', 'now');
+
+-- name: DeletePluginValue :exec
+DELETE FROM plugin_kv
+WHERE plugin = ? AND key = ?;
@@ -0,0 +1,25 @@
+-- name: CreateToolExecution :exec
+INSERT INTO tool_executions (
+    session_id,
+    message_id,
+    tool_call_id,
+    tool_name,
+    arguments,
+    output,
+    error,
+    is_error,
+    duration_ms,
+    created_at
+) VALUES (
+    ?, ?, ?, ?, ?, ?, ?, ?, ?, strftime('%s', 'now')
+);
+
+-- name: ListToolExecutionsBySession :many
+SELECT *
+FROM tool_executions
+WHERE session_id = ?
+ORDER BY created_at ASC, id ASC;
+
+-- name: DeleteToolExecutionsBefore :execrows
+DELETE FROM tool_executions
+WHERE created_at < ?;
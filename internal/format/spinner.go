@@ -23,12 +23,18 @@ type model struct {
 	anim   *anim.Anim
 }
 
+// labelMsg changes the spinner label
+type labelMsg string
+
 func (m model) Init() tea.Cmd  { return m.anim.Init() }
 func (m model) View() tea.View { return tea.NewView(m.anim.View()) }
 
 // Update implements tea.Model.
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
+	case labelMsg:
+		m.anim.SetLabel(string(msg))
+		return m, nil
 	case tea.KeyPressMsg:
 		switch msg.String() {
 		case "ctrl+c", "esc":
@@ -81,6 +87,11 @@ func (s *Spinner) Start() {
 	}()
 }
 
+// SetLabel changes the text shown next to the spinner
+func (s *Spinner) SetLabel(label string) {
+	s.prog.Send(labelMsg(label))
+}
+
 // Stop ends the spinner animation
 func (s *Spinner) Stop() {
 	s.prog.Quit()
@@ -0,0 +1,171 @@
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// ExportFormat is the output format of a session transcript
+type ExportFormat string
+
+const (
+	Markdown ExportFormat = "markdown"
+	HTML     ExportFormat = "html"
+)
+
+// ErrUnknownExportFormat is returned for export formats other than Markdown
+// and HTML
+var ErrUnknownExportFormat = errors.New("unknown export format")
+
+// WriteTranscript renders a session's messages as a human-readable
+// transcript. Tool calls are rendered together with their results.
+func WriteTranscript(w io.Writer, sess session.Session, msgs []message.Message, exportFormat ExportFormat) error {
+	var md bytes.Buffer
+	writeMarkdown(&md, sess, msgs)
+
+	switch exportFormat {
+	case Markdown:
+		_, err := md.WriteTo(w)
+		return err
+	case HTML:
+		var body bytes.Buffer
+		if err := goldmark.New(goldmark.WithExtensions(extension.GFM)).Convert(md.Bytes(), &body); err != nil {
+			return fmt.Errorf("failed to render transcript as HTML: %w", err)
+		}
+		_, err := fmt.Fprintf(w, htmlTemplate, html.EscapeString(transcriptTitle(sess)), body.String())
+		return err
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownExportFormat, exportFormat)
+	}
+}
+
+const htmlTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>%s</title>
+<style>
+body { max-width: 50rem; margin: 2rem auto; padding: 0 1rem; font-family: sans-serif; line-height: 1.5; }
+pre { background: #f4f4f4; padding: 0.75rem; overflow-x: auto; }
+code { font-family: monospace; }
+</style>
+</head>
+<body>
+%s</body>
+</html>
+`
+
+func transcriptTitle(sess session.Session) string {
+	if sess.Title != "" {
+		return sess.Title
+	}
+	return "Session " + sess.ID
+}
+
+func writeMarkdown(w *bytes.Buffer, sess session.Session, msgs []message.Message) {
+	fmt.Fprintf(w, "# %s\n\n", transcriptTitle(sess))
+	fmt.Fprintf(w, "- Session: `%s`\n", sess.ID)
+	if sess.CreatedAt > 0 {
+		fmt.Fprintf(w, "- Created: %s\n", time.Unix(sess.CreatedAt, 0).UTC().Format(time.RFC3339))
+	}
+	fmt.Fprintf(w, "- Tokens: %d prompt, %d completion\n", sess.PromptTokens, sess.CompletionTokens)
+	if sess.Cost > 0 {
+		fmt.Fprintf(w, "- Cost: $%.4f\n", sess.Cost)
+	}
+
+	// Results live in separate tool messages; render them with their calls
+	results := make(map[string]message.ToolResult)
+	for _, msg := range msgs {
+		for _, result := range msg.ToolResults() {
+			results[result.ToolCallID] = result
+		}
+	}
+
+	for _, msg := range msgs {
+		if msg.Role == message.Tool {
+			continue
+		}
+		writeMessage(w, msg, results)
+	}
+}
+
+func writeMessage(w *bytes.Buffer, msg message.Message, results map[string]message.ToolResult) {
+	switch {
+	case msg.IsSummaryMessage:
+		w.WriteString("\n## Summary\n\n")
+	case msg.Role == message.Assistant && msg.Model != "":
+		fmt.Fprintf(w, "\n## Assistant (%s)\n\n", msg.Model)
+	case msg.Role == message.Assistant:
+		w.WriteString("\n## Assistant\n\n")
+	case msg.Role == message.User:
+		w.WriteString("\n## User\n\n")
+	default:
+		fmt.Fprintf(w, "\n## %s\n\n", msg.Role)
+	}
+
+	if text := strings.TrimSpace(msg.Content().Text); text != "" {
+		w.WriteString(text)
+		w.WriteString("\n\n")
+	}
+
+	for _, attachment := range msg.BinaryContent() {
+		fmt.Fprintf(w, "*Attachment: `%s` (%s)*\n\n", attachment.Path, attachment.MIMEType)
+	}
+
+	for _, call := range msg.ToolCalls() {
+		fmt.Fprintf(w, "**Tool call:** `%s`\n\n", call.Name)
+		writeCodeBlock(w, "json", indentJSON(call.Input))
+
+		result, ok := results[call.ID]
+		switch {
+		case !ok:
+			w.WriteString("*No result*\n\n")
+		case result.IsError:
+			w.WriteString("**Error:**\n\n")
+			writeCodeBlock(w, "", result.DisplayText())
+		default:
+			w.WriteString("**Result:**\n\n")
+			writeCodeBlock(w, "", result.DisplayText())
+		}
+	}
+
+	if finish := msg.FinishPart(); finish != nil {
+		switch finish.Reason {
+		case message.FinishReasonCanceled:
+			w.WriteString("*Canceled*\n\n")
+		case message.FinishReasonError:
+			fmt.Fprintf(w, "*Error: %s*\n\n", strings.TrimSpace(finish.Message+" "+finish.Details))
+		case message.FinishReasonPermissionDenied:
+			w.WriteString("*Permission denied*\n\n")
+		}
+	}
+}
+
+// writeCodeBlock writes content in a fenced code block whose fence is longer
+// than any backtick run in content
+func writeCodeBlock(w *bytes.Buffer, lang, content string) {
+	fence := "```"
+	for strings.Contains(content, fence) {
+		fence += "`"
+	}
+	fmt.Fprintf(w, "%s%s\n%s\n%s\n\n", fence, lang, strings.TrimRight(content, "\n"), fence)
+}
+
+func indentJSON(input string) string {
+	var out bytes.Buffer
+	if err := json.Indent(&out, []byte(input), "", "  "); err != nil {
+		return input
+	}
+	return out.String()
+}
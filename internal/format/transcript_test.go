@@ -0,0 +1,58 @@
+package format
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteTranscript(t *testing.T) {
+	t.Parallel()
+
+	sess := session.Session{ID: "s1", Title: "Fix <the> build"}
+	msgs := []message.Message{
+		{Role: message.User, Parts: []message.ContentPart{message.TextContent{Text: "Why does the build fail?"}}},
+		{Role: message.Assistant, Model: "test-model", Parts: []message.ContentPart{
+			message.TextContent{Text: "Let me check."},
+			message.ToolCall{ID: "call-1", Name: "bash", Input: `{"command":"go build ./..."}`},
+		}},
+		{Role: message.Tool, Parts: []message.ContentPart{
+			message.ToolResult{ToolCallID: "call-1", Name: "bash", Content: "main.go:3: ```oops```", IsError: true},
+		}},
+	}
+
+	t.Run("markdown", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+		require.NoError(t, WriteTranscript(&buf, sess, msgs, Markdown))
+		out := buf.String()
+		require.Contains(t, out, "# Fix <the> build\n")
+		require.Contains(t, out, "## User\n\nWhy does the build fail?")
+		require.Contains(t, out, "## Assistant (test-model)\n\nLet me check.")
+		require.Contains(t, out, "**Tool call:** `bash`\n\n```json\n{\n  \"command\": \"go build ./...\"\n}\n```")
+		require.Contains(t, out, "**Error:**\n\n````\nmain.go:3: ```oops```\n````")
+		require.NotContains(t, out, "## tool")
+	})
+
+	t.Run("html", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+		require.NoError(t, WriteTranscript(&buf, sess, msgs, HTML))
+		out := buf.String()
+		require.Contains(t, out, "<title>Fix &lt;the&gt; build</title>")
+		require.Contains(t, out, "<h2>User</h2>")
+		require.Contains(t, out, `<code class="language-json">`)
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		t.Parallel()
+
+		err := WriteTranscript(&bytes.Buffer{}, sess, msgs, "pdf")
+		require.ErrorIs(t, err, ErrUnknownExportFormat)
+	})
+}
@@ -0,0 +1,168 @@
+// Package injector implements a built-in plugin that adds the contents of
+// configured project files to the system prompt of every agent run.
+package injector
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/plugin"
+)
+
+// DefaultMaxBytes is the default budget for injected file content
+const DefaultMaxBytes = 32 * 1024
+
+// truncatedNotice marks a file whose content was cut to fit the budget
+const truncatedNotice = "\n[truncated]"
+
+// Plugin implements the Crush plugin interface for the context injector
+type Plugin struct {
+	plugin.NilAgentHook
+
+	info       plugin.PluginInfo
+	hooks      *plugin.BaseHooks
+	patterns   []string
+	maxBytes   int
+	workingDir string
+}
+
+// NewPlugin returns a plugin that adds the files matching opts.Files to the
+// system prompt.
+func NewPlugin(opts config.ContextInjector) *Plugin {
+	maxBytes := opts.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+	p := &Plugin{
+		info: plugin.PluginInfo{
+			Name:        "crush-context-injector",
+			Version:     "1.0.0",
+			Description: "Adds the contents of project files to the system prompt",
+			Author:      "Crush Team",
+			Homepage:    "https://github.com/charmbracelet/crush",
+			License:     "FSL-1.1-MIT",
+			Tags:        []string{"context", "builtin"},
+		},
+		hooks:    plugin.NewBaseHooks(),
+		patterns: opts.Files,
+		maxBytes: maxBytes,
+	}
+	p.hooks.AgentHook = p
+	return p
+}
+
+// Info returns metadata about the plugin
+func (p *Plugin) Info() plugin.PluginInfo {
+	return p.info
+}
+
+// Init is called when the plugin is loaded
+func (p *Plugin) Init(ctx context.Context, pluginCtx plugin.PluginContext) error {
+	p.workingDir = pluginCtx.WorkingDir
+	for _, pattern := range p.patterns {
+		if !doublestar.ValidatePattern(filepath.ToSlash(pattern)) {
+			return fmt.Errorf("invalid context injector pattern: %q", pattern)
+		}
+	}
+	return nil
+}
+
+// Hooks returns the hook implementations provided by this plugin
+func (p *Plugin) Hooks() plugin.Hooks {
+	return p.hooks
+}
+
+// Shutdown is called when the application is shutting down
+func (p *Plugin) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// OnSystemPrompt implements plugin.SystemPromptHook. Files are read on every
+// run so edits are picked up.
+func (p *Plugin) OnSystemPrompt(ctx context.Context, sessionID, prompt string) (string, error) {
+	injected := p.render()
+	if injected == "" {
+		return prompt, nil
+	}
+	return prompt + "\n\n" + injected, nil
+}
+
+// render returns the matched files formatted for the system prompt, or an
+// empty string if none could be read
+func (p *Plugin) render() string {
+	var b strings.Builder
+	budget := p.maxBytes
+	for _, path := range p.files() {
+		if budget <= 0 {
+			slog.Warn("Context injector budget exhausted, skipping file", "path", path, "max_bytes", p.maxBytes)
+			continue
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			slog.Warn("Failed to read context file", "path", path, "error", err)
+			continue
+		}
+		text := string(content)
+		if len(text) > budget {
+			text = truncate(text, budget) + truncatedNotice
+			slog.Warn("Context file truncated", "path", path, "max_bytes", p.maxBytes)
+		}
+		budget -= len(content)
+
+		name := path
+		if rel, err := filepath.Rel(p.workingDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+			name = rel
+		}
+		fmt.Fprintf(&b, "<file path=%q>\n%s\n</file>\n", name, text)
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return "<injected_context>\n" + b.String() + "</injected_context>"
+}
+
+// files returns the regular files matching the configured patterns, in
+// pattern order, without duplicates. Patterns matching nothing are logged.
+func (p *Plugin) files() []string {
+	var files []string
+	for _, pattern := range p.patterns {
+		expanded, err := plugin.ExpandPath(pattern)
+		if err != nil {
+			slog.Warn("Failed to expand context injector pattern", "pattern", pattern, "error", err)
+			continue
+		}
+		if !filepath.IsAbs(expanded) {
+			expanded = filepath.Join(p.workingDir, expanded)
+		}
+		matches, err := doublestar.FilepathGlob(expanded, doublestar.WithFilesOnly())
+		if err != nil {
+			slog.Warn("Invalid context injector pattern", "pattern", pattern, "error", err)
+			continue
+		}
+		if len(matches) == 0 {
+			slog.Debug("Context injector pattern matched no files", "pattern", pattern)
+		}
+		for _, match := range matches {
+			if !slices.Contains(files, match) {
+				files = append(files, match)
+			}
+		}
+	}
+	return files
+}
+
+// truncate returns at most n bytes of s without splitting a UTF-8 character
+func truncate(s string, n int) string {
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
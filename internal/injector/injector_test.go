@@ -0,0 +1,56 @@
+package injector
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/plugin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextInjector(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "CONVENTIONS.md"), []byte("Use tabs."), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "docs", "api"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "docs", "api", "sessions.md"), []byte(strings.Repeat("é", 20)), 0o644))
+
+	p := NewPlugin(config.ContextInjector{
+		Files:    []string{"CONVENTIONS.md", "missing.md", "docs/**/*.md", "CONVENTIONS.md"},
+		MaxBytes: 30,
+	})
+	require.NoError(t, p.Init(t.Context(), plugin.PluginContext{WorkingDir: dir}))
+
+	prompt, err := p.OnSystemPrompt(t.Context(), "session", "You are Crush.")
+	require.NoError(t, err)
+	require.Equal(t, `You are Crush.
+
+<injected_context>
+<file path="CONVENTIONS.md">
+Use tabs.
+</file>
+<file path="docs/api/sessions.md">
+`+strings.Repeat("é", 10)+`
+[truncated]
+</file>
+</injected_context>`, prompt)
+
+	// Files are re-read on every run
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "CONVENTIONS.md"), []byte("Use spaces."), 0o644))
+	prompt, err = p.OnSystemPrompt(t.Context(), "session", "You are Crush.")
+	require.NoError(t, err)
+	require.Contains(t, prompt, "Use spaces.")
+
+	// Nothing to inject leaves the prompt unchanged
+	empty := NewPlugin(config.ContextInjector{Files: []string{"missing.md"}})
+	require.NoError(t, empty.Init(t.Context(), plugin.PluginContext{WorkingDir: dir}))
+	prompt, err = empty.OnSystemPrompt(t.Context(), "session", "You are Crush.")
+	require.NoError(t, err)
+	require.Equal(t, "You are Crush.", prompt)
+
+	require.Error(t, NewPlugin(config.ContextInjector{Files: []string{"docs/[.md"}}).Init(t.Context(), plugin.PluginContext{WorkingDir: dir}))
+}
@@ -110,10 +110,22 @@ type ToolResult struct {
 	MIMEType   string `json:"mime_type"`
 	Metadata   string `json:"metadata"`
 	IsError    bool   `json:"is_error"`
+
+	// DisplayContent, if set, is shown to the user in place of Content,
+	// which is what the model sees
+	DisplayContent string `json:"display_content,omitempty"`
 }
 
 func (ToolResult) isPart() {}
 
+// DisplayText returns the content to show the user
+func (r ToolResult) DisplayText() string {
+	if r.DisplayContent != "" {
+		return r.DisplayContent
+	}
+	return r.Content
+}
+
 type Finish struct {
 	Reason  FinishReason `json:"reason"`
 	Time    int64        `json:"time"`
@@ -1,6 +1,7 @@
 package message
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
 	"encoding/json"
@@ -13,6 +14,8 @@ import (
 )
 
 type CreateMessageParams struct {
+	// ID is the ID of the new message. A new one is generated if empty.
+	ID               string
 	Role             MessageRole
 	Parts            []ContentPart
 	Model            string
@@ -70,7 +73,7 @@ func (s *service) Create(ctx context.Context, sessionID string, params CreateMes
 		isSummary = 1
 	}
 	dbMessage, err := s.q.CreateMessage(ctx, db.CreateMessageParams{
-		ID:               uuid.New().String(),
+		ID:               cmp.Or(params.ID, uuid.New().String()),
 		SessionID:        sessionID,
 		Role:             string(params.Role),
 		Parts:            string(partsJSON),
@@ -0,0 +1,69 @@
+package permission
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxSymlinks bounds how many symlinks ResolvePath follows, like the OS does
+const maxSymlinks = 40
+
+// ResolvePath returns the absolute form of path, resolving a relative path
+// against workingDir, with symlinks and .. resolved one component at a time
+// the way the OS would, so a .. after a symlink leaves the symlink's target.
+// Components that don't exist yet are kept as is, so creating a file under a
+// symlinked directory, or through a dangling symlink, resolves to where the
+// file would end up.
+func ResolvePath(workingDir, path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = workingDir + string(filepath.Separator) + path
+	}
+	links := 0
+	return resolveFrom(filepath.VolumeName(path)+string(filepath.Separator), path[len(filepath.VolumeName(path)):], &links)
+}
+
+// resolveFrom resolves the components of rest relative to the resolved
+// directory dir
+func resolveFrom(dir, rest string, links *int) (string, error) {
+	resolved := dir
+	for _, part := range strings.Split(rest, string(filepath.Separator)) {
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+		next := filepath.Join(resolved, part)
+		info, err := os.Lstat(next)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				return "", err
+			}
+			resolved = next
+			continue
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		if *links++; *links > maxSymlinks {
+			return "", fmt.Errorf("too many levels of symbolic links: %s", next)
+		}
+		target, err := os.Readlink(next)
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			resolved = filepath.VolumeName(target) + string(filepath.Separator)
+			target = target[len(filepath.VolumeName(target)):]
+		}
+		if resolved, err = resolveFrom(resolved, target, links); err != nil {
+			return "", err
+		}
+	}
+	return resolved, nil
+}
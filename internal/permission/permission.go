@@ -3,10 +3,12 @@ package permission
 import (
 	"context"
 	"errors"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"slices"
 	"sync"
+	"time"
 
 	"github.com/charmbracelet/crush/internal/csync"
 	"github.com/charmbracelet/crush/internal/pubsub"
@@ -23,12 +25,18 @@ type CreatePermissionRequest struct {
 	Action      string `json:"action"`
 	Params      any    `json:"params"`
 	Path        string `json:"path"`
+
+	// ResolvedPath is Path made absolute against the working directory,
+	// with symlinks and .. resolved. It is filled in before permission hooks
+	// run, and is empty if Path is empty or can't be resolved.
+	ResolvedPath string `json:"resolved_path,omitempty"`
 }
 
 type PermissionNotification struct {
 	ToolCallID string `json:"tool_call_id"`
 	Granted    bool   `json:"granted"`
 	Denied     bool   `json:"denied"`
+	TimedOut   bool   `json:"timed_out"` // decided because the user didn't answer in time
 }
 
 type PermissionRequest struct {
@@ -48,7 +56,10 @@ type Service interface {
 	Grant(permission PermissionRequest)
 	Deny(permission PermissionRequest)
 	Request(opts CreatePermissionRequest) bool
+	Resolve(opts CreatePermissionRequest) CreatePermissionRequest
 	AutoApproveSession(sessionID string)
+	AutoApproveSessionTools(sessionID string, tools []string)
+	InheritSession(sessionID, parentSessionID string)
 	SetSkipRequests(skip bool)
 	SkipRequests() bool
 	SubscribeNotifications(ctx context.Context) <-chan pubsub.Event[PermissionNotification]
@@ -63,13 +74,33 @@ type permissionService struct {
 	sessionPermissionsMu  sync.RWMutex
 	pendingRequests       *csync.Map[string, chan bool]
 	autoApproveSessions   map[string]bool
+	autoApproveTools      map[string][]string // session ID -> auto-approved tools
+	sessionParents        map[string]string   // session ID -> parent session ID
 	autoApproveSessionsMu sync.RWMutex
 	skip                  bool
 	allowedTools          []string
 
+	// promptTimeout bounds how long a request waits for the user, after
+	// which it is decided by timeoutAllows. Zero waits forever.
+	promptTimeout time.Duration
+	timeoutAllows bool
+
 	// used to make sure we only process one request at a time
-	requestMu     sync.Mutex
-	activeRequest *PermissionRequest
+	requestMu sync.Mutex
+
+	// activeRequest is set while Request holds requestMu and cleared by
+	// whoever answers it, so it has its own lock
+	activeRequest   *PermissionRequest
+	activeRequestMu sync.Mutex
+}
+
+// clearActiveRequest forgets the active request if it has the given ID
+func (s *permissionService) clearActiveRequest(id string) {
+	s.activeRequestMu.Lock()
+	defer s.activeRequestMu.Unlock()
+	if s.activeRequest != nil && s.activeRequest.ID == id {
+		s.activeRequest = nil
+	}
 }
 
 func (s *permissionService) GrantPersistent(permission PermissionRequest) {
@@ -86,9 +117,7 @@ func (s *permissionService) GrantPersistent(permission PermissionRequest) {
 	s.sessionPermissions = append(s.sessionPermissions, permission)
 	s.sessionPermissionsMu.Unlock()
 
-	if s.activeRequest != nil && s.activeRequest.ID == permission.ID {
-		s.activeRequest = nil
-	}
+	s.clearActiveRequest(permission.ID)
 }
 
 func (s *permissionService) Grant(permission PermissionRequest) {
@@ -101,9 +130,7 @@ func (s *permissionService) Grant(permission PermissionRequest) {
 		respCh <- true
 	}
 
-	if s.activeRequest != nil && s.activeRequest.ID == permission.ID {
-		s.activeRequest = nil
-	}
+	s.clearActiveRequest(permission.ID)
 }
 
 func (s *permissionService) Deny(permission PermissionRequest) {
@@ -117,9 +144,22 @@ func (s *permissionService) Deny(permission PermissionRequest) {
 		respCh <- false
 	}
 
-	if s.activeRequest != nil && s.activeRequest.ID == permission.ID {
-		s.activeRequest = nil
+	s.clearActiveRequest(permission.ID)
+}
+
+// Resolve returns opts with ResolvedPath filled in from Path
+func (s *permissionService) Resolve(opts CreatePermissionRequest) CreatePermissionRequest {
+	opts.ResolvedPath = ""
+	if opts.Path == "" {
+		return opts
 	}
+	resolved, err := ResolvePath(s.workingDir, opts.Path)
+	if err != nil {
+		slog.Warn("Can't resolve permission request path", "tool", opts.ToolName, "path", opts.Path, "error", err)
+		return opts
+	}
+	opts.ResolvedPath = resolved
+	return opts
 }
 
 func (s *permissionService) Request(opts CreatePermissionRequest) bool {
@@ -141,7 +181,15 @@ func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 	}
 
 	s.autoApproveSessionsMu.RLock()
-	autoApprove := s.autoApproveSessions[opts.SessionID]
+	autoApprove := false
+	seen := map[string]bool{}
+	for sessionID := opts.SessionID; !autoApprove && !seen[sessionID]; sessionID = s.sessionParents[sessionID] {
+		seen[sessionID] = true
+		sessionTools := s.autoApproveTools[sessionID]
+		autoApprove = s.autoApproveSessions[sessionID] ||
+			slices.Contains(sessionTools, commandKey) ||
+			slices.Contains(sessionTools, opts.ToolName)
+	}
 	s.autoApproveSessionsMu.RUnlock()
 
 	if autoApprove {
@@ -190,7 +238,9 @@ func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 	}
 	s.sessionPermissionsMu.RUnlock()
 
+	s.activeRequestMu.Lock()
 	s.activeRequest = &permission
+	s.activeRequestMu.Unlock()
 
 	respCh := make(chan bool, 1)
 	s.pendingRequests.Set(permission.ID, respCh)
@@ -199,7 +249,35 @@ func (s *permissionService) Request(opts CreatePermissionRequest) bool {
 	// Publish the request
 	s.Publish(pubsub.CreatedEvent, permission)
 
-	return <-respCh
+	if s.promptTimeout <= 0 {
+		return <-respCh
+	}
+	timer := time.NewTimer(s.promptTimeout)
+	defer timer.Stop()
+	select {
+	case granted := <-respCh:
+		return granted
+	case <-timer.C:
+		return s.timeOut(permission)
+	}
+}
+
+// timeOut decides a request the user didn't answer in time
+func (s *permissionService) timeOut(permission PermissionRequest) bool {
+	slog.Warn("Permission request timed out",
+		"tool", permission.ToolName,
+		"action", permission.Action,
+		"timeout", s.promptTimeout,
+		"granted", s.timeoutAllows,
+	)
+	s.notificationBroker.Publish(pubsub.CreatedEvent, PermissionNotification{
+		ToolCallID: permission.ToolCallID,
+		Granted:    s.timeoutAllows,
+		Denied:     !s.timeoutAllows,
+		TimedOut:   true,
+	})
+	s.clearActiveRequest(permission.ID)
+	return s.timeoutAllows
 }
 
 func (s *permissionService) AutoApproveSession(sessionID string) {
@@ -208,6 +286,23 @@ func (s *permissionService) AutoApproveSession(sessionID string) {
 	s.autoApproveSessionsMu.Unlock()
 }
 
+// AutoApproveSessionTools approves requests from the given tools in a session
+// without prompting. Like allowed tools, entries are either a tool name or a
+// "tool:action" pair. Requests from other tools go through the usual flow.
+func (s *permissionService) AutoApproveSessionTools(sessionID string, tools []string) {
+	s.autoApproveSessionsMu.Lock()
+	s.autoApproveTools[sessionID] = append(s.autoApproveTools[sessionID], tools...)
+	s.autoApproveSessionsMu.Unlock()
+}
+
+// InheritSession makes a session, e.g. the child session of a sub-agent,
+// auto-approve whatever its parent session auto-approves, now or later
+func (s *permissionService) InheritSession(sessionID, parentSessionID string) {
+	s.autoApproveSessionsMu.Lock()
+	s.sessionParents[sessionID] = parentSessionID
+	s.autoApproveSessionsMu.Unlock()
+}
+
 func (s *permissionService) SubscribeNotifications(ctx context.Context) <-chan pubsub.Event[PermissionNotification] {
 	return s.notificationBroker.Subscribe(ctx)
 }
@@ -220,15 +315,34 @@ func (s *permissionService) SkipRequests() bool {
 	return s.skip
 }
 
-func NewPermissionService(workingDir string, skip bool, allowedTools []string) Service {
-	return &permissionService{
+// Option configures a permission service
+type Option func(*permissionService)
+
+// WithPromptTimeout decides requests the user hasn't answered within timeout,
+// granting them if allow is set and denying them otherwise. A timeout of zero
+// waits for the user forever.
+func WithPromptTimeout(timeout time.Duration, allow bool) Option {
+	return func(s *permissionService) {
+		s.promptTimeout = timeout
+		s.timeoutAllows = allow
+	}
+}
+
+func NewPermissionService(workingDir string, skip bool, allowedTools []string, opts ...Option) Service {
+	s := &permissionService{
 		Broker:              pubsub.NewBroker[PermissionRequest](),
 		notificationBroker:  pubsub.NewBroker[PermissionNotification](),
 		workingDir:          workingDir,
 		sessionPermissions:  make([]PermissionRequest, 0),
 		autoApproveSessions: make(map[string]bool),
+		autoApproveTools:    make(map[string][]string),
+		sessionParents:      make(map[string]string),
 		skip:                skip,
 		allowedTools:        allowedTools,
 		pendingRequests:     csync.NewMap[string, chan bool](),
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
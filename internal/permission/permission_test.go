@@ -1,10 +1,14 @@
 package permission
 
 import (
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestPermissionService_AllowedCommands(t *testing.T) {
@@ -94,6 +98,132 @@ func TestPermissionService_SkipMode(t *testing.T) {
 	}
 }
 
+func TestPermissionService_AutoApproveSessionTools(t *testing.T) {
+	service := NewPermissionService("/tmp", false, []string{})
+	service.AutoApproveSessionTools("session1", []string{"view", "edit:write"})
+
+	request := func(sessionID, toolName, action string) CreatePermissionRequest {
+		return CreatePermissionRequest{
+			SessionID: sessionID,
+			ToolName:  toolName,
+			Action:    action,
+			Path:      "/tmp",
+		}
+	}
+
+	assert.True(t, service.Request(request("session1", "view", "read")), "tool in scope should be approved")
+	assert.True(t, service.Request(request("session1", "edit", "write")), "tool:action in scope should be approved")
+
+	// Requests outside the scope go through the usual flow
+	events := service.Subscribe(t.Context())
+	for _, req := range []CreatePermissionRequest{
+		request("session1", "bash", "execute"),
+		request("session1", "edit", "create"),
+		request("session2", "view", "read"),
+	} {
+		done := make(chan bool, 1)
+		go func() { done <- service.Request(req) }()
+
+		event := <-events
+		assert.Equal(t, req.ToolName, event.Payload.ToolName)
+		service.Deny(event.Payload)
+		assert.False(t, <-done)
+	}
+}
+
+func TestPermissionService_InheritSession(t *testing.T) {
+	service := NewPermissionService("/tmp", false, []string{}, WithPromptTimeout(10*time.Millisecond, false))
+	service.AutoApproveSession("approved")
+	service.AutoApproveSessionTools("scoped", []string{"view"})
+	service.InheritSession("child", "approved")
+	service.InheritSession("grandchild", "child")
+	service.InheritSession("scoped-child", "scoped")
+	service.InheritSession("loop-a", "loop-b")
+	service.InheritSession("loop-b", "loop-a")
+
+	request := func(sessionID, toolName string) CreatePermissionRequest {
+		return CreatePermissionRequest{SessionID: sessionID, ToolName: toolName, Action: "execute", Path: "/tmp"}
+	}
+	assert.True(t, service.Request(request("child", "bash")))
+	assert.True(t, service.Request(request("grandchild", "bash")))
+	assert.True(t, service.Request(request("scoped-child", "view")))
+
+	// The others prompt, and time out unanswered
+	assert.False(t, service.Request(request("scoped-child", "bash")))
+	assert.False(t, service.Request(request("loop-a", "bash")))
+}
+
+func TestPermissionService_Resolve(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	project := filepath.Join(root, "project")
+	secrets := filepath.Join(root, "secrets")
+	require.NoError(t, os.MkdirAll(filepath.Join(project, "src"), 0o755))
+	require.NoError(t, os.MkdirAll(secrets, 0o755))
+	require.NoError(t, os.Symlink(secrets, filepath.Join(project, "link")))
+	require.NoError(t, os.Symlink("loop", filepath.Join(project, "loop")))
+
+	service := NewPermissionService(project, false, []string{})
+	for path, resolved := range map[string]string{
+		"":                               "",
+		"src/main.go":                    filepath.Join(project, "src", "main.go"),
+		"./src/../README.md":             filepath.Join(project, "README.md"),
+		"../secrets/key":                 filepath.Join(secrets, "key"),
+		project + "/src/../../secrets":   secrets,
+		"link/key":                       filepath.Join(secrets, "key"),
+		"link/../project/src":            filepath.Join(root, "project", "src"),
+		filepath.Join(project, "link"):   secrets,
+		filepath.Join(project, "new/f"):  filepath.Join(project, "new", "f"),
+		filepath.Join(project, "loop/x"): "",
+	} {
+		req := service.Resolve(CreatePermissionRequest{Path: path, ResolvedPath: "stale"})
+		assert.Equal(t, resolved, req.ResolvedPath, path)
+		assert.Equal(t, path, req.Path, "the requested path is kept")
+	}
+}
+
+func TestPermissionService_PromptTimeout(t *testing.T) {
+	req := CreatePermissionRequest{
+		SessionID:  "session1",
+		ToolCallID: "call1",
+		ToolName:   "bash",
+		Action:     "execute",
+		Path:       "/tmp",
+	}
+
+	for _, allow := range []bool{false, true} {
+		service := NewPermissionService("/tmp", false, []string{}, WithPromptTimeout(20*time.Millisecond, allow))
+		notifications := service.SubscribeNotifications(t.Context())
+
+		assert.Equal(t, allow, service.Request(req), "unanswered request should be decided by the timeout")
+
+		<-notifications // the request
+		notification := <-notifications
+		assert.Equal(t, PermissionNotification{ToolCallID: "call1", Granted: allow, Denied: !allow, TimedOut: true}, notification.Payload)
+	}
+
+	// Requests answered in time aren't affected
+	service := NewPermissionService("/tmp", false, []string{}, WithPromptTimeout(time.Minute, false))
+	events := service.Subscribe(t.Context())
+	done := make(chan bool, 1)
+	go func() { done <- service.Request(req) }()
+	service.Grant((<-events).Payload)
+	assert.True(t, <-done)
+
+	// Answers finishing up while the next request starts or times out
+	// don't race with it
+	service = NewPermissionService("/tmp", false, []string{}, WithPromptTimeout(time.Millisecond, false))
+	events = service.Subscribe(t.Context())
+	go func() {
+		for event := range events {
+			go service.Grant(event.Payload)
+		}
+	}()
+	for range 10 {
+		service.Request(req)
+	}
+}
+
 func TestPermissionService_SequentialProperties(t *testing.T) {
 	t.Run("Sequential permission requests with persistent grants", func(t *testing.T) {
 		service := NewPermissionService("/tmp", false, []string{})
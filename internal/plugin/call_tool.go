@@ -0,0 +1,107 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"charm.land/fantasy"
+)
+
+// MaxToolCallDepth limits how deeply plugin tools may call tools through
+// Registry.CallTool. A tool calling another that calls a third is two levels
+// deep.
+const MaxToolCallDepth = 4
+
+// ToolRunner runs the tools offered to the agent, built-in or from plugins,
+// with their hooks and permission requests. The app provides it to the
+// registry.
+type ToolRunner interface {
+	// RunTool runs the named tool with JSON arguments in the session
+	RunTool(ctx context.Context, sessionID, name string, args json.RawMessage) (fantasy.ToolResponse, error)
+
+	// ToolInfos describes the tools offered to the agent
+	ToolInfos(ctx context.Context) ([]fantasy.ToolInfo, error)
+}
+
+// ToolCaller lets plugin tools list and invoke the other tools offered to
+// the agent, e.g. to chain several of them in one step
+type ToolCaller interface {
+	// CallTool runs the named tool with JSON arguments in the session of
+	// ctx, which must be the ctx the calling tool was run with
+	CallTool(ctx context.Context, name string, args json.RawMessage) (fantasy.ToolResponse, error)
+
+	// ListTools describes the tools that can be called
+	ListTools(ctx context.Context) ([]fantasy.ToolInfo, error)
+}
+
+type toolCallContextKey string
+
+const toolCallsKey toolCallContextKey = "plugin_tool_calls"
+
+// toolCalls returns the names of the plugin tools running in ctx, outermost
+// first
+func toolCalls(ctx context.Context) []string {
+	calls, _ := ctx.Value(toolCallsKey).([]string)
+	return calls
+}
+
+// withToolCall returns ctx for running the named plugin tool
+func withToolCall(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, toolCallsKey, append(slices.Clip(toolCalls(ctx)), name))
+}
+
+// SetToolRunner sets the runner that CallTool and ListTools use
+func (r *Registry) SetToolRunner(runner ToolRunner) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.toolRunner = runner
+}
+
+func (r *Registry) getToolRunner() (ToolRunner, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.toolRunner == nil {
+		return nil, ErrNoToolRunner
+	}
+	return r.toolRunner, nil
+}
+
+// CallTool implements ToolCaller. The tool runs like one the agent called:
+// its hooks run and it requests permission. It fails with ErrToolRecursion
+// if the tool is already running in ctx, or if plugin tools are nested
+// MaxToolCallDepth levels deep, and with ctx's error once the agent run is
+// canceled or aborted, e.g. by a cost budget.
+func (r *Registry) CallTool(ctx context.Context, name string, args json.RawMessage) (fantasy.ToolResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return fantasy.ToolResponse{}, err
+	}
+	sessionID := SessionID(ctx)
+	if sessionID == "" {
+		return fantasy.ToolResponse{}, fmt.Errorf("cannot call tool %s: ctx doesn't belong to a session", name)
+	}
+	calls := toolCalls(ctx)
+	if slices.Contains(calls, name) {
+		return fantasy.ToolResponse{}, fmt.Errorf("%w: %s calls itself through %s", ErrToolRecursion, name, strings.Join(calls, " -> "))
+	}
+	if len(calls) >= MaxToolCallDepth {
+		return fantasy.ToolResponse{}, fmt.Errorf("%w: tool calls nested more than %d levels deep", ErrToolRecursion, MaxToolCallDepth)
+	}
+
+	runner, err := r.getToolRunner()
+	if err != nil {
+		return fantasy.ToolResponse{}, err
+	}
+	return runner.RunTool(ctx, sessionID, name, args)
+}
+
+// ListTools implements ToolCaller
+func (r *Registry) ListTools(ctx context.Context) ([]fantasy.ToolInfo, error) {
+	runner, err := r.getToolRunner()
+	if err != nil {
+		return nil, err
+	}
+	return runner.ToolInfos(ctx)
+}
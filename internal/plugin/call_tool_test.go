@@ -0,0 +1,107 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/agent/tools"
+	"github.com/stretchr/testify/require"
+)
+
+// relayTool calls the next tool through the registry, or answers itself if
+// there is none
+type relayTool struct {
+	name, next string
+	registry   *Registry
+}
+
+func (t *relayTool) Info() fantasy.ToolInfo { return fantasy.ToolInfo{Name: t.name} }
+
+func (t *relayTool) Run(ctx context.Context, params fantasy.ToolCall) (fantasy.ToolResponse, error) {
+	if t.next == "" {
+		return fantasy.NewTextResponse("reached " + t.name), nil
+	}
+	return t.registry.CallTool(ctx, t.next, nil)
+}
+
+type relayPlugin struct {
+	flakyPlugin
+	tools []PluginTool
+}
+
+func (p *relayPlugin) GetTools() []PluginTool { return p.tools }
+
+// registryToolRunner runs the registry's plugin tools like the app would
+type registryToolRunner struct {
+	registry *Registry
+}
+
+func (r *registryToolRunner) RunTool(ctx context.Context, sessionID, name string, args json.RawMessage) (fantasy.ToolResponse, error) {
+	for _, tool := range r.registry.GetPluginTools() {
+		if tool.Info().Name == name {
+			return tool.Run(ctx, fantasy.ToolCall{ID: "call-" + name, Name: name, Input: string(args)})
+		}
+	}
+	return fantasy.ToolResponse{}, fmt.Errorf("unknown tool %s", name)
+}
+
+func (r *registryToolRunner) ToolInfos(ctx context.Context) ([]fantasy.ToolInfo, error) {
+	var infos []fantasy.ToolInfo
+	for _, tool := range r.registry.GetPluginTools() {
+		infos = append(infos, tool.Info())
+	}
+	return infos, nil
+}
+
+func TestCallTool(t *testing.T) {
+	t.Parallel()
+
+	registry := NewRegistry()
+	_, err := registry.ListTools(t.Context())
+	require.ErrorIs(t, err, ErrNoToolRunner)
+
+	registry.SetToolRunner(&registryToolRunner{registry: registry})
+	require.NoError(t, registry.LoadPlugin(t.Context(), &relayPlugin{
+		flakyPlugin: flakyPlugin{name: "relays"},
+		tools: []PluginTool{
+			&relayTool{name: "a", next: "b", registry: registry},
+			&relayTool{name: "b", next: "c", registry: registry},
+			&relayTool{name: "c", next: "d", registry: registry},
+			&relayTool{name: "d", registry: registry},
+			&relayTool{name: "too-deep", next: "a", registry: registry},
+			&relayTool{name: "loop", next: "loop", registry: registry},
+			&relayTool{name: "ping", next: "pong", registry: registry},
+			&relayTool{name: "pong", next: "ping", registry: registry},
+		},
+	}, PluginContext{}))
+
+	infos, err := registry.ListTools(t.Context())
+	require.NoError(t, err)
+	require.Len(t, infos, 8)
+
+	ctx := context.WithValue(t.Context(), tools.SessionIDContextKey, "session-1")
+	_, err = registry.CallTool(t.Context(), "a", nil)
+	require.ErrorContains(t, err, "doesn't belong to a session")
+
+	resp, err := registry.CallTool(ctx, "a", nil)
+	require.NoError(t, err)
+	require.Equal(t, "reached d", resp.Content)
+
+	_, err = registry.CallTool(ctx, "too-deep", nil)
+	require.ErrorIs(t, err, ErrToolRecursion)
+
+	_, err = registry.CallTool(ctx, "loop", nil)
+	require.ErrorIs(t, err, ErrToolRecursion)
+
+	_, err = registry.CallTool(ctx, "ping", nil)
+	require.ErrorIs(t, err, ErrToolRecursion)
+	require.ErrorContains(t, err, "ping -> pong")
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = registry.CallTool(canceled, "d", nil)
+	require.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,76 @@
+package plugin
+
+import (
+	"cmp"
+	"context"
+	"io"
+	"log/slog"
+	"regexp"
+	"slices"
+)
+
+// CommandProvider is an interface that plugins can implement to provide
+// slash commands that the user runs from the TUI
+type CommandProvider interface {
+	// GetCommands returns the list of commands provided by this plugin
+	GetCommands() []PluginCommand
+}
+
+// CommandHandler runs a plugin command. args holds the values the user
+// entered for the command's Args, in order. Output written to out is shown
+// to the user once the handler returns; a returned error is reported instead.
+// SessionID(ctx) is the session the command was run in, if any.
+type CommandHandler func(ctx context.Context, args []string, out io.Writer) error
+
+// PluginCommand describes a slash command provided by a plugin
+type PluginCommand struct {
+	// Name is the command name without the leading slash, e.g. "metrics".
+	// It must be lowercase alphanumeric with hyphens or underscores.
+	Name string
+
+	// Description is shown next to the command in the command list
+	Description string
+
+	// Args names the arguments the user is prompted for before the
+	// command runs, if any
+	Args []string
+
+	// Handler runs the command
+	Handler CommandHandler
+}
+
+// RegisteredCommand is a command along with the plugin that provides it
+type RegisteredCommand struct {
+	PluginCommand
+
+	// Plugin is the name of the plugin that provides the command
+	Plugin string
+}
+
+var commandNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// GetPluginCommands returns the commands of all loaded plugins, sorted by
+// name and then by plugin. Commands with an invalid name or no handler are
+// skipped and logged.
+func (r *Registry) GetPluginCommands() []RegisteredCommand {
+	var commands []RegisteredCommand
+
+	for name, plugin := range r.plugins.Seq2() {
+		commandProvider, ok := plugin.(CommandProvider)
+		if !ok {
+			continue
+		}
+		for _, command := range commandProvider.GetCommands() {
+			if !commandNamePattern.MatchString(command.Name) || command.Handler == nil {
+				slog.Warn("Skipping invalid plugin command", "plugin", name, "command", command.Name)
+				continue
+			}
+			commands = append(commands, RegisteredCommand{PluginCommand: command, Plugin: name})
+		}
+	}
+
+	slices.SortFunc(commands, func(a, b RegisteredCommand) int {
+		return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.Plugin, b.Plugin))
+	})
+	return commands
+}
@@ -0,0 +1,57 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type commandPlugin struct {
+	flakyPlugin
+	commands []PluginCommand
+}
+
+func (p *commandPlugin) GetCommands() []PluginCommand { return p.commands }
+
+func TestGetPluginCommands(t *testing.T) {
+	t.Parallel()
+
+	greet := func(ctx context.Context, args []string, out io.Writer) error {
+		_, err := fmt.Fprintf(out, "hello %s", strings.Join(args, " "))
+		return err
+	}
+
+	r := NewRegistry()
+	require.NoError(t, r.LoadPlugin(t.Context(), &commandPlugin{
+		flakyPlugin: flakyPlugin{name: "b"},
+		commands: []PluginCommand{
+			{Name: "greet", Args: []string{"NAME"}, Handler: greet},
+			{Name: "Bad Name", Handler: greet},
+			{Name: "no-handler"},
+		},
+	}, PluginContext{}))
+	require.NoError(t, r.LoadPlugin(t.Context(), &commandPlugin{
+		flakyPlugin: flakyPlugin{name: "a"},
+		commands:    []PluginCommand{{Name: "greet", Handler: greet}, {Name: "about", Handler: greet}},
+	}, PluginContext{}))
+
+	commands := r.GetPluginCommands()
+	require.Len(t, commands, 3, "invalid commands must be skipped")
+	require.Equal(t, "about", commands[0].Name)
+	require.Equal(t, "a", commands[1].Plugin)
+	require.Equal(t, "b", commands[2].Plugin)
+
+	var out strings.Builder
+	require.NoError(t, commands[2].Handler(t.Context(), []string{"crush"}, &out))
+	require.Equal(t, "hello crush", out.String())
+
+	for _, d := range r.DescribePlugins() {
+		if d.Info.Name == "a" {
+			require.Equal(t, []string{"greet", "about"}, d.Commands)
+		}
+	}
+}
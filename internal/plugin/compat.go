@@ -0,0 +1,107 @@
+package plugin
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// SDKVersionSymbol is the optional symbol a Go plugin exports with the SDK
+// version it was built against, e.g. var SDKVersion = crushsdk.SDKVersion.
+// It is checked before the plugin's Plugin symbol is used.
+const SDKVersionSymbol = "SDKVersion"
+
+// NewPluginSymbol is the optional symbol a Go plugin exports to create fresh
+// instances of itself, e.g. func NewPlugin() crushsdk.Plugin. Go can't unload
+// a .so file, so a quarantined plugin is only reloaded if it exports one.
+const NewPluginSymbol = "NewPlugin"
+
+// rebuildHint tells users how to fix a plugin built against other versions
+var rebuildHint = fmt.Sprintf("rebuild the plugin with Go %s and the module versions this Crush build uses, e.g. by requiring the same version of github.com/charmbracelet/crush in its go.mod", strings.TrimPrefix(runtime.Version(), "go"))
+
+// differentPackagePattern matches the error plugin.Open returns when the
+// plugin and Crush were built with different versions of a shared package
+var differentPackagePattern = regexp.MustCompile(`different version of package (\S+)`)
+
+// openError explains why plugin.Open failed, if it is because the plugin was
+// built against other versions of Crush's packages or another Go toolchain
+func openError(path string, err error) error {
+	match := differentPackagePattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return fmt.Errorf("failed to open plugin: %w", err)
+	}
+	return &PluginError{Path: path, Err: fmt.Errorf("%w: package %s differs; %s", ErrBuildMismatch, match[1], rebuildHint)}
+}
+
+// checkSDKSymbol checks the SDK version the plugin exports, if it does
+func checkSDKSymbol(lookup func(string) (any, error)) error {
+	symbol, err := lookup(SDKVersionSymbol)
+	if err != nil {
+		return nil
+	}
+	var version string
+	switch v := symbol.(type) {
+	case *string:
+		version = *v
+	case string:
+		version = v
+	default:
+		return nil
+	}
+	if err := checkSDKVersion(version); err != nil {
+		return fmt.Errorf("%w; %s", err, rebuildHint)
+	}
+	return nil
+}
+
+// asPlugin returns the Plugin a plugin's symbol holds. The symbol is either
+// a Plugin or, for the usual var Plugin crushsdk.Plugin = ..., a pointer to
+// one. Otherwise the error explains what doesn't match.
+func asPlugin(symbol any) (Plugin, error) {
+	switch v := symbol.(type) {
+	case Plugin:
+		return v, nil
+	case *Plugin:
+		if *v == nil {
+			return nil, fmt.Errorf("%w: the Plugin variable is nil", ErrIncompatibleInterface)
+		}
+		return *v, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrIncompatibleInterface, describeMismatch(symbol))
+}
+
+// describeMismatch explains why symbol doesn't implement Plugin. A symbol
+// whose methods have the right names but other types was most likely built
+// against another version of the SDK or of a package its types come from,
+// such as fantasy.
+func describeMismatch(symbol any) string {
+	value := reflect.ValueOf(symbol)
+	// Look through a pointer to a variable of another interface type
+	if value.Kind() == reflect.Pointer && value.Elem().Kind() == reflect.Interface && !value.Elem().IsNil() {
+		value = value.Elem().Elem()
+	}
+
+	var missing, different []string
+	want := reflect.TypeFor[Plugin]()
+	for i := range want.NumMethod() {
+		method := want.Method(i)
+		got := value.MethodByName(method.Name)
+		switch {
+		case !got.IsValid():
+			missing = append(missing, method.Name)
+		case got.Type() != method.Type:
+			different = append(different, method.Name)
+		}
+	}
+
+	switch {
+	case len(missing) > 0:
+		return fmt.Sprintf("%s lacks the methods %s", value.Type(), strings.Join(missing, ", "))
+	case len(different) > 0:
+		return fmt.Sprintf("the methods %s of %s have different types than this Crush build expects, so the plugin was likely built against a different version of the plugin SDK or of its dependencies such as charm.land/fantasy; %s", strings.Join(different, ", "), value.Type(), rebuildHint)
+	default:
+		return fmt.Sprintf("%s does not implement crushsdk.Plugin", value.Type())
+	}
+}
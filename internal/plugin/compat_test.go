@@ -0,0 +1,90 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// stalePlugin looks like a plugin built against another SDK version, whose
+// PluginContext was a different type
+type stalePlugin struct{}
+
+type stalePluginContext struct{}
+
+func (stalePlugin) Info() PluginInfo                                             { return PluginInfo{Name: "stale"} }
+func (stalePlugin) Init(ctx context.Context, pluginCtx stalePluginContext) error { return nil }
+func (stalePlugin) Hooks() Hooks                                                 { return NewBaseHooks() }
+func (stalePlugin) Shutdown(ctx context.Context) error                           { return nil }
+
+func TestAsPlugin(t *testing.T) {
+	t.Parallel()
+
+	p, err := asPlugin(&flakyPlugin{name: "direct"})
+	require.NoError(t, err)
+	require.Equal(t, "direct", p.Info().Name)
+
+	// var Plugin crushsdk.Plugin = ... is looked up as a pointer
+	var variable Plugin = &flakyPlugin{name: "variable"}
+	p, err = asPlugin(&variable)
+	require.NoError(t, err)
+	require.Equal(t, "variable", p.Info().Name)
+
+	var unset Plugin
+	_, err = asPlugin(&unset)
+	require.ErrorIs(t, err, ErrIncompatibleInterface)
+	require.ErrorContains(t, err, "nil")
+
+	_, err = asPlugin(stalePlugin{})
+	require.ErrorIs(t, err, ErrIncompatibleInterface)
+	require.ErrorContains(t, err, "methods Init of plugin.stalePlugin have different types")
+	require.ErrorContains(t, err, "rebuild the plugin")
+
+	// Variables of a stale Plugin interface are looked through
+	var staleVariable interface{ Info() PluginInfo } = stalePlugin{}
+	_, err = asPlugin(&staleVariable)
+	require.ErrorContains(t, err, "methods Init of plugin.stalePlugin")
+
+	_, err = asPlugin(&struct{ Name string }{})
+	require.ErrorIs(t, err, ErrIncompatibleInterface)
+	require.ErrorContains(t, err, "lacks the methods Hooks, Info, Init, Shutdown")
+}
+
+func TestOpenError(t *testing.T) {
+	t.Parallel()
+
+	err := openError("/p/x.so", errors.New(`plugin.Open("/p/x.so"): plugin was built with a different version of package charm.land/fantasy`))
+	require.ErrorIs(t, err, ErrBuildMismatch)
+	require.ErrorContains(t, err, "package charm.land/fantasy differs")
+	require.ErrorContains(t, err, "rebuild the plugin")
+	var pluginErr *PluginError
+	require.ErrorAs(t, err, &pluginErr)
+	require.Equal(t, "/p/x.so", pluginErr.Path)
+
+	other := errors.New("realpath failed")
+	err = openError("/p/x.so", other)
+	require.ErrorIs(t, err, other)
+	require.NotErrorIs(t, err, ErrBuildMismatch)
+}
+
+func TestCheckSDKSymbol(t *testing.T) {
+	t.Parallel()
+
+	lookup := func(version any) func(string) (any, error) {
+		return func(name string) (any, error) {
+			require.Equal(t, SDKVersionSymbol, name)
+			if version == nil {
+				return nil, errors.New("symbol not found")
+			}
+			return version, nil
+		}
+	}
+	current := SDKVersion
+	newer := "99.0.0"
+	require.NoError(t, checkSDKSymbol(lookup(nil)))
+	require.NoError(t, checkSDKSymbol(lookup(&current)))
+	require.NoError(t, checkSDKSymbol(lookup(42)), "symbols of other types are ignored")
+	require.ErrorIs(t, checkSDKSymbol(lookup(&newer)), ErrIncompatibleSDK)
+}
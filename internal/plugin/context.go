@@ -0,0 +1,62 @@
+package plugin
+
+import (
+	"context"
+
+	"github.com/charmbracelet/crush/internal/agent/tools"
+)
+
+type abortContextKey string
+
+// AbortContextKey is the context key under which the agent stores the abort
+// function for the current run.
+const AbortContextKey abortContextKey = "plugin_abort"
+
+// AbortFunc cancels the current agent run, surfacing reason to the user. It
+// reports whether the run was still active.
+type AbortFunc func(reason string) bool
+
+// WithAbort returns a copy of ctx carrying an abort function for the current
+// agent run.
+func WithAbort(ctx context.Context, abort AbortFunc) context.Context {
+	return context.WithValue(ctx, AbortContextKey, abort)
+}
+
+// Abort cancels the agent run associated with ctx. It reports whether ctx
+// belongs to an agent run that is still active; otherwise, the abort is
+// ignored.
+func Abort(ctx context.Context, reason string) bool {
+	abort, ok := ctx.Value(AbortContextKey).(AbortFunc)
+	if !ok || abort == nil {
+		return false
+	}
+	return abort(reason)
+}
+
+// SessionID returns the ID of the session ctx belongs to, as passed to tools
+// and plugin commands. It is empty if ctx doesn't belong to a session.
+func SessionID(ctx context.Context) string {
+	return tools.GetSessionFromContext(ctx)
+}
+
+// MessageID returns the ID of the assistant message whose tool call ctx
+// belongs to. It is empty outside tool runs.
+func MessageID(ctx context.Context) string {
+	return tools.GetMessageFromContext(ctx)
+}
+
+type servicesContextKey struct{}
+
+// withServices returns a copy of ctx carrying the services of the plugin
+// whose tool runs with it
+func withServices(ctx context.Context, services Services) context.Context {
+	return context.WithValue(ctx, servicesContextKey{}, services)
+}
+
+// ServicesFromContext returns the services of the plugin whose tool ctx was
+// passed to, the same the plugin received in its PluginContext. It reports
+// false if ctx doesn't belong to the run of a plugin's tool.
+func ServicesFromContext(ctx context.Context) (Services, bool) {
+	services, ok := ctx.Value(servicesContextKey{}).(Services)
+	return services, ok
+}
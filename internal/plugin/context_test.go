@@ -0,0 +1,26 @@
+package plugin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAbort(t *testing.T) {
+	t.Parallel()
+
+	require.False(t, Abort(t.Context(), "no run"), "contexts without a run are ignored")
+
+	var reasons []string
+	active := true
+	ctx := WithAbort(t.Context(), func(reason string) bool {
+		if active {
+			reasons = append(reasons, reason)
+		}
+		return active
+	})
+	require.True(t, Abort(ctx, "policy violation"))
+	active = false
+	require.False(t, Abort(ctx, "too late"), "finished runs are not aborted")
+	require.Equal(t, []string{"policy violation"}, reasons)
+}
@@ -0,0 +1,83 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrPluginAlreadyLoaded   = errors.New("plugin is already loaded")
+	ErrPluginNotLoaded       = errors.New("plugin is not loaded")
+	ErrSymbolMissing         = errors.New("plugin does not export a 'Plugin' symbol")
+	ErrIncompatibleInterface = errors.New("'Plugin' symbol does not implement plugin.Plugin")
+	ErrInitFailed            = errors.New("plugin initialization failed")
+	ErrShutdownFailed        = errors.New("plugin shutdown failed")
+	ErrConfigHookFailed      = errors.New("config hook failed")
+	ErrInvalidConfig         = errors.New("config hook left the configuration invalid")
+
+	ErrPathNotAllowed = errors.New("plugin path is outside the allowed roots")
+	ErrPluginDenied   = errors.New("plugin is denied by configuration")
+	ErrUndefinedEnv   = errors.New("undefined environment variable")
+	ErrRequiredPlugin = errors.New("required plugin failed to load")
+
+	ErrChecksumMismatch = errors.New("plugin checksum mismatch")
+	ErrNotCached        = errors.New("remote plugin is not cached and offline mode is enabled")
+	ErrInsecureURL      = errors.New("remote plugins must be downloaded over https")
+	ErrUnpinnedRemote   = errors.New("remote plugin must pin a sha256 checksum or come from an allowed host")
+	ErrRemoteTooLarge   = errors.New("remote plugin is too large")
+
+	ErrUnsupportedKind  = errors.New("unsupported plugin kind")
+	ErrManifestMismatch = errors.New("plugin does not match its manifest")
+	ErrIncompatibleSDK  = errors.New("plugin requires an incompatible SDK version")
+	ErrBuildMismatch    = errors.New("plugin was built against different versions of Crush's packages")
+
+	ErrInvalidKey    = errors.New("invalid plugin store key")
+	ErrValueTooLarge = errors.New("plugin store value is too large")
+	ErrInvalidTopic  = errors.New("invalid plugin topic name")
+
+	ErrHookRecursion = errors.New("hooks are triggering each other recursively")
+	ErrHookPanicked  = errors.New("plugin panicked")
+	ErrNoMessages    = errors.New("hook returned no messages")
+
+	ErrToolStreamClosed = errors.New("tool stream is closed")
+	ErrToolTimeout      = errors.New("tool timed out")
+	ErrResponseTooLarge = errors.New("HTTP response is too large")
+	ErrToolRecursion    = errors.New("tool calls are nested too deeply")
+	ErrNoToolRunner     = errors.New("tools cannot be called outside the app")
+
+	// ErrTemporary can be wrapped by plugins to signal that a failure is
+	// transient and the operation may be retried.
+	ErrTemporary = errors.New("temporary plugin error")
+)
+
+// PluginError is an error concerning a specific plugin. Err wraps one of the
+// package's sentinel errors where one applies, so callers can inspect it
+// with errors.Is and recover the plugin with errors.As.
+type PluginError struct {
+	// Name is the plugin's name, if known
+	Name string
+
+	// Path is the file the plugin was loaded from, if any
+	Path string
+
+	Err error
+}
+
+func (e *PluginError) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("plugin at %s: %v", e.Path, e.Err)
+	}
+	return fmt.Sprintf("plugin %s: %v", e.Name, e.Err)
+}
+
+func (e *PluginError) Unwrap() error { return e.Err }
+
+// isTemporary reports whether err is a transient error, either by wrapping
+// ErrTemporary or by implementing Temporary() bool.
+func isTemporary(err error) bool {
+	if errors.Is(err, ErrTemporary) {
+		return true
+	}
+	var t interface{ Temporary() bool }
+	return errors.As(err, &t) && t.Temporary()
+}
@@ -0,0 +1,53 @@
+package plugin
+
+import (
+	"context"
+
+	"github.com/charmbracelet/crush/internal/pubsub"
+)
+
+// PluginEventType identifies a plugin lifecycle change
+type PluginEventType string
+
+const (
+	PluginLoaded   PluginEventType = "loaded"
+	PluginUnloaded PluginEventType = "unloaded"
+
+	// PluginQuarantined is published when a plugin is unloaded because its
+	// hooks kept panicking. A reload publishes PluginLoaded.
+	PluginQuarantined PluginEventType = "quarantined"
+)
+
+// PluginEvent is published by the registry when a plugin is loaded,
+// unloaded, or quarantined
+type PluginEvent struct {
+	Type PluginEventType
+	Info PluginInfo
+}
+
+// Subscribe returns a channel of plugin lifecycle events
+func (r *Registry) Subscribe(ctx context.Context) <-chan pubsub.Event[PluginEvent] {
+	return r.broker.Subscribe(ctx)
+}
+
+func (r *Registry) publish(eventType PluginEventType, info PluginInfo) {
+	r.broker.Publish(pubsub.UpdatedEvent, PluginEvent{Type: eventType, Info: info})
+}
+
+// ToolProgress is published while a StreamingPluginTool runs, carrying the
+// output it emitted so far
+type ToolProgress struct {
+	SessionID  string
+	ToolCallID string
+	ToolName   string
+
+	// Output is the tool's output so far, or its most recent part if it is
+	// long
+	Output string
+}
+
+// SubscribeToolProgress returns a channel of the progress of running
+// streaming plugin tools
+func (r *Registry) SubscribeToolProgress(ctx context.Context) <-chan pubsub.Event[ToolProgress] {
+	return r.progress.Subscribe(ctx)
+}
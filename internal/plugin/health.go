@@ -0,0 +1,77 @@
+package plugin
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// HealthChecker is an optional interface plugins can implement to report
+// their health. Plugins that don't implement it are always considered healthy.
+type HealthChecker interface {
+	// HealthCheck returns a non-nil error when the plugin is unhealthy
+	HealthCheck(ctx context.Context) error
+}
+
+// defaultHealthCheckTimeout bounds a single health check when the probe
+// interval is long
+const defaultHealthCheckTimeout = 10 * time.Second
+
+// IsHealthy reports whether the named plugin passed its last health check.
+// Plugins that have never been probed are considered healthy.
+func (r *Registry) IsHealthy(name string) bool {
+	err, ok := r.health.Get(name)
+	return !ok || err == nil
+}
+
+// CheckHealth probes every loaded plugin that implements HealthChecker once,
+// records the result, and logs health transitions
+func (r *Registry) CheckHealth(ctx context.Context, timeout time.Duration) {
+	for name, p := range r.plugins.Seq2() {
+		checker, ok := p.(HealthChecker)
+		if !ok {
+			continue
+		}
+
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := checker.HealthCheck(checkCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+
+		wasHealthy := r.IsHealthy(name)
+		r.health.Set(name, err)
+		switch {
+		case wasHealthy && err != nil:
+			slog.Warn("Plugin became unhealthy", "plugin", name, "error", err)
+			r.refreshHooks()
+		case !wasHealthy && err == nil:
+			slog.Info("Plugin recovered", "plugin", name)
+			r.refreshHooks()
+		}
+	}
+}
+
+// StartHealthChecks probes plugin health every interval until ctx is done.
+// Hooks of unhealthy plugins are skipped until they recover.
+func (r *Registry) StartHealthChecks(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	timeout := min(interval, defaultHealthCheckTimeout)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.CheckHealth(ctx, timeout)
+			}
+		}
+	}()
+}
@@ -0,0 +1,234 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Defaults of HTTPClientOptions
+const (
+	DefaultHTTPTimeout          = 30 * time.Second
+	DefaultHTTPMaxRedirects     = 5
+	DefaultHTTPMaxRetries       = 2
+	DefaultHTTPInitialBackoff   = 500 * time.Millisecond
+	DefaultHTTPMaxBackoff       = 5 * time.Second
+	DefaultHTTPMaxResponseBytes = 5 << 20
+	DefaultHTTPUserAgent        = "crush-plugin"
+)
+
+// errTooManyRedirects fails requests that were redirected more often than
+// allowed; they aren't retried
+var errTooManyRedirects = errors.New("too many redirects")
+
+// HTTPClientOptions configures an HTTPClient. Zero values select the
+// defaults.
+type HTTPClientOptions struct {
+	// Timeout bounds each attempt, including reading the response body
+	Timeout time.Duration
+
+	// MaxRedirects is the number of redirects followed. Negative values
+	// follow none and return the redirect response instead.
+	MaxRedirects int
+
+	// MaxRetries is the number of times a request is retried after a
+	// network error, a timed out attempt, or a 429 or 5xx response. Negative values disable
+	// retries.
+	MaxRetries int
+
+	// InitialBackoff is the delay before the first retry; it doubles with
+	// each retry up to MaxBackoff. A Retry-After header takes precedence if
+	// it asks for no more than MaxBackoff.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the delay between retries
+	MaxBackoff time.Duration
+
+	// MaxResponseBytes limits the response body. Reading more fails with
+	// ErrResponseTooLarge.
+	MaxResponseBytes int64
+
+	// UserAgent is sent with requests that don't set one
+	UserAgent string
+}
+
+// HTTPClient is an HTTP client for plugin tools that retries transient
+// failures with backoff, limits redirects and response sizes, and stops as
+// soon as the request's context is canceled
+type HTTPClient struct {
+	client *http.Client
+	opts   HTTPClientOptions
+}
+
+// NewHTTPClient returns an HTTPClient with the given options
+func NewHTTPClient(opts HTTPClientOptions) *HTTPClient {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultHTTPTimeout
+	}
+	if opts.MaxRedirects == 0 {
+		opts.MaxRedirects = DefaultHTTPMaxRedirects
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = DefaultHTTPMaxRetries
+	}
+	if opts.InitialBackoff <= 0 {
+		opts.InitialBackoff = DefaultHTTPInitialBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultHTTPMaxBackoff
+	}
+	if opts.MaxResponseBytes <= 0 {
+		opts.MaxResponseBytes = DefaultHTTPMaxResponseBytes
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = DefaultHTTPUserAgent
+	}
+
+	maxRedirects := opts.MaxRedirects
+	return &HTTPClient{
+		client: &http.Client{
+			Timeout: opts.Timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if maxRedirects < 0 {
+					return http.ErrUseLastResponse
+				}
+				if len(via) > maxRedirects {
+					return fmt.Errorf("%w: stopped after %d redirects", errTooManyRedirects, maxRedirects)
+				}
+				return nil
+			},
+		},
+		opts: opts,
+	}
+}
+
+// Do sends req, retrying network errors and 429 and 5xx responses. The
+// response of the last attempt is returned; its body fails with
+// ErrResponseTooLarge once more than MaxResponseBytes are read, and the
+// caller must close it. Requests with a body are only retried if
+// req.GetBody is set, as it is by http.NewRequest for common body types.
+func (c *HTTPClient) Do(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", c.opts.UserAgent)
+	}
+	retries := max(c.opts.MaxRetries, 0)
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		retries = 0
+	}
+
+	backoff := c.opts.InitialBackoff
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		resp, err := c.client.Do(req)
+		if attempt >= retries || !retryable(req.Context(), resp, err) {
+			if err != nil {
+				return nil, err
+			}
+			return c.limit(resp)
+		}
+
+		delay := backoff
+		if resp != nil {
+			if after, ok := retryAfter(resp); ok && after <= c.opts.MaxBackoff {
+				delay = after
+			}
+			// Let the connection be reused
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+		backoff = min(backoff*2, c.opts.MaxBackoff)
+	}
+}
+
+// Get sends a GET request for url, like Do
+func (c *HTTPClient) Get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// limit fails resp early if it announces a body larger than allowed, and
+// otherwise limits reading its body
+func (c *HTTPClient) limit(resp *http.Response) (*http.Response, error) {
+	if resp.ContentLength > c.opts.MaxResponseBytes {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %d bytes exceeds the limit of %d", ErrResponseTooLarge, resp.ContentLength, c.opts.MaxResponseBytes)
+	}
+	resp.Body = &limitedBody{body: resp.Body, remaining: c.opts.MaxResponseBytes, limit: c.opts.MaxResponseBytes}
+	return resp, nil
+}
+
+// retryable reports whether an attempt's outcome is worth retrying. Attempts
+// that timed out are retried unless the request's context is done.
+func retryable(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return !errors.Is(err, errTooManyRedirects)
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// retryAfter returns the delay a Retry-After header asks for
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
+
+// limitedBody fails with ErrResponseTooLarge once more than limit bytes are
+// read
+type limitedBody struct {
+	body      io.ReadCloser
+	remaining int64
+	limit     int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, fmt.Errorf("%w: more than %d bytes", ErrResponseTooLarge, b.limit)
+	}
+	// Read one byte past the limit to tell a body of exactly limit bytes
+	// from a larger one
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.body.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n + int(b.remaining), fmt.Errorf("%w: more than %d bytes", ErrResponseTooLarge, b.limit)
+	}
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	return b.body.Close()
+}
@@ -0,0 +1,156 @@
+package plugin
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPClient(t *testing.T) {
+	t.Parallel()
+
+	newClient := func(opts HTTPClientOptions) *HTTPClient {
+		opts.InitialBackoff = time.Millisecond
+		return NewHTTPClient(opts)
+	}
+
+	t.Run("retries server errors", func(t *testing.T) {
+		t.Parallel()
+
+		var attempts atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Retries must resend the body
+			if body, _ := io.ReadAll(r.Body); string(body) != "payload" || r.UserAgent() != DefaultHTTPUserAgent {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if attempts.Add(1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			io.WriteString(w, "ok")
+		}))
+		t.Cleanup(server.Close)
+
+		req, err := http.NewRequestWithContext(t.Context(), http.MethodPost, server.URL, strings.NewReader("payload"))
+		require.NoError(t, err)
+		resp, err := newClient(HTTPClientOptions{}).Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, "ok", string(body))
+		require.Equal(t, int32(3), attempts.Load())
+	})
+
+	t.Run("returns the last response once retries run out", func(t *testing.T) {
+		t.Parallel()
+
+		var attempts atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts.Add(1)
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		t.Cleanup(server.Close)
+
+		resp, err := newClient(HTTPClientOptions{MaxRetries: 1}).Get(t.Context(), server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusBadGateway, resp.StatusCode)
+		require.Equal(t, int32(2), attempts.Load())
+	})
+
+	t.Run("does not retry client errors", func(t *testing.T) {
+		t.Parallel()
+
+		var attempts atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts.Add(1)
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		t.Cleanup(server.Close)
+
+		resp, err := newClient(HTTPClientOptions{}).Get(t.Context(), server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, int32(1), attempts.Load())
+	})
+
+	t.Run("limits the response size", func(t *testing.T) {
+		t.Parallel()
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/announced" {
+				w.Header().Set("Content-Length", "100")
+			} else {
+				w.(http.Flusher).Flush() // stream without a length
+			}
+			io.WriteString(w, strings.Repeat("x", 100))
+		}))
+		t.Cleanup(server.Close)
+		client := newClient(HTTPClientOptions{MaxResponseBytes: 10})
+
+		_, err := client.Get(t.Context(), server.URL+"/announced")
+		require.ErrorIs(t, err, ErrResponseTooLarge)
+
+		resp, err := client.Get(t.Context(), server.URL+"/streamed")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.ErrorIs(t, err, ErrResponseTooLarge)
+		require.Len(t, body, 10)
+
+		resp, err = newClient(HTTPClientOptions{MaxResponseBytes: 100}).Get(t.Context(), server.URL+"/streamed")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err = io.ReadAll(resp.Body)
+		require.NoError(t, err, "a body of exactly the limit is allowed")
+		require.Len(t, body, 100)
+	})
+
+	t.Run("limits redirects", func(t *testing.T) {
+		t.Parallel()
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		}))
+		t.Cleanup(server.Close)
+
+		var attempts atomic.Int32
+		server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts.Add(1)
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		})
+		_, err := newClient(HTTPClientOptions{MaxRedirects: 2}).Get(t.Context(), server.URL)
+		require.ErrorContains(t, err, "stopped after 2 redirects")
+		require.Equal(t, int32(3), attempts.Load(), "redirect loops must not be retried")
+
+		resp, err := newClient(HTTPClientOptions{MaxRedirects: -1}).Get(t.Context(), server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusFound, resp.StatusCode)
+	})
+
+	t.Run("stops waiting when canceled", func(t *testing.T) {
+		t.Parallel()
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		t.Cleanup(server.Close)
+
+		ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		_, err := NewHTTPClient(HTTPClientOptions{InitialBackoff: time.Minute, MaxBackoff: time.Minute}).Get(ctx, server.URL)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Less(t, time.Since(start), 5*time.Second)
+	})
+}
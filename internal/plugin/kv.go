@@ -0,0 +1,111 @@
+package plugin
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/charmbracelet/crush/internal/db"
+)
+
+const (
+	// MaxKVKeySize is the maximum length of a key in a plugin's store, in bytes
+	MaxKVKeySize = 256
+
+	// MaxKVValueSize is the maximum size of a JSON-encoded value in a
+	// plugin's store, in bytes
+	MaxKVValueSize = 64 * 1024
+)
+
+// KVStore is persistent key-value storage scoped to a single plugin. Values
+// are stored as JSON, so anything encoding/json can marshal may be stored.
+// It is safe for concurrent use.
+type KVStore interface {
+	// Get decodes the value stored under key into v. It reports false if
+	// there is no such key.
+	Get(ctx context.Context, key string, v any) (bool, error)
+
+	// Set stores v under key, replacing any existing value
+	Set(ctx context.Context, key string, v any) error
+
+	// Delete removes key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// kvBackend is the subset of db.Querier used by plugin stores
+type kvBackend interface {
+	GetPluginValue(ctx context.Context, arg db.GetPluginValueParams) (string, error)
+	SetPluginValue(ctx context.Context, arg db.SetPluginValueParams) error
+	DeletePluginValue(ctx context.Context, arg db.DeletePluginValueParams) error
+}
+
+// kvStore is a KVStore backed by the plugin_kv table
+type kvStore struct {
+	backend kvBackend
+	plugin  string
+}
+
+func newKVStore(backend kvBackend, plugin string) *kvStore {
+	return &kvStore{backend: backend, plugin: plugin}
+}
+
+func (s *kvStore) Get(ctx context.Context, key string, v any) (bool, error) {
+	if err := checkKey(key); err != nil {
+		return false, err
+	}
+	value, err := s.backend.GetPluginValue(ctx, db.GetPluginValueParams{Plugin: s.plugin, Key: key})
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get %q: %w", key, err)
+	}
+	if err := json.Unmarshal([]byte(value), v); err != nil {
+		return true, fmt.Errorf("failed to decode %q: %w", key, err)
+	}
+	return true, nil
+}
+
+func (s *kvStore) Set(ctx context.Context, key string, v any) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	value, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %q: %w", key, err)
+	}
+	if len(value) > MaxKVValueSize {
+		return fmt.Errorf("%w: %q is %d bytes, the limit is %d", ErrValueTooLarge, key, len(value), MaxKVValueSize)
+	}
+	if err := s.backend.SetPluginValue(ctx, db.SetPluginValueParams{
+		Plugin: s.plugin,
+		Key:    key,
+		Value:  string(value),
+	}); err != nil {
+		return fmt.Errorf("failed to set %q: %w", key, err)
+	}
+	return nil
+}
+
+func (s *kvStore) Delete(ctx context.Context, key string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	if err := s.backend.DeletePluginValue(ctx, db.DeletePluginValueParams{Plugin: s.plugin, Key: key}); err != nil {
+		return fmt.Errorf("failed to delete %q: %w", key, err)
+	}
+	return nil
+}
+
+// checkKey validates a store key
+func checkKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("%w: key is empty", ErrInvalidKey)
+	}
+	if len(key) > MaxKVKeySize {
+		return fmt.Errorf("%w: key is %d bytes, the limit is %d", ErrInvalidKey, len(key), MaxKVKeySize)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package plugin
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/db"
+	"github.com/stretchr/testify/require"
+)
+
+type storePlugin struct {
+	flakyPlugin
+	store KVStore
+}
+
+func (p *storePlugin) Init(ctx context.Context, pluginCtx PluginContext) error {
+	p.store = pluginCtx.Store
+	return nil
+}
+
+func TestPluginKVStore(t *testing.T) {
+	t.Parallel()
+
+	conn, err := db.Connect(t.Context(), t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	registry := NewRegistry()
+	registry.SetStorage(db.New(conn))
+	first := &storePlugin{flakyPlugin: flakyPlugin{name: "first"}}
+	second := &storePlugin{flakyPlugin: flakyPlugin{name: "second"}}
+	require.NoError(t, registry.LoadPlugin(t.Context(), first, PluginContext{}))
+	require.NoError(t, registry.LoadPlugin(t.Context(), second, PluginContext{}))
+	require.NotNil(t, first.store)
+
+	type counter struct {
+		Count int `json:"count"`
+	}
+	var got counter
+	found, err := first.store.Get(t.Context(), "counter", &got)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	require.NoError(t, first.store.Set(t.Context(), "counter", counter{Count: 1}))
+	require.NoError(t, first.store.Set(t.Context(), "counter", counter{Count: 2}))
+	found, err = first.store.Get(t.Context(), "counter", &got)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, 2, got.Count)
+
+	found, err = second.store.Get(t.Context(), "counter", &got)
+	require.NoError(t, err)
+	require.False(t, found, "stores must be scoped to their plugin")
+
+	require.NoError(t, first.store.Delete(t.Context(), "counter"))
+	require.NoError(t, first.store.Delete(t.Context(), "counter"))
+	found, err = first.store.Get(t.Context(), "counter", &got)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	require.ErrorIs(t, first.store.Set(t.Context(), "", 1), ErrInvalidKey)
+	require.ErrorIs(t, first.store.Set(t.Context(), strings.Repeat("k", MaxKVKeySize+1), 1), ErrInvalidKey)
+	require.ErrorIs(t, first.store.Set(t.Context(), "big", strings.Repeat("v", MaxKVValueSize)), ErrValueTooLarge)
+}
@@ -0,0 +1,149 @@
+package plugin
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"charm.land/fantasy"
+)
+
+// ListOptions filters and pages the plugins returned by ListPluginsFiltered.
+// Zero values don't filter.
+type ListOptions struct {
+	// Name matches plugins whose name contains it, ignoring case
+	Name string
+
+	// Tag matches plugins with the tag, ignoring case
+	Tag string
+
+	// Hook matches plugins implementing the hook type
+	Hook HookType
+
+	// Offset is the number of matching plugins skipped. Negative values
+	// count as zero.
+	Offset int
+
+	// Limit is the most plugins returned. Zero or negative values return
+	// all of them.
+	Limit int
+}
+
+// PluginPage is a page of the plugins matching a ListOptions
+type PluginPage struct {
+	// Plugins are the plugins on the page, sorted by name
+	Plugins []PluginInfo
+
+	// Total is the number of matching plugins on all pages
+	Total int
+}
+
+// ListPluginsFiltered returns the loaded plugins matching opts, sorted by
+// name, one page at a time
+func (r *Registry) ListPluginsFiltered(opts ListOptions) PluginPage {
+	var implementing []string
+	if opts.Hook != "" {
+		implementing = r.PluginsImplementing(opts.Hook)
+	}
+
+	matches := []PluginInfo{}
+	for name, plugin := range r.plugins.Seq2() {
+		if !containsFold(name, opts.Name) {
+			continue
+		}
+		if opts.Hook != "" && !slices.Contains(implementing, name) {
+			continue
+		}
+		info := plugin.Info()
+		if opts.Tag != "" && !slices.ContainsFunc(info.Tags, func(tag string) bool {
+			return strings.EqualFold(tag, opts.Tag)
+		}) {
+			continue
+		}
+		matches = append(matches, info)
+	}
+	slices.SortFunc(matches, func(a, b PluginInfo) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return PluginPage{
+		Plugins: page(matches, opts.Offset, opts.Limit),
+		Total:   len(matches),
+	}
+}
+
+// ToolListOptions filters and pages the tools returned by
+// ListPluginToolsFiltered. Zero values don't filter.
+type ToolListOptions struct {
+	// Name matches tools whose name contains it, ignoring case
+	Name string
+
+	// Plugin matches the tools of the named plugin
+	Plugin string
+
+	// Offset is the number of matching tools skipped. Negative values
+	// count as zero.
+	Offset int
+
+	// Limit is the most tools returned. Zero or negative values return all
+	// of them.
+	Limit int
+}
+
+// PluginToolInfo describes a tool and the plugin providing it
+type PluginToolInfo struct {
+	Plugin string
+	Info   fantasy.ToolInfo
+
+	// Annotations are the tool's annotations, if it is an AnnotatedTool
+	Annotations map[string]string
+}
+
+// ToolPage is a page of the tools matching a ToolListOptions
+type ToolPage struct {
+	// Tools are the tools on the page, sorted by plugin and tool name
+	Tools []PluginToolInfo
+
+	// Total is the number of matching tools on all pages
+	Total int
+}
+
+// ListPluginToolsFiltered returns the tools of the loaded plugins matching
+// opts, sorted by plugin and tool name, one page at a time
+func (r *Registry) ListPluginToolsFiltered(opts ToolListOptions) ToolPage {
+	matches := []PluginToolInfo{}
+	for name, plugin := range r.plugins.Seq2() {
+		toolProvider, ok := plugin.(ToolProvider)
+		if !ok || (opts.Plugin != "" && name != opts.Plugin) {
+			continue
+		}
+		for _, tool := range toolProvider.GetTools() {
+			info := tool.Info()
+			if containsFold(info.Name, opts.Name) {
+				matches = append(matches, PluginToolInfo{Plugin: name, Info: info, Annotations: ToolAnnotations(tool)})
+			}
+		}
+	}
+	slices.SortFunc(matches, func(a, b PluginToolInfo) int {
+		return cmp.Or(cmp.Compare(a.Plugin, b.Plugin), cmp.Compare(a.Info.Name, b.Info.Name))
+	})
+	return ToolPage{
+		Tools: page(matches, opts.Offset, opts.Limit),
+		Total: len(matches),
+	}
+}
+
+// page returns the items after offset, at most limit of them if limit is
+// positive. It returns an empty slice if offset is past the end.
+func page[T any](items []T, offset, limit int) []T {
+	offset = min(max(offset, 0), len(items))
+	items = items[offset:]
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
+
+// containsFold reports whether s contains substr, ignoring case
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
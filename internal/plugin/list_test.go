@@ -0,0 +1,162 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+
+	"charm.land/fantasy"
+	"github.com/stretchr/testify/require"
+)
+
+type namedTool struct{ name string }
+
+func (t namedTool) Info() fantasy.ToolInfo { return fantasy.ToolInfo{Name: t.name} }
+
+func (t namedTool) Run(ctx context.Context, params fantasy.ToolCall) (fantasy.ToolResponse, error) {
+	return fantasy.NewTextResponse(t.name), nil
+}
+
+// listedPlugin has tags and tools to filter by
+type listedPlugin struct {
+	sessionHookPlugin
+	tags  []string
+	tools []string
+}
+
+func (p *listedPlugin) Info() PluginInfo { return PluginInfo{Name: p.name, Tags: p.tags} }
+
+func (p *listedPlugin) GetTools() []PluginTool {
+	var tools []PluginTool
+	for _, name := range p.tools {
+		tools = append(tools, namedTool{name: name})
+	}
+	return tools
+}
+
+func newListedRegistry(t *testing.T) *Registry {
+	r := NewRegistry()
+	for _, p := range []*listedPlugin{
+		{sessionHookPlugin: sessionHookPlugin{flakyPlugin: flakyPlugin{name: "git-helper"}, hook: &orderSessionHook{}}, tags: []string{"VCS"}, tools: []string{"git_log", "git_blame"}},
+		{sessionHookPlugin: sessionHookPlugin{flakyPlugin: flakyPlugin{name: "metrics"}}, tags: []string{"observability"}},
+		{sessionHookPlugin: sessionHookPlugin{flakyPlugin: flakyPlugin{name: "github"}}, tags: []string{"vcs", "web"}, tools: []string{"gh_pr"}},
+		{sessionHookPlugin: sessionHookPlugin{flakyPlugin: flakyPlugin{name: "audit"}}},
+	} {
+		require.NoError(t, r.LoadPlugin(t.Context(), p, PluginContext{}))
+	}
+	return r
+}
+
+func TestListPluginsFiltered(t *testing.T) {
+	t.Parallel()
+
+	r := newListedRegistry(t)
+	names := func(page PluginPage) []string {
+		var names []string
+		for _, info := range page.Plugins {
+			names = append(names, info.Name)
+		}
+		return names
+	}
+
+	for name, tc := range map[string]struct {
+		opts  ListOptions
+		names []string
+		total int
+	}{
+		"empty filter":       {ListOptions{}, []string{"audit", "git-helper", "github", "metrics"}, 4},
+		"name":               {ListOptions{Name: "GIT"}, []string{"git-helper", "github"}, 2},
+		"tag":                {ListOptions{Tag: "vcs"}, []string{"git-helper", "github"}, 2},
+		"hook":               {ListOptions{Hook: HookSession}, []string{"git-helper"}, 1},
+		"combined":           {ListOptions{Name: "git", Tag: "web"}, []string{"github"}, 1},
+		"no match":           {ListOptions{Tag: "missing"}, nil, 0},
+		"first page":         {ListOptions{Limit: 3}, []string{"audit", "git-helper", "github"}, 4},
+		"last page":          {ListOptions{Offset: 3, Limit: 3}, []string{"metrics"}, 4},
+		"offset past end":    {ListOptions{Offset: 10, Limit: 3}, nil, 4},
+		"negative offset":    {ListOptions{Offset: -1, Limit: 1}, []string{"audit"}, 4},
+		"filtered and paged": {ListOptions{Tag: "vcs", Offset: 1}, []string{"github"}, 2},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			page := r.ListPluginsFiltered(tc.opts)
+			require.Equal(t, tc.names, names(page))
+			require.NotNil(t, page.Plugins)
+			require.Equal(t, tc.total, page.Total)
+		})
+	}
+}
+
+func TestListPluginToolsFiltered(t *testing.T) {
+	t.Parallel()
+
+	r := newListedRegistry(t)
+	names := func(page ToolPage) []string {
+		var names []string
+		for _, tool := range page.Tools {
+			names = append(names, tool.Plugin+"/"+tool.Info.Name)
+		}
+		return names
+	}
+
+	page := r.ListPluginToolsFiltered(ToolListOptions{})
+	require.Equal(t, []string{"git-helper/git_blame", "git-helper/git_log", "github/gh_pr"}, names(page))
+	require.Equal(t, 3, page.Total)
+
+	page = r.ListPluginToolsFiltered(ToolListOptions{Name: "git", Limit: 1})
+	require.Equal(t, []string{"git-helper/git_blame"}, names(page))
+	require.Equal(t, 2, page.Total)
+
+	page = r.ListPluginToolsFiltered(ToolListOptions{Plugin: "github"})
+	require.Equal(t, []string{"github/gh_pr"}, names(page))
+
+	page = r.ListPluginToolsFiltered(ToolListOptions{Offset: 3})
+	require.Empty(t, page.Tools)
+	require.Equal(t, 3, page.Total)
+}
+
+// annotatedTool has annotations for the UI
+type annotatedTool struct {
+	namedTool
+	annotations map[string]string
+}
+
+func (t annotatedTool) Annotations() map[string]string { return t.annotations }
+
+type annotatedPlugin struct {
+	flakyPlugin
+	tools []PluginTool
+}
+
+func (p *annotatedPlugin) GetTools() []PluginTool { return p.tools }
+
+func TestToolAnnotations(t *testing.T) {
+	t.Parallel()
+
+	annotated := annotatedTool{namedTool{"skills_review"}, map[string]string{"category": "review"}}
+	require.Equal(t, map[string]string{"category": "review"}, ToolAnnotations(annotated))
+	require.Nil(t, ToolAnnotations(namedTool{"plain"}))
+
+	r := NewRegistry()
+	require.NoError(t, r.LoadPlugin(t.Context(), &annotatedPlugin{
+		flakyPlugin: flakyPlugin{name: "skills"},
+		tools:       []PluginTool{annotated, namedTool{"plain"}},
+	}, PluginContext{}))
+
+	// Tools handed to the agent keep their annotations
+	agentTools := r.GetPluginTools()
+	require.Len(t, agentTools, 2)
+	for _, tool := range agentTools {
+		if tool.Info().Name == "skills_review" {
+			require.Equal(t, map[string]string{"category": "review"}, ToolAnnotations(tool))
+		}
+	}
+
+	page := r.ListPluginToolsFiltered(ToolListOptions{})
+	require.Len(t, page.Tools, 2)
+	require.Nil(t, page.Tools[0].Annotations)
+	require.Equal(t, map[string]string{"category": "review"}, page.Tools[1].Annotations)
+
+	details := r.DescribePlugins()
+	require.Len(t, details, 1)
+	require.Equal(t, map[string]map[string]string{"skills_review": {"category": "review"}}, details[0].ToolAnnotations)
+}
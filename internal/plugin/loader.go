@@ -2,62 +2,296 @@ package plugin
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net/http"
 	"os"
 	"path/filepath"
 	"plugin"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/charmbracelet/crush/internal/config"
 )
 
 // Loader handles loading plugins from various sources
 type Loader struct {
-	registry *Registry
+	registry     *Registry
+	allowedRoots []string
+	denied       []string
+	retry        RetryPolicy
+	cacheDir     string
+	offline      bool
+	allowedHosts []string
+	client       *http.Client    // downloads remote plugins; nil uses the default
+	loaded       map[string]bool // resolved paths loaded successfully
+	mu           sync.Mutex
+}
+
+// LoaderOption configures a Loader.
+type LoaderOption func(*Loader)
+
+// WithAllowedRoots restricts plugin loading to paths under the given
+// directories. When no roots are given, plugins may be loaded from anywhere.
+func WithAllowedRoots(roots ...string) LoaderOption {
+	return func(l *Loader) {
+		for _, root := range roots {
+			if abs, err := filepath.Abs(root); err == nil {
+				l.allowedRoots = append(l.allowedRoots, resolveSymlinks(abs))
+			}
+		}
+	}
+}
+
+// WithDeniedPlugins refuses to load plugins with any of the given names.
+func WithDeniedPlugins(names ...string) LoaderOption {
+	return func(l *Loader) {
+		l.denied = append(l.denied, names...)
+	}
+}
+
+// WithRetryPolicy retries plugin initialization on temporary failures.
+func WithRetryPolicy(policy RetryPolicy) LoaderOption {
+	return func(l *Loader) {
+		l.retry = policy
+	}
+}
+
+// WithAllowedHosts lets remote plugins from the given hosts be loaded
+// without a pinned checksum.
+func WithAllowedHosts(hosts ...string) LoaderOption {
+	return func(l *Loader) {
+		l.allowedHosts = append(l.allowedHosts, hosts...)
+	}
+}
+
+// WithCacheDir sets the directory remote plugins are downloaded to.
+func WithCacheDir(dir string) LoaderOption {
+	return func(l *Loader) {
+		l.cacheDir = dir
+	}
+}
+
+// WithOffline loads remote plugins from the cache only, without network
+// access.
+func WithOffline(offline bool) LoaderOption {
+	return func(l *Loader) {
+		l.offline = offline
+	}
 }
 
 // NewLoader creates a new plugin loader
-func NewLoader(registry *Registry) *Loader {
-	return &Loader{
+func NewLoader(registry *Registry, opts ...LoaderOption) *Loader {
+	l := &Loader{
 		registry: registry,
+		cacheDir: defaultCacheDir(),
+		loaded:   make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(l)
 	}
+	return l
 }
 
 // LoadFromPath loads a plugin from a file path.
 // Supports:
 //   - .so files (Go plugins compiled with -buildmode=plugin)
 //   - Directories containing a .so file
+//   - http(s) URLs of .so files, downloaded to the plugin cache
 func (l *Loader) LoadFromPath(ctx context.Context, path string, pluginCtx PluginContext) error {
-	// Resolve the path
+	return l.LoadEntry(ctx, config.PluginEntry{Path: path}, pluginCtx)
+}
+
+// LoadEntry loads the plugin described by a configuration entry, like
+// LoadFromPath, applying the entry's options. Disabled entries are skipped.
+func (l *Loader) LoadEntry(ctx context.Context, entry config.PluginEntry, pluginCtx PluginContext) error {
+	if !entry.IsEnabled() {
+		slog.Debug("Skipping disabled plugin", "path", entry.Path)
+		return nil
+	}
+	pluginPath, err := l.resolveEntry(ctx, entry)
+	if err != nil {
+		return err
+	}
+
+	// Don't open the same file twice, e.g. when it is listed in config and
+	// also matched by a directory entry. Paths are resolved, so each file
+	// has a single spelling.
+	if l.isLoaded(pluginPath) {
+		slog.Debug("Skipping plugin that was already loaded", "path", pluginPath)
+		return nil
+	}
+
+	// Load the plugin, remembering the path only once it has loaded so
+	// failed loads are tried again
+	if err := l.loadGoPlugin(ctx, pluginPath, entry.GetSymbol(), pluginCtx); err != nil {
+		return err
+	}
+	l.markLoaded(pluginPath)
+	return nil
+}
+
+// resolveEntry resolves a plugin entry to the .so file to load, checking
+// its kind and checksum
+func (l *Loader) resolveEntry(ctx context.Context, entry config.PluginEntry) (string, error) {
+	if kind := entry.GetKind(); kind != config.PluginKindSO {
+		return "", fmt.Errorf("%w: %q", ErrUnsupportedKind, kind)
+	}
+	pluginPath, err := l.resolvePath(ctx, entry.Path)
+	if err != nil {
+		return "", err
+	}
+	if entry.SHA256 != "" {
+		if err := verifyChecksum(pluginPath, strings.ToLower(entry.SHA256)); err != nil {
+			return "", &PluginError{Path: pluginPath, Err: err}
+		}
+	}
+	return pluginPath, nil
+}
+
+// resolvePath resolves a configured plugin path to the .so file to load
+func (l *Loader) resolvePath(ctx context.Context, path string) (string, error) {
+	path, err := ExpandPath(path)
+	if err != nil {
+		return "", err
+	}
+	if IsRemote(path) {
+		if path, err = l.fetchRemote(ctx, path); err != nil {
+			return "", err
+		}
+	}
 	absPath, err := filepath.Abs(path)
 	if err != nil {
-		return fmt.Errorf("failed to resolve plugin path: %w", err)
+		return "", fmt.Errorf("failed to resolve plugin path: %w", err)
+	}
+
+	// Check where links lead, so a link in an allowed root can't escape it
+	absPath = resolveSymlinks(absPath)
+	if !l.isPathAllowed(absPath) {
+		return "", fmt.Errorf("%w: %s", ErrPathNotAllowed, absPath)
 	}
 
 	// Check if path exists
 	info, err := os.Stat(absPath)
 	if err != nil {
-		return fmt.Errorf("plugin path does not exist: %w", err)
+		return "", fmt.Errorf("plugin path does not exist: %w", err)
 	}
 
-	var pluginPath string
+	pluginPath := absPath
 	if info.IsDir() {
 		// Look for .so file in directory
 		pluginPath, err = l.findPluginInDir(absPath)
 		if err != nil {
-			return err
+			return "", err
+		}
+		pluginPath = resolveSymlinks(pluginPath)
+		if !l.isPathAllowed(pluginPath) {
+			return "", fmt.Errorf("%w: %s", ErrPathNotAllowed, pluginPath)
 		}
-	} else {
-		pluginPath = absPath
 	}
 
 	// Validate it's a .so file
 	if !strings.HasSuffix(pluginPath, ".so") {
-		return fmt.Errorf("plugin must be a .so file, got: %s", pluginPath)
+		return "", fmt.Errorf("plugin must be a .so file, got: %s", pluginPath)
 	}
+	return pluginPath, nil
+}
 
-	// Load the plugin
-	return l.loadGoPlugin(ctx, pluginPath, pluginCtx)
+// isLoaded reports whether the plugin at path was loaded
+func (l *Loader) isLoaded(path string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.loaded[path]
+}
+
+// markLoaded records that the plugin at path was loaded
+func (l *Loader) markLoaded(path string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.loaded[path] = true
+}
+
+// resolveSymlinks returns path with its symlinks resolved, or path itself if
+// it can't be resolved, e.g. because it doesn't exist
+func resolveSymlinks(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return path
+}
+
+// isPathAllowed reports whether path is under one of the allowed roots
+func (l *Loader) isPathAllowed(path string) bool {
+	if len(l.allowedRoots) == 0 {
+		return true
+	}
+	for _, root := range l.allowedRoots {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			continue
+		}
+		if rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadFromDir loads every .so file in a plugin directory, in name order.
+// Unlike LoadFromPath, which treats a directory as a single plugin
+// distributed as a directory, each .so file is loaded as its own plugin.
+// Failing plugins don't stop the others from loading; their errors are
+// returned together.
+func (l *Loader) LoadFromDir(ctx context.Context, dir string, pluginCtx PluginContext) error {
+	paths, err := l.pluginsInDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, path := range paths {
+		if err := l.LoadFromPath(ctx, path, pluginCtx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", filepath.Base(path), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// pluginsInDir returns the .so files in a plugin directory, in name order
+func (l *Loader) pluginsInDir(dir string) ([]string, error) {
+	dir, err := ExpandPath(dir)
+	if err != nil {
+		return nil, err
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve plugin directory: %w", err)
+	}
+	absDir = resolveSymlinks(absDir)
+	if !l.isPathAllowed(absDir) {
+		return nil, fmt.Errorf("%w: %s", ErrPathNotAllowed, absDir)
+	}
+
+	entries, err := os.ReadDir(absDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugin directory: %w", err)
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".so") {
+			paths = append(paths, filepath.Join(absDir, entry.Name()))
+		}
+	}
+	if len(paths) == 0 {
+		slog.Warn("Plugin directory contains no .so files", "dir", absDir)
+	}
+	return paths, nil
 }
 
 // findPluginInDir finds the first .so file in a directory
@@ -76,46 +310,144 @@ func (l *Loader) findPluginInDir(dir string) (string, error) {
 	return "", fmt.Errorf("no .so file found in directory: %s", dir)
 }
 
-// loadGoPlugin loads a Go plugin (.so file)
-func (l *Loader) loadGoPlugin(ctx context.Context, path string, pluginCtx PluginContext) error {
+// loadGoPlugin loads a Go plugin (.so file) that exports symbol
+func (l *Loader) loadGoPlugin(ctx context.Context, path, symbolName string, pluginCtx PluginContext) error {
+	start := time.Now()
+	pluginImpl, err := l.openGoPlugin(path, symbolName)
+	if err != nil {
+		return err
+	}
+	openTime := time.Since(start)
+
+	// Load the plugin into the registry
+	source := pluginSource{path: path, open: reopener(path, pluginImpl.Info().Name)}
+	if err := l.registry.loadPlugin(ctx, pluginImpl, pluginCtx, l.retry, source, openTime); err != nil {
+		var pluginErr *PluginError
+		if errors.As(err, &pluginErr) {
+			pluginErr.Path = path
+		}
+		return err
+	}
+
+	return nil
+}
+
+// reopener returns a function creating a fresh instance of the plugin named
+// name at path with its NewPluginSymbol, or nil if it doesn't export one
+func reopener(path, name string) func() (Plugin, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil
+	}
+	symbol, err := p.Lookup(NewPluginSymbol)
+	if err != nil {
+		return nil
+	}
+	newPlugin, ok := symbol.(func() Plugin)
+	if !ok {
+		slog.Warn("Ignoring plugin constructor of the wrong type", "plugin", name, "type", fmt.Sprintf("%T", symbol))
+		return nil
+	}
+	return func() (Plugin, error) {
+		created := newPlugin()
+		if created == nil {
+			return nil, fmt.Errorf("%w: %s returned nil", ErrIncompatibleInterface, NewPluginSymbol)
+		}
+		if got := created.Info().Name; got != name {
+			return nil, fmt.Errorf("%w: %s created plugin %q instead of %q", ErrIncompatibleInterface, NewPluginSymbol, got, name)
+		}
+		return created, nil
+	}
+}
+
+// openGoPlugin opens a Go plugin (.so file) and checks it may be loaded,
+// without initializing it
+func (l *Loader) openGoPlugin(path, symbolName string) (Plugin, error) {
+	// Check the manifest, if any, and the denylist before running any
+	// plugin code. Opening a plugin runs its init functions.
+	manifest, err := loadManifest(path)
+	if err != nil {
+		return nil, err
+	}
+	if manifest != nil {
+		if err := manifest.checkSDKVersion(); err != nil {
+			return nil, err
+		}
+	}
+	if name := expectedName(path, manifest); slices.Contains(l.denied, name) {
+		return nil, &PluginError{Name: name, Path: path, Err: ErrPluginDenied}
+	}
+
 	// Open the plugin
 	p, err := plugin.Open(path)
 	if err != nil {
-		return fmt.Errorf("failed to open plugin: %w", err)
+		return nil, openError(path, err)
+	}
+	if err := checkSDKSymbol(func(name string) (any, error) { return p.Lookup(name) }); err != nil {
+		return nil, &PluginError{Path: path, Err: err}
 	}
 
-	// Look for the exported "Plugin" symbol
-	symbol, err := p.Lookup("Plugin")
+	// Look for the exported plugin symbol
+	symbol, err := p.Lookup(symbolName)
 	if err != nil {
-		return fmt.Errorf("plugin does not export 'Plugin' symbol: %w", err)
+		return nil, &PluginError{Path: path, Err: fmt.Errorf("%w: %w", ErrSymbolMissing, err)}
 	}
 
 	// Assert that it implements the Plugin interface
-	pluginImpl, ok := symbol.(Plugin)
-	if !ok {
-		return fmt.Errorf("Plugin symbol does not implement plugin.Plugin interface")
+	pluginImpl, err := asPlugin(symbol)
+	if err != nil {
+		return nil, &PluginError{Path: path, Err: err}
 	}
 
-	// Load the plugin into the registry
-	if err := l.registry.LoadPlugin(ctx, pluginImpl, pluginCtx); err != nil {
-		return fmt.Errorf("failed to load plugin: %w", err)
+	name := pluginImpl.Info().Name
+	if slices.Contains(l.denied, name) {
+		return nil, &PluginError{Name: name, Path: path, Err: ErrPluginDenied}
 	}
 
-	return nil
+	if manifest != nil {
+		if err := manifest.verify(pluginImpl); err != nil {
+			return nil, &PluginError{Name: name, Path: path, Err: err}
+		}
+	}
+	return pluginImpl, nil
+}
+
+// expectedName returns the name the plugin at path is expected to report:
+// its manifest's name, or else its file name without the extension
+func expectedName(path string, manifest *Manifest) string {
+	if manifest != nil {
+		return manifest.Info.Name
+	}
+	return strings.TrimSuffix(filepath.Base(path), ".so")
 }
 
-// LoadFromConfig loads all plugins specified in the configuration
+// LoadFromConfig loads all plugins specified in the configuration. Plugins
+// that fail to load are skipped with a warning, unless they are required.
 func (l *Loader) LoadFromConfig(ctx context.Context, cfg *config.Config, pluginCtx PluginContext) error {
-	// Get plugin paths from config
-	pluginPaths := cfg.GetPluginPaths()
+	if name := cfg.ActivePluginProfile(); name != "" {
+		if _, ok := cfg.Options.Plugins.Profiles[name]; ok {
+			slog.Info("Using plugin profile", "profile", name)
+		} else {
+			slog.Warn("Plugin profile is not configured", "profile", name)
+		}
+	}
 
-	for _, path := range pluginPaths {
-		if err := l.LoadFromPath(ctx, path, pluginCtx); err != nil {
+	for _, entry := range cfg.GetPluginEntries() {
+		if err := l.LoadEntry(ctx, entry, pluginCtx); err != nil {
+			if entry.Required {
+				return fmt.Errorf("%w: %s: %w", ErrRequiredPlugin, entry.Path, err)
+			}
 			// Log error but continue loading other plugins
-			fmt.Fprintf(os.Stderr, "Warning: failed to load plugin from %s: %v\n", path, err)
+			fmt.Fprintf(os.Stderr, "Warning: failed to load plugin from %s: %v\n", entry.Path, err)
 			continue
 		}
 	}
 
+	for _, dir := range cfg.GetPluginDirs() {
+		if err := l.LoadFromDir(ctx, dir, pluginCtx); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to load plugins from %s: %v\n", dir, err)
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,224 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoaderAllowedRoots(t *testing.T) {
+	t.Parallel()
+
+	base := t.TempDir()
+	allowed := filepath.Join(base, "allowed")
+	outside := filepath.Join(base, "outside")
+	require.NoError(t, os.MkdirAll(allowed, 0o755))
+	require.NoError(t, os.MkdirAll(outside, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "evil.so"), nil, 0o644))
+
+	loader := NewLoader(NewRegistry(), WithAllowedRoots(allowed))
+
+	t.Run("path traversal escaping root is refused", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(allowed, "..", "outside", "evil.so")
+		err := loader.LoadFromPath(t.Context(), path, PluginContext{})
+		require.ErrorIs(t, err, ErrPathNotAllowed)
+	})
+
+	t.Run("sibling with shared prefix is refused", func(t *testing.T) {
+		t.Parallel()
+		path := allowed + "-other/evil.so"
+		err := loader.LoadFromPath(t.Context(), path, PluginContext{})
+		require.ErrorIs(t, err, ErrPathNotAllowed)
+	})
+
+	t.Run("path under root is allowed", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(allowed, "missing.so")
+		err := loader.LoadFromPath(t.Context(), path, PluginContext{})
+		require.Error(t, err)
+		require.NotErrorIs(t, err, ErrPathNotAllowed)
+	})
+
+	t.Run("symlink escaping root is refused", func(t *testing.T) {
+		t.Parallel()
+		link := filepath.Join(allowed, "link.so")
+		require.NoError(t, os.Symlink(filepath.Join(outside, "evil.so"), link))
+		err := loader.LoadFromPath(t.Context(), link, PluginContext{})
+		require.ErrorIs(t, err, ErrPathNotAllowed)
+
+		dirLink := filepath.Join(allowed, "linked-dir")
+		require.NoError(t, os.Symlink(outside, dirLink))
+		err = loader.LoadFromPath(t.Context(), dirLink, PluginContext{})
+		require.ErrorIs(t, err, ErrPathNotAllowed)
+		err = loader.LoadFromDir(t.Context(), dirLink, PluginContext{})
+		require.ErrorIs(t, err, ErrPathNotAllowed)
+	})
+
+	t.Run("no roots allows any path", func(t *testing.T) {
+		t.Parallel()
+		open := NewLoader(NewRegistry())
+		path := filepath.Join(allowed, "..", "outside", "missing.so")
+		err := open.LoadFromPath(t.Context(), path, PluginContext{})
+		require.Error(t, err)
+		require.NotErrorIs(t, err, ErrPathNotAllowed)
+	})
+}
+
+func TestLoaderDeniesBeforeOpening(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for _, name := range []string{"metrics.so", "renamed.so"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("not a plugin"), 0o644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "renamed.json"), []byte(`{"info": {"name": "audit"}}`), 0o644))
+	loader := NewLoader(NewRegistry(), WithDeniedPlugins("metrics", "audit"))
+
+	// Opening the files would fail, so the denial must come first
+	for _, name := range []string{"metrics.so", "renamed.so"} {
+		err := loader.LoadFromPath(t.Context(), filepath.Join(dir, name), PluginContext{})
+		require.ErrorIs(t, err, ErrPluginDenied, name)
+		require.NotContains(t, err.Error(), "failed to open plugin", name)
+	}
+}
+
+func TestLoaderSkipsDuplicatePaths(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "dup.so")
+	require.NoError(t, os.WriteFile(path, []byte("not a plugin"), 0o644))
+	loader := NewLoader(NewRegistry())
+	spellings := []string{
+		path,
+		filepath.Join(dir, ".", "..", filepath.Base(dir), "dup.so"),
+		dir,
+	}
+
+	// Failed loads aren't remembered, so every attempt opens the file again
+	for _, spelling := range spellings {
+		err := loader.LoadFromPath(t.Context(), spelling, PluginContext{})
+		require.ErrorContains(t, err, "failed to open plugin", spelling)
+	}
+
+	// Once the file has loaded, every spelling of it is skipped before it
+	// is opened again
+	resolved, err := filepath.EvalSymlinks(path)
+	require.NoError(t, err)
+	loader.markLoaded(resolved)
+	for _, spelling := range spellings {
+		require.NoError(t, loader.LoadFromPath(t.Context(), spelling, PluginContext{}), spelling)
+	}
+}
+
+func TestLoaderLoadFromDir(t *testing.T) {
+	t.Parallel()
+
+	t.Run("loads every plugin", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		for _, name := range []string{"b.so", "a.so", "notes.txt"} {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("not a plugin"), 0o644))
+		}
+		require.NoError(t, os.Mkdir(filepath.Join(dir, "nested.so"), 0o755))
+
+		// Each file is opened, so each fails separately
+		err := NewLoader(NewRegistry()).LoadFromDir(t.Context(), dir, PluginContext{})
+		require.Error(t, err)
+		msg := err.Error()
+		require.Contains(t, msg, "a.so: ")
+		require.Contains(t, msg, "b.so: ")
+		require.Less(t, strings.Index(msg, "a.so"), strings.Index(msg, "b.so"))
+		require.NotContains(t, msg, "notes.txt")
+		require.NotContains(t, msg, "nested.so")
+	})
+
+	t.Run("empty directory", func(t *testing.T) {
+		t.Parallel()
+
+		err := NewLoader(NewRegistry()).LoadFromDir(t.Context(), t.TempDir(), PluginContext{})
+		require.NoError(t, err)
+	})
+}
+
+func TestLoaderLoadEntry(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "plugin.so")
+	require.NoError(t, os.WriteFile(path, []byte("not a plugin"), 0o644))
+	disabled := false
+
+	for _, tt := range []struct {
+		name  string
+		entry config.PluginEntry
+		check func(t *testing.T, err error)
+	}{
+		{
+			name:  "disabled entries are skipped",
+			entry: config.PluginEntry{Path: path, Enabled: &disabled},
+			check: func(t *testing.T, err error) { require.NoError(t, err) },
+		},
+		{
+			name:  "unsupported kind",
+			entry: config.PluginEntry{Path: path, Kind: config.PluginKindWASM},
+			check: func(t *testing.T, err error) { require.ErrorIs(t, err, ErrUnsupportedKind) },
+		},
+		{
+			name:  "checksum mismatch",
+			entry: config.PluginEntry{Path: path, SHA256: strings.Repeat("0", 64)},
+			check: func(t *testing.T, err error) { require.ErrorIs(t, err, ErrChecksumMismatch) },
+		},
+		{
+			name:  "matching checksum is opened",
+			entry: config.PluginEntry{Path: path, SHA256: "C6C74590250E3D5D3E3EA67CD2074D7E4689B25A30B8DD137999C3ED6E00858E"},
+			check: func(t *testing.T, err error) { require.ErrorContains(t, err, "failed to open plugin") },
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tt.check(t, NewLoader(NewRegistry()).LoadEntry(t.Context(), tt.entry, PluginContext{}))
+		})
+	}
+}
+
+func TestLoaderLoadFromConfigRequired(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "plugin.so")
+	require.NoError(t, os.WriteFile(path, []byte("not a plugin"), 0o644))
+
+	optional := &config.Config{Plugins: []config.PluginEntry{{Path: path}}}
+	require.NoError(t, NewLoader(NewRegistry()).LoadFromConfig(t.Context(), optional, PluginContext{}))
+
+	required := &config.Config{Plugins: []config.PluginEntry{{Path: path, Required: true}}}
+	err := NewLoader(NewRegistry()).LoadFromConfig(t.Context(), required, PluginContext{})
+	require.ErrorIs(t, err, ErrRequiredPlugin)
+	require.ErrorContains(t, err, "failed to open plugin")
+}
+
+func TestLoaderValidatePath(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.so"), []byte("not a plugin"), 0o644))
+	registry := NewRegistry()
+	loader := NewLoader(registry)
+
+	result := loader.ValidatePath(t.Context(), filepath.Join(dir, "broken.so"))
+	require.False(t, result.OK())
+	require.Equal(t, KindPlugin, result.Kind)
+	require.Contains(t, result.Error, "failed to open plugin")
+
+	result = loader.ValidatePath(t.Context(), filepath.Join(dir, "missing.so"))
+	require.Contains(t, result.Error, "plugin path does not exist")
+
+	require.Empty(t, registry.ListPlugins(), "validation must not register plugins")
+}
@@ -0,0 +1,143 @@
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// SDKVersion is the version of the plugin SDK implemented by this build.
+// Plugin manifests may require a compatible version. The minor version is
+// bumped when the SDK gains API, so plugins using it are refused by older
+// builds.
+const SDKVersion = "1.1.0"
+
+// Manifest describes what a binary plugin is expected to be. It is read from
+// a JSON file next to the .so file with the same base name (e.g. metrics.json
+// for metrics.so) and checked before the plugin is registered.
+type Manifest struct {
+	// Info is the expected plugin metadata. Name and, if set, Version must
+	// match what the plugin reports.
+	Info PluginInfo `json:"info"`
+
+	// SDKVersion is the plugin SDK version the plugin was built against. It
+	// must have the same major version as SDKVersion and not be newer.
+	SDKVersion string `json:"sdk_version,omitempty"`
+
+	// Capabilities lists the hooks (e.g. "tool", "permission") and "tools"
+	// the plugin may use. When set, a plugin using anything else is refused.
+	Capabilities []string `json:"capabilities,omitempty"`
+}
+
+// manifestPath returns the path of the manifest for the plugin at path
+func manifestPath(path string) string {
+	return strings.TrimSuffix(path, ".so") + ".json"
+}
+
+// loadManifest reads the manifest for the plugin at path. It returns nil if
+// the plugin has no manifest.
+func loadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(manifestPath(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugin manifest: %w", err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse plugin manifest %s: %w", manifestPath(path), err)
+	}
+	if manifest.Info.Name == "" {
+		return nil, fmt.Errorf("%w: plugin manifest %s has no name", ErrManifestMismatch, manifestPath(path))
+	}
+	return &manifest, nil
+}
+
+// checkSDKVersion reports whether a plugin built against the manifest's SDK
+// version can run on this build
+func (m *Manifest) checkSDKVersion() error {
+	if m.SDKVersion == "" {
+		return nil
+	}
+	if _, err := parseVersion(m.SDKVersion); err != nil {
+		return fmt.Errorf("%w: invalid sdk_version %q", ErrManifestMismatch, m.SDKVersion)
+	}
+	return checkSDKVersion(m.SDKVersion)
+}
+
+// checkSDKVersion reports whether a plugin built against the given SDK
+// version can run on this build: it must have the same major version as
+// SDKVersion and not be newer
+func checkSDKVersion(version string) error {
+	required, err := parseVersion(version)
+	if err != nil {
+		return fmt.Errorf("%w: invalid SDK version %q", ErrIncompatibleSDK, version)
+	}
+	current, _ := parseVersion(SDKVersion)
+	if required[0] != current[0] || slices.Compare(required[:], current[:]) > 0 {
+		return fmt.Errorf("%w: plugin requires SDK %s, have %s", ErrIncompatibleSDK, version, SDKVersion)
+	}
+	return nil
+}
+
+// verify checks that an opened plugin matches the manifest's info. The
+// capabilities are checked by verifyCapabilities once Init has succeeded,
+// since plugins may only provide their hooks and tools after Init.
+func (m *Manifest) verify(p Plugin) error {
+	info := p.Info()
+	if info.Name != m.Info.Name {
+		return fmt.Errorf("%w: expected name %q, plugin reports %q", ErrManifestMismatch, m.Info.Name, info.Name)
+	}
+	if m.Info.Version != "" && info.Version != m.Info.Version {
+		return fmt.Errorf("%w: expected version %q, plugin reports %q", ErrManifestMismatch, m.Info.Version, info.Version)
+	}
+	return nil
+}
+
+// verifyCapabilities checks that a plugin only uses the capabilities the
+// manifest declares, if it declares any
+func (m *Manifest) verifyCapabilities(p Plugin) error {
+	if len(m.Capabilities) == 0 {
+		return nil
+	}
+	for _, capability := range capabilities(p) {
+		if !slices.Contains(m.Capabilities, capability) {
+			return fmt.Errorf("%w: plugin uses undeclared capability %q", ErrManifestMismatch, capability)
+		}
+	}
+	return nil
+}
+
+// capabilities returns the hooks a plugin implements, plus "tools" if it
+// contributes tools
+func capabilities(p Plugin) []string {
+	caps := implementedHooks(p.Hooks())
+	if toolProvider, ok := p.(ToolProvider); ok && len(toolProvider.GetTools()) > 0 {
+		caps = append(caps, "tools")
+	}
+	return caps
+}
+
+// parseVersion parses a "major.minor.patch" version with an optional "v"
+// prefix; missing components are zero
+func parseVersion(version string) ([3]int, error) {
+	var parsed [3]int
+	parts := strings.Split(strings.TrimPrefix(version, "v"), ".")
+	if len(parts) > 3 {
+		return parsed, fmt.Errorf("invalid version %q", version)
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return parsed, fmt.Errorf("invalid version %q", version)
+		}
+		parsed[i] = n
+	}
+	return parsed, nil
+}
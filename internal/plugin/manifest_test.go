@@ -0,0 +1,77 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadManifest(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "metrics.so")
+
+	manifest, err := loadManifest(path)
+	require.NoError(t, err)
+	require.Nil(t, manifest, "plugins without a manifest load as before")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "metrics.json"), []byte(`{
+		"info": {"name": "metrics", "version": "1.2.0"},
+		"sdk_version": "1.0.0",
+		"capabilities": ["tool", "session"]
+	}`), 0o644))
+	manifest, err = loadManifest(path)
+	require.NoError(t, err)
+	require.Equal(t, "metrics", manifest.Info.Name)
+	require.Equal(t, []string{"tool", "session"}, manifest.Capabilities)
+}
+
+func TestManifestCheckSDKVersion(t *testing.T) {
+	t.Parallel()
+
+	for version, wantErr := range map[string]error{
+		"":       nil,
+		"1":      nil,
+		"v1.0.0": nil,
+		"1.9.0":  ErrIncompatibleSDK,
+		"2.0.0":  ErrIncompatibleSDK,
+		"0.9.0":  ErrIncompatibleSDK,
+		"latest": ErrManifestMismatch,
+	} {
+		err := (&Manifest{SDKVersion: version}).checkSDKVersion()
+		if wantErr == nil {
+			require.NoError(t, err, version)
+		} else {
+			require.ErrorIs(t, err, wantErr, version)
+		}
+	}
+}
+
+func TestManifestVerify(t *testing.T) {
+	t.Parallel()
+
+	p := &toolHookPlugin{flakyPlugin: flakyPlugin{name: "metrics"}, hook: &metadataToolHook{}}
+
+	require.NoError(t, (&Manifest{Info: PluginInfo{Name: "metrics"}}).verify(p))
+
+	err := (&Manifest{Info: PluginInfo{Name: "other"}}).verify(p)
+	require.ErrorIs(t, err, ErrManifestMismatch)
+
+	err = (&Manifest{Info: PluginInfo{Name: "metrics", Version: "2.0.0"}}).verify(p)
+	require.ErrorIs(t, err, ErrManifestMismatch)
+
+	require.NoError(t, (&Manifest{Info: PluginInfo{Name: "metrics"}, Capabilities: []string{"tool"}}).verifyCapabilities(p))
+	err = (&Manifest{Info: PluginInfo{Name: "metrics"}, Capabilities: []string{"session"}}).verifyCapabilities(p)
+	require.ErrorIs(t, err, ErrManifestMismatch)
+	require.ErrorContains(t, err, `"tool"`)
+
+	// Plugins that choose their hooks in Init are verified before it
+	// without asking for their hooks
+	uninitialized := &configuredPlugin{flakyPlugin: flakyPlugin{name: "enforce"}}
+	require.NotPanics(t, func() {
+		require.NoError(t, (&Manifest{Info: PluginInfo{Name: "enforce"}, Capabilities: []string{"session"}}).verify(uninitialized))
+	})
+}
@@ -0,0 +1,33 @@
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/home"
+)
+
+// ExpandPath expands a leading ~ to the user's home directory and replaces
+// $VAR and ${VAR} with their environment values. Referencing a variable that
+// isn't set is an error wrapping ErrUndefinedEnv, so a typo never silently
+// resolves to a different path.
+func ExpandPath(path string) (string, error) {
+	var missing []string
+	path = os.Expand(path, func(name string) string {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return value
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("%w: %s", ErrUndefinedEnv, strings.Join(missing, ", "))
+	}
+
+	if path == "~" || strings.HasPrefix(path, "~"+string(filepath.Separator)) || strings.HasPrefix(path, "~/") {
+		path = home.Long(path)
+	}
+	return path, nil
+}
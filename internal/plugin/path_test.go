@@ -0,0 +1,39 @@
+package plugin
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/home"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandPath(t *testing.T) {
+	t.Setenv("CRUSH_PLUGIN_DIR", "/opt/crush/plugins")
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"tilde", "~/plugins/foo.so", filepath.Join(home.Dir(), "plugins", "foo.so")},
+		{"bare tilde", "~", home.Dir()},
+		{"dollar", "$CRUSH_PLUGIN_DIR/foo.so", "/opt/crush/plugins/foo.so"},
+		{"braces", "${CRUSH_PLUGIN_DIR}/bar.so", "/opt/crush/plugins/bar.so"},
+		{"tilde user unchanged", "~other/foo.so", "~other/foo.so"},
+		{"plain", "/usr/lib/crush/foo.so", "/usr/lib/crush/foo.so"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandPath(tt.path)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("undefined variable", func(t *testing.T) {
+		_, err := ExpandPath("${CRUSH_TEST_UNDEFINED_VAR}/foo.so")
+		require.ErrorIs(t, err, ErrUndefinedEnv)
+		require.ErrorContains(t, err, "CRUSH_TEST_UNDEFINED_VAR")
+	})
+}
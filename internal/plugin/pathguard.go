@@ -0,0 +1,109 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/permission"
+)
+
+// pathParams are the tool parameters holding the paths a tool touches
+var pathParams = []string{"file_path", "path"}
+
+// PathGuardHook is a PermissionHook that denies requests for paths outside a
+// set of allowed prefixes
+type PathGuardHook struct {
+	prefixes []string
+}
+
+// NewPathGuardHook returns a PermissionHook that denies any request whose
+// path, or file_path or path parameter, is outside all of allowedPrefixes.
+// Relative paths are resolved against the working directory, and symlinks
+// and .. are resolved before comparing, so a path can't escape through
+// either. Requests inside the prefixes, or without a path, are left to other
+// hooks and the user. Shell commands are only checked by their working
+// directory, not by the paths they use.
+func NewPathGuardHook(allowedPrefixes []string) *PathGuardHook {
+	prefixes := make([]string, 0, len(allowedPrefixes))
+	for _, prefix := range allowedPrefixes {
+		resolved, err := resolvePath(prefix)
+		if err != nil {
+			slog.Warn("Ignoring allowed path prefix", "prefix", prefix, "error", err)
+			continue
+		}
+		prefixes = append(prefixes, resolved)
+	}
+	return &PathGuardHook{prefixes: prefixes}
+}
+
+func (h *PathGuardHook) OnPermissionRequest(ctx context.Context, req permission.CreatePermissionRequest) (*bool, error) {
+	if err := h.Check(req); err != nil {
+		slog.Warn("Path guard denied permission request", "tool", req.ToolName, "reason", err)
+		deny := false
+		return &deny, nil
+	}
+	return nil, nil
+}
+
+// Check returns an error giving the reason req must be denied, or nil if all
+// of its paths are inside the allowed prefixes
+func (h *PathGuardHook) Check(req permission.CreatePermissionRequest) error {
+	for _, path := range requestPaths(req) {
+		resolved, err := resolvePath(path)
+		if err != nil {
+			return fmt.Errorf("can't resolve path %q: %w", path, err)
+		}
+		if !h.allowed(resolved) {
+			return fmt.Errorf("%s %s: path %q is outside the allowed directories", req.ToolName, req.Action, path)
+		}
+	}
+	return nil
+}
+
+// allowed reports whether the resolved path is inside one of the prefixes
+func (h *PathGuardHook) allowed(path string) bool {
+	for _, prefix := range h.prefixes {
+		rel, err := filepath.Rel(prefix, path)
+		if err != nil {
+			continue
+		}
+		if rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestPaths returns the paths a permission request refers to
+func requestPaths(req permission.CreatePermissionRequest) []string {
+	var paths []string
+	if req.Path != "" {
+		paths = append(paths, req.Path)
+	}
+
+	var params map[string]any
+	if data, err := json.Marshal(req.Params); err == nil {
+		_ = json.Unmarshal(data, &params)
+	}
+	for _, key := range pathParams {
+		if path, ok := params[key].(string); ok && path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// resolvePath resolves path like permission.ResolvePath, relative to the
+// process's working directory
+func resolvePath(path string) (string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return permission.ResolvePath(wd, path)
+}
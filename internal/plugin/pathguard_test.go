@@ -0,0 +1,70 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathGuardHook(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	allowed := filepath.Join(root, "project")
+	outside := filepath.Join(root, "secrets")
+	require.NoError(t, os.MkdirAll(filepath.Join(allowed, "src"), 0o755))
+	require.NoError(t, os.MkdirAll(outside, 0o755))
+	require.NoError(t, os.Symlink(outside, filepath.Join(allowed, "escape")))
+	require.NoError(t, os.Symlink(filepath.Join(outside, "new.txt"), filepath.Join(allowed, "dangling")))
+	require.NoError(t, os.Symlink(filepath.Join(allowed, "src"), filepath.Join(root, "alias")))
+	require.NoError(t, os.Symlink("loop", filepath.Join(allowed, "loop")))
+
+	hook := NewPathGuardHook([]string{allowed})
+	tests := []struct {
+		name string
+		req  permission.CreatePermissionRequest
+		deny bool
+	}{
+		{"inside", permission.CreatePermissionRequest{Path: filepath.Join(allowed, "src", "main.go")}, false},
+		{"prefix itself", permission.CreatePermissionRequest{Path: allowed}, false},
+		{"new file inside", permission.CreatePermissionRequest{Path: filepath.Join(allowed, "new", "file.go")}, false},
+		{"no path", permission.CreatePermissionRequest{ToolName: "fetch"}, false},
+		{"outside", permission.CreatePermissionRequest{Path: "/etc/passwd"}, true},
+		{"sibling with shared prefix", permission.CreatePermissionRequest{Path: allowed + "-other/file"}, true},
+		{"dot dot", permission.CreatePermissionRequest{Path: filepath.Join(allowed, "src") + "/../../secrets/key"}, true},
+		{"dot dot back inside", permission.CreatePermissionRequest{Path: allowed + "/src/../src/main.go"}, false},
+		{"symlink out", permission.CreatePermissionRequest{Path: filepath.Join(allowed, "escape", "key")}, true},
+		{"dot dot after symlink", permission.CreatePermissionRequest{Path: filepath.Join(allowed, "escape") + "/../project/x"}, false},
+		{"dangling symlink out", permission.CreatePermissionRequest{Path: filepath.Join(allowed, "dangling")}, true},
+		{"symlink in", permission.CreatePermissionRequest{Path: filepath.Join(root, "alias", "main.go")}, false},
+		{"symlink loop", permission.CreatePermissionRequest{Path: filepath.Join(allowed, "loop", "x")}, true},
+		{"param outside", permission.CreatePermissionRequest{
+			Path:   allowed,
+			Params: map[string]any{"file_path": filepath.Join(outside, "key")},
+		}, true},
+		{"struct param outside", permission.CreatePermissionRequest{
+			Path: allowed,
+			Params: struct {
+				Path string `json:"path"`
+			}{Path: outside},
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			decision, err := hook.OnPermissionRequest(t.Context(), tt.req)
+			require.NoError(t, err)
+			if tt.deny {
+				require.NotNil(t, decision)
+				require.False(t, *decision)
+				require.Error(t, hook.Check(tt.req))
+			} else {
+				require.Nil(t, decision)
+			}
+		})
+	}
+}
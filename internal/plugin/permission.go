@@ -0,0 +1,124 @@
+package plugin
+
+import (
+	"container/list"
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/charmbracelet/crush/internal/permission"
+)
+
+// hookedPermissions consults plugin permission hooks before falling back to
+// the wrapped service
+type hookedPermissions struct {
+	permission.Service
+	registry *Registry
+}
+
+// WithPermissionHooks wraps a permission service so that plugin permission
+// hooks can allow or deny a request before the user is prompted. Requests
+// reach the hooks with their ResolvedPath filled in. A failing hook denies
+// the request. Hooks are notified of every denied request.
+func WithPermissionHooks(service permission.Service, registry *Registry) permission.Service {
+	return &hookedPermissions{Service: service, registry: registry}
+}
+
+func (p *hookedPermissions) Request(opts permission.CreatePermissionRequest) bool {
+	if p.SkipRequests() {
+		return true
+	}
+
+	opts = p.Resolve(opts)
+	decision, plugin, err := p.registry.decidePermission(context.Background(), opts)
+	if err != nil {
+		slog.Error("Plugin permission hook failed, denying request", "tool", opts.ToolName, "error", err)
+		p.denied(opts, DeniedByPluginPrefix+plugin)
+		return false
+	}
+	if decision != nil {
+		if !*decision {
+			p.denied(opts, DeniedByPluginPrefix+plugin)
+		}
+		return *decision
+	}
+	if !p.Service.Request(opts) {
+		p.denied(opts, DeniedByUser)
+		return false
+	}
+	return true
+}
+
+// denied notifies the hooks that a request was denied
+func (p *hookedPermissions) denied(opts permission.CreatePermissionRequest, deniedBy string) {
+	if err := p.registry.TriggerPermissionDenied(context.Background(), opts, deniedBy); err != nil {
+		slog.Error("Plugin permission denied hook failed", "tool", opts.ToolName, "error", err)
+	}
+}
+
+// SetMaxPermissionRequests bounds how many permission requests are passed to
+// permission hooks at once. Excess requests wait and are admitted in the
+// order they arrived. A limit of zero or less removes the bound; requests
+// already waiting still finish under the previous limit.
+func (r *Registry) SetMaxPermissionRequests(n int) {
+	if n <= 0 {
+		r.permLimit.Store(nil)
+		return
+	}
+	r.permLimit.Store(newFIFOSemaphore(n))
+}
+
+// fifoSemaphore bounds concurrency, admitting waiters in arrival order
+type fifoSemaphore struct {
+	mu      sync.Mutex
+	size    int
+	held    int
+	waiters list.List // of chan struct{}, closed when the slot is handed over
+}
+
+func newFIFOSemaphore(size int) *fifoSemaphore {
+	return &fifoSemaphore{size: size}
+}
+
+// acquire waits for a slot or until ctx is done
+func (s *fifoSemaphore) acquire(ctx context.Context) error {
+	s.mu.Lock()
+	if s.held < s.size && s.waiters.Len() == 0 {
+		s.held++
+		s.mu.Unlock()
+		return nil
+	}
+	ready := make(chan struct{})
+	elem := s.waiters.PushBack(ready)
+	s.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		select {
+		case <-ready:
+			// The slot was handed over as ctx was cancelled; pass it on
+			s.mu.Unlock()
+			s.release()
+		default:
+			s.waiters.Remove(elem)
+			s.mu.Unlock()
+		}
+		return ctx.Err()
+	}
+}
+
+// release frees a slot, handing it to the longest waiter if there is one
+func (s *fifoSemaphore) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if front := s.waiters.Front(); front != nil {
+		s.waiters.Remove(front)
+		close(front.Value.(chan struct{}))
+		return
+	}
+	s.held--
+}
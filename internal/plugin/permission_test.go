@@ -0,0 +1,164 @@
+package plugin
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingPermissionHook records requests in the order they reach it and
+// holds each one until released
+type blockingPermissionHook struct {
+	release chan struct{}
+	mu      sync.Mutex
+	seen    []string
+}
+
+func (h *blockingPermissionHook) OnPermissionRequest(ctx context.Context, req permission.CreatePermissionRequest) (*bool, error) {
+	h.mu.Lock()
+	h.seen = append(h.seen, req.ToolName)
+	h.mu.Unlock()
+	<-h.release
+	return nil, nil
+}
+
+type permissionHookPlugin struct {
+	flakyPlugin
+	hook PermissionHook
+}
+
+func (p *permissionHookPlugin) Hooks() Hooks {
+	hooks := NewBaseHooks()
+	hooks.PermissionHook = p.hook
+	return hooks
+}
+
+func TestMaxPermissionRequests(t *testing.T) {
+	t.Parallel()
+
+	hook := &blockingPermissionHook{release: make(chan struct{})}
+	r := NewRegistry()
+	r.SetMaxPermissionRequests(1)
+	require.NoError(t, r.LoadPlugin(t.Context(), &permissionHookPlugin{
+		flakyPlugin: flakyPlugin{name: "slow"},
+		hook:        hook,
+	}, PluginContext{}))
+	limit := r.permLimit.Load()
+
+	var wg sync.WaitGroup
+	tools := []string{"first", "second", "third", "fourth"}
+	for i, tool := range tools {
+		wg.Go(func() {
+			_, err := r.TriggerPermissionRequest(context.Background(), permission.CreatePermissionRequest{ToolName: tool})
+			require.NoError(t, err)
+		})
+		// Wait for the request to start or queue so arrival order is known
+		require.Eventually(t, func() bool {
+			limit.mu.Lock()
+			defer limit.mu.Unlock()
+			return limit.held+limit.waiters.Len() == i+1
+		}, time.Second, time.Millisecond)
+	}
+
+	// A cancelled request leaves the queue without taking a slot
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	_, err := r.TriggerPermissionRequest(ctx, permission.CreatePermissionRequest{ToolName: "cancelled"})
+	require.ErrorIs(t, err, context.Canceled)
+
+	for range tools {
+		hook.release <- struct{}{}
+	}
+	wg.Wait()
+	require.Equal(t, tools, hook.seen)
+	require.Zero(t, limit.held)
+}
+
+// denyingPermissionHook denies requests for one tool and records denials
+type denyingPermissionHook struct {
+	NilPermissionHook
+	tool   string
+	denied []string
+}
+
+func (h *denyingPermissionHook) OnPermissionRequest(ctx context.Context, req permission.CreatePermissionRequest) (*bool, error) {
+	if req.ToolName == h.tool {
+		deny := false
+		return &deny, nil
+	}
+	return nil, nil
+}
+
+func (h *denyingPermissionHook) OnPermissionDenied(ctx context.Context, req permission.CreatePermissionRequest, deniedBy string) error {
+	h.denied = append(h.denied, req.ToolName+" by "+deniedBy)
+	return nil
+}
+
+// promptPermissions stands in for the user, granting only one tool
+type promptPermissions struct {
+	permission.Service
+	grant string
+}
+
+func (p *promptPermissions) SkipRequests() bool { return false }
+
+func (p *promptPermissions) Resolve(opts permission.CreatePermissionRequest) permission.CreatePermissionRequest {
+	return opts
+}
+
+func (p *promptPermissions) Request(opts permission.CreatePermissionRequest) bool {
+	return opts.ToolName == p.grant
+}
+
+func TestPermissionDeniedHook(t *testing.T) {
+	t.Parallel()
+
+	hook := &denyingPermissionHook{tool: "rm"}
+	r := NewRegistry()
+	require.NoError(t, r.LoadPlugin(t.Context(), &permissionHookPlugin{
+		flakyPlugin: flakyPlugin{name: "policy"},
+		hook:        hook,
+	}, PluginContext{}))
+
+	service := WithPermissionHooks(&promptPermissions{grant: "view"}, r)
+	require.False(t, service.Request(permission.CreatePermissionRequest{ToolName: "rm"}))
+	require.False(t, service.Request(permission.CreatePermissionRequest{ToolName: "bash"}))
+	require.True(t, service.Request(permission.CreatePermissionRequest{ToolName: "view"}))
+
+	require.Equal(t, []string{"rm by plugin:policy", "bash by user"}, hook.denied)
+}
+
+// pathRecordingHook denies every request, recording its resolved path
+type pathRecordingHook struct {
+	NilPermissionHook
+	resolved []string
+}
+
+func (h *pathRecordingHook) OnPermissionRequest(ctx context.Context, req permission.CreatePermissionRequest) (*bool, error) {
+	h.resolved = append(h.resolved, req.ResolvedPath)
+	deny := false
+	return &deny, nil
+}
+
+func TestPermissionHookResolvedPath(t *testing.T) {
+	t.Parallel()
+
+	workingDir, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	hook := &pathRecordingHook{}
+	r := NewRegistry()
+	require.NoError(t, r.LoadPlugin(t.Context(), &permissionHookPlugin{
+		flakyPlugin: flakyPlugin{name: "policy"},
+		hook:        hook,
+	}, PluginContext{}))
+
+	service := WithPermissionHooks(permission.NewPermissionService(workingDir, false, nil), r)
+	require.False(t, service.Request(permission.CreatePermissionRequest{ToolName: "edit", Path: "src/../main.go"}))
+	require.False(t, service.Request(permission.CreatePermissionRequest{ToolName: "fetch"}))
+	require.Equal(t, []string{filepath.Join(workingDir, "main.go"), ""}, hook.resolved)
+}
@@ -2,11 +2,14 @@ package plugin
 
 import (
 	"context"
+	"encoding/json"
+	"time"
 
 	"charm.land/fantasy"
 	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/message"
 	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/pubsub"
 	"github.com/charmbracelet/crush/internal/session"
 )
 
@@ -19,10 +22,14 @@ type Plugin interface {
 
 	// Init is called when the plugin is loaded, before any hooks are registered.
 	// The plugin should use this to perform any necessary initialization.
+	// It may also decide which hooks to provide, e.g. based on the
+	// configuration in pluginCtx.
 	Init(ctx context.Context, pluginCtx PluginContext) error
 
-	// Hooks returns the hook implementations provided by this plugin.
-	// Returning nil for any hook means the plugin doesn't implement it.
+	// Hooks returns the hook implementations provided by this plugin. It is
+	// called once Init has succeeded, and the hooks it returns then are
+	// the ones registered. Returning nil for any hook means the plugin
+	// doesn't implement it.
 	Hooks() Hooks
 
 	// Shutdown is called when the application is shutting down.
@@ -33,16 +40,25 @@ type Plugin interface {
 // PluginInfo contains metadata about a plugin
 type PluginInfo struct {
 	// Name is the unique identifier for the plugin
-	Name string
+	Name string `json:"name"`
 
 	// Version is the semantic version of the plugin
-	Version string
+	Version string `json:"version"`
 
 	// Description is a human-readable description of what the plugin does
-	Description string
+	Description string `json:"description,omitempty"`
 
 	// Author is the plugin author or organization
-	Author string
+	Author string `json:"author,omitempty"`
+
+	// Homepage is an optional URL to the plugin's homepage or source repository
+	Homepage string `json:"homepage,omitempty"`
+
+	// License is an optional SPDX license identifier (e.g. "MIT")
+	License string `json:"license,omitempty"`
+
+	// Tags are optional keywords used to categorize the plugin
+	Tags []string `json:"tags,omitempty"`
 }
 
 // PluginContext provides plugins with access to application services and state.
@@ -57,6 +73,20 @@ type PluginContext struct {
 
 	// WorkingDir is the current working directory
 	WorkingDir string
+
+	// Store is persistent key-value storage scoped to the plugin. It is nil
+	// when the registry has no storage configured.
+	Store KVStore
+
+	// Topics lets the plugin publish events on its own topics and subscribe
+	// to those of other plugins. Subscriptions end when the plugin is
+	// unloaded.
+	Topics TopicBus
+
+	// Settings is the plugin's section of the plugins.settings
+	// configuration, keyed by the plugin's name. It is nil if there is
+	// none.
+	Settings json.RawMessage
 }
 
 // Services provides access to core application services that plugins can use
@@ -69,6 +99,36 @@ type Services struct {
 
 	// Permission service for permission requests
 	Permission permission.Service
+
+	// Plugins publishes events when other plugins are loaded or unloaded
+	Plugins pubsub.Suscriber[PluginEvent]
+
+	// Exporter renders session transcripts
+	Exporter SessionExporter
+
+	// Agent runs the agent on child sessions
+	Agent SubAgentRunner
+
+	// Tools lists and calls the tools offered to the agent
+	Tools ToolCaller
+}
+
+// SubAgentRunner runs the agent on child sessions, e.g. to let a plugin tool
+// delegate a subtask
+type SubAgentRunner interface {
+	// RunSubAgent creates a child session of parentSessionID, runs the agent
+	// on it with prompt, and returns the agent's final response. The run
+	// requests permissions and counts against the parent's cost budget like
+	// any other run. It is canceled with ctx or when the parent session is
+	// canceled, and fails if child sessions would be nested too deeply.
+	RunSubAgent(ctx context.Context, parentSessionID, prompt string) (string, error)
+}
+
+// SessionExporter renders a session as a human-readable transcript
+type SessionExporter interface {
+	// ExportSession renders the session's messages, including tool calls
+	// and their results, as "markdown" or "html"
+	ExportSession(ctx context.Context, sessionID, format string) ([]byte, error)
 }
 
 // Hooks defines all available hook points that plugins can implement.
@@ -94,6 +154,60 @@ type Hooks interface {
 	Agent() AgentHook
 }
 
+// ProviderHooks may be implemented by Hooks to observe the calls made to the
+// model provider. They are only called when enabled in the configuration.
+type ProviderHooks interface {
+	Provider() ProviderHook
+}
+
+// providerHook returns the provider hook of hooks, or nil if it has none
+func providerHook(hooks Hooks) ProviderHook {
+	if provHooks, ok := hooks.(ProviderHooks); ok {
+		return provHooks.Provider()
+	}
+	return nil
+}
+
+// LSPHooks may be implemented by Hooks to observe the state of language
+// servers
+type LSPHooks interface {
+	LSP() LSPHook
+}
+
+// lspHook returns the LSP hook of hooks, or nil if it has none
+func lspHook(hooks Hooks) LSPHook {
+	if lspHooks, ok := hooks.(LSPHooks); ok {
+		return lspHooks.LSP()
+	}
+	return nil
+}
+
+// HookType identifies one of the hook points in Hooks
+type HookType string
+
+const (
+	HookConfig     HookType = "config"
+	HookSession    HookType = "session"
+	HookMessage    HookType = "message"
+	HookPermission HookType = "permission"
+	HookTool       HookType = "tool"
+	HookAgent      HookType = "agent"
+	HookProvider   HookType = "provider"
+	HookLSP        HookType = "lsp"
+)
+
+// HookTypes lists every hook type, in the order of the Hooks methods
+var HookTypes = []HookType{
+	HookConfig,
+	HookSession,
+	HookMessage,
+	HookPermission,
+	HookTool,
+	HookAgent,
+	HookProvider,
+	HookLSP,
+}
+
 // ConfigHook allows plugins to modify configuration during loading
 type ConfigHook interface {
 	// OnConfigLoad is called after the config is loaded from files but
@@ -111,6 +225,22 @@ type SessionHook interface {
 
 	// OnSessionDeleted is called after a session is deleted
 	OnSessionDeleted(ctx context.Context, sessionID string) error
+
+	// OnSessionCompacted is called after a session's conversation has been
+	// summarized. droppedMessageIDs are the messages replaced by the summary,
+	// which are no longer sent to the model.
+	OnSessionCompacted(ctx context.Context, sessionID string, summary message.Message, droppedMessageIDs []string) error
+}
+
+// ContextTruncatedHook may be implemented by a SessionHook to be notified
+// whenever messages stop being sent to the model, both when the conversation
+// is summarized and when a BeforeModelCallHook leaves messages out
+type ContextTruncatedHook interface {
+	// OnContextTruncated is called with the messages no longer sent to the
+	// model. droppedTokens is the size of the context they took up, which
+	// is estimated from their content when the provider didn't report it.
+	// Each message is reported once.
+	OnContextTruncated(ctx context.Context, sessionID string, droppedTokens int, droppedMessageIDs []string) error
 }
 
 // MessageHook provides hooks for message lifecycle events
@@ -122,6 +252,20 @@ type MessageHook interface {
 	OnMessageUpdated(ctx context.Context, msg message.Message) error
 }
 
+// BatchMessageHook may be implemented by a MessageHook to receive message
+// events in batches when options.plugins.event_batch_window is set. Hooks
+// that don't implement it are called once per message instead.
+type BatchMessageHook interface {
+	// OnMessagesCreated is called with messages created within the batch
+	// window, in order
+	OnMessagesCreated(ctx context.Context, msgs []message.Message) error
+
+	// OnMessagesUpdated is called with messages updated within the batch
+	// window, in order. A message updated several times appears once per
+	// update.
+	OnMessagesUpdated(ctx context.Context, msgs []message.Message) error
+}
+
 // PermissionHook provides hooks for permission request handling
 type PermissionHook interface {
 	// OnPermissionRequest is called when a permission request is made,
@@ -134,6 +278,24 @@ type PermissionHook interface {
 	OnPermissionRequest(ctx context.Context, req permission.CreatePermissionRequest) (*bool, error)
 }
 
+// DeniedByUser is the deniedBy value of requests denied when the user was
+// prompted, including automatic denials in non-interactive mode
+const DeniedByUser = "user"
+
+// DeniedByPluginPrefix prefixes the deniedBy value of requests denied by a
+// plugin's permission hook, followed by the plugin name, e.g. "plugin:policy"
+const DeniedByPluginPrefix = "plugin:"
+
+// PermissionDeniedHook may be implemented by a PermissionHook to be notified
+// when a permission request is ultimately denied, unlike OnPermissionRequest
+// which runs before the outcome is known
+type PermissionDeniedHook interface {
+	// OnPermissionDenied is called after a request was denied. deniedBy is
+	// DeniedByUser or DeniedByPluginPrefix followed by the name of the
+	// plugin whose hook denied the request or failed.
+	OnPermissionDenied(ctx context.Context, req permission.CreatePermissionRequest, deniedBy string) error
+}
+
 // ToolHook provides hooks for tool execution
 type ToolHook interface {
 	// OnToolExecuteBefore is called before a tool is executed.
@@ -145,6 +307,25 @@ type ToolHook interface {
 	// The plugin can modify the tool result by returning a modified result.
 	// Returning nil means no modifications.
 	OnToolExecuteAfter(ctx context.Context, input ToolExecuteInput, result ToolExecuteResult) (*ToolExecuteResult, error)
+
+	// OnToolsAssemble is called when the agent's tool list is built. The
+	// plugin can remove, reorder, or re-describe tools before the model sees
+	// them by returning a modified list. Tools are matched by name, so
+	// renamed or unknown tools are dropped. Returning nil means no
+	// modifications.
+	OnToolsAssemble(ctx context.Context, tools []fantasy.ToolInfo) ([]fantasy.ToolInfo, error)
+}
+
+// RawToolHook may be implemented by a ToolHook that needs to rewrite the
+// exact JSON input of a tool call, for example to preserve key order or
+// numeric precision that a round trip through a map would lose.
+type RawToolHook interface {
+	// OnToolExecuteBeforeRaw is called after OnToolExecuteBefore with the
+	// same input. The plugin can replace the tool input by returning new
+	// JSON. If both methods return modifications, the raw bytes take
+	// precedence and the modified map is discarded. Returning nil means no
+	// modifications.
+	OnToolExecuteBeforeRaw(ctx context.Context, input ToolExecuteInput) (json.RawMessage, error)
 }
 
 // ToolExecuteInput contains information about a tool execution
@@ -163,20 +344,54 @@ type ToolExecuteInput struct {
 
 	// Arguments are the input arguments to the tool (as JSON-serializable map)
 	Arguments map[string]any
+
+	// RawArguments is the tool input exactly as sent by the model. It is
+	// kept in sync with Arguments as hooks modify either.
+	RawArguments json.RawMessage
 }
 
 // ToolExecuteResult contains the result of a tool execution
 type ToolExecuteResult struct {
-	// Output is the text output from the tool
+	// Output is the text output from the tool, as the model sees it
 	Output string
 
-	// Error is any error that occurred during tool execution
+	// DisplayOutput, if set, is shown to the user in place of Output, so
+	// that e.g. the model gets a summary while the user sees everything.
+	// It is empty unless a hook sets it.
+	DisplayOutput string
+
+	// Error is the error the tool returned, if any, as a *ToolError
+	// classifying the failure; see ToolErrorKindOf. Error responses
+	// returned to the model are part of Output and leave it nil; their
+	// kind is in Metadata[MetadataErrorKind].
 	Error error
 
-	// Metadata contains additional metadata about the execution
+	// Metadata contains additional metadata about the execution. Crush
+	// fills in the standard Metadata* keys before calling after hooks.
 	Metadata map[string]any
 }
 
+// Standard ToolExecuteResult.Metadata keys
+const (
+	// MetadataDurationMS is the tool's execution time in milliseconds (int64)
+	MetadataDurationMS = "duration_ms"
+
+	// MetadataBytesOut is the size of the tool's text output in bytes (int)
+	MetadataBytesOut = "bytes_out"
+
+	// MetadataIsError reports whether the tool returned an error (bool)
+	MetadataIsError = "is_error"
+
+	// MetadataErrorKind is the ToolErrorKind of a failed execution, as a
+	// string. Tools can classify their own error responses by setting it in
+	// their response metadata.
+	MetadataErrorKind = "error_kind"
+
+	// MetadataTool holds the tool-specific metadata the tool attached to its
+	// response, decoded from JSON (map[string]any)
+	MetadataTool = "tool"
+)
+
 // AgentHook provides hooks for agent execution lifecycle
 type AgentHook interface {
 	// OnAgentStart is called when an agent starts processing a prompt
@@ -187,6 +402,44 @@ type AgentHook interface {
 
 	// OnAgentFinish is called when an agent completes execution
 	OnAgentFinish(ctx context.Context, input AgentFinishInput) error
+
+	// OnAgentRetry is called before a provider request is retried after a
+	// transient error. Retries are not steps; OnAgentStep is only called
+	// once the step succeeds.
+	OnAgentRetry(ctx context.Context, input AgentRetryInput) error
+
+	// OnAgentFinalMessage is called with the final assistant message of a
+	// successful run before its final version is saved. It may return a
+	// rewritten message, or nil to keep it unchanged.
+	OnAgentFinalMessage(ctx context.Context, sessionID string, msg message.Message) (*message.Message, error)
+
+	// OnModelChanged is called when the agent's large or small model or
+	// its provider changes, once for each model type that changed. It is
+	// only called when the model actually changes. The session ID is empty
+	// if the change was not made from within a session.
+	OnModelChanged(ctx context.Context, sessionID string, modelType config.SelectedModelType, oldModel, newModel, provider string) error
+}
+
+// SystemPromptHook may be implemented by an AgentHook to change the system
+// prompt of each agent run
+type SystemPromptHook interface {
+	// OnSystemPrompt is called when an agent run starts, before
+	// OnAgentStart, and returns the system prompt to use for the run. Hooks
+	// run in plugin order, each receiving the previous hook's result.
+	OnSystemPrompt(ctx context.Context, sessionID, prompt string) (string, error)
+}
+
+// BeforeModelCallHook may be implemented by an AgentHook to change the
+// conversation sent to the model, e.g. to trim it to fit the context window
+type BeforeModelCallHook interface {
+	// OnBeforeModelCall is called before each provider call of an agent run
+	// with the session's messages, including those of earlier steps of the
+	// run, and returns the messages to send instead. It may drop, reorder,
+	// or rewrite messages; the session itself is not changed, and the
+	// system prompt is sent as before. Hooks run in plugin order, each
+	// receiving the previous hook's result, and must not return an empty
+	// list.
+	OnBeforeModelCall(ctx context.Context, sessionID string, messages []message.Message) ([]message.Message, error)
 }
 
 // AgentStartInput contains information about an agent starting execution
@@ -202,6 +455,10 @@ type AgentStartInput struct {
 
 	// Provider is the provider being used
 	Provider string
+
+	// AvailableTools are the names of the tools offered to the model in the
+	// run, built-in, MCP, and plugin tools alike, after plugins assembled them
+	AvailableTools []string
 }
 
 // AgentStepInput contains information about an agent step
@@ -215,8 +472,18 @@ type AgentStepInput struct {
 	// ToolCalls are the tool calls made in this step
 	ToolCalls []fantasy.ToolCallContent
 
+	// ToolCallIDs are the IDs of the tool calls made in this step, in order.
+	// They match ToolExecuteInput.ToolCallID of the corresponding executions.
+	ToolCallIDs []string
+
 	// Response is the agent's text response in this step
 	Response string
+
+	// Usage is the tokens used by this step
+	Usage fantasy.Usage
+
+	// Cost is the cost of this step in USD, as added to the session's cost
+	Cost float64
 }
 
 // AgentFinishInput contains information about an agent completing execution
@@ -227,6 +494,12 @@ type AgentFinishInput struct {
 	// TotalSteps is the total number of steps executed
 	TotalSteps int
 
+	// Usage is the tokens used by all steps of the run
+	Usage fantasy.Usage
+
+	// Cost is the cost of all steps of the run in USD
+	Cost float64
+
 	// Result is the final agent result
 	Result *fantasy.AgentResult
 
@@ -234,6 +507,86 @@ type AgentFinishInput struct {
 	Error error
 }
 
+// AgentRetryInput contains information about a retried provider request
+type AgentRetryInput struct {
+	// SessionID is the ID of the session
+	SessionID string
+
+	// StepNumber is the number of the step being retried
+	StepNumber int
+
+	// Attempt is the retry attempt for this step, starting at 1
+	Attempt int
+
+	// Error is the error that triggered the retry
+	Error error
+
+	// Delay is how long the agent waits before retrying
+	Delay time.Duration
+}
+
+// ProviderHook observes the requests sent to the model provider and their
+// responses. It is only called when options.plugins.provider_hooks is set,
+// since payloads include the whole conversation and can be large. Errors are
+// logged and never affect the call.
+type ProviderHook interface {
+	// OnProviderRequest is called before each call to the provider
+	OnProviderRequest(ctx context.Context, req ProviderRequest) error
+
+	// OnProviderResponse is called when a provider call finishes,
+	// successfully or not. For streamed calls, that is when the stream ends.
+	OnProviderResponse(ctx context.Context, resp ProviderResponse) error
+}
+
+// ProviderRequest describes a call to the model provider
+type ProviderRequest struct {
+	// ID identifies the call; the matching ProviderResponse has the same ID
+	ID string
+
+	// SessionID is the ID of the session, if the call was made for one
+	SessionID string
+
+	// Provider and Model identify the model being called
+	Provider string
+	Model    string
+
+	// Stream is true for streamed calls
+	Stream bool
+
+	// Payload is the request serialized as JSON, with credentials redacted
+	Payload json.RawMessage
+}
+
+// ProviderResponse describes the outcome of a call to the model provider
+type ProviderResponse struct {
+	// ID is the ID of the matching ProviderRequest
+	ID string
+
+	// SessionID is the ID of the session, if the call was made for one
+	SessionID string
+
+	// Provider and Model identify the model that was called
+	Provider string
+	Model    string
+
+	// Usage holds the token counts reported by the provider
+	Usage fantasy.Usage
+
+	// FinishReason is why the model stopped generating
+	FinishReason fantasy.FinishReason
+
+	// Latency is the time from the request until the response (or, for
+	// streamed calls, the stream) ended
+	Latency time.Duration
+
+	// Error is the error the call failed with, if any
+	Error error
+
+	// Payload is the response serialized as JSON, with credentials
+	// redacted. For streamed calls it is the array of stream parts.
+	Payload json.RawMessage
+}
+
 // NilConfigHook implements ConfigHook with no-op methods
 type NilConfigHook struct{}
 
@@ -249,6 +602,13 @@ func (n NilSessionHook) OnSessionUpdated(ctx context.Context, sess session.Sessi
 	return nil
 }
 func (n NilSessionHook) OnSessionDeleted(ctx context.Context, sessionID string) error { return nil }
+func (n NilSessionHook) OnSessionCompacted(ctx context.Context, sessionID string, summary message.Message, droppedMessageIDs []string) error {
+	return nil
+}
+
+func (n NilSessionHook) OnContextTruncated(ctx context.Context, sessionID string, droppedTokens int, droppedMessageIDs []string) error {
+	return nil
+}
 
 // NilMessageHook implements MessageHook with no-op methods
 type NilMessageHook struct{}
@@ -263,6 +623,10 @@ func (n NilPermissionHook) OnPermissionRequest(ctx context.Context, req permissi
 	return nil, nil
 }
 
+func (n NilPermissionHook) OnPermissionDenied(ctx context.Context, req permission.CreatePermissionRequest, deniedBy string) error {
+	return nil
+}
+
 // NilToolHook implements ToolHook with no-op methods
 type NilToolHook struct{}
 
@@ -272,13 +636,58 @@ func (n NilToolHook) OnToolExecuteBefore(ctx context.Context, input ToolExecuteI
 func (n NilToolHook) OnToolExecuteAfter(ctx context.Context, input ToolExecuteInput, result ToolExecuteResult) (*ToolExecuteResult, error) {
 	return nil, nil
 }
+func (n NilToolHook) OnToolsAssemble(ctx context.Context, tools []fantasy.ToolInfo) ([]fantasy.ToolInfo, error) {
+	return nil, nil
+}
 
 // NilAgentHook implements AgentHook with no-op methods
 type NilAgentHook struct{}
 
-func (n NilAgentHook) OnAgentStart(ctx context.Context, input AgentStartInput) error    { return nil }
-func (n NilAgentHook) OnAgentStep(ctx context.Context, input AgentStepInput) error      { return nil }
-func (n NilAgentHook) OnAgentFinish(ctx context.Context, input AgentFinishInput) error  { return nil }
+func (n NilAgentHook) OnAgentStart(ctx context.Context, input AgentStartInput) error   { return nil }
+func (n NilAgentHook) OnAgentStep(ctx context.Context, input AgentStepInput) error     { return nil }
+func (n NilAgentHook) OnAgentFinish(ctx context.Context, input AgentFinishInput) error { return nil }
+func (n NilAgentHook) OnAgentRetry(ctx context.Context, input AgentRetryInput) error   { return nil }
+func (n NilAgentHook) OnAgentFinalMessage(ctx context.Context, sessionID string, msg message.Message) (*message.Message, error) {
+	return nil, nil
+}
+func (n NilAgentHook) OnModelChanged(ctx context.Context, sessionID string, modelType config.SelectedModelType, oldModel, newModel, provider string) error {
+	return nil
+}
+
+// LSPState is the state of a language server client
+type LSPState string
+
+const (
+	LSPStateStarting LSPState = "starting"
+	LSPStateReady    LSPState = "ready"
+	LSPStateError    LSPState = "error"
+	LSPStateDisabled LSPState = "disabled"
+)
+
+// LSPHook observes the language server clients. Errors are logged and never
+// affect the clients.
+type LSPHook interface {
+	// OnLSPClientStateChanged is called when the named client changes
+	// state, e.g. to LSPStateError when its server fails to start
+	OnLSPClientStateChanged(ctx context.Context, name string, state LSPState) error
+}
+
+// NilProviderHook implements ProviderHook with no-op methods
+type NilProviderHook struct{}
+
+func (n NilProviderHook) OnProviderRequest(ctx context.Context, req ProviderRequest) error {
+	return nil
+}
+func (n NilProviderHook) OnProviderResponse(ctx context.Context, resp ProviderResponse) error {
+	return nil
+}
+
+// NilLSPHook implements LSPHook with no-op methods
+type NilLSPHook struct{}
+
+func (n NilLSPHook) OnLSPClientStateChanged(ctx context.Context, name string, state LSPState) error {
+	return nil
+}
 
 // BaseHooks provides default no-op implementations for all hooks.
 // Plugins can embed this to only implement the hooks they need.
@@ -289,6 +698,8 @@ type BaseHooks struct {
 	PermissionHook PermissionHook
 	ToolHook       ToolHook
 	AgentHook      AgentHook
+	ProviderHook   ProviderHook
+	LSPHook        LSPHook
 }
 
 func (b *BaseHooks) Config() ConfigHook         { return b.ConfigHook }
@@ -297,6 +708,8 @@ func (b *BaseHooks) Message() MessageHook       { return b.MessageHook }
 func (b *BaseHooks) Permission() PermissionHook { return b.PermissionHook }
 func (b *BaseHooks) Tool() ToolHook             { return b.ToolHook }
 func (b *BaseHooks) Agent() AgentHook           { return b.AgentHook }
+func (b *BaseHooks) Provider() ProviderHook     { return b.ProviderHook }
+func (b *BaseHooks) LSP() LSPHook               { return b.LSPHook }
 
 // NewBaseHooks creates a new BaseHooks with all nil implementations
 func NewBaseHooks() *BaseHooks {
@@ -307,5 +720,7 @@ func NewBaseHooks() *BaseHooks {
 		PermissionHook: NilPermissionHook{},
 		ToolHook:       NilToolHook{},
 		AgentHook:      NilAgentHook{},
+		ProviderHook:   NilProviderHook{},
+		LSPHook:        NilLSPHook{},
 	}
 }
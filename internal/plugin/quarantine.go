@@ -0,0 +1,198 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"slices"
+	"time"
+)
+
+// quarantineShutdownTimeout bounds shutting down a quarantined plugin
+const quarantineShutdownTimeout = 5 * time.Second
+
+// QuarantinePolicy controls what happens to plugins whose hooks panic.
+// Panics are always recovered and reported as errors wrapping
+// ErrHookPanicked; the policy decides when a plugin is quarantined for it.
+type QuarantinePolicy struct {
+	// Threshold is the number of panics after which a plugin is unloaded
+	// (quarantined). Zero never quarantines plugins.
+	Threshold int
+
+	// Cooldown is how long a quarantined plugin that was loaded from a file
+	// exporting NewPluginSymbol stays unloaded before a fresh instance is
+	// loaded. Zero leaves it unloaded.
+	Cooldown time.Duration
+}
+
+// quarantine records a plugin that was unloaded for panicking
+type quarantine struct {
+	plugin   Plugin
+	hooks    []string // hooks the plugin implemented, empty if Init failed
+	ctx      PluginContext
+	source   pluginSource
+	reason   string
+	since    time.Time
+	reloadAt time.Time     // zero if the plugin isn't reloaded
+	timer    *time.Timer   // fires the reload
+	done     chan struct{} // closed once the plugin has shut down
+}
+
+// SetQuarantinePolicy sets the policy for plugins whose hooks panic. It
+// applies to panics from then on; panics counted before still count.
+func (r *Registry) SetQuarantinePolicy(policy QuarantinePolicy) {
+	r.panicPolicy.Store(&policy)
+}
+
+// IsQuarantined reports whether the named plugin is currently quarantined
+func (r *Registry) IsQuarantined(name string) bool {
+	_, ok := r.quarantined.Get(name)
+	return ok
+}
+
+// guard runs a hook or tool of the named plugin, recovering a panic as an
+// error wrapping ErrHookPanicked. A nil registry doesn't count the panic.
+func (r *Registry) guard(name string, hook func() error) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			slog.Error("Plugin panicked", "plugin", name, "panic", p, "stack", string(debug.Stack()))
+			err = fmt.Errorf("%w: %v", ErrHookPanicked, p)
+			if r != nil {
+				r.recordPanic(name, err)
+			}
+		}
+	}()
+	return hook()
+}
+
+// recordPanic counts a panic of the named plugin, quarantining it once the
+// policy's threshold is reached
+func (r *Registry) recordPanic(name string, err error) {
+	r.mu.Lock()
+	r.panics[name]++
+	count := r.panics[name]
+	r.mu.Unlock()
+
+	policy := r.panicPolicy.Load()
+	if policy == nil || policy.Threshold <= 0 || count < policy.Threshold {
+		return
+	}
+	r.quarantine(name, fmt.Sprintf("hooks panicked %d times, last: %v", count, err), *policy)
+}
+
+// quarantine unloads the named plugin and, if it can be created again and
+// the policy has a cooldown, schedules a fresh instance to be loaded. Its hooks
+// stop running at once; shutting it down happens in the background, since
+// quarantine runs inside a hook trigger.
+func (r *Registry) quarantine(name, reason string, policy QuarantinePolicy) {
+	// Only the first of concurrent panics gets the plugin
+	plugin, ok := r.plugins.Take(name)
+	if !ok {
+		return
+	}
+	pluginCtx, _ := r.contexts.Take(name)
+	source, _ := r.sources.Take(name)
+	r.health.Del(name)
+	r.loadTimes.Del(name)
+	r.mu.Lock()
+	delete(r.panics, name)
+	r.mu.Unlock()
+	hooks := r.registeredHooks(name)
+	r.unregisterHooks(name)
+	r.closeTopics(name)
+
+	q := &quarantine{
+		plugin: plugin,
+		hooks:  hooks,
+		ctx:    pluginCtx,
+		source: source,
+		reason: reason,
+		since:  time.Now(),
+		done:   make(chan struct{}),
+	}
+	go func() {
+		defer close(q.done)
+		shutdownQuarantined(name, plugin)
+	}()
+	if policy.Cooldown > 0 && source.open != nil {
+		q.reloadAt = q.since.Add(policy.Cooldown)
+		q.timer = time.AfterFunc(policy.Cooldown, func() {
+			<-q.done
+			r.reload(name, q)
+		})
+	}
+	r.quarantined.Set(name, q)
+
+	slog.Warn("Quarantined plugin", "plugin", name, "reason", reason, "reload_at", q.reloadAt)
+	r.publish(PluginQuarantined, plugin.Info())
+}
+
+// registeredHooks returns the hooks registered for the named plugin,
+// without calling into the plugin
+func (r *Registry) registeredHooks(name string) []string {
+	hooks := []string{}
+	for _, hookType := range HookTypes {
+		if slices.Contains(r.PluginsImplementing(hookType), name) {
+			hooks = append(hooks, string(hookType))
+		}
+	}
+	return hooks
+}
+
+// shutdownQuarantined shuts a quarantined plugin down, ignoring failures
+// since the plugin is already known to be broken
+func shutdownQuarantined(name string, plugin Plugin) {
+	defer func() {
+		if p := recover(); p != nil {
+			slog.Warn("Quarantined plugin panicked while shutting down", "plugin", name, "panic", p)
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), quarantineShutdownTimeout)
+	defer cancel()
+	if err := plugin.Shutdown(ctx); err != nil {
+		slog.Warn("Quarantined plugin failed to shut down", "plugin", name, "error", err)
+	}
+}
+
+// reload loads a fresh instance of a quarantined plugin with its original
+// context. If that fails the plugin stays quarantined for good.
+func (r *Registry) reload(name string, q *quarantine) {
+	// The quarantine is lifted if the plugin was loaded again meanwhile
+	if current, ok := r.quarantined.Get(name); !ok || current != q {
+		return
+	}
+	r.quarantined.Del(name)
+
+	slog.Info("Reloading quarantined plugin", "plugin", name)
+	err := r.guard(name, func() error {
+		plugin, err := q.source.open()
+		if err != nil {
+			return err
+		}
+		return r.loadPlugin(context.Background(), plugin, q.ctx, RetryPolicy{}, q.source, 0)
+	})
+	if err != nil {
+		slog.Error("Failed to reload quarantined plugin", "plugin", name, "error", err)
+		r.mu.Lock()
+		delete(r.panics, name)
+		r.mu.Unlock()
+		r.quarantined.Set(name, &quarantine{
+			plugin: q.plugin,
+			hooks:  []string{},
+			ctx:    q.ctx,
+			source: q.source,
+			reason: fmt.Sprintf("reload failed: %v", err),
+			since:  time.Now(),
+			done:   q.done,
+		})
+	}
+}
+
+// liftQuarantine forgets that the named plugin was quarantined, cancelling
+// its reload
+func (r *Registry) liftQuarantine(name string) {
+	if q, ok := r.quarantined.Take(name); ok && q.timer != nil {
+		q.timer.Stop()
+	}
+}
@@ -0,0 +1,190 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+// panickyPlugin has a session hook that panics
+type panickyPlugin struct {
+	NilSessionHook
+	name      string
+	inits     atomic.Int32
+	shutdowns atomic.Int32
+}
+
+func (p *panickyPlugin) Info() PluginInfo { return PluginInfo{Name: p.name} }
+
+func (p *panickyPlugin) Hooks() Hooks {
+	hooks := NewBaseHooks()
+	hooks.SessionHook = p
+	return hooks
+}
+
+func (p *panickyPlugin) Init(ctx context.Context, pluginCtx PluginContext) error {
+	p.inits.Add(1)
+	return nil
+}
+
+func (p *panickyPlugin) Shutdown(ctx context.Context) error {
+	p.shutdowns.Add(1)
+	return nil
+}
+
+func (p *panickyPlugin) OnSessionCreated(ctx context.Context, sess session.Session) error {
+	panic("corrupted state")
+}
+
+// unreloadablePlugin fails every Init after the first and only has hooks
+// while it is initialized
+type unreloadablePlugin struct {
+	panickyPlugin
+	initialized atomic.Bool
+}
+
+func (p *unreloadablePlugin) Init(ctx context.Context, pluginCtx PluginContext) error {
+	ok := p.inits.Add(1) == 1
+	p.initialized.Store(ok)
+	if !ok {
+		return errors.New("state lost")
+	}
+	return nil
+}
+
+func (p *unreloadablePlugin) Hooks() Hooks {
+	if !p.initialized.Load() {
+		panic("Hooks called without a successful Init")
+	}
+	return p.panickyPlugin.Hooks()
+}
+
+func TestHookPanicRecovered(t *testing.T) {
+	t.Parallel()
+
+	r := NewRegistry()
+	require.NoError(t, r.LoadPlugin(t.Context(), &panickyPlugin{name: "panicky"}, PluginContext{}))
+
+	for range 3 {
+		err := r.TriggerSessionCreated(t.Context(), session.Session{})
+		require.ErrorIs(t, err, ErrHookPanicked)
+		require.ErrorContains(t, err, "corrupted state")
+	}
+
+	// Without a quarantine policy the plugin stays loaded
+	require.False(t, r.IsQuarantined("panicky"))
+	details := r.DescribePlugins()
+	require.Len(t, details, 1)
+	require.Equal(t, 3, details[0].Panics)
+	require.False(t, details[0].Quarantined)
+}
+
+func TestQuarantine(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reloads a fresh instance of plugins loaded from a file", func(t *testing.T) {
+		t.Parallel()
+
+		r := NewRegistry()
+		r.SetQuarantinePolicy(QuarantinePolicy{Threshold: 2, Cooldown: 50 * time.Millisecond})
+		events := r.Subscribe(t.Context())
+		p := &panickyPlugin{name: "panicky"}
+		fresh := &panickyPlugin{name: "panicky"}
+		source := pluginSource{path: "/plugins/panicky.so", open: func() (Plugin, error) { return fresh, nil }}
+		require.NoError(t, r.loadPlugin(t.Context(), p, PluginContext{}, RetryPolicy{}, source, 0))
+		<-events
+
+		require.ErrorIs(t, r.TriggerSessionCreated(t.Context(), session.Session{}), ErrHookPanicked)
+		require.False(t, r.IsQuarantined("panicky"))
+		require.ErrorIs(t, r.TriggerSessionCreated(t.Context(), session.Session{}), ErrHookPanicked)
+		require.True(t, r.IsQuarantined("panicky"))
+
+		event := <-events
+		require.Equal(t, PluginQuarantined, event.Payload.Type)
+		_, loaded := r.GetPlugin("panicky")
+		require.False(t, loaded)
+		require.NoError(t, r.TriggerSessionCreated(t.Context(), session.Session{}), "hooks of quarantined plugins must not run")
+
+		details := r.DescribePlugins()
+		require.Len(t, details, 1)
+		require.True(t, details[0].Quarantined)
+		require.False(t, details[0].Healthy)
+		require.Contains(t, details[0].QuarantineReason, "panicked 2 times")
+		require.NotNil(t, details[0].ReloadAt)
+		require.Equal(t, []string{"session"}, details[0].Hooks)
+
+		event = <-events
+		require.Equal(t, PluginLoaded, event.Payload.Type)
+		require.False(t, r.IsQuarantined("panicky"))
+		loadedPlugin, _ := r.GetPlugin("panicky")
+		require.Same(t, fresh, loadedPlugin)
+		require.Equal(t, int32(1), p.inits.Load(), "the quarantined instance isn't initialized again")
+		require.Equal(t, int32(1), p.shutdowns.Load())
+		require.Equal(t, int32(1), fresh.inits.Load())
+
+		// The panic count starts over after a reload
+		require.ErrorIs(t, r.TriggerSessionCreated(t.Context(), session.Session{}), ErrHookPanicked)
+		require.False(t, r.IsQuarantined("panicky"))
+	})
+
+	t.Run("keeps plugins whose reload fails quarantined", func(t *testing.T) {
+		t.Parallel()
+
+		r := NewRegistry()
+		r.SetQuarantinePolicy(QuarantinePolicy{Threshold: 1, Cooldown: time.Millisecond})
+		// The constructor returns the broken instance again
+		p := &unreloadablePlugin{panickyPlugin: panickyPlugin{name: "panicky"}}
+		source := pluginSource{path: "/plugins/panicky.so", open: func() (Plugin, error) { return p, nil }}
+		require.NoError(t, r.loadPlugin(t.Context(), p, PluginContext{}, RetryPolicy{}, source, 0))
+
+		require.ErrorIs(t, r.TriggerSessionCreated(t.Context(), session.Session{}), ErrHookPanicked)
+		require.Eventually(t, func() bool {
+			details := r.DescribePlugins()
+			return len(details) == 1 && strings.HasPrefix(details[0].QuarantineReason, "reload failed")
+		}, time.Second, time.Millisecond)
+
+		details := r.DescribePlugins()
+		require.True(t, details[0].Quarantined)
+		require.Empty(t, details[0].Hooks)
+		require.Nil(t, details[0].ReloadAt)
+		require.Equal(t, int32(2), p.inits.Load())
+	})
+
+	t.Run("keeps plugins without a constructor unloaded", func(t *testing.T) {
+		t.Parallel()
+
+		r := NewRegistry()
+		r.SetQuarantinePolicy(QuarantinePolicy{Threshold: 1, Cooldown: time.Millisecond})
+		p := &panickyPlugin{name: "panicky"}
+		require.NoError(t, r.loadPlugin(t.Context(), p, PluginContext{}, RetryPolicy{}, pluginSource{path: "/plugins/panicky.so"}, 0))
+
+		require.ErrorIs(t, r.TriggerSessionCreated(t.Context(), session.Session{}), ErrHookPanicked)
+		require.True(t, r.IsQuarantined("panicky"))
+		require.Nil(t, r.DescribePlugins()[0].ReloadAt)
+		require.Equal(t, int32(1), p.inits.Load())
+	})
+
+	t.Run("keeps in-process plugins unloaded", func(t *testing.T) {
+		t.Parallel()
+
+		r := NewRegistry()
+		r.SetQuarantinePolicy(QuarantinePolicy{Threshold: 1, Cooldown: time.Millisecond})
+		p := &panickyPlugin{name: "panicky"}
+		require.NoError(t, r.LoadPlugin(t.Context(), p, PluginContext{}))
+
+		require.ErrorIs(t, r.TriggerSessionCreated(t.Context(), session.Session{}), ErrHookPanicked)
+		require.True(t, r.IsQuarantined("panicky"))
+		require.Nil(t, r.DescribePlugins()[0].ReloadAt)
+
+		// Loading the plugin again lifts the quarantine
+		require.NoError(t, r.LoadPlugin(t.Context(), p, PluginContext{}))
+		require.False(t, r.IsQuarantined("panicky"))
+		require.Equal(t, int32(2), p.inits.Load())
+	})
+}
@@ -0,0 +1,160 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/google/uuid"
+)
+
+// MaxHookDepth limits how many times hooks may cause further hooks to run,
+// e.g. a message hook that creates a message through Services.Message. The
+// chain is broken with ErrHookRecursion once it nests deeper.
+const MaxHookDepth = 8
+
+// maxPendingOrigins bounds the origins waiting for their event. Events can be
+// dropped when a subscriber falls behind, so their origins would never be
+// taken; once the bound is reached the pending origins are discarded.
+const maxPendingOrigins = 1024
+
+type hookDepthContextKey string
+
+const hookDepthKey hookDepthContextKey = "plugin_hook_depth"
+
+// InHook reports whether ctx was passed to a hook, or derives from one
+func InHook(ctx context.Context) bool {
+	return hookDepth(ctx) > 0
+}
+
+func hookDepth(ctx context.Context) int {
+	depth, _ := ctx.Value(hookDepthKey).(int)
+	return depth
+}
+
+// enterHook returns ctx for running the named hooks. Their depth is one more
+// than that of the hook that caused the events, taken from ctx or, for events
+// delivered asynchronously, from the origins recorded by the guarded services.
+func (r *Registry) enterHook(ctx context.Context, name string, origins ...string) (context.Context, error) {
+	depth := hookDepth(ctx)
+	for _, origin := range origins {
+		if d, ok := r.origins.Take(origin); ok {
+			depth = max(depth, d)
+		}
+	}
+	depth++
+	if depth > MaxHookDepth {
+		slog.Error("Breaking hook recursion", "hook", name, "depth", depth)
+		return ctx, fmt.Errorf("%w: %s hooks nested %d levels deep", ErrHookRecursion, name, depth)
+	}
+	return context.WithValue(ctx, hookDepthKey, depth), nil
+}
+
+// markOrigin records that the next event for origin is caused by the hook
+// running with ctx, if any. Callers only mark origins whose events trigger
+// hooks, as the origins of other events are never taken.
+func (r *Registry) markOrigin(ctx context.Context, origin string) {
+	depth := hookDepth(ctx)
+	if depth == 0 {
+		return
+	}
+	if r.origins.Len() >= maxPendingOrigins {
+		slog.Warn("Discarding pending hook origins", "count", r.origins.Len())
+		r.origins.Reset(map[string]int{})
+	}
+	r.origins.Set(origin, depth)
+}
+
+// Origins of the events that service calls publish. Message events are
+// keyed by message, so that events of other runs in the same session aren't
+// charged a hook's depth.
+func messageOrigin(messageID string) string { return "message:" + messageID }
+func sessionOrigin(sessionID string) string { return "session:" + sessionID }
+
+func messageOrigins(msgs []message.Message) []string {
+	origins := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		origins = append(origins, messageOrigin(msg.ID))
+	}
+	return origins
+}
+
+// guardServices wraps the services whose events trigger hooks, so that calls
+// made from a hook pass its depth on to the hooks they trigger. Services
+// that are already wrapped, e.g. when a plugin is reloaded, are kept.
+func (r *Registry) guardServices(services Services) Services {
+	if _, guarded := services.Message.(*guardedMessageService); services.Message != nil && !guarded {
+		services.Message = &guardedMessageService{Service: services.Message, registry: r}
+	}
+	if _, guarded := services.Session.(*guardedSessionService); services.Session != nil && !guarded {
+		services.Session = &guardedSessionService{Service: services.Session, registry: r}
+	}
+	return services
+}
+
+type guardedMessageService struct {
+	message.Service
+	registry *Registry
+}
+
+// The ID of a new message is chosen before it is created, so that its
+// creation event can be told apart from others
+func (s *guardedMessageService) Create(ctx context.Context, sessionID string, params message.CreateMessageParams) (message.Message, error) {
+	if params.ID == "" {
+		params.ID = uuid.New().String()
+	}
+	if !s.registry.HasMessageHooks() {
+		return s.Service.Create(ctx, sessionID, params)
+	}
+	s.registry.markOrigin(ctx, messageOrigin(params.ID))
+	msg, err := s.Service.Create(ctx, sessionID, params)
+	if err != nil {
+		s.registry.origins.Del(messageOrigin(params.ID))
+	}
+	return msg, err
+}
+
+func (s *guardedMessageService) Update(ctx context.Context, msg message.Message) error {
+	if !s.registry.HasMessageHooks() {
+		return s.Service.Update(ctx, msg)
+	}
+	s.registry.markOrigin(ctx, messageOrigin(msg.ID))
+	err := s.Service.Update(ctx, msg)
+	if err != nil {
+		s.registry.origins.Del(messageOrigin(msg.ID))
+	}
+	return err
+}
+
+type guardedSessionService struct {
+	session.Service
+	registry *Registry
+}
+
+// The ID of a new session isn't known until it is created, so creations
+// share an origin
+func (s *guardedSessionService) Create(ctx context.Context, title string) (session.Session, error) {
+	if !s.registry.HasSessionHooks() {
+		return s.Service.Create(ctx, title)
+	}
+	s.registry.markOrigin(ctx, sessionOrigin(""))
+	sess, err := s.Service.Create(ctx, title)
+	if err != nil {
+		s.registry.origins.Del(sessionOrigin(""))
+	}
+	return sess, err
+}
+
+func (s *guardedSessionService) Save(ctx context.Context, sess session.Session) (session.Session, error) {
+	if !s.registry.HasSessionHooks() {
+		return s.Service.Save(ctx, sess)
+	}
+	s.registry.markOrigin(ctx, sessionOrigin(sess.ID))
+	saved, err := s.Service.Save(ctx, sess)
+	if err != nil {
+		s.registry.origins.Del(sessionOrigin(sess.ID))
+	}
+	return saved, err
+}
@@ -0,0 +1,162 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/stretchr/testify/require"
+)
+
+// loopingMessages forwards created messages to the registry's hooks the way
+// the app does, without the creating context
+type loopingMessages struct {
+	message.Service
+	registry *Registry
+	created  int
+	errs     []error
+}
+
+func (s *loopingMessages) Create(ctx context.Context, sessionID string, params message.CreateMessageParams) (message.Message, error) {
+	s.created++
+	msg := message.Message{ID: params.ID, SessionID: sessionID}
+	if err := s.registry.TriggerMessageCreated(context.Background(), msg); err != nil {
+		s.errs = append(s.errs, err)
+	}
+	return msg, nil
+}
+
+// echoPlugin creates a message whenever a message is created
+type echoPlugin struct {
+	flakyPlugin
+	NilMessageHook
+	messages message.Service
+	inHook   bool
+}
+
+func (p *echoPlugin) Init(ctx context.Context, pluginCtx PluginContext) error {
+	p.messages = pluginCtx.Services.Message
+	return nil
+}
+
+func (p *echoPlugin) Hooks() Hooks {
+	hooks := NewBaseHooks()
+	hooks.MessageHook = p
+	return hooks
+}
+
+func (p *echoPlugin) OnMessageCreated(ctx context.Context, msg message.Message) error {
+	p.inHook = InHook(ctx)
+	_, err := p.messages.Create(ctx, msg.SessionID, message.CreateMessageParams{})
+	return err
+}
+
+func TestHookRecursionIsBroken(t *testing.T) {
+	t.Parallel()
+
+	r := NewRegistry()
+	messages := &loopingMessages{registry: r}
+	p := &echoPlugin{flakyPlugin: flakyPlugin{name: "echo"}}
+	require.NoError(t, r.LoadPlugin(t.Context(), p, PluginContext{
+		Services: Services{Message: messages},
+	}))
+
+	require.NoError(t, r.TriggerMessageCreated(t.Context(), message.Message{SessionID: "session"}))
+	require.True(t, p.inHook)
+	require.Equal(t, MaxHookDepth, messages.created)
+	require.Len(t, messages.errs, 1)
+	require.ErrorIs(t, messages.errs[0], ErrHookRecursion)
+
+	// The chain's depth doesn't leak into unrelated events
+	messages.created = 0
+	messages.errs = nil
+	require.NoError(t, r.TriggerMessageCreated(t.Context(), message.Message{SessionID: "session"}))
+	require.Equal(t, MaxHookDepth, messages.created)
+}
+
+// pendingMessages creates messages without publishing them
+type pendingMessages struct {
+	message.Service
+}
+
+func (s *pendingMessages) Create(ctx context.Context, sessionID string, params message.CreateMessageParams) (message.Message, error) {
+	return message.Message{ID: params.ID, SessionID: sessionID}, nil
+}
+
+// depthPlugin records the hook depth each message created hook runs at
+type depthPlugin struct {
+	flakyPlugin
+	NilMessageHook
+	messages message.Service
+	depths   map[string]int
+}
+
+func (p *depthPlugin) Init(ctx context.Context, pluginCtx PluginContext) error {
+	p.messages = pluginCtx.Services.Message
+	return nil
+}
+
+func (p *depthPlugin) Hooks() Hooks {
+	hooks := NewBaseHooks()
+	hooks.MessageHook = p
+	return hooks
+}
+
+func (p *depthPlugin) OnMessageCreated(ctx context.Context, msg message.Message) error {
+	p.depths[msg.ID] = hookDepth(ctx)
+	return nil
+}
+
+func TestHookDepthIsPerMessage(t *testing.T) {
+	t.Parallel()
+
+	r := NewRegistry()
+	p := &depthPlugin{flakyPlugin: flakyPlugin{name: "depth"}, depths: map[string]int{}}
+	require.NoError(t, r.LoadPlugin(t.Context(), p, PluginContext{
+		Services: Services{Message: &pendingMessages{}},
+	}))
+
+	// A hook three levels deep creates a message while another run creates
+	// one in the same session, whose event is delivered first
+	hookCtx := context.WithValue(t.Context(), hookDepthKey, 3)
+	created, err := p.messages.Create(hookCtx, "session", message.CreateMessageParams{})
+	require.NoError(t, err)
+	require.NotEmpty(t, created.ID)
+
+	require.NoError(t, r.TriggerMessageCreated(t.Context(), message.Message{ID: "other", SessionID: "session"}))
+	require.NoError(t, r.TriggerMessageCreated(t.Context(), created))
+	require.Equal(t, map[string]int{"other": 1, created.ID: 4}, p.depths)
+}
+
+func TestHookOriginsArePending(t *testing.T) {
+	t.Parallel()
+
+	hookCtx := context.WithValue(t.Context(), hookDepthKey, 1)
+
+	t.Run("no message hooks", func(t *testing.T) {
+		t.Parallel()
+
+		// Nothing takes the origins of messages created without message hooks
+		r := NewRegistry()
+		require.NoError(t, r.LoadPlugin(t.Context(), &flakyPlugin{name: "quiet"}, PluginContext{}))
+		messages := r.guardServices(Services{Message: &pendingMessages{}}).Message
+		_, err := messages.Create(hookCtx, "session", message.CreateMessageParams{})
+		require.NoError(t, err)
+		require.Zero(t, r.origins.Len())
+	})
+
+	t.Run("dropped events", func(t *testing.T) {
+		t.Parallel()
+
+		r := NewRegistry()
+		p := &depthPlugin{flakyPlugin: flakyPlugin{name: "depth"}, depths: map[string]int{}}
+		require.NoError(t, r.LoadPlugin(t.Context(), p, PluginContext{
+			Services: Services{Message: &pendingMessages{}},
+		}))
+		for range maxPendingOrigins + 10 {
+			_, err := p.messages.Create(hookCtx, "session", message.CreateMessageParams{})
+			require.NoError(t, err)
+		}
+		require.LessOrEqual(t, r.origins.Len(), maxPendingOrigins)
+	})
+}
@@ -1,14 +1,26 @@
 package plugin
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"charm.land/fantasy"
 	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/db"
 	"github.com/charmbracelet/crush/internal/message"
 	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/pubsub"
 	"github.com/charmbracelet/crush/internal/session"
 )
 
@@ -16,101 +28,459 @@ import (
 // It provides methods to load plugins, register hooks, and trigger hook execution.
 type Registry struct {
 	plugins      *csync.Map[string, Plugin]
-	configHooks  []ConfigHook
-	sessionHooks []SessionHook
-	messageHooks []MessageHook
-	permHooks    []PermissionHook
-	toolHooks    []ToolHook
-	agentHooks   []AgentHook
-	mu           sync.RWMutex
+	health       *csync.Map[string, error]
+	contexts     *csync.Map[string, PluginContext]
+	loadTimes    *csync.Map[string, LoadTime]
+	origins      *csync.Map[string, int] // hook depths of pending events caused by hooks
+	sources      *csync.Map[string, pluginSource]
+	quarantined  *csync.Map[string, *quarantine]
+	panics       map[string]int // hook panics per loaded plugin, guarded by mu
+	panicPolicy  atomic.Pointer[QuarantinePolicy]
+	broker       *pubsub.Broker[PluginEvent]
+	progress     *pubsub.Broker[ToolProgress]
+	topics       *pubsub.Broker[TopicEvent]
+	topicScopes  *csync.Map[string, context.CancelFunc] // ends a plugin's topic subscriptions
+	configHooks  []hookEntry[ConfigHook]
+	sessionHooks []hookEntry[SessionHook]
+	messageHooks []hookEntry[MessageHook]
+	permHooks    []hookEntry[PermissionHook]
+	toolHooks    []hookEntry[ToolHook]
+	agentHooks   []hookEntry[AgentHook]
+	provHooks    []hookEntry[ProviderHook]
+	lspHooks     []hookEntry[LSPHook]
+	active       atomic.Pointer[hookSet]
+	storage      kvBackend
+	order        []string // plugins whose hooks run first, in order
+	permLimit    atomic.Pointer[fifoSemaphore]
+	toolRunner   ToolRunner // guarded by mu
+	mu           sync.Mutex
+}
+
+// hookEntry associates a hook with the plugin that registered it
+type hookEntry[T any] struct {
+	plugin string
+	hook   T
+}
+
+// hookSet is an immutable snapshot of the hooks of healthy plugins. It is
+// rebuilt when plugins are loaded or unloaded or change health, so triggers
+// read it without locking.
+type hookSet struct {
+	config     []hookEntry[ConfigHook]
+	session    []hookEntry[SessionHook]
+	message    []hookEntry[MessageHook]
+	permission []hookEntry[PermissionHook]
+	tool       []hookEntry[ToolHook]
+	agent      []hookEntry[AgentHook]
+	provider   []hookEntry[ProviderHook]
+	lsp        []hookEntry[LSPHook]
 }
 
 // NewRegistry creates a new plugin registry
 func NewRegistry() *Registry {
-	return &Registry{
+	r := &Registry{
 		plugins:      csync.NewMap[string, Plugin](),
-		configHooks:  make([]ConfigHook, 0),
-		sessionHooks: make([]SessionHook, 0),
-		messageHooks: make([]MessageHook, 0),
-		permHooks:    make([]PermissionHook, 0),
-		toolHooks:    make([]ToolHook, 0),
-		agentHooks:   make([]AgentHook, 0),
+		health:       csync.NewMap[string, error](),
+		contexts:     csync.NewMap[string, PluginContext](),
+		loadTimes:    csync.NewMap[string, LoadTime](),
+		origins:      csync.NewMap[string, int](),
+		sources:      csync.NewMap[string, pluginSource](),
+		quarantined:  csync.NewMap[string, *quarantine](),
+		panics:       make(map[string]int),
+		broker:       pubsub.NewBroker[PluginEvent](),
+		progress:     pubsub.NewBroker[ToolProgress](),
+		topics:       pubsub.NewBroker[TopicEvent](),
+		topicScopes:  csync.NewMap[string, context.CancelFunc](),
+		configHooks:  make([]hookEntry[ConfigHook], 0),
+		sessionHooks: make([]hookEntry[SessionHook], 0),
+		messageHooks: make([]hookEntry[MessageHook], 0),
+		permHooks:    make([]hookEntry[PermissionHook], 0),
+		toolHooks:    make([]hookEntry[ToolHook], 0),
+		agentHooks:   make([]hookEntry[AgentHook], 0),
+		provHooks:    make([]hookEntry[ProviderHook], 0),
+		lspHooks:     make([]hookEntry[LSPHook], 0),
 	}
+	r.active.Store(&hookSet{})
+	return r
+}
+
+// SetStorage sets the database backing the plugins' key-value stores.
+// Plugins loaded afterwards receive a PluginContext.Store scoped to their
+// name.
+func (r *Registry) SetStorage(q db.Querier) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.storage = q
+}
+
+// RetryPolicy controls how plugin initialization is retried when Init
+// returns a temporary error. Permanent errors are never retried.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of Init attempts (values below 1 mean 1)
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the first retry
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the delay between retries (zero means no cap)
+	MaxBackoff time.Duration
 }
 
 // LoadPlugin loads a plugin and registers its hooks.
 // The plugin is initialized with the provided context.
 func (r *Registry) LoadPlugin(ctx context.Context, plugin Plugin, pluginCtx PluginContext) error {
+	return r.LoadPluginWithRetry(ctx, plugin, pluginCtx, RetryPolicy{})
+}
+
+// LoadPluginWithRetry loads a plugin like LoadPlugin, retrying Init with
+// exponential backoff according to the policy when it fails transiently.
+func (r *Registry) LoadPluginWithRetry(ctx context.Context, plugin Plugin, pluginCtx PluginContext, policy RetryPolicy) error {
+	return r.loadPlugin(ctx, plugin, pluginCtx, policy, pluginSource{}, 0)
+}
+
+// pluginSource is the file a plugin was loaded from
+type pluginSource struct {
+	path string
+
+	// open creates a fresh instance of the plugin, or is nil if the plugin
+	// can't be created again
+	open func() (Plugin, error)
+}
+
+// loadPlugin loads a plugin that took openTime to open from source, if it
+// was loaded from a file
+func (r *Registry) loadPlugin(ctx context.Context, plugin Plugin, pluginCtx PluginContext, policy RetryPolicy, source pluginSource, openTime time.Duration) error {
 	info := plugin.Info()
 
 	// Check if plugin is already loaded
 	if _, exists := r.plugins.Get(info.Name); exists {
-		return fmt.Errorf("plugin %s is already loaded", info.Name)
+		return &PluginError{Name: info.Name, Err: ErrPluginAlreadyLoaded}
 	}
 
+	r.mu.Lock()
+	storage := r.storage
+	r.mu.Unlock()
+	if storage != nil {
+		pluginCtx.Store = newKVStore(storage, info.Name)
+	}
+	if cfg := pluginCtx.Config; cfg != nil && cfg.Options != nil && cfg.Options.Plugins != nil {
+		pluginCtx.Settings = cfg.Options.Plugins.Settings[info.Name]
+	}
+	pluginCtx.Services = r.guardServices(pluginCtx.Services)
+	pluginCtx.Topics = r.openTopics(info.Name)
+
 	// Initialize the plugin
-	if err := plugin.Init(ctx, pluginCtx); err != nil {
-		return fmt.Errorf("failed to initialize plugin %s: %w", info.Name, err)
+	start := time.Now()
+	if err := initWithRetry(ctx, plugin, pluginCtx, policy); err != nil {
+		r.closeTopics(info.Name)
+		return &PluginError{Name: info.Name, Err: fmt.Errorf("%w: %w", ErrInitFailed, err)}
+	}
+	initTime := time.Since(start)
+
+	// Plugins may choose their hooks in Init, so check them against the
+	// manifest again now that they are final
+	if err := verifySource(plugin, source.path); err != nil {
+		r.closeTopics(info.Name)
+		if shutdownErr := plugin.Shutdown(ctx); shutdownErr != nil {
+			slog.Warn("Failed to shut down rejected plugin", "plugin", info.Name, "error", shutdownErr)
+		}
+		return &PluginError{Name: info.Name, Err: err}
 	}
 
 	// Register the plugin
 	r.plugins.Set(info.Name, plugin)
+	r.contexts.Set(info.Name, pluginCtx)
+	r.loadTimes.Set(info.Name, LoadTime{Open: openTime, Init: initTime})
+	if source.path != "" {
+		r.sources.Set(info.Name, source)
+	}
+	r.liftQuarantine(info.Name)
 
-	// Register all hooks
+	// Register all hooks, as the plugin provides them after Init
 	hooks := plugin.Hooks()
-	r.registerHooks(hooks)
+	r.registerHooks(info.Name, hooks)
 
+	r.publish(PluginLoaded, info)
 	return nil
 }
 
+// verifySource checks an initialized plugin against the manifest next to
+// the file it was loaded from, if there is one
+func verifySource(plugin Plugin, source string) error {
+	if source == "" {
+		return nil
+	}
+	manifest, err := loadManifest(source)
+	if err != nil || manifest == nil {
+		return err
+	}
+	return manifest.verifyCapabilities(plugin)
+}
+
+// initWithRetry calls plugin.Init, retrying temporary failures
+func initWithRetry(ctx context.Context, plugin Plugin, pluginCtx PluginContext, policy RetryPolicy) error {
+	backoff := policy.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := plugin.Init(ctx, pluginCtx)
+		if err == nil {
+			return nil
+		}
+		if attempt >= policy.MaxAttempts || !isTemporary(err) {
+			return err
+		}
+
+		slog.Warn("Plugin init failed, retrying",
+			"plugin", plugin.Info().Name,
+			"attempt", attempt,
+			"backoff", backoff,
+			"error", err)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return fmt.Errorf("%w (last error: %v)", ctx.Err(), err)
+		}
+
+		backoff *= 2
+		if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+	}
+}
+
 // registerHooks registers all hooks from a plugin
-func (r *Registry) registerHooks(hooks Hooks) {
+func (r *Registry) registerHooks(name string, hooks Hooks) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if configHook := hooks.Config(); configHook != nil {
-		r.configHooks = append(r.configHooks, configHook)
+	// No-op hooks are not registered, so triggers and event forwarding can
+	// skip hook types no plugin implements
+	if configHook := hooks.Config(); configHook != nil && configHook != ConfigHook(NilConfigHook{}) {
+		r.configHooks = append(r.configHooks, hookEntry[ConfigHook]{name, configHook})
+	}
+
+	if sessionHook := hooks.Session(); sessionHook != nil && sessionHook != SessionHook(NilSessionHook{}) {
+		r.sessionHooks = append(r.sessionHooks, hookEntry[SessionHook]{name, sessionHook})
 	}
 
-	if sessionHook := hooks.Session(); sessionHook != nil {
-		r.sessionHooks = append(r.sessionHooks, sessionHook)
+	if messageHook := hooks.Message(); messageHook != nil && messageHook != MessageHook(NilMessageHook{}) {
+		r.messageHooks = append(r.messageHooks, hookEntry[MessageHook]{name, messageHook})
 	}
 
-	if messageHook := hooks.Message(); messageHook != nil {
-		r.messageHooks = append(r.messageHooks, messageHook)
+	if permHook := hooks.Permission(); permHook != nil && permHook != PermissionHook(NilPermissionHook{}) {
+		r.permHooks = append(r.permHooks, hookEntry[PermissionHook]{name, permHook})
 	}
 
-	if permHook := hooks.Permission(); permHook != nil {
-		r.permHooks = append(r.permHooks, permHook)
+	if toolHook := hooks.Tool(); toolHook != nil && toolHook != ToolHook(NilToolHook{}) {
+		r.toolHooks = append(r.toolHooks, hookEntry[ToolHook]{name, toolHook})
 	}
 
-	if toolHook := hooks.Tool(); toolHook != nil {
-		r.toolHooks = append(r.toolHooks, toolHook)
+	if agentHook := hooks.Agent(); agentHook != nil && agentHook != AgentHook(NilAgentHook{}) {
+		r.agentHooks = append(r.agentHooks, hookEntry[AgentHook]{name, agentHook})
 	}
 
-	if agentHook := hooks.Agent(); agentHook != nil {
-		r.agentHooks = append(r.agentHooks, agentHook)
+	if provHook := providerHook(hooks); provHook != nil && provHook != ProviderHook(NilProviderHook{}) {
+		r.provHooks = append(r.provHooks, hookEntry[ProviderHook]{name, provHook})
+	}
+
+	if serverHook := lspHook(hooks); serverHook != nil && serverHook != LSPHook(NilLSPHook{}) {
+		r.lspHooks = append(r.lspHooks, hookEntry[LSPHook]{name, serverHook})
+	}
+
+	r.sortHooks()
+	r.rebuildHooks()
+}
+
+// SetPluginOrder sets the order in which plugins' hooks run. Hooks of the
+// named plugins run first, in the given order, followed by those of other
+// plugins in load order. It applies to plugins loaded before and after.
+func (r *Registry) SetPluginOrder(names []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.order = slices.Clone(names)
+	r.sortHooks()
+	r.rebuildHooks()
+}
+
+// sortHooks orders hook entries by the configured plugin order. The caller
+// must hold r.mu.
+func (r *Registry) sortHooks() {
+	sortHooks(r.configHooks, r.order)
+	sortHooks(r.sessionHooks, r.order)
+	sortHooks(r.messageHooks, r.order)
+	sortHooks(r.permHooks, r.order)
+	sortHooks(r.toolHooks, r.order)
+	sortHooks(r.agentHooks, r.order)
+	sortHooks(r.provHooks, r.order)
+	sortHooks(r.lspHooks, r.order)
+}
+
+func sortHooks[T any](entries []hookEntry[T], order []string) {
+	if len(order) == 0 {
+		return
 	}
+	rank := func(name string) int {
+		if i := slices.Index(order, name); i >= 0 {
+			return i
+		}
+		return len(order)
+	}
+	slices.SortStableFunc(entries, func(a, b hookEntry[T]) int {
+		return cmp.Compare(rank(a.plugin), rank(b.plugin))
+	})
+}
+
+// unregisterHooks removes all hooks registered by the named plugin
+func (r *Registry) unregisterHooks(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.configHooks = removeHooks(r.configHooks, name)
+	r.sessionHooks = removeHooks(r.sessionHooks, name)
+	r.messageHooks = removeHooks(r.messageHooks, name)
+	r.permHooks = removeHooks(r.permHooks, name)
+	r.toolHooks = removeHooks(r.toolHooks, name)
+	r.agentHooks = removeHooks(r.agentHooks, name)
+	r.provHooks = removeHooks(r.provHooks, name)
+	r.lspHooks = removeHooks(r.lspHooks, name)
+
+	r.rebuildHooks()
+}
+
+// refreshHooks rebuilds the hook snapshot, e.g. after a health change
+func (r *Registry) refreshHooks() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.rebuildHooks()
+}
+
+// rebuildHooks publishes a new snapshot of the hooks of healthy plugins. The
+// caller must hold r.mu.
+func (r *Registry) rebuildHooks() {
+	r.active.Store(&hookSet{
+		config:     healthyEntries(r, r.configHooks),
+		session:    healthyEntries(r, r.sessionHooks),
+		message:    healthyEntries(r, r.messageHooks),
+		permission: healthyEntries(r, r.permHooks),
+		tool:       healthyEntries(r, r.toolHooks),
+		agent:      healthyEntries(r, r.agentHooks),
+		provider:   healthyEntries(r, r.provHooks),
+		lsp:        healthyEntries(r, r.lspHooks),
+	})
+}
+
+// HasSessionHooks reports whether any loaded plugin has a session hook
+func (r *Registry) HasSessionHooks() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.sessionHooks) > 0
+}
+
+// HasMessageHooks reports whether any loaded plugin has a message hook
+func (r *Registry) HasMessageHooks() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.messageHooks) > 0
+}
+
+// HasLSPHooks reports whether any loaded plugin has an LSP hook
+func (r *Registry) HasLSPHooks() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.lspHooks) > 0
+}
+
+// HasToolHooks reports whether any healthy plugin has a tool hook. It
+// doesn't lock, so it can be checked on every tool call.
+func (r *Registry) HasToolHooks() bool {
+	return len(r.hooks().tool) > 0
+}
+
+// HasProviderHooks reports whether any healthy plugin has a provider hook.
+// It doesn't lock, so it can be checked on every provider call.
+func (r *Registry) HasProviderHooks() bool {
+	return len(r.hooks().provider) > 0
+}
+
+// HookCount returns the number of registered hooks of the given type,
+// including those of unhealthy plugins
+func (r *Registry) HookCount(hookType HookType) int {
+	return len(r.PluginsImplementing(hookType))
+}
+
+// PluginsImplementing returns the names of the plugins that registered a
+// hook of the given type, in the order the hooks run. Unhealthy plugins are
+// included since their hooks resume once they recover.
+func (r *Registry) PluginsImplementing(hookType HookType) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch hookType {
+	case HookConfig:
+		return entryPlugins(r.configHooks)
+	case HookSession:
+		return entryPlugins(r.sessionHooks)
+	case HookMessage:
+		return entryPlugins(r.messageHooks)
+	case HookPermission:
+		return entryPlugins(r.permHooks)
+	case HookTool:
+		return entryPlugins(r.toolHooks)
+	case HookAgent:
+		return entryPlugins(r.agentHooks)
+	case HookProvider:
+		return entryPlugins(r.provHooks)
+	case HookLSP:
+		return entryPlugins(r.lspHooks)
+	}
+	return []string{}
+}
+
+func entryPlugins[T any](entries []hookEntry[T]) []string {
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.plugin)
+	}
+	return names
+}
+
+// hooks returns the current hook snapshot. It must not be modified.
+func (r *Registry) hooks() *hookSet {
+	return r.active.Load()
+}
+
+func removeHooks[T any](entries []hookEntry[T], name string) []hookEntry[T] {
+	return slices.DeleteFunc(entries, func(entry hookEntry[T]) bool {
+		return entry.plugin == name
+	})
 }
 
 // UnloadPlugin unloads a plugin by name
 func (r *Registry) UnloadPlugin(ctx context.Context, name string) error {
 	plugin, exists := r.plugins.Get(name)
 	if !exists {
-		return fmt.Errorf("plugin %s is not loaded", name)
+		return &PluginError{Name: name, Err: ErrPluginNotLoaded}
 	}
 
 	// Shutdown the plugin
 	if err := plugin.Shutdown(ctx); err != nil {
-		return fmt.Errorf("failed to shutdown plugin %s: %w", name, err)
+		return &PluginError{Name: name, Err: fmt.Errorf("%w: %w", ErrShutdownFailed, err)}
 	}
 
 	// Remove from registry
 	r.plugins.Del(name)
-
-	// Note: We don't remove hooks here because it would require rebuilding
-	// the hook arrays. In practice, plugins are loaded once at startup.
-	// For dynamic plugin loading/unloading, we'd need a more sophisticated approach.
+	r.health.Del(name)
+	r.contexts.Del(name)
+	r.loadTimes.Del(name)
+	r.sources.Del(name)
+	r.mu.Lock()
+	delete(r.panics, name)
+	r.mu.Unlock()
+	r.unregisterHooks(name)
+	r.closeTopics(name)
+	r.publish(PluginUnloaded, plugin.Info())
 
 	return nil
 }
@@ -129,35 +499,260 @@ func (r *Registry) ListPlugins() []PluginInfo {
 	return infos
 }
 
-// Shutdown shuts down all loaded plugins
+// LoadTime records how long loading a plugin took
+type LoadTime struct {
+	// Open is the time spent opening the plugin file. It is zero for
+	// plugins registered in-process.
+	Open time.Duration
+
+	// Init is the time spent in Init, including retries
+	Init time.Duration
+}
+
+// Total returns the time spent opening and initializing the plugin
+func (t LoadTime) Total() time.Duration {
+	return t.Open + t.Init
+}
+
+// LoadTimes returns how long each loaded plugin took to load, by name
+func (r *Registry) LoadTimes() map[string]LoadTime {
+	return maps.Collect(r.loadTimes.Seq2())
+}
+
+// PluginDetails describes a loaded plugin for introspection purposes.
+type PluginDetails struct {
+	// Info is the plugin metadata
+	Info PluginInfo `json:"info"`
+
+	// Hooks lists the hook types the plugin implements
+	Hooks []string `json:"hooks"`
+
+	// Tools lists the names of the tools the plugin contributes
+	Tools []string `json:"tools"`
+
+	// ToolAnnotations are the annotations of the plugin's tools that have
+	// any, by tool name
+	ToolAnnotations map[string]map[string]string `json:"tool_annotations,omitempty"`
+
+	// Commands lists the names of the slash commands the plugin contributes
+	Commands []string `json:"commands"`
+
+	// Healthy reports whether the plugin is currently considered healthy
+	Healthy bool `json:"healthy"`
+
+	// HealthError is the error from the last failed health check, if any
+	HealthError string `json:"health_error,omitempty"`
+
+	// OpenTimeMS and InitTimeMS are how long opening the plugin file and
+	// initializing the plugin took, in milliseconds
+	OpenTimeMS float64 `json:"open_time_ms"`
+	InitTimeMS float64 `json:"init_time_ms"`
+
+	// Panics is the number of times the plugin's hooks panicked since it
+	// was loaded
+	Panics int `json:"panics,omitempty"`
+
+	// Quarantined reports whether the plugin was unloaded because its hooks
+	// kept panicking. QuarantineReason says why, and ReloadAt is when it is
+	// initialized again, if it is.
+	Quarantined      bool       `json:"quarantined,omitempty"`
+	QuarantineReason string     `json:"quarantine_reason,omitempty"`
+	ReloadAt         *time.Time `json:"reload_at,omitempty"`
+}
+
+// DescribePlugins returns details about every loaded or quarantined plugin,
+// sorted by name.
+func (r *Registry) DescribePlugins() []PluginDetails {
+	r.mu.Lock()
+	panics := maps.Clone(r.panics)
+	r.mu.Unlock()
+
+	details := []PluginDetails{}
+	for _, plugin := range r.plugins.Seq2() {
+		d := PluginDetails{
+			Info:     plugin.Info(),
+			Hooks:    implementedHooks(plugin.Hooks()),
+			Tools:    []string{},
+			Commands: []string{},
+			Healthy:  true,
+		}
+		if err, ok := r.health.Get(d.Info.Name); ok && err != nil {
+			d.Healthy = false
+			d.HealthError = err.Error()
+		}
+		if t, ok := r.loadTimes.Get(d.Info.Name); ok {
+			d.OpenTimeMS = milliseconds(t.Open)
+			d.InitTimeMS = milliseconds(t.Init)
+		}
+		d.Panics = panics[d.Info.Name]
+		if toolProvider, ok := plugin.(ToolProvider); ok {
+			for _, tool := range toolProvider.GetTools() {
+				name := tool.Info().Name
+				d.Tools = append(d.Tools, name)
+				if annotations := ToolAnnotations(tool); len(annotations) > 0 {
+					if d.ToolAnnotations == nil {
+						d.ToolAnnotations = map[string]map[string]string{}
+					}
+					d.ToolAnnotations[name] = annotations
+				}
+			}
+		}
+		if commandProvider, ok := plugin.(CommandProvider); ok {
+			for _, command := range commandProvider.GetCommands() {
+				d.Commands = append(d.Commands, command.Name)
+			}
+		}
+		details = append(details, d)
+	}
+	for _, q := range r.quarantined.Seq2() {
+		d := PluginDetails{
+			Info:             q.plugin.Info(),
+			Hooks:            q.hooks,
+			Tools:            []string{},
+			Commands:         []string{},
+			Quarantined:      true,
+			QuarantineReason: q.reason,
+		}
+		if !q.reloadAt.IsZero() {
+			d.ReloadAt = &q.reloadAt
+		}
+		details = append(details, d)
+	}
+	slices.SortFunc(details, func(a, b PluginDetails) int {
+		return strings.Compare(a.Info.Name, b.Info.Name)
+	})
+	return details
+}
+
+// milliseconds returns d in milliseconds with microsecond precision
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// implementedHooks returns the names of the hooks that are set to something
+// other than the no-op implementations.
+func implementedHooks(hooks Hooks) []string {
+	names := []string{}
+	if hooks == nil {
+		return names
+	}
+	if h := hooks.Config(); h != nil && h != ConfigHook(NilConfigHook{}) {
+		names = append(names, string(HookConfig))
+	}
+	if h := hooks.Session(); h != nil && h != SessionHook(NilSessionHook{}) {
+		names = append(names, string(HookSession))
+	}
+	if h := hooks.Message(); h != nil && h != MessageHook(NilMessageHook{}) {
+		names = append(names, string(HookMessage))
+	}
+	if h := hooks.Permission(); h != nil && h != PermissionHook(NilPermissionHook{}) {
+		names = append(names, string(HookPermission))
+	}
+	if h := hooks.Tool(); h != nil && h != ToolHook(NilToolHook{}) {
+		names = append(names, string(HookTool))
+	}
+	if h := hooks.Agent(); h != nil && h != AgentHook(NilAgentHook{}) {
+		names = append(names, string(HookAgent))
+	}
+	if h := providerHook(hooks); h != nil && h != ProviderHook(NilProviderHook{}) {
+		names = append(names, string(HookProvider))
+	}
+	if h := lspHook(hooks); h != nil && h != LSPHook(NilLSPHook{}) {
+		names = append(names, string(HookLSP))
+	}
+	return names
+}
+
+// Shutdown shuts down all loaded plugins concurrently. Plugins that haven't
+// finished by the time ctx is done are abandoned, logged, and reported in the
+// returned error.
 func (r *Registry) Shutdown(ctx context.Context) error {
-	var errors []error
+	for _, q := range r.quarantined.Seq2() {
+		if q.timer != nil {
+			q.timer.Stop()
+		}
+	}
+
+	type result struct {
+		name string
+		err  error
+	}
+	results := make(chan result, r.plugins.Len())
+	pending := make(map[string]struct{})
 	for name, plugin := range r.plugins.Seq2() {
-		if err := plugin.Shutdown(ctx); err != nil {
-			errors = append(errors, fmt.Errorf("plugin %s: %w", name, err))
+		pending[name] = struct{}{}
+		go func() {
+			results <- result{name: name, err: plugin.Shutdown(ctx)}
+		}()
+	}
+
+	var errs []error
+	for len(pending) > 0 {
+		select {
+		case res := <-results:
+			delete(pending, res.name)
+			if res.err != nil {
+				errs = append(errs, &PluginError{Name: res.name, Err: fmt.Errorf("%w: %w", ErrShutdownFailed, res.err)})
+			}
+		case <-ctx.Done():
+			for _, name := range slices.Sorted(maps.Keys(pending)) {
+				slog.Warn("Plugin did not shut down in time", "plugin", name)
+				errs = append(errs, &PluginError{Name: name, Err: fmt.Errorf("%w: %w", ErrShutdownFailed, ctx.Err())})
+			}
+			clear(pending)
 		}
 	}
+	for name := range r.topicScopes.Seq2() {
+		r.closeTopics(name)
+	}
+	r.topics.Shutdown()
 
-	if len(errors) > 0 {
-		return fmt.Errorf("failed to shutdown %d plugin(s): %v", len(errors), errors)
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to shutdown %d plugin(s): %w", len(errs), errors.Join(errs...))
 	}
 
 	return nil
 }
 
+// healthyEntries returns the hooks in entries whose plugin is currently
+// healthy
+func healthyEntries[T any](r *Registry, entries []hookEntry[T]) []hookEntry[T] {
+	return slices.DeleteFunc(slices.Clone(entries), func(entry hookEntry[T]) bool {
+		return !r.IsHealthy(entry.plugin)
+	})
+}
+
 // Hook Trigger Methods
 // These methods trigger all registered hooks of a specific type in sequence.
 
-// TriggerConfigHooks triggers all config hooks
+// TriggerConfigHooks triggers all config hooks. Failures are reported as
+// PluginErrors naming the plugin. A hook error wraps ErrConfigHookFailed; it
+// is returned if the configuration enables strict config hooks and is
+// otherwise logged, and the remaining hooks run. A hook that leaves a valid
+// configuration invalid always fails with ErrInvalidConfig.
 func (r *Registry) TriggerConfigHooks(ctx context.Context, cfg *config.Config) error {
-	r.mu.RLock()
-	hooks := make([]ConfigHook, len(r.configHooks))
-	copy(hooks, r.configHooks)
-	r.mu.RUnlock()
+	hooks := r.hooks().config
+	if len(hooks) == 0 {
+		return nil
+	}
 
-	for _, hook := range hooks {
-		if err := hook.OnConfigLoad(ctx, cfg); err != nil {
-			return fmt.Errorf("config hook failed: %w", err)
+	strict := cfg.Options != nil && cfg.Options.Plugins != nil && cfg.Options.Plugins.StrictConfigHooks
+	valid := cfg.Validate() == nil
+	for _, entry := range hooks {
+		if err := r.guard(entry.plugin, func() error {
+			return entry.hook.OnConfigLoad(ctx, cfg)
+		}); err != nil {
+			err = &PluginError{Name: entry.plugin, Err: fmt.Errorf("%w: %w", ErrConfigHookFailed, err)}
+			if strict {
+				return err
+			}
+			slog.Warn("Ignoring failed plugin config hook", "plugin", entry.plugin, "error", err)
+		}
+		if !valid {
+			continue
+		}
+		if err := cfg.Validate(); err != nil {
+			return &PluginError{Name: entry.plugin, Err: fmt.Errorf("%w: %w", ErrInvalidConfig, err)}
 		}
 	}
 	return nil
@@ -165,13 +760,16 @@ func (r *Registry) TriggerConfigHooks(ctx context.Context, cfg *config.Config) e
 
 // TriggerSessionCreated triggers all session created hooks
 func (r *Registry) TriggerSessionCreated(ctx context.Context, sess session.Session) error {
-	r.mu.RLock()
-	hooks := make([]SessionHook, len(r.sessionHooks))
-	copy(hooks, r.sessionHooks)
-	r.mu.RUnlock()
+	ctx, err := r.enterHook(ctx, "session created", sessionOrigin(""))
+	if err != nil {
+		return err
+	}
+	hooks := r.hooks().session
 
-	for _, hook := range hooks {
-		if err := hook.OnSessionCreated(ctx, sess); err != nil {
+	for _, entry := range hooks {
+		if err := r.guard(entry.plugin, func() error {
+			return entry.hook.OnSessionCreated(ctx, sess)
+		}); err != nil {
 			return fmt.Errorf("session created hook failed: %w", err)
 		}
 	}
@@ -180,13 +778,16 @@ func (r *Registry) TriggerSessionCreated(ctx context.Context, sess session.Sessi
 
 // TriggerSessionUpdated triggers all session updated hooks
 func (r *Registry) TriggerSessionUpdated(ctx context.Context, sess session.Session) error {
-	r.mu.RLock()
-	hooks := make([]SessionHook, len(r.sessionHooks))
-	copy(hooks, r.sessionHooks)
-	r.mu.RUnlock()
+	ctx, err := r.enterHook(ctx, "session updated", sessionOrigin(sess.ID))
+	if err != nil {
+		return err
+	}
+	hooks := r.hooks().session
 
-	for _, hook := range hooks {
-		if err := hook.OnSessionUpdated(ctx, sess); err != nil {
+	for _, entry := range hooks {
+		if err := r.guard(entry.plugin, func() error {
+			return entry.hook.OnSessionUpdated(ctx, sess)
+		}); err != nil {
 			return fmt.Errorf("session updated hook failed: %w", err)
 		}
 	}
@@ -195,28 +796,63 @@ func (r *Registry) TriggerSessionUpdated(ctx context.Context, sess session.Sessi
 
 // TriggerSessionDeleted triggers all session deleted hooks
 func (r *Registry) TriggerSessionDeleted(ctx context.Context, sessionID string) error {
-	r.mu.RLock()
-	hooks := make([]SessionHook, len(r.sessionHooks))
-	copy(hooks, r.sessionHooks)
-	r.mu.RUnlock()
+	hooks := r.hooks().session
 
-	for _, hook := range hooks {
-		if err := hook.OnSessionDeleted(ctx, sessionID); err != nil {
+	for _, entry := range hooks {
+		if err := r.guard(entry.plugin, func() error {
+			return entry.hook.OnSessionDeleted(ctx, sessionID)
+		}); err != nil {
 			return fmt.Errorf("session deleted hook failed: %w", err)
 		}
 	}
 	return nil
 }
 
+// TriggerSessionCompacted triggers all session compacted hooks
+func (r *Registry) TriggerSessionCompacted(ctx context.Context, sessionID string, summary message.Message, droppedMessageIDs []string) error {
+	hooks := r.hooks().session
+
+	for _, entry := range hooks {
+		if err := r.guard(entry.plugin, func() error {
+			return entry.hook.OnSessionCompacted(ctx, sessionID, summary, droppedMessageIDs)
+		}); err != nil {
+			return fmt.Errorf("session compacted hook failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// TriggerContextTruncated notifies session hooks implementing
+// ContextTruncatedHook that messages are no longer sent to the model
+func (r *Registry) TriggerContextTruncated(ctx context.Context, sessionID string, droppedTokens int, droppedMessageIDs []string) error {
+	hooks := r.hooks().session
+
+	for _, entry := range hooks {
+		truncatedHook, ok := entry.hook.(ContextTruncatedHook)
+		if !ok {
+			continue
+		}
+		if err := r.guard(entry.plugin, func() error {
+			return truncatedHook.OnContextTruncated(ctx, sessionID, droppedTokens, droppedMessageIDs)
+		}); err != nil {
+			return fmt.Errorf("context truncated hook failed: %w", err)
+		}
+	}
+	return nil
+}
+
 // TriggerMessageCreated triggers all message created hooks
 func (r *Registry) TriggerMessageCreated(ctx context.Context, msg message.Message) error {
-	r.mu.RLock()
-	hooks := make([]MessageHook, len(r.messageHooks))
-	copy(hooks, r.messageHooks)
-	r.mu.RUnlock()
+	ctx, err := r.enterHook(ctx, "message created", messageOrigin(msg.ID))
+	if err != nil {
+		return err
+	}
+	hooks := r.hooks().message
 
-	for _, hook := range hooks {
-		if err := hook.OnMessageCreated(ctx, msg); err != nil {
+	for _, entry := range hooks {
+		if err := r.guard(entry.plugin, func() error {
+			return entry.hook.OnMessageCreated(ctx, msg)
+		}); err != nil {
 			return fmt.Errorf("message created hook failed: %w", err)
 		}
 	}
@@ -225,13 +861,71 @@ func (r *Registry) TriggerMessageCreated(ctx context.Context, msg message.Messag
 
 // TriggerMessageUpdated triggers all message updated hooks
 func (r *Registry) TriggerMessageUpdated(ctx context.Context, msg message.Message) error {
-	r.mu.RLock()
-	hooks := make([]MessageHook, len(r.messageHooks))
-	copy(hooks, r.messageHooks)
-	r.mu.RUnlock()
+	ctx, err := r.enterHook(ctx, "message updated", messageOrigin(msg.ID))
+	if err != nil {
+		return err
+	}
+	hooks := r.hooks().message
 
-	for _, hook := range hooks {
-		if err := hook.OnMessageUpdated(ctx, msg); err != nil {
+	for _, entry := range hooks {
+		if err := r.guard(entry.plugin, func() error {
+			return entry.hook.OnMessageUpdated(ctx, msg)
+		}); err != nil {
+			return fmt.Errorf("message updated hook failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// TriggerMessagesCreated triggers the message created hooks for a batch of
+// messages. Hooks implementing BatchMessageHook receive the whole batch;
+// others are called once per message.
+func (r *Registry) TriggerMessagesCreated(ctx context.Context, msgs []message.Message) error {
+	ctx, err := r.enterHook(ctx, "message created", messageOrigins(msgs)...)
+	if err != nil {
+		return err
+	}
+	hooks := r.hooks().message
+
+	for _, entry := range hooks {
+		if err := r.guard(entry.plugin, func() error {
+			if batchHook, ok := entry.hook.(BatchMessageHook); ok {
+				return batchHook.OnMessagesCreated(ctx, msgs)
+			}
+			for _, msg := range msgs {
+				if err := entry.hook.OnMessageCreated(ctx, msg); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			return fmt.Errorf("message created hook failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// TriggerMessagesUpdated triggers the message updated hooks for a batch of
+// messages, like TriggerMessagesCreated
+func (r *Registry) TriggerMessagesUpdated(ctx context.Context, msgs []message.Message) error {
+	ctx, err := r.enterHook(ctx, "message updated", messageOrigins(msgs)...)
+	if err != nil {
+		return err
+	}
+	hooks := r.hooks().message
+
+	for _, entry := range hooks {
+		if err := r.guard(entry.plugin, func() error {
+			if batchHook, ok := entry.hook.(BatchMessageHook); ok {
+				return batchHook.OnMessagesUpdated(ctx, msgs)
+			}
+			for _, msg := range msgs {
+				if err := entry.hook.OnMessageUpdated(ctx, msg); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
 			return fmt.Errorf("message updated hook failed: %w", err)
 		}
 	}
@@ -240,79 +934,160 @@ func (r *Registry) TriggerMessageUpdated(ctx context.Context, msg message.Messag
 
 // TriggerPermissionRequest triggers all permission request hooks.
 // Returns the first non-nil decision, or nil if all hooks return nil.
+// If SetMaxPermissionRequests was called, the request may first wait for
+// earlier requests to finish.
 func (r *Registry) TriggerPermissionRequest(ctx context.Context, req permission.CreatePermissionRequest) (*bool, error) {
-	r.mu.RLock()
-	hooks := make([]PermissionHook, len(r.permHooks))
-	copy(hooks, r.permHooks)
-	r.mu.RUnlock()
+	decision, _, err := r.decidePermission(ctx, req)
+	return decision, err
+}
+
+// decidePermission runs the permission hooks like TriggerPermissionRequest,
+// also returning the name of the plugin whose hook decided or failed
+func (r *Registry) decidePermission(ctx context.Context, req permission.CreatePermissionRequest) (*bool, string, error) {
+	hooks := r.hooks().permission
+	if len(hooks) == 0 {
+		return nil, "", nil
+	}
+
+	if limit := r.permLimit.Load(); limit != nil {
+		if err := limit.acquire(ctx); err != nil {
+			return nil, "", fmt.Errorf("waiting for permission hooks: %w", err)
+		}
+		defer limit.release()
+	}
 
-	for _, hook := range hooks {
-		decision, err := hook.OnPermissionRequest(ctx, req)
-		if err != nil {
-			return nil, fmt.Errorf("permission hook failed: %w", err)
+	for _, entry := range hooks {
+		var decision *bool
+		if err := r.guard(entry.plugin, func() (err error) {
+			decision, err = entry.hook.OnPermissionRequest(ctx, req)
+			return err
+		}); err != nil {
+			return nil, entry.plugin, fmt.Errorf("permission hook failed: %w", err)
 		}
 		// Return the first non-nil decision
 		if decision != nil {
-			return decision, nil
+			return decision, entry.plugin, nil
+		}
+	}
+	return nil, "", nil
+}
+
+// TriggerPermissionDenied notifies permission hooks implementing
+// PermissionDeniedHook that a request was denied
+func (r *Registry) TriggerPermissionDenied(ctx context.Context, req permission.CreatePermissionRequest, deniedBy string) error {
+	hooks := r.hooks().permission
+
+	for _, entry := range hooks {
+		deniedHook, ok := entry.hook.(PermissionDeniedHook)
+		if !ok {
+			continue
+		}
+		if err := r.guard(entry.plugin, func() error {
+			return deniedHook.OnPermissionDenied(ctx, req, deniedBy)
+		}); err != nil {
+			return fmt.Errorf("permission denied hook failed: %w", err)
 		}
 	}
-	return nil, nil
+	return nil
 }
 
 // TriggerToolExecuteBefore triggers all tool execute before hooks.
 // Each hook can modify the arguments, and the modifications are passed to the next hook.
-func (r *Registry) TriggerToolExecuteBefore(ctx context.Context, input ToolExecuteInput) (map[string]any, error) {
-	r.mu.RLock()
-	hooks := make([]ToolHook, len(r.toolHooks))
-	copy(hooks, r.toolHooks)
-	r.mu.RUnlock()
-
-	args := input.Arguments
-	for _, hook := range hooks {
-		modifiedArgs, err := hook.OnToolExecuteBefore(ctx, input)
-		if err != nil {
-			return nil, fmt.Errorf("tool execute before hook failed: %w", err)
+// Hooks implementing RawToolHook may instead replace the raw input, which
+// takes precedence over a modified map returned by the same hook. The
+// returned input has Arguments and RawArguments in sync.
+func (r *Registry) TriggerToolExecuteBefore(ctx context.Context, input ToolExecuteInput) (ToolExecuteInput, error) {
+	hooks := r.hooks().tool
+
+	for _, entry := range hooks {
+		var modifiedArgs map[string]any
+		var modifiedRaw json.RawMessage
+		if err := r.guard(entry.plugin, func() (err error) {
+			if modifiedArgs, err = entry.hook.OnToolExecuteBefore(ctx, input); err != nil {
+				return err
+			}
+			if rawHook, ok := entry.hook.(RawToolHook); ok {
+				modifiedRaw, err = rawHook.OnToolExecuteBeforeRaw(ctx, input)
+			}
+			return err
+		}); err != nil {
+			return input, fmt.Errorf("tool execute before hook failed: %w", err)
 		}
-		// Apply modifications if returned
-		if modifiedArgs != nil {
-			args = modifiedArgs
-			// Update input for next hook
+		// Apply modifications if returned, updating input for the next hook
+		switch {
+		case modifiedRaw != nil:
+			var args map[string]any
+			if err := json.Unmarshal(modifiedRaw, &args); err != nil {
+				return input, fmt.Errorf("tool execute before hook returned invalid input: %w", err)
+			}
 			input.Arguments = args
+			input.RawArguments = slices.Clone(modifiedRaw)
+		case modifiedArgs != nil:
+			encoded, err := json.Marshal(modifiedArgs)
+			if err != nil {
+				return input, fmt.Errorf("failed to encode tool input: %w", err)
+			}
+			input.Arguments = modifiedArgs
+			input.RawArguments = encoded
 		}
 	}
-	return args, nil
+	return input, nil
 }
 
 // TriggerToolExecuteAfter triggers all tool execute after hooks.
 // Each hook can modify the result, and the modifications are passed to the next hook.
+// Metadata returned by a hook is merged into the existing metadata rather
+// than replacing it.
 func (r *Registry) TriggerToolExecuteAfter(ctx context.Context, input ToolExecuteInput, result ToolExecuteResult) (ToolExecuteResult, error) {
-	r.mu.RLock()
-	hooks := make([]ToolHook, len(r.toolHooks))
-	copy(hooks, r.toolHooks)
-	r.mu.RUnlock()
-
-	for _, hook := range hooks {
-		modifiedResult, err := hook.OnToolExecuteAfter(ctx, input, result)
-		if err != nil {
+	hooks := r.hooks().tool
+
+	for _, entry := range hooks {
+		var modifiedResult *ToolExecuteResult
+		if err := r.guard(entry.plugin, func() (err error) {
+			modifiedResult, err = entry.hook.OnToolExecuteAfter(ctx, input, result)
+			return err
+		}); err != nil {
 			return result, fmt.Errorf("tool execute after hook failed: %w", err)
 		}
 		// Apply modifications if returned
 		if modifiedResult != nil {
+			metadata := result.Metadata
 			result = *modifiedResult
+			result.Metadata = mergeMetadata(metadata, modifiedResult.Metadata)
 		}
 	}
 	return result, nil
 }
 
+// TriggerToolsAssemble lets tool hooks filter, reorder, or re-describe the
+// tools exposed to the model. Hooks are chained in plugin load order, each
+// receiving the previous hook's result.
+func (r *Registry) TriggerToolsAssemble(ctx context.Context, tools []fantasy.ToolInfo) ([]fantasy.ToolInfo, error) {
+	hooks := r.hooks().tool
+
+	for _, entry := range hooks {
+		var assembled []fantasy.ToolInfo
+		if err := r.guard(entry.plugin, func() (err error) {
+			assembled, err = entry.hook.OnToolsAssemble(ctx, slices.Clone(tools))
+			return err
+		}); err != nil {
+			return tools, fmt.Errorf("tools assemble hook failed: %w", err)
+		}
+		if assembled != nil {
+			tools = assembled
+		}
+	}
+	return tools, nil
+}
+
 // TriggerAgentStart triggers all agent start hooks
 func (r *Registry) TriggerAgentStart(ctx context.Context, input AgentStartInput) error {
-	r.mu.RLock()
-	hooks := make([]AgentHook, len(r.agentHooks))
-	copy(hooks, r.agentHooks)
-	r.mu.RUnlock()
+	hooks := r.hooks().agent
 
-	for _, hook := range hooks {
-		if err := hook.OnAgentStart(ctx, input); err != nil {
+	for _, entry := range hooks {
+		if err := r.guard(entry.plugin, func() error {
+			return entry.hook.OnAgentStart(ctx, input)
+		}); err != nil {
 			return fmt.Errorf("agent start hook failed: %w", err)
 		}
 	}
@@ -321,13 +1096,12 @@ func (r *Registry) TriggerAgentStart(ctx context.Context, input AgentStartInput)
 
 // TriggerAgentStep triggers all agent step hooks
 func (r *Registry) TriggerAgentStep(ctx context.Context, input AgentStepInput) error {
-	r.mu.RLock()
-	hooks := make([]AgentHook, len(r.agentHooks))
-	copy(hooks, r.agentHooks)
-	r.mu.RUnlock()
+	hooks := r.hooks().agent
 
-	for _, hook := range hooks {
-		if err := hook.OnAgentStep(ctx, input); err != nil {
+	for _, entry := range hooks {
+		if err := r.guard(entry.plugin, func() error {
+			return entry.hook.OnAgentStep(ctx, input)
+		}); err != nil {
 			return fmt.Errorf("agent step hook failed: %w", err)
 		}
 	}
@@ -336,15 +1110,180 @@ func (r *Registry) TriggerAgentStep(ctx context.Context, input AgentStepInput) e
 
 // TriggerAgentFinish triggers all agent finish hooks
 func (r *Registry) TriggerAgentFinish(ctx context.Context, input AgentFinishInput) error {
-	r.mu.RLock()
-	hooks := make([]AgentHook, len(r.agentHooks))
-	copy(hooks, r.agentHooks)
-	r.mu.RUnlock()
+	hooks := r.hooks().agent
 
-	for _, hook := range hooks {
-		if err := hook.OnAgentFinish(ctx, input); err != nil {
+	for _, entry := range hooks {
+		if err := r.guard(entry.plugin, func() error {
+			return entry.hook.OnAgentFinish(ctx, input)
+		}); err != nil {
 			return fmt.Errorf("agent finish hook failed: %w", err)
 		}
 	}
 	return nil
 }
+
+// TriggerAgentFinalMessage lets agent hooks rewrite the final assistant
+// message of a run. Hooks are chained in plugin load order, each receiving
+// the previous hook's result. It returns nil if no hook changed the message.
+// A rewrite can't change the message's ID, session, or role.
+func (r *Registry) TriggerAgentFinalMessage(ctx context.Context, sessionID string, msg message.Message) (*message.Message, error) {
+	hooks := r.hooks().agent
+
+	var rewritten *message.Message
+	for _, entry := range hooks {
+		input := msg
+		input.Parts = slices.Clone(msg.Parts)
+		var result *message.Message
+		if err := r.guard(entry.plugin, func() (err error) {
+			result, err = entry.hook.OnAgentFinalMessage(ctx, sessionID, input)
+			return err
+		}); err != nil {
+			return rewritten, fmt.Errorf("agent final message hook failed: %w", err)
+		}
+		if result == nil {
+			continue
+		}
+		result.ID, result.SessionID, result.Role = msg.ID, msg.SessionID, msg.Role
+		msg = *result
+		rewritten = result
+	}
+	return rewritten, nil
+}
+
+// TriggerAgentRetry triggers all agent retry hooks
+func (r *Registry) TriggerAgentRetry(ctx context.Context, input AgentRetryInput) error {
+	hooks := r.hooks().agent
+
+	for _, entry := range hooks {
+		if err := r.guard(entry.plugin, func() error {
+			return entry.hook.OnAgentRetry(ctx, input)
+		}); err != nil {
+			return fmt.Errorf("agent retry hook failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// TriggerSystemPrompt executes all system prompt hooks and returns the
+// resulting system prompt
+func (r *Registry) TriggerSystemPrompt(ctx context.Context, sessionID, prompt string) (string, error) {
+	hooks := r.hooks().agent
+
+	for _, entry := range hooks {
+		promptHook, ok := entry.hook.(SystemPromptHook)
+		if !ok {
+			continue
+		}
+		var modified string
+		if err := r.guard(entry.plugin, func() (err error) {
+			modified, err = promptHook.OnSystemPrompt(ctx, sessionID, prompt)
+			return err
+		}); err != nil {
+			return "", fmt.Errorf("system prompt hook failed: %w", err)
+		}
+		prompt = modified
+	}
+	return prompt, nil
+}
+
+// HasBeforeModelCallHooks reports whether any agent hook implements
+// BeforeModelCallHook
+func (r *Registry) HasBeforeModelCallHooks() bool {
+	return slices.ContainsFunc(r.hooks().agent, func(entry hookEntry[AgentHook]) bool {
+		_, ok := entry.hook.(BeforeModelCallHook)
+		return ok
+	})
+}
+
+// TriggerBeforeModelCall executes all before model call hooks and returns
+// the messages to send to the model. Hooks are chained in plugin order, each
+// receiving the previous hook's result. A hook that returns no messages
+// fails with ErrNoMessages.
+func (r *Registry) TriggerBeforeModelCall(ctx context.Context, sessionID string, messages []message.Message) ([]message.Message, error) {
+	hooks := r.hooks().agent
+
+	for _, entry := range hooks {
+		modelCallHook, ok := entry.hook.(BeforeModelCallHook)
+		if !ok {
+			continue
+		}
+		var modified []message.Message
+		if err := r.guard(entry.plugin, func() (err error) {
+			modified, err = modelCallHook.OnBeforeModelCall(ctx, sessionID, slices.Clone(messages))
+			if err == nil && len(modified) == 0 {
+				err = &PluginError{Name: entry.plugin, Err: ErrNoMessages}
+			}
+			return err
+		}); err != nil {
+			return nil, fmt.Errorf("before model call hook failed: %w", err)
+		}
+		messages = modified
+	}
+	return messages, nil
+}
+
+// TriggerModelChanged triggers all model changed hooks
+func (r *Registry) TriggerModelChanged(ctx context.Context, sessionID string, modelType config.SelectedModelType, oldModel, newModel, provider string) error {
+	hooks := r.hooks().agent
+
+	for _, entry := range hooks {
+		if err := r.guard(entry.plugin, func() error {
+			return entry.hook.OnModelChanged(ctx, sessionID, modelType, oldModel, newModel, provider)
+		}); err != nil {
+			return fmt.Errorf("model changed hook failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// TriggerProviderRequest executes all provider request hooks
+func (r *Registry) TriggerProviderRequest(ctx context.Context, req ProviderRequest) error {
+	hooks := r.hooks().provider
+
+	for _, entry := range hooks {
+		if err := r.guard(entry.plugin, func() error {
+			return entry.hook.OnProviderRequest(ctx, req)
+		}); err != nil {
+			return fmt.Errorf("provider request hook failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// TriggerProviderResponse executes all provider response hooks
+func (r *Registry) TriggerProviderResponse(ctx context.Context, resp ProviderResponse) error {
+	hooks := r.hooks().provider
+
+	for _, entry := range hooks {
+		if err := r.guard(entry.plugin, func() error {
+			return entry.hook.OnProviderResponse(ctx, resp)
+		}); err != nil {
+			return fmt.Errorf("provider response hook failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// TriggerLSPClientStateChanged executes all LSP client state hooks
+func (r *Registry) TriggerLSPClientStateChanged(ctx context.Context, name string, state LSPState) error {
+	hooks := r.hooks().lsp
+
+	for _, entry := range hooks {
+		if err := r.guard(entry.plugin, func() error {
+			return entry.hook.OnLSPClientStateChanged(ctx, name, state)
+		}); err != nil {
+			return fmt.Errorf("lsp client state hook failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// mergeMetadata returns a copy of base with the keys of override applied
+func mergeMetadata(base, override map[string]any) map[string]any {
+	if len(base) == 0 {
+		return override
+	}
+	merged := maps.Clone(base)
+	maps.Copy(merged, override)
+	return merged
+}
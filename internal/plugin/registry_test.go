@@ -0,0 +1,889 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+type flakyPlugin struct {
+	name     string
+	failures int
+	err      error
+	attempts int
+}
+
+func (p *flakyPlugin) Info() PluginInfo { return PluginInfo{Name: p.name} }
+func (p *flakyPlugin) Hooks() Hooks     { return NewBaseHooks() }
+
+func (p *flakyPlugin) Init(ctx context.Context, pluginCtx PluginContext) error {
+	p.attempts++
+	if p.attempts <= p.failures {
+		return p.err
+	}
+	return nil
+}
+
+func (p *flakyPlugin) Shutdown(ctx context.Context) error { return nil }
+
+type temporaryError struct{}
+
+func (temporaryError) Error() string   { return "socket not ready" }
+func (temporaryError) Temporary() bool { return true }
+
+func TestLoadPluginWithRetry(t *testing.T) {
+	t.Parallel()
+
+	policy := RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     2 * time.Millisecond,
+	}
+
+	t.Run("retries temporary errors until success", func(t *testing.T) {
+		t.Parallel()
+		p := &flakyPlugin{name: "flaky", failures: 2, err: fmt.Errorf("dial: %w", ErrTemporary)}
+		r := NewRegistry()
+		require.NoError(t, r.LoadPluginWithRetry(t.Context(), p, PluginContext{}, policy))
+		require.Equal(t, 3, p.attempts)
+		_, ok := r.GetPlugin("flaky")
+		require.True(t, ok)
+	})
+
+	t.Run("honors Temporary interface", func(t *testing.T) {
+		t.Parallel()
+		p := &flakyPlugin{name: "flaky", failures: 2, err: temporaryError{}}
+		require.NoError(t, NewRegistry().LoadPluginWithRetry(t.Context(), p, PluginContext{}, policy))
+		require.Equal(t, 3, p.attempts)
+	})
+
+	t.Run("permanent errors fail fast", func(t *testing.T) {
+		t.Parallel()
+		permanent := errors.New("bad config")
+		p := &flakyPlugin{name: "broken", failures: 2, err: permanent}
+		r := NewRegistry()
+		err := r.LoadPluginWithRetry(t.Context(), p, PluginContext{}, policy)
+		require.ErrorIs(t, err, permanent)
+		require.ErrorIs(t, err, ErrInitFailed)
+		require.Equal(t, 1, p.attempts)
+		_, ok := r.GetPlugin("broken")
+		require.False(t, ok)
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		t.Parallel()
+		p := &flakyPlugin{name: "flaky", failures: 10, err: ErrTemporary}
+		err := NewRegistry().LoadPluginWithRetry(t.Context(), p, PluginContext{}, policy)
+		require.ErrorIs(t, err, ErrTemporary)
+		require.Equal(t, 5, p.attempts)
+	})
+
+	t.Run("LoadPlugin does not retry", func(t *testing.T) {
+		t.Parallel()
+		p := &flakyPlugin{name: "flaky", failures: 1, err: ErrTemporary}
+		require.Error(t, NewRegistry().LoadPlugin(t.Context(), p, PluginContext{}))
+		require.Equal(t, 1, p.attempts)
+	})
+}
+
+func TestLoadTimes(t *testing.T) {
+	t.Parallel()
+
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: 2 * time.Millisecond}
+	r := NewRegistry()
+	require.NoError(t, r.LoadPluginWithRetry(t.Context(), &flakyPlugin{name: "flaky", failures: 1, err: ErrTemporary}, PluginContext{}, policy))
+
+	loadTime := r.LoadTimes()["flaky"]
+	require.Zero(t, loadTime.Open, "in-process plugins aren't opened")
+	require.GreaterOrEqual(t, loadTime.Init, 2*time.Millisecond, "init time must include retries")
+	require.Equal(t, loadTime.Init, loadTime.Total())
+
+	details := r.DescribePlugins()
+	require.Len(t, details, 1)
+	require.GreaterOrEqual(t, details[0].InitTimeMS, 2.0)
+
+	require.NoError(t, r.UnloadPlugin(t.Context(), "flaky"))
+	require.Empty(t, r.LoadTimes())
+}
+
+func TestRegistryErrors(t *testing.T) {
+	t.Parallel()
+
+	r := NewRegistry()
+	require.NoError(t, r.LoadPlugin(t.Context(), &flakyPlugin{name: "loaded"}, PluginContext{}))
+
+	err := r.LoadPlugin(t.Context(), &flakyPlugin{name: "loaded"}, PluginContext{})
+	require.ErrorIs(t, err, ErrPluginAlreadyLoaded)
+	var pluginErr *PluginError
+	require.ErrorAs(t, err, &pluginErr)
+	require.Equal(t, "loaded", pluginErr.Name)
+	require.EqualError(t, err, "plugin loaded: plugin is already loaded")
+
+	err = r.UnloadPlugin(t.Context(), "missing")
+	require.ErrorIs(t, err, ErrPluginNotLoaded)
+	require.ErrorAs(t, err, &pluginErr)
+	require.Equal(t, "missing", pluginErr.Name)
+}
+
+type healthPlugin struct {
+	name    string
+	err     error
+	created int
+}
+
+func (p *healthPlugin) Info() PluginInfo                          { return PluginInfo{Name: p.name} }
+func (p *healthPlugin) Init(context.Context, PluginContext) error { return nil }
+func (p *healthPlugin) Shutdown(context.Context) error            { return nil }
+func (p *healthPlugin) HealthCheck(context.Context) error         { return p.err }
+
+func (p *healthPlugin) Hooks() Hooks {
+	hooks := NewBaseHooks()
+	hooks.SessionHook = &countingSessionHook{count: &p.created}
+	return hooks
+}
+
+type countingSessionHook struct {
+	NilSessionHook
+	count *int
+}
+
+func (h *countingSessionHook) OnSessionCreated(context.Context, session.Session) error {
+	*h.count++
+	return nil
+}
+
+func TestHealthChecks(t *testing.T) {
+	t.Parallel()
+
+	p := &healthPlugin{name: "health"}
+	r := NewRegistry()
+	require.NoError(t, r.LoadPlugin(t.Context(), p, PluginContext{}))
+	require.True(t, r.IsHealthy("health"))
+
+	r.CheckHealth(t.Context(), time.Second)
+	require.NoError(t, r.TriggerSessionCreated(t.Context(), session.Session{}))
+	require.Equal(t, 1, p.created)
+
+	p.err = errors.New("backend down")
+	r.CheckHealth(t.Context(), time.Second)
+	require.False(t, r.IsHealthy("health"))
+	require.NoError(t, r.TriggerSessionCreated(t.Context(), session.Session{}))
+	require.Equal(t, 1, p.created, "hooks of unhealthy plugins must be skipped")
+
+	details := r.DescribePlugins()
+	require.Len(t, details, 1)
+	require.False(t, details[0].Healthy)
+	require.Equal(t, "backend down", details[0].HealthError)
+
+	p.err = nil
+	r.CheckHealth(t.Context(), time.Second)
+	require.True(t, r.IsHealthy("health"))
+	require.NoError(t, r.TriggerSessionCreated(t.Context(), session.Session{}))
+	require.Equal(t, 2, p.created)
+}
+
+func TestUnloadPluginRemovesHooks(t *testing.T) {
+	t.Parallel()
+
+	p := &healthPlugin{name: "health"}
+	r := NewRegistry()
+	require.NoError(t, r.LoadPlugin(t.Context(), p, PluginContext{}))
+	require.NoError(t, r.TriggerSessionCreated(t.Context(), session.Session{}))
+	require.Equal(t, 1, p.created)
+
+	require.NoError(t, r.UnloadPlugin(t.Context(), "health"))
+	require.NoError(t, r.TriggerSessionCreated(t.Context(), session.Session{}))
+	require.Equal(t, 1, p.created, "hooks of unloaded plugins must not run")
+}
+
+type orderSessionHook struct {
+	NilSessionHook
+	name  string
+	calls *[]string
+}
+
+func (h *orderSessionHook) OnSessionCreated(context.Context, session.Session) error {
+	*h.calls = append(*h.calls, h.name)
+	return nil
+}
+
+type sessionHookPlugin struct {
+	flakyPlugin
+	hook SessionHook
+}
+
+func (p *sessionHookPlugin) Hooks() Hooks {
+	hooks := NewBaseHooks()
+	hooks.SessionHook = p.hook
+	return hooks
+}
+
+type settingsPlugin struct {
+	flakyPlugin
+	settings json.RawMessage
+}
+
+func (p *settingsPlugin) Init(ctx context.Context, pluginCtx PluginContext) error {
+	p.settings = pluginCtx.Settings
+	return nil
+}
+
+func TestPluginSettings(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config.Config{Options: &config.Options{Plugins: &config.PluginOptions{
+		Settings: map[string]json.RawMessage{"first": json.RawMessage(`{"threshold":3}`)},
+	}}}
+	registry := NewRegistry()
+	first := &settingsPlugin{flakyPlugin: flakyPlugin{name: "first"}}
+	second := &settingsPlugin{flakyPlugin: flakyPlugin{name: "second"}}
+	require.NoError(t, registry.LoadPlugin(t.Context(), first, PluginContext{Config: cfg}))
+	require.NoError(t, registry.LoadPlugin(t.Context(), second, PluginContext{Config: cfg}))
+	require.JSONEq(t, `{"threshold":3}`, string(first.settings))
+	require.Nil(t, second.settings, "plugins must only receive their own settings")
+}
+
+// configuredPlugin provides a permission hook only if its settings ask for
+// one
+type configuredPlugin struct {
+	flakyPlugin
+	hooks *BaseHooks
+}
+
+func (p *configuredPlugin) Init(ctx context.Context, pluginCtx PluginContext) error {
+	p.hooks = NewBaseHooks()
+	if string(pluginCtx.Settings) == "true" {
+		p.hooks.PermissionHook = &denyingPermissionHook{tool: "bash"}
+	}
+	return nil
+}
+
+func (p *configuredPlugin) Hooks() Hooks {
+	if p.hooks == nil {
+		panic("Hooks called before Init")
+	}
+	return p.hooks
+}
+
+func TestHooksChosenInInit(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config.Config{Options: &config.Options{Plugins: &config.PluginOptions{
+		Settings: map[string]json.RawMessage{"enforce": json.RawMessage("true")},
+	}}}
+	r := NewRegistry()
+	require.NoError(t, r.LoadPlugin(t.Context(), &configuredPlugin{flakyPlugin: flakyPlugin{name: "enforce"}}, PluginContext{Config: cfg}))
+	require.NoError(t, r.LoadPlugin(t.Context(), &configuredPlugin{flakyPlugin: flakyPlugin{name: "observe"}}, PluginContext{Config: cfg}))
+	require.Equal(t, []string{"enforce"}, r.PluginsImplementing(HookPermission))
+
+	// Hooks chosen in Init must still match the plugin's manifest
+	dir := t.TempDir()
+	source := filepath.Join(dir, "enforce.so")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "enforce.json"), []byte(`{
+		"info": {"name": "enforce"},
+		"capabilities": ["session"]
+	}`), 0o644))
+	r = NewRegistry()
+	err := r.loadPlugin(t.Context(), &configuredPlugin{flakyPlugin: flakyPlugin{name: "enforce"}}, PluginContext{Config: cfg}, RetryPolicy{}, pluginSource{path: source}, 0)
+	require.ErrorIs(t, err, ErrManifestMismatch)
+	require.ErrorContains(t, err, `"permission"`)
+	require.Empty(t, r.ListPlugins())
+}
+
+func TestSetPluginOrder(t *testing.T) {
+	t.Parallel()
+
+	var calls []string
+	r := NewRegistry()
+	load := func(name string) {
+		require.NoError(t, r.LoadPlugin(t.Context(), &sessionHookPlugin{
+			flakyPlugin: flakyPlugin{name: name},
+			hook:        &orderSessionHook{name: name, calls: &calls},
+		}, PluginContext{}))
+	}
+	trigger := func() []string {
+		calls = nil
+		require.NoError(t, r.TriggerSessionCreated(t.Context(), session.Session{}))
+		return calls
+	}
+
+	load("a")
+	load("b")
+	load("c")
+	require.Equal(t, []string{"a", "b", "c"}, trigger())
+
+	r.SetPluginOrder([]string{"c", "missing", "b"})
+	require.Equal(t, []string{"c", "b", "a"}, trigger())
+
+	// Plugins loaded later are placed by the same order
+	load("d")
+	load("missing")
+	require.Equal(t, []string{"c", "missing", "b", "a", "d"}, trigger())
+}
+
+func TestPluginsImplementing(t *testing.T) {
+	t.Parallel()
+
+	r := NewRegistry()
+	for _, name := range []string{"b", "a"} {
+		require.NoError(t, r.LoadPlugin(t.Context(), &sessionHookPlugin{
+			flakyPlugin: flakyPlugin{name: name},
+			hook:        &orderSessionHook{name: name},
+		}, PluginContext{}))
+	}
+	require.NoError(t, r.LoadPlugin(t.Context(), &flakyPlugin{name: "none"}, PluginContext{}))
+	r.SetPluginOrder([]string{"a"})
+
+	require.Equal(t, []string{"a", "b"}, r.PluginsImplementing(HookSession))
+	require.Equal(t, 2, r.HookCount(HookSession))
+	require.Empty(t, r.PluginsImplementing(HookTool))
+	require.Zero(t, r.HookCount("unknown"))
+
+	require.NoError(t, r.UnloadPlugin(t.Context(), "a"))
+	require.Equal(t, []string{"b"}, r.PluginsImplementing(HookSession))
+}
+
+// minimalHooks implements only the hooks every Hooks has, like plugins built
+// before optional hooks were added
+type minimalHooks struct{ session SessionHook }
+
+func (h minimalHooks) Config() ConfigHook         { return nil }
+func (h minimalHooks) Session() SessionHook       { return h.session }
+func (h minimalHooks) Message() MessageHook       { return nil }
+func (h minimalHooks) Permission() PermissionHook { return nil }
+func (h minimalHooks) Tool() ToolHook             { return nil }
+func (h minimalHooks) Agent() AgentHook           { return nil }
+
+type minimalHooksPlugin struct{ flakyPlugin }
+
+func (p *minimalHooksPlugin) Hooks() Hooks {
+	return minimalHooks{session: &orderSessionHook{name: p.name}}
+}
+
+func TestOptionalHooks(t *testing.T) {
+	t.Parallel()
+
+	r := NewRegistry()
+	require.NoError(t, r.LoadPlugin(t.Context(), &minimalHooksPlugin{flakyPlugin{name: "minimal"}}, PluginContext{}))
+	require.Equal(t, []string{"minimal"}, r.PluginsImplementing(HookSession))
+	require.Empty(t, r.PluginsImplementing(HookProvider))
+	require.False(t, r.HasProviderHooks())
+	require.Empty(t, r.PluginsImplementing(HookLSP))
+	require.False(t, r.HasLSPHooks())
+}
+
+func TestPluginEvents(t *testing.T) {
+	t.Parallel()
+
+	r := NewRegistry()
+	events := r.Subscribe(t.Context())
+
+	p := &flakyPlugin{name: "events"}
+	require.NoError(t, r.LoadPlugin(t.Context(), p, PluginContext{}))
+	event := <-events
+	require.Equal(t, PluginLoaded, event.Payload.Type)
+	require.Equal(t, "events", event.Payload.Info.Name)
+
+	require.NoError(t, r.UnloadPlugin(t.Context(), "events"))
+	event = <-events
+	require.Equal(t, PluginUnloaded, event.Payload.Type)
+	require.Equal(t, "events", event.Payload.Info.Name)
+}
+
+type metadataToolHook struct {
+	NilToolHook
+	metadata map[string]any
+}
+
+func (h *metadataToolHook) OnToolExecuteAfter(ctx context.Context, input ToolExecuteInput, result ToolExecuteResult) (*ToolExecuteResult, error) {
+	result.Metadata = h.metadata
+	return &result, nil
+}
+
+type toolHookPlugin struct {
+	flakyPlugin
+	hook ToolHook
+}
+
+func (p *toolHookPlugin) Hooks() Hooks {
+	hooks := NewBaseHooks()
+	hooks.ToolHook = p.hook
+	return hooks
+}
+
+type configHookFunc func(cfg *config.Config) error
+
+func (f configHookFunc) OnConfigLoad(ctx context.Context, cfg *config.Config) error { return f(cfg) }
+
+type configHookPlugin struct {
+	flakyPlugin
+	hook configHookFunc
+}
+
+func (p *configHookPlugin) Hooks() Hooks {
+	hooks := NewBaseHooks()
+	hooks.ConfigHook = p.hook
+	return hooks
+}
+
+func TestTriggerConfigHooks(t *testing.T) {
+	t.Parallel()
+
+	newConfig := func(strict bool) *config.Config {
+		return &config.Config{
+			Options:   &config.Options{Plugins: &config.PluginOptions{StrictConfigHooks: strict}},
+			Providers: csync.NewMap[string, config.ProviderConfig](),
+		}
+	}
+	newRegistry := func(hooks ...configHookFunc) *Registry {
+		r := NewRegistry()
+		for i, hook := range hooks {
+			require.NoError(t, r.LoadPlugin(t.Context(), &configHookPlugin{
+				flakyPlugin: flakyPlugin{name: fmt.Sprintf("plugin-%d", i)},
+				hook:        hook,
+			}, PluginContext{}))
+		}
+		return r
+	}
+	failing := func(cfg *config.Config) error { return errors.New("bad setting") }
+	var ran bool
+	marking := func(cfg *config.Config) error {
+		ran = true
+		return nil
+	}
+
+	r := newRegistry(failing, marking)
+	require.NoError(t, r.TriggerConfigHooks(t.Context(), newConfig(false)))
+	require.True(t, ran, "hooks after a failed hook must still run")
+
+	err := r.TriggerConfigHooks(t.Context(), newConfig(true))
+	require.ErrorIs(t, err, ErrConfigHookFailed)
+	var pluginErr *PluginError
+	require.ErrorAs(t, err, &pluginErr)
+	require.Equal(t, "plugin-0", pluginErr.Name)
+
+	r = newRegistry(marking, func(cfg *config.Config) error {
+		cfg.MCP = config.MCPs{"broken": {Type: config.MCPStdio}}
+		return nil
+	})
+	err = r.TriggerConfigHooks(t.Context(), newConfig(false))
+	require.ErrorIs(t, err, ErrInvalidConfig)
+	require.ErrorAs(t, err, &pluginErr)
+	require.Equal(t, "plugin-1", pluginErr.Name)
+}
+
+type argsToolHook struct {
+	NilToolHook
+	args map[string]any
+	raw  json.RawMessage
+	seen []json.RawMessage
+}
+
+func (h *argsToolHook) OnToolExecuteBefore(ctx context.Context, input ToolExecuteInput) (map[string]any, error) {
+	h.seen = append(h.seen, input.RawArguments)
+	return h.args, nil
+}
+
+func (h *argsToolHook) OnToolExecuteBeforeRaw(ctx context.Context, input ToolExecuteInput) (json.RawMessage, error) {
+	return h.raw, nil
+}
+
+func TestTriggerToolExecuteBeforeRaw(t *testing.T) {
+	t.Parallel()
+
+	first := &argsToolHook{
+		args: map[string]any{"path": "ignored"},
+		raw:  json.RawMessage(`{"path":"a.go","limit":10}`),
+	}
+	second := &argsToolHook{}
+	third := &argsToolHook{args: map[string]any{"path": "b.go"}}
+
+	r := NewRegistry()
+	for i, hook := range []ToolHook{first, second, third} {
+		require.NoError(t, r.LoadPlugin(t.Context(), &toolHookPlugin{
+			flakyPlugin: flakyPlugin{name: fmt.Sprintf("plugin-%d", i)},
+			hook:        hook,
+		}, PluginContext{}))
+	}
+
+	input, err := r.TriggerToolExecuteBefore(t.Context(), ToolExecuteInput{
+		ToolName:     "view",
+		Arguments:    map[string]any{"path": "main.go"},
+		RawArguments: json.RawMessage(`{"path":"main.go"}`),
+	})
+	require.NoError(t, err)
+	require.Equal(t, []json.RawMessage{json.RawMessage(`{"path":"main.go"}`)}, first.seen)
+	require.Equal(t, []json.RawMessage{json.RawMessage(`{"path":"a.go","limit":10}`)}, second.seen, "raw input takes precedence over the map")
+	require.Equal(t, map[string]any{"path": "b.go"}, input.Arguments)
+	require.JSONEq(t, `{"path":"b.go"}`, string(input.RawArguments))
+
+	second.raw = json.RawMessage(`not json`)
+	_, err = r.TriggerToolExecuteBefore(t.Context(), ToolExecuteInput{ToolName: "view"})
+	require.Error(t, err)
+}
+
+func TestTriggerToolExecuteAfterMergesMetadata(t *testing.T) {
+	t.Parallel()
+
+	r := NewRegistry()
+	require.NoError(t, r.LoadPlugin(t.Context(), &toolHookPlugin{
+		flakyPlugin: flakyPlugin{name: "first"},
+		hook:        &metadataToolHook{metadata: map[string]any{"cache": "hit"}},
+	}, PluginContext{}))
+	require.NoError(t, r.LoadPlugin(t.Context(), &toolHookPlugin{
+		flakyPlugin: flakyPlugin{name: "second"},
+		hook:        &metadataToolHook{metadata: map[string]any{MetadataBytesOut: 0}},
+	}, PluginContext{}))
+
+	result, err := r.TriggerToolExecuteAfter(t.Context(), ToolExecuteInput{ToolName: "view"}, ToolExecuteResult{
+		Output:   "hello",
+		Metadata: map[string]any{MetadataDurationMS: int64(12), MetadataBytesOut: 5},
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{
+		MetadataDurationMS: int64(12),
+		MetadataBytesOut:   0,
+		"cache":            "hit",
+	}, result.Metadata)
+}
+
+type hangingPlugin struct {
+	flakyPlugin
+}
+
+func (p *hangingPlugin) Shutdown(ctx context.Context) error {
+	select {}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	t.Parallel()
+
+	r := NewRegistry()
+	require.NoError(t, r.LoadPlugin(t.Context(), &hangingPlugin{flakyPlugin{name: "stuck"}}, PluginContext{}))
+	require.NoError(t, r.LoadPlugin(t.Context(), &flakyPlugin{name: "fine"}, PluginContext{}))
+
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+
+	err := r.Shutdown(ctx)
+	require.ErrorIs(t, err, ErrShutdownFailed)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	var pluginErr *PluginError
+	require.ErrorAs(t, err, &pluginErr)
+	require.Equal(t, "stuck", pluginErr.Name)
+	require.NotContains(t, err.Error(), "plugin fine")
+}
+
+// messageHookPlugin has a message hook that does nothing. Unlike
+// NilMessageHook, the registry can't skip it.
+type messageHookPlugin struct {
+	flakyPlugin
+}
+
+func (p *messageHookPlugin) Hooks() Hooks {
+	hooks := NewBaseHooks()
+	hooks.MessageHook = p
+	return hooks
+}
+
+func (p *messageHookPlugin) OnMessageCreated(ctx context.Context, msg message.Message) error {
+	return nil
+}
+
+func (p *messageHookPlugin) OnMessageUpdated(ctx context.Context, msg message.Message) error {
+	return nil
+}
+
+// benchmarkTriggerMessageUpdated benchmarks message updates with 8 plugins
+// loaded by newPlugin
+func benchmarkTriggerMessageUpdated(b *testing.B, newPlugin func(name string) Plugin) {
+	r := NewRegistry()
+	for i := range 8 {
+		require.NoError(b, r.LoadPlugin(b.Context(), newPlugin(fmt.Sprintf("plugin-%d", i)), PluginContext{}))
+	}
+
+	b.RunParallel(func(pb *testing.PB) {
+		ctx := context.Background()
+		for pb.Next() {
+			_ = r.TriggerMessageUpdated(ctx, message.Message{})
+		}
+	})
+}
+
+func BenchmarkTriggerMessageUpdated(b *testing.B) {
+	benchmarkTriggerMessageUpdated(b, func(name string) Plugin {
+		return &messageHookPlugin{flakyPlugin{name: name}}
+	})
+}
+
+func BenchmarkTriggerMessageUpdatedNoHooks(b *testing.B) {
+	benchmarkTriggerMessageUpdated(b, func(name string) Plugin {
+		return &flakyPlugin{name: name}
+	})
+}
+
+// lspStatePlugin records the LSP client states it is notified of
+type lspStatePlugin struct {
+	flakyPlugin
+	states []string
+}
+
+func (p *lspStatePlugin) Hooks() Hooks {
+	hooks := NewBaseHooks()
+	hooks.LSPHook = p
+	return hooks
+}
+
+func (p *lspStatePlugin) OnLSPClientStateChanged(ctx context.Context, name string, state LSPState) error {
+	p.states = append(p.states, name+":"+string(state))
+	return nil
+}
+
+func TestTriggerLSPClientStateChanged(t *testing.T) {
+	t.Parallel()
+
+	r := NewRegistry()
+	require.False(t, r.HasLSPHooks())
+
+	p := &lspStatePlugin{flakyPlugin: flakyPlugin{name: "lsp-watcher"}}
+	require.NoError(t, r.LoadPlugin(t.Context(), p, PluginContext{}))
+	require.True(t, r.HasLSPHooks())
+	require.Equal(t, []string{"lsp-watcher"}, r.PluginsImplementing(HookLSP))
+
+	require.NoError(t, r.TriggerLSPClientStateChanged(t.Context(), "gopls", LSPStateReady))
+	require.NoError(t, r.TriggerLSPClientStateChanged(t.Context(), "gopls", LSPStateError))
+	require.Equal(t, []string{"gopls:ready", "gopls:error"}, p.states)
+}
+
+// countingMessageHook counts the messages created one at a time
+type countingMessageHook struct {
+	flakyPlugin
+	NilMessageHook
+	single int
+}
+
+func (p *countingMessageHook) Hooks() Hooks {
+	hooks := NewBaseHooks()
+	hooks.MessageHook = p
+	return hooks
+}
+
+func (p *countingMessageHook) OnMessageCreated(ctx context.Context, msg message.Message) error {
+	p.single++
+	return nil
+}
+
+// batchingMessageHook records the batches of messages created
+type batchingMessageHook struct {
+	countingMessageHook
+	batches [][]message.Message
+}
+
+func (p *batchingMessageHook) Hooks() Hooks {
+	hooks := NewBaseHooks()
+	hooks.MessageHook = p
+	return hooks
+}
+
+func (p *batchingMessageHook) OnMessagesCreated(ctx context.Context, msgs []message.Message) error {
+	p.batches = append(p.batches, msgs)
+	return nil
+}
+
+func (p *batchingMessageHook) OnMessagesUpdated(ctx context.Context, msgs []message.Message) error {
+	return errors.New("update failed")
+}
+
+func TestTriggerMessageBatches(t *testing.T) {
+	t.Parallel()
+
+	r := NewRegistry()
+	single := &countingMessageHook{flakyPlugin: flakyPlugin{name: "single"}}
+	batching := &batchingMessageHook{countingMessageHook: countingMessageHook{flakyPlugin: flakyPlugin{name: "batching"}}}
+	require.NoError(t, r.LoadPlugin(t.Context(), single, PluginContext{}))
+	require.NoError(t, r.LoadPlugin(t.Context(), batching, PluginContext{}))
+
+	msgs := []message.Message{{ID: "1"}, {ID: "2"}, {ID: "3"}}
+	require.NoError(t, r.TriggerMessagesCreated(t.Context(), msgs))
+	require.Equal(t, 3, single.single)
+	require.Equal(t, [][]message.Message{msgs}, batching.batches)
+	require.Zero(t, batching.single)
+
+	err := r.TriggerMessagesUpdated(t.Context(), msgs)
+	require.ErrorContains(t, err, "message updated hook failed: update failed")
+}
+
+type finalMessageHook struct {
+	NilAgentHook
+	rewrite func(message.Message) *message.Message
+}
+
+func (h *finalMessageHook) OnAgentFinalMessage(ctx context.Context, sessionID string, msg message.Message) (*message.Message, error) {
+	return h.rewrite(msg), nil
+}
+
+type agentHookPlugin struct {
+	flakyPlugin
+	hook AgentHook
+}
+
+func (p *agentHookPlugin) Hooks() Hooks {
+	hooks := NewBaseHooks()
+	hooks.AgentHook = p.hook
+	return hooks
+}
+
+func TestTriggerAgentFinalMessage(t *testing.T) {
+	t.Parallel()
+
+	r := NewRegistry()
+	msg := message.Message{ID: "m1", SessionID: "s1", Role: message.Assistant, Parts: []message.ContentPart{
+		message.TextContent{Text: "The answer is 42."},
+	}}
+
+	rewritten, err := r.TriggerAgentFinalMessage(t.Context(), "s1", msg)
+	require.NoError(t, err)
+	require.Nil(t, rewritten, "no hooks must leave the message unchanged")
+
+	require.NoError(t, r.LoadPlugin(t.Context(), &agentHookPlugin{
+		flakyPlugin: flakyPlugin{name: "cite"},
+		hook: &finalMessageHook{rewrite: func(msg message.Message) *message.Message {
+			msg.Parts[0] = message.TextContent{Text: msg.Content().Text + " [1]"}
+			msg.ID = "changed"
+			return &msg
+		}},
+	}, PluginContext{}))
+	require.NoError(t, r.LoadPlugin(t.Context(), &agentHookPlugin{
+		flakyPlugin: flakyPlugin{name: "observe"},
+		hook:        &finalMessageHook{rewrite: func(message.Message) *message.Message { return nil }},
+	}, PluginContext{}))
+	require.NoError(t, r.LoadPlugin(t.Context(), &agentHookPlugin{
+		flakyPlugin: flakyPlugin{name: "footer"},
+		hook: &finalMessageHook{rewrite: func(msg message.Message) *message.Message {
+			require.Equal(t, "The answer is 42. [1]", msg.Content().Text)
+			msg.Parts = append(msg.Parts, message.TextContent{Text: "Sources: ..."})
+			return &msg
+		}},
+	}, PluginContext{}))
+
+	rewritten, err = r.TriggerAgentFinalMessage(t.Context(), "s1", msg)
+	require.NoError(t, err)
+	require.NotNil(t, rewritten)
+	require.Equal(t, "m1", rewritten.ID, "hooks can't change the message ID")
+	require.Len(t, rewritten.Parts, 2)
+	require.Equal(t, "The answer is 42.", msg.Content().Text, "the original message must not be modified")
+}
+
+type systemPromptHook struct {
+	NilAgentHook
+	suffix string
+}
+
+func (h *systemPromptHook) OnSystemPrompt(ctx context.Context, sessionID, prompt string) (string, error) {
+	return prompt + h.suffix, nil
+}
+
+func TestTriggerSystemPrompt(t *testing.T) {
+	t.Parallel()
+
+	r := NewRegistry()
+	require.NoError(t, r.LoadPlugin(t.Context(), &agentHookPlugin{
+		flakyPlugin: flakyPlugin{name: "first"},
+		hook:        &systemPromptHook{suffix: " first"},
+	}, PluginContext{}))
+	require.NoError(t, r.LoadPlugin(t.Context(), &agentHookPlugin{
+		flakyPlugin: flakyPlugin{name: "plain"},
+		hook:        &finalMessageHook{},
+	}, PluginContext{}))
+	require.NoError(t, r.LoadPlugin(t.Context(), &agentHookPlugin{
+		flakyPlugin: flakyPlugin{name: "second"},
+		hook:        &systemPromptHook{suffix: " second"},
+	}, PluginContext{}))
+
+	prompt, err := r.TriggerSystemPrompt(t.Context(), "s1", "base")
+	require.NoError(t, err)
+	require.Equal(t, "base first second", prompt)
+}
+
+type modelCallHook struct {
+	NilAgentHook
+	rewrite func([]message.Message) []message.Message
+}
+
+func (h *modelCallHook) OnBeforeModelCall(ctx context.Context, sessionID string, messages []message.Message) ([]message.Message, error) {
+	return h.rewrite(messages), nil
+}
+
+func TestTriggerBeforeModelCall(t *testing.T) {
+	t.Parallel()
+
+	msgs := []message.Message{{ID: "m1"}, {ID: "m2"}, {ID: "m3"}}
+
+	r := NewRegistry()
+	require.False(t, r.HasBeforeModelCallHooks())
+	require.NoError(t, r.LoadPlugin(t.Context(), &agentHookPlugin{
+		flakyPlugin: flakyPlugin{name: "trim"},
+		hook:        &modelCallHook{rewrite: func(msgs []message.Message) []message.Message { return msgs[1:] }},
+	}, PluginContext{}))
+	require.NoError(t, r.LoadPlugin(t.Context(), &agentHookPlugin{
+		flakyPlugin: flakyPlugin{name: "reverse"},
+		hook: &modelCallHook{rewrite: func(msgs []message.Message) []message.Message {
+			slices.Reverse(msgs)
+			return msgs
+		}},
+	}, PluginContext{}))
+	require.True(t, r.HasBeforeModelCallHooks())
+
+	rewritten, err := r.TriggerBeforeModelCall(t.Context(), "s1", msgs)
+	require.NoError(t, err)
+	require.Equal(t, []message.Message{{ID: "m3"}, {ID: "m2"}}, rewritten)
+	require.Equal(t, "m1", msgs[0].ID, "the original list must not be modified")
+
+	require.NoError(t, r.LoadPlugin(t.Context(), &agentHookPlugin{
+		flakyPlugin: flakyPlugin{name: "drop"},
+		hook:        &modelCallHook{rewrite: func([]message.Message) []message.Message { return nil }},
+	}, PluginContext{}))
+	_, err = r.TriggerBeforeModelCall(t.Context(), "s1", msgs)
+	require.ErrorIs(t, err, ErrNoMessages)
+}
+
+// truncationRecorder records the context truncations it is notified of
+type truncationRecorder struct {
+	NilSessionHook
+	truncations []string
+}
+
+func (h *truncationRecorder) OnContextTruncated(ctx context.Context, sessionID string, droppedTokens int, droppedMessageIDs []string) error {
+	h.truncations = append(h.truncations, fmt.Sprintf("%s: %d tokens %v", sessionID, droppedTokens, droppedMessageIDs))
+	return nil
+}
+
+func TestTriggerContextTruncated(t *testing.T) {
+	t.Parallel()
+
+	hook := &truncationRecorder{}
+	r := NewRegistry()
+	require.NoError(t, r.LoadPlugin(t.Context(), &sessionHookPlugin{
+		flakyPlugin: flakyPlugin{name: "metrics"},
+		hook:        hook,
+	}, PluginContext{}))
+	require.NoError(t, r.LoadPlugin(t.Context(), &sessionHookPlugin{
+		flakyPlugin: flakyPlugin{name: "sessions"},
+		hook:        &orderSessionHook{name: "sessions", calls: new([]string)},
+	}, PluginContext{}))
+
+	require.NoError(t, r.TriggerContextTruncated(t.Context(), "s1", 1200, []string{"m1", "m2"}))
+	require.Equal(t, []string{"s1: 1200 tokens [m1 m2]"}, hook.truncations)
+}
@@ -0,0 +1,234 @@
+package plugin
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/crush/internal/config"
+)
+
+// checksumFragment is the URL fragment prefix used to pin a remote plugin's
+// SHA-256 checksum, e.g. https://example.com/metrics.so#sha256=<hex>
+const checksumFragment = "sha256="
+
+// maxRemoteSize limits the size of a downloaded file
+const maxRemoteSize = 256 << 20
+
+var remoteClient = &http.Client{Timeout: 2 * time.Minute}
+
+// defaultCacheDir returns the directory remote plugins are cached in
+func defaultCacheDir() string {
+	return filepath.Join(filepath.Dir(config.GlobalConfig()), "plugins", "cache")
+}
+
+// IsRemote reports whether path is an http(s) URL that must be downloaded.
+// Only https URLs can be fetched.
+func IsRemote(path string) bool {
+	return strings.HasPrefix(path, "https://") || strings.HasPrefix(path, "http://")
+}
+
+// FetchOptions controls how FetchRemote downloads and caches files
+type FetchOptions struct {
+	// Dir is the directory downloaded files are cached in
+	Dir string
+
+	// Ext is the extension of the cached files
+	Ext string
+
+	// Offline uses cached files only, without network access
+	Offline bool
+
+	// AllowedHosts are the hosts files may be downloaded from without a
+	// pinned checksum
+	AllowedHosts []string
+
+	client  *http.Client // defaults to remoteClient
+	maxSize int64        // defaults to maxRemoteSize
+}
+
+// fetchRemote returns the path of a cached copy of the plugin at rawURL,
+// downloading it if needed
+func (l *Loader) fetchRemote(ctx context.Context, rawURL string) (string, error) {
+	return FetchRemote(ctx, rawURL, FetchOptions{
+		Dir:          l.cacheDir,
+		Ext:          ".so",
+		Offline:      l.offline,
+		AllowedHosts: l.allowedHosts,
+		client:       l.client,
+	})
+}
+
+// FetchRemote returns the path of a cached copy of the file at the https URL
+// rawURL, downloading it if needed. Files are stored by their SHA-256
+// checksum. A checksum pinned with a #sha256=<hex> fragment is verified and
+// lets a matching cached copy be used without a download. Unpinned files
+// must come from one of the allowed hosts and are downloaded again unless
+// offline is set.
+func FetchRemote(ctx context.Context, rawURL string, opts FetchOptions) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+	if u.Scheme != "https" {
+		return "", fmt.Errorf("%w: %s", ErrInsecureURL, rawURL)
+	}
+	var pinned string
+	if u.Fragment != "" {
+		if !strings.HasPrefix(u.Fragment, checksumFragment) {
+			return "", fmt.Errorf("invalid URL fragment %q: expected %s<hex>", u.Fragment, checksumFragment)
+		}
+		pinned = strings.ToLower(strings.TrimPrefix(u.Fragment, checksumFragment))
+		u.Fragment = ""
+	}
+	source := u.String()
+	if pinned == "" && !isHostAllowed(u, opts.AllowedHosts) {
+		return "", fmt.Errorf("%w: %s", ErrUnpinnedRemote, source)
+	}
+	cache := remoteCache{
+		dir:     opts.Dir,
+		ext:     opts.Ext,
+		client:  cmp.Or(opts.client, remoteClient),
+		maxSize: cmp.Or(opts.maxSize, maxRemoteSize),
+	}
+
+	// Prefer the cache: pinned checksums identify the file directly, otherwise
+	// the index records the last download of this URL when offline.
+	sum := pinned
+	if sum == "" && opts.Offline {
+		sum = cache.readIndex(source)
+	}
+	if sum != "" {
+		cached := cache.path(sum)
+		if err := verifyChecksum(cached, sum); err == nil {
+			return cached, nil
+		}
+	}
+	if opts.Offline {
+		return "", fmt.Errorf("%w: %s", ErrNotCached, source)
+	}
+
+	return cache.download(ctx, source, pinned)
+}
+
+// isHostAllowed reports whether u's host, with or without its port, is one
+// of hosts
+func isHostAllowed(u *url.URL, hosts []string) bool {
+	return slices.ContainsFunc(hosts, func(host string) bool {
+		return strings.EqualFold(host, u.Host) || strings.EqualFold(host, u.Hostname())
+	})
+}
+
+// remoteCache stores downloaded files in a directory by their checksum
+type remoteCache struct {
+	dir     string
+	ext     string
+	client  *http.Client
+	maxSize int64
+}
+
+func (c remoteCache) download(ctx context.Context, source, pinned string) (string, error) {
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create download cache: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", source, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download %s: %s", source, resp.Status)
+	}
+
+	tmp, err := os.CreateTemp(c.dir, "download-*"+c.ext)
+	if err != nil {
+		return "", fmt.Errorf("failed to create download cache file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(resp.Body, c.maxSize+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && n > c.maxSize {
+		err = fmt.Errorf("%w: over %d bytes", ErrRemoteTooLarge, c.maxSize)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", source, err)
+	}
+
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if pinned != "" && sum != pinned {
+		return "", fmt.Errorf("%w: %s: expected %s, got %s", ErrChecksumMismatch, source, pinned, sum)
+	}
+
+	cached := c.path(sum)
+	if err := os.Rename(tmp.Name(), cached); err != nil {
+		return "", fmt.Errorf("failed to store download in cache: %w", err)
+	}
+	c.writeIndex(source, sum)
+	return cached, nil
+}
+
+func (c remoteCache) path(sum string) string {
+	return filepath.Join(c.dir, sum+c.ext)
+}
+
+func (c remoteCache) indexPath(source string) string {
+	key := sha256.Sum256([]byte(source))
+	return filepath.Join(c.dir, "index", hex.EncodeToString(key[:]))
+}
+
+// readIndex returns the checksum of the last download of source
+func (c remoteCache) readIndex(source string) string {
+	data, err := os.ReadFile(c.indexPath(source))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// writeIndex records the checksum of the last download of source. The index
+// only speeds up offline loading, so failures are ignored.
+func (c remoteCache) writeIndex(source, sum string) {
+	path := c.indexPath(source)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return
+	}
+	_ = os.WriteFile(path, []byte(sum), 0o644)
+}
+
+// verifyChecksum returns an error unless the file at path has the given
+// SHA-256 checksum
+func verifyChecksum(path, sum string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != sum {
+		return fmt.Errorf("%w: expected %s, got %s", ErrChecksumMismatch, sum, got)
+	}
+	return nil
+}
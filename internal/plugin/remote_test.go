@@ -0,0 +1,115 @@
+package plugin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchRemote(t *testing.T) {
+	t.Parallel()
+
+	content := []byte("not really a plugin")
+	digest := sha256.Sum256(content)
+	sum := hex.EncodeToString(digest[:])
+
+	var downloads atomic.Int32
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads.Add(1)
+		_, _ = w.Write(content)
+	}))
+	t.Cleanup(srv.Close)
+	pluginURL := srv.URL + "/metrics.so"
+	host := strings.TrimPrefix(srv.URL, "https://")
+	newLoader := func(opts ...LoaderOption) *Loader {
+		l := NewLoader(NewRegistry(), opts...)
+		l.client = srv.Client()
+		return l
+	}
+
+	t.Run("pinned checksum is cached", func(t *testing.T) {
+		t.Parallel()
+		l := newLoader(WithCacheDir(t.TempDir()))
+
+		path, err := l.fetchRemote(t.Context(), pluginURL+"#sha256="+sum)
+		require.NoError(t, err)
+		got, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, content, got)
+
+		before := downloads.Load()
+		cached, err := l.fetchRemote(t.Context(), pluginURL+"#sha256="+sum)
+		require.NoError(t, err)
+		require.Equal(t, path, cached)
+		require.Equal(t, before, downloads.Load(), "cached plugin must not be downloaded again")
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		l := newLoader(WithCacheDir(dir))
+
+		_, err := l.fetchRemote(t.Context(), pluginURL+"#sha256="+hex.EncodeToString(make([]byte, 32)))
+		require.ErrorIs(t, err, ErrChecksumMismatch)
+	})
+
+	t.Run("offline uses cache", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+
+		_, err := newLoader(WithCacheDir(dir), WithAllowedHosts(host), WithOffline(true)).fetchRemote(t.Context(), pluginURL)
+		require.ErrorIs(t, err, ErrNotCached)
+
+		path, err := newLoader(WithCacheDir(dir), WithAllowedHosts(host)).fetchRemote(t.Context(), pluginURL)
+		require.NoError(t, err)
+
+		before := downloads.Load()
+		cached, err := newLoader(WithCacheDir(dir), WithAllowedHosts(host), WithOffline(true)).fetchRemote(t.Context(), pluginURL)
+		require.NoError(t, err)
+		require.Equal(t, path, cached)
+		require.Equal(t, before, downloads.Load())
+	})
+
+	t.Run("unpinned plugins need an allowed host", func(t *testing.T) {
+		t.Parallel()
+		before := downloads.Load()
+
+		_, err := newLoader(WithCacheDir(t.TempDir()), WithAllowedHosts("plugins.example.com")).fetchRemote(t.Context(), pluginURL)
+		require.ErrorIs(t, err, ErrUnpinnedRemote)
+		require.Equal(t, before, downloads.Load(), "unpinned plugins must be refused before downloading")
+
+		// The port may be left out of the allowed host
+		_, err = newLoader(WithCacheDir(t.TempDir()), WithAllowedHosts("127.0.0.1")).fetchRemote(t.Context(), pluginURL)
+		require.NoError(t, err)
+	})
+
+	t.Run("plain http is refused", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := newLoader(WithCacheDir(t.TempDir()), WithAllowedHosts(host)).fetchRemote(t.Context(), "http://"+host+"/metrics.so#sha256="+sum)
+		require.ErrorIs(t, err, ErrInsecureURL)
+	})
+
+	t.Run("download size is limited", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+
+		_, err := FetchRemote(t.Context(), pluginURL, FetchOptions{
+			Dir:          dir,
+			AllowedHosts: []string{host},
+			client:       srv.Client(),
+			maxSize:      int64(len(content) - 1),
+		})
+		require.ErrorIs(t, err, ErrRemoteTooLarge)
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		require.Empty(t, entries, "partial downloads must not be cached")
+	})
+}
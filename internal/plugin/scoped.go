@@ -0,0 +1,62 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+
+	"charm.land/fantasy"
+)
+
+// scopedToolHook forwards tool executions to a ToolHook only for tools whose
+// name matches one of its patterns
+type scopedToolHook struct {
+	patterns []string
+	inner    ToolHook
+}
+
+// ScopedToolHook returns a ToolHook that calls inner only for tools whose name
+// matches one of tools. Entries may be glob patterns as accepted by
+// path.Match, e.g. "mcp_*"; invalid patterns match nothing. Executions of
+// other tools are left unmodified. OnToolsAssemble is always forwarded since
+// it receives the whole tool list.
+func ScopedToolHook(tools []string, inner ToolHook) ToolHook {
+	return &scopedToolHook{patterns: tools, inner: inner}
+}
+
+// matches reports whether the tool name matches one of the patterns
+func (h *scopedToolHook) matches(name string) bool {
+	for _, pattern := range h.patterns {
+		if ok, err := path.Match(pattern, name); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *scopedToolHook) OnToolExecuteBefore(ctx context.Context, input ToolExecuteInput) (map[string]any, error) {
+	if !h.matches(input.ToolName) {
+		return nil, nil
+	}
+	return h.inner.OnToolExecuteBefore(ctx, input)
+}
+
+// OnToolExecuteBeforeRaw implements RawToolHook if inner does
+func (h *scopedToolHook) OnToolExecuteBeforeRaw(ctx context.Context, input ToolExecuteInput) (json.RawMessage, error) {
+	raw, ok := h.inner.(RawToolHook)
+	if !ok || !h.matches(input.ToolName) {
+		return nil, nil
+	}
+	return raw.OnToolExecuteBeforeRaw(ctx, input)
+}
+
+func (h *scopedToolHook) OnToolExecuteAfter(ctx context.Context, input ToolExecuteInput, result ToolExecuteResult) (*ToolExecuteResult, error) {
+	if !h.matches(input.ToolName) {
+		return nil, nil
+	}
+	return h.inner.OnToolExecuteAfter(ctx, input, result)
+}
+
+func (h *scopedToolHook) OnToolsAssemble(ctx context.Context, tools []fantasy.ToolInfo) ([]fantasy.ToolInfo, error) {
+	return h.inner.OnToolsAssemble(ctx, tools)
+}
@@ -0,0 +1,49 @@
+package plugin
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScopedToolHook(t *testing.T) {
+	t.Parallel()
+
+	inner := &argsToolHook{
+		args: map[string]any{"command": "ls"},
+		raw:  json.RawMessage(`{"command":"ls"}`),
+	}
+	hook := ScopedToolHook([]string{"bash", "mcp_*", "[bad"}, inner)
+
+	for _, name := range []string{"bash", "mcp_github_search"} {
+		args, err := hook.OnToolExecuteBefore(t.Context(), ToolExecuteInput{ToolName: name})
+		require.NoError(t, err)
+		require.Equal(t, inner.args, args, name)
+
+		raw, err := hook.(RawToolHook).OnToolExecuteBeforeRaw(t.Context(), ToolExecuteInput{ToolName: name})
+		require.NoError(t, err)
+		require.Equal(t, inner.raw, raw, name)
+	}
+	require.Len(t, inner.seen, 2)
+
+	for _, name := range []string{"view", "bash_extra", "[bad"} {
+		args, err := hook.OnToolExecuteBefore(t.Context(), ToolExecuteInput{ToolName: name})
+		require.NoError(t, err)
+		require.Nil(t, args, name)
+
+		raw, err := hook.(RawToolHook).OnToolExecuteBeforeRaw(t.Context(), ToolExecuteInput{ToolName: name})
+		require.NoError(t, err)
+		require.Nil(t, raw, name)
+
+		result, err := hook.OnToolExecuteAfter(t.Context(), ToolExecuteInput{ToolName: name}, ToolExecuteResult{})
+		require.NoError(t, err)
+		require.Nil(t, result, name)
+	}
+	require.Len(t, inner.seen, 2, "non-matching tools must not reach the inner hook")
+
+	// Without a raw inner hook, the raw method is a no-op
+	raw, err := ScopedToolHook([]string{"bash"}, NilToolHook{}).(RawToolHook).OnToolExecuteBeforeRaw(t.Context(), ToolExecuteInput{ToolName: "bash"})
+	require.NoError(t, err)
+	require.Nil(t, raw)
+}
@@ -2,8 +2,17 @@ package plugin
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"regexp"
+	"time"
 
 	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/agent/tools"
+	"github.com/charmbracelet/crush/internal/permission"
 )
 
 // ToolProvider is an interface that plugins can implement to provide custom tools
@@ -18,14 +27,115 @@ type PluginTool interface {
 	// Info returns metadata about the tool
 	Info() fantasy.ToolInfo
 
-	// Run executes the tool with the given parameters
+	// Run executes the tool with the given parameters. ctx is canceled when
+	// the user cancels the agent run; long-running tools must watch
+	// ctx.Done() and return promptly.
 	Run(ctx context.Context, params fantasy.ToolCall) (fantasy.ToolResponse, error)
 }
 
+// StreamingPluginTool is an optional interface a PluginTool can implement to
+// report output while it runs, e.g. the lines of a build it tails. When it is
+// implemented RunStream is called instead of Run.
+//
+// Each emitted partial is appended to the output the UI shows for the tool
+// call; only the final response is returned to the model. emit never blocks
+// on the UI: output emitted faster than it is displayed is coalesced, and
+// only the most recent output is kept. emit is safe for concurrent use. It
+// returns ctx's error once the run is canceled, so tools can stop, and
+// ErrToolStreamClosed once RunStream has returned.
+type StreamingPluginTool interface {
+	PluginTool
+
+	// RunStream executes the tool like Run, calling emit with partial
+	// output as it becomes available
+	RunStream(ctx context.Context, params fantasy.ToolCall, emit func(partial string) error) (fantasy.ToolResponse, error)
+}
+
+// TimedTool is an optional interface a PluginTool can implement to limit how
+// long a run may take. Once the timeout passes the run's ctx is canceled and
+// the model is told the tool timed out, without waiting for the tool to
+// return.
+type TimedTool interface {
+	// Timeout returns the longest a run may take. Zero means no limit.
+	Timeout() time.Duration
+}
+
+// AnnotatedTool is an optional interface a PluginTool can implement to expose
+// structured annotations (e.g. category or icon) that the UI can use to group
+// and filter tools.
+type AnnotatedTool interface {
+	// Annotations returns key/value annotations for the tool
+	Annotations() map[string]string
+}
+
+// ToolAnnotations returns the annotations of a tool, or nil if the tool does
+// not provide any.
+func ToolAnnotations(tool any) map[string]string {
+	if adapter, ok := tool.(*pluginToolAdapter); ok {
+		tool = adapter.tool
+	}
+	if annotated, ok := tool.(AnnotatedTool); ok {
+		return annotated.Annotations()
+	}
+	return nil
+}
+
+// ToolPermission describes the permission a tool must be granted before it
+// runs. It is turned into a permission.CreatePermissionRequest.
+type ToolPermission struct {
+	// Action is the request's Action, e.g. "execute" or "write"
+	Action string
+
+	// Description is the request's Description. Defaults to the tool's
+	// description.
+	Description string
+
+	// Path is the request's Path. {name} placeholders are replaced with the
+	// tool input parameter of the same name, e.g. "{file_path}". Relative
+	// paths are resolved against the working directory, which is also used
+	// when Path is empty.
+	Path string
+}
+
+// PermissionedTool is an optional interface a PluginTool can implement to
+// require permission before each run. Tools that return nil run without
+// prompting.
+type PermissionedTool interface {
+	// Permission returns the permission the tool requires, or nil
+	Permission() *ToolPermission
+}
+
+var pathPlaceholder = regexp.MustCompile(`\{(\w+)\}`)
+
+// resolvePath expands the path placeholders with the tool's input
+func (p ToolPermission) resolvePath(input map[string]any, workingDir string) string {
+	path := pathPlaceholder.ReplaceAllStringFunc(p.Path, func(match string) string {
+		value, ok := input[match[1:len(match)-1]]
+		if !ok {
+			return ""
+		}
+		return fmt.Sprint(value)
+	})
+	if path == "" {
+		return workingDir
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(workingDir, path)
+	}
+	return path
+}
+
 // pluginToolAdapter adapts a PluginTool to the fantasy.AgentTool interface
 type pluginToolAdapter struct {
 	tool            PluginTool
 	providerOptions fantasy.ProviderOptions
+	permissions     permission.Service
+	services        Services
+	workingDir      string
+
+	// owner is the plugin that provides the tool, tracked by registry
+	owner    string
+	registry *Registry
 }
 
 // NewAgentTool wraps a PluginTool to make it compatible with fantasy.AgentTool
@@ -41,9 +151,122 @@ func (a *pluginToolAdapter) Info() fantasy.ToolInfo {
 }
 
 func (a *pluginToolAdapter) Run(ctx context.Context, params fantasy.ToolCall) (fantasy.ToolResponse, error) {
+	// Don't prompt for or start a tool whose run was already canceled
+	if err := ctx.Err(); err != nil {
+		return fantasy.ToolResponse{}, err
+	}
+	// The model may still call a tool offered before its plugin was unloaded
+	if a.registry != nil {
+		if _, loaded := a.registry.GetPlugin(a.owner); !loaded {
+			return fantasy.NewTextErrorResponse(fmt.Sprintf("tool %s is no longer available: plugin %s was unloaded", a.tool.Info().Name, a.owner)), nil
+		}
+	}
+	if err := a.requestPermission(ctx, params); err != nil {
+		return fantasy.ToolResponse{}, err
+	}
+	ctx = withToolCall(ctx, a.tool.Info().Name)
+	if a.registry != nil {
+		ctx = withServices(ctx, a.services)
+	}
+	if timed, ok := a.tool.(TimedTool); ok {
+		if timeout := timed.Timeout(); timeout > 0 {
+			return a.runWithTimeout(ctx, params, timeout)
+		}
+	}
+	return a.run(ctx, params)
+}
+
+// run runs the tool. A panic fails the call instead of crashing Crush and
+// counts towards the plugin's quarantine, like a panic in a hook.
+func (a *pluginToolAdapter) run(ctx context.Context, params fantasy.ToolCall) (resp fantasy.ToolResponse, err error) {
+	if panicErr := a.registry.guard(a.owner, func() error {
+		resp, err = a.runTool(ctx, params)
+		return nil
+	}); panicErr != nil {
+		resp = fantasy.NewTextErrorResponse(fmt.Sprintf("tool %s failed: %v", a.tool.Info().Name, panicErr))
+		return fantasy.WithResponseMetadata(resp, map[string]any{MetadataErrorKind: ToolErrorExecution}), nil
+	}
+	return resp, err
+}
+
+func (a *pluginToolAdapter) runTool(ctx context.Context, params fantasy.ToolCall) (fantasy.ToolResponse, error) {
+	if streaming, ok := a.tool.(StreamingPluginTool); ok {
+		stream := a.newToolStream(ctx, params)
+		defer stream.close()
+		return streaming.RunStream(ctx, params, stream.emit)
+	}
 	return a.tool.Run(ctx, params)
 }
 
+// runWithTimeout runs the tool, returning a timeout error response if it
+// takes longer than timeout. A tool that ignores its canceled ctx is left
+// running in the background.
+func (a *pluginToolAdapter) runWithTimeout(ctx context.Context, params fantasy.ToolCall, timeout time.Duration) (fantasy.ToolResponse, error) {
+	runCtx, cancel := context.WithTimeoutCause(ctx, timeout, ErrToolTimeout)
+	defer cancel()
+
+	type outcome struct {
+		resp fantasy.ToolResponse
+		err  error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		resp, err := a.run(runCtx, params)
+		done <- outcome{resp, err}
+	}()
+
+	select {
+	case out := <-done:
+		if ctx.Err() != nil || !errors.Is(context.Cause(runCtx), ErrToolTimeout) {
+			return out.resp, out.err
+		}
+	case <-runCtx.Done():
+		if err := ctx.Err(); err != nil {
+			return fantasy.ToolResponse{}, err
+		}
+	}
+	slog.Warn("Plugin tool timed out", "plugin", a.owner, "tool", params.Name, "timeout", timeout)
+	resp := fantasy.NewTextErrorResponse(fmt.Sprintf("tool %s timed out after %s", a.tool.Info().Name, timeout))
+	return fantasy.WithResponseMetadata(resp, map[string]any{MetadataErrorKind: ToolErrorTimeout}), nil
+}
+
+// requestPermission asks the permission service for the permission the tool
+// declares, if any
+func (a *pluginToolAdapter) requestPermission(ctx context.Context, params fantasy.ToolCall) error {
+	permissioned, ok := a.tool.(PermissionedTool)
+	if !ok || a.permissions == nil {
+		return nil
+	}
+	perm := permissioned.Permission()
+	if perm == nil {
+		return nil
+	}
+
+	var input map[string]any
+	if params.Input != "" {
+		_ = json.Unmarshal([]byte(params.Input), &input)
+	}
+	info := a.tool.Info()
+	description := perm.Description
+	if description == "" {
+		description = info.Description
+	}
+
+	granted := a.permissions.Request(permission.CreatePermissionRequest{
+		SessionID:   tools.GetSessionFromContext(ctx),
+		ToolCallID:  params.ID,
+		ToolName:    info.Name,
+		Description: description,
+		Action:      perm.Action,
+		Params:      input,
+		Path:        perm.resolvePath(input, a.workingDir),
+	})
+	if !granted {
+		return permission.ErrorPermissionDenied
+	}
+	return nil
+}
+
 func (a *pluginToolAdapter) ProviderOptions() fantasy.ProviderOptions {
 	return a.providerOptions
 }
@@ -56,11 +279,20 @@ func (a *pluginToolAdapter) SetProviderOptions(opts fantasy.ProviderOptions) {
 func (r *Registry) GetPluginTools() []fantasy.AgentTool {
 	var tools []fantasy.AgentTool
 
-	for _, plugin := range r.plugins.Seq2() {
+	for name, plugin := range r.plugins.Seq2() {
 		// Check if plugin implements ToolProvider
 		if toolProvider, ok := plugin.(ToolProvider); ok {
+			pluginCtx, _ := r.contexts.Get(name)
 			for _, pluginTool := range toolProvider.GetTools() {
-				tools = append(tools, NewAgentTool(pluginTool))
+				tools = append(tools, &pluginToolAdapter{
+					tool:            pluginTool,
+					providerOptions: make(fantasy.ProviderOptions),
+					permissions:     pluginCtx.Services.Permission,
+					services:        pluginCtx.Services,
+					workingDir:      pluginCtx.WorkingDir,
+					owner:           name,
+					registry:        r,
+				})
 			}
 		}
 	}
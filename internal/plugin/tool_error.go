@@ -0,0 +1,44 @@
+package plugin
+
+import "errors"
+
+// ToolErrorKind classifies why a tool execution failed
+type ToolErrorKind string
+
+const (
+	// ToolErrorPermission means the user denied the tool permission to run
+	ToolErrorPermission ToolErrorKind = "permission"
+
+	// ToolErrorInvalidArgs means the tool's input could not be used
+	ToolErrorInvalidArgs ToolErrorKind = "invalid_args"
+
+	// ToolErrorTimeout means the tool took longer than it was allowed to
+	ToolErrorTimeout ToolErrorKind = "timeout"
+
+	// ToolErrorExecution means the tool ran and failed
+	ToolErrorExecution ToolErrorKind = "execution"
+
+	// ToolErrorCancelled means the agent run was canceled while the tool ran
+	ToolErrorCancelled ToolErrorKind = "cancelled"
+)
+
+// ToolError is the ToolExecuteResult.Error of a tool that returned an
+// error, classifying the failure. Err is the tool's error.
+type ToolError struct {
+	Kind ToolErrorKind
+	Err  error
+}
+
+func (e *ToolError) Error() string { return e.Err.Error() }
+
+func (e *ToolError) Unwrap() error { return e.Err }
+
+// ToolErrorKindOf returns the kind of a tool execution error, or "" if err
+// isn't a ToolError
+func ToolErrorKindOf(err error) ToolErrorKind {
+	var toolErr *ToolError
+	if errors.As(err, &toolErr) {
+		return toolErr.Kind
+	}
+	return ""
+}
@@ -0,0 +1,120 @@
+package plugin
+
+import (
+	"context"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/agent/tools"
+	"github.com/charmbracelet/crush/internal/pubsub"
+)
+
+const (
+	// toolProgressInterval is the least time between progress updates of a
+	// streaming tool; partials emitted in between are coalesced
+	toolProgressInterval = 100 * time.Millisecond
+
+	// maxToolProgress limits the output kept for a streaming tool's
+	// progress; older output is dropped
+	maxToolProgress = 64 << 10
+)
+
+// toolStream collects the partial output of a StreamingPluginTool and
+// publishes it as ToolProgress
+type toolStream struct {
+	ctx      context.Context
+	progress ToolProgress
+	registry *Registry // nil if progress isn't published
+
+	mu      sync.Mutex
+	output  []byte
+	last    time.Time   // when progress was last published
+	flush   *time.Timer // publishes coalesced output, if pending
+	changed bool        // output changed since progress was published
+	closed  bool
+}
+
+func (a *pluginToolAdapter) newToolStream(ctx context.Context, params fantasy.ToolCall) *toolStream {
+	return &toolStream{
+		ctx: ctx,
+		progress: ToolProgress{
+			SessionID:  tools.GetSessionFromContext(ctx),
+			ToolCallID: params.ID,
+			ToolName:   params.Name,
+		},
+		registry: a.registry,
+	}
+}
+
+// emit appends partial to the output, publishing it unless progress was
+// published less than toolProgressInterval ago, in which case it is
+// published once the interval has passed
+func (s *toolStream) emit(partial string) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrToolStreamClosed
+	}
+
+	s.output = append(s.output, partial...)
+	if excess := len(s.output) - maxToolProgress; excess > 0 {
+		s.output = append(s.output[:0], s.output[excess:]...)
+		for len(s.output) > 0 && !utf8.RuneStart(s.output[0]) {
+			s.output = s.output[1:]
+		}
+	}
+	s.changed = true
+
+	if wait := toolProgressInterval - time.Since(s.last); wait > 0 {
+		if s.flush == nil {
+			s.flush = time.AfterFunc(wait, func() {
+				s.mu.Lock()
+				defer s.mu.Unlock()
+				s.flush = nil
+				if !s.closed {
+					s.publishLocked()
+				}
+			})
+		}
+		return nil
+	}
+	s.publishLocked()
+	return nil
+}
+
+// close publishes any coalesced output and makes further emits fail
+func (s *toolStream) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.flush != nil {
+		s.flush.Stop()
+		s.flush = nil
+	}
+	if s.ctx.Err() == nil {
+		s.publishLocked()
+	}
+	s.closed = true
+}
+
+// publishLocked publishes the output if it changed. The caller must hold
+// s.mu.
+func (s *toolStream) publishLocked() {
+	if !s.changed {
+		return
+	}
+	s.changed = false
+	s.last = time.Now()
+	if s.registry == nil {
+		return
+	}
+	progress := s.progress
+	progress.Output = string(s.output)
+	s.registry.progress.Publish(pubsub.UpdatedEvent, progress)
+}
@@ -0,0 +1,318 @@
+package plugin
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/agent/tools"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingPermissions struct {
+	permission.Service
+	grant    bool
+	requests []permission.CreatePermissionRequest
+}
+
+func (p *recordingPermissions) Request(opts permission.CreatePermissionRequest) bool {
+	p.requests = append(p.requests, opts)
+	return p.grant
+}
+
+type permissionedTool struct {
+	permission *ToolPermission
+	runs       int
+}
+
+func (t *permissionedTool) Info() fantasy.ToolInfo {
+	return fantasy.ToolInfo{Name: "deploy", Description: "Deploys a service"}
+}
+
+func (t *permissionedTool) Run(ctx context.Context, params fantasy.ToolCall) (fantasy.ToolResponse, error) {
+	t.runs++
+	return fantasy.NewTextResponse("deployed"), nil
+}
+
+func (t *permissionedTool) Permission() *ToolPermission { return t.permission }
+
+func TestPluginToolPermission(t *testing.T) {
+	t.Parallel()
+
+	workingDir := t.TempDir()
+	ctx := context.WithValue(context.Background(), tools.SessionIDContextKey, "session-1")
+	call := fantasy.ToolCall{ID: "call-1", Name: "deploy", Input: `{"service":"api"}`}
+
+	t.Run("granted", func(t *testing.T) {
+		t.Parallel()
+
+		perms := &recordingPermissions{grant: true}
+		tool := &permissionedTool{permission: &ToolPermission{Action: "execute", Path: "deploy/{service}"}}
+		adapter := &pluginToolAdapter{tool: tool, permissions: perms, workingDir: workingDir}
+
+		resp, err := adapter.Run(ctx, call)
+		require.NoError(t, err)
+		require.Equal(t, "deployed", resp.Content)
+		require.Equal(t, 1, tool.runs)
+		require.Len(t, perms.requests, 1)
+
+		req := perms.requests[0]
+		require.Equal(t, "session-1", req.SessionID)
+		require.Equal(t, "call-1", req.ToolCallID)
+		require.Equal(t, "deploy", req.ToolName)
+		require.Equal(t, "execute", req.Action)
+		require.Equal(t, "Deploys a service", req.Description)
+		require.Equal(t, filepath.Join(workingDir, "deploy", "api"), req.Path)
+		require.Equal(t, map[string]any{"service": "api"}, req.Params)
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		t.Parallel()
+
+		perms := &recordingPermissions{grant: false}
+		tool := &permissionedTool{permission: &ToolPermission{Action: "execute"}}
+		adapter := &pluginToolAdapter{tool: tool, permissions: perms, workingDir: workingDir}
+
+		_, err := adapter.Run(ctx, call)
+		require.ErrorIs(t, err, permission.ErrorPermissionDenied)
+		require.Zero(t, tool.runs)
+		require.Equal(t, workingDir, perms.requests[0].Path)
+	})
+
+	t.Run("no descriptor", func(t *testing.T) {
+		t.Parallel()
+
+		perms := &recordingPermissions{grant: false}
+		tool := &permissionedTool{}
+		adapter := &pluginToolAdapter{tool: tool, permissions: perms, workingDir: workingDir}
+
+		_, err := adapter.Run(ctx, call)
+		require.NoError(t, err)
+		require.Equal(t, 1, tool.runs)
+		require.Empty(t, perms.requests)
+	})
+}
+
+type blockingTool struct {
+	started chan struct{}
+}
+
+func (t *blockingTool) Info() fantasy.ToolInfo { return fantasy.ToolInfo{Name: "block"} }
+
+func (t *blockingTool) Run(ctx context.Context, params fantasy.ToolCall) (fantasy.ToolResponse, error) {
+	close(t.started)
+	<-ctx.Done()
+	return fantasy.ToolResponse{}, ctx.Err()
+}
+
+type blockingToolPlugin struct {
+	flakyPlugin
+	tool *blockingTool
+}
+
+func (p *blockingToolPlugin) GetTools() []PluginTool { return []PluginTool{p.tool} }
+
+func TestPluginToolCancellation(t *testing.T) {
+	t.Parallel()
+
+	registry := NewRegistry()
+	tool := &blockingTool{started: make(chan struct{})}
+	require.NoError(t, registry.LoadPlugin(t.Context(), &blockingToolPlugin{
+		flakyPlugin: flakyPlugin{name: "blocker"},
+		tool:        tool,
+	}, PluginContext{}))
+
+	agentTools := registry.GetPluginTools()
+	require.Len(t, agentTools, 1)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() {
+		_, err := agentTools[0].Run(ctx, fantasy.ToolCall{ID: "call-1", Name: "block"})
+		done <- err
+	}()
+
+	<-tool.started
+	cancel()
+
+	select {
+	case err := <-done:
+		require.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("tool did not return after cancellation")
+	}
+
+	_, err := agentTools[0].Run(ctx, fantasy.ToolCall{ID: "call-2", Name: "block"})
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestPluginToolAfterUnload(t *testing.T) {
+	t.Parallel()
+
+	registry := NewRegistry()
+	tool := &permissionedTool{}
+	require.NoError(t, registry.LoadPlugin(t.Context(), &deployPlugin{
+		flakyPlugin: flakyPlugin{name: "deployer"},
+		tool:        tool,
+	}, PluginContext{}))
+
+	agentTools := registry.GetPluginTools()
+	require.Len(t, agentTools, 1)
+	require.NoError(t, registry.UnloadPlugin(t.Context(), "deployer"))
+	require.Empty(t, registry.GetPluginTools())
+
+	// A stale reference to the tool fails cleanly without running it
+	resp, err := agentTools[0].Run(t.Context(), fantasy.ToolCall{ID: "call-1", Name: "deploy"})
+	require.NoError(t, err)
+	require.True(t, resp.IsError)
+	require.Contains(t, resp.Content, "no longer available")
+	require.Zero(t, tool.runs)
+}
+
+type deployPlugin struct {
+	flakyPlugin
+	tool *permissionedTool
+}
+
+func (p *deployPlugin) GetTools() []PluginTool { return []PluginTool{p.tool} }
+
+// streamingTool emits its lines as partial output
+type streamingTool struct {
+	lines []string
+	emit  func(string) error
+}
+
+func (t *streamingTool) Info() fantasy.ToolInfo { return fantasy.ToolInfo{Name: "tail"} }
+
+func (t *streamingTool) Run(ctx context.Context, params fantasy.ToolCall) (fantasy.ToolResponse, error) {
+	return fantasy.NewTextErrorResponse("Run must not be called"), nil
+}
+
+func (t *streamingTool) RunStream(ctx context.Context, params fantasy.ToolCall, emit func(string) error) (fantasy.ToolResponse, error) {
+	t.emit = emit
+	for _, line := range t.lines {
+		if err := emit(line + "\n"); err != nil {
+			return fantasy.ToolResponse{}, err
+		}
+	}
+	return fantasy.NewTextResponse("build passed"), nil
+}
+
+type streamingToolPlugin struct {
+	flakyPlugin
+	tool *streamingTool
+}
+
+func (p *streamingToolPlugin) GetTools() []PluginTool { return []PluginTool{p.tool} }
+
+func TestStreamingPluginTool(t *testing.T) {
+	t.Parallel()
+
+	registry := NewRegistry()
+	tool := &streamingTool{lines: []string{"compiling", "linking"}}
+	require.NoError(t, registry.LoadPlugin(t.Context(), &streamingToolPlugin{
+		flakyPlugin: flakyPlugin{name: "builder"},
+		tool:        tool,
+	}, PluginContext{}))
+	progress := registry.SubscribeToolProgress(t.Context())
+
+	ctx := context.WithValue(t.Context(), tools.SessionIDContextKey, "session-1")
+	resp, err := registry.GetPluginTools()[0].Run(ctx, fantasy.ToolCall{ID: "call-1", Name: "tail"})
+	require.NoError(t, err)
+	require.Equal(t, "build passed", resp.Content)
+
+	// The first partial is published at once and the second, coalesced with
+	// it, when the tool finishes
+	first := <-progress
+	require.Equal(t, ToolProgress{SessionID: "session-1", ToolCallID: "call-1", ToolName: "tail", Output: "compiling\n"}, first.Payload)
+	last := <-progress
+	require.Equal(t, "compiling\nlinking\n", last.Payload.Output)
+
+	require.ErrorIs(t, tool.emit("late"), ErrToolStreamClosed)
+
+	canceled, cancel := context.WithCancel(t.Context())
+	stream := (&pluginToolAdapter{}).newToolStream(canceled, fantasy.ToolCall{ID: "call-2"})
+	cancel()
+	require.ErrorIs(t, stream.emit("ignored"), context.Canceled)
+}
+
+// sleepingTool ignores its ctx and sleeps until released
+type sleepingTool struct {
+	timeout time.Duration
+	release chan struct{}
+}
+
+func (t *sleepingTool) Info() fantasy.ToolInfo { return fantasy.ToolInfo{Name: "fetch"} }
+
+func (t *sleepingTool) Timeout() time.Duration { return t.timeout }
+
+func (t *sleepingTool) Run(ctx context.Context, params fantasy.ToolCall) (fantasy.ToolResponse, error) {
+	<-t.release
+	return fantasy.NewTextResponse("fetched"), nil
+}
+
+func TestTimedPluginTool(t *testing.T) {
+	t.Parallel()
+
+	tool := &sleepingTool{timeout: 20 * time.Millisecond, release: make(chan struct{})}
+	t.Cleanup(func() { close(tool.release) })
+
+	start := time.Now()
+	resp, err := NewAgentTool(tool).Run(t.Context(), fantasy.ToolCall{ID: "call-1", Name: "fetch"})
+	require.NoError(t, err)
+	require.True(t, resp.IsError)
+	require.Equal(t, "tool fetch timed out after 20ms", resp.Content)
+	require.Less(t, time.Since(start), 5*time.Second)
+
+	// Tools that finish in time, or declare no timeout, are unaffected
+	for _, timeout := range []time.Duration{time.Minute, 0} {
+		quick := &sleepingTool{timeout: timeout, release: make(chan struct{})}
+		close(quick.release)
+		resp, err := NewAgentTool(quick).Run(t.Context(), fantasy.ToolCall{ID: "call-2", Name: "fetch"})
+		require.NoError(t, err)
+		require.False(t, resp.IsError)
+		require.Equal(t, "fetched", resp.Content)
+	}
+}
+
+// panickingTool panics whenever it runs
+type panickingTool struct{}
+
+func (panickingTool) Info() fantasy.ToolInfo { return fantasy.ToolInfo{Name: "crash"} }
+
+func (panickingTool) Run(ctx context.Context, params fantasy.ToolCall) (fantasy.ToolResponse, error) {
+	panic("corrupted state")
+}
+
+func (panickingTool) Timeout() time.Duration { return time.Minute }
+
+type panickingToolPlugin struct{ flakyPlugin }
+
+func (p *panickingToolPlugin) GetTools() []PluginTool { return []PluginTool{panickingTool{}} }
+
+func TestPluginToolPanicRecovered(t *testing.T) {
+	t.Parallel()
+
+	registry := NewRegistry()
+	registry.SetQuarantinePolicy(QuarantinePolicy{Threshold: 2})
+	require.NoError(t, registry.LoadPlugin(t.Context(), &panickingToolPlugin{flakyPlugin{name: "crasher"}}, PluginContext{}))
+
+	agentTools := registry.GetPluginTools()
+	require.Len(t, agentTools, 1)
+
+	// The panic happens in the goroutine running the timed tool, and still
+	// only fails the call
+	resp, err := agentTools[0].Run(t.Context(), fantasy.ToolCall{ID: "call-1", Name: "crash"})
+	require.NoError(t, err)
+	require.True(t, resp.IsError)
+	require.Equal(t, "tool crash failed: plugin panicked: corrupted state", resp.Content)
+	require.Equal(t, 1, registry.DescribePlugins()[0].Panics)
+
+	// Tool panics count towards the quarantine
+	_, err = agentTools[0].Run(t.Context(), fantasy.ToolCall{ID: "call-2", Name: "crash"})
+	require.NoError(t, err)
+	require.True(t, registry.IsQuarantined("crasher"))
+}
@@ -0,0 +1,139 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/pubsub"
+)
+
+// TopicPublished is the event type of events published on plugin topics
+const TopicPublished pubsub.EventType = "published"
+
+// topicBufferSize is the number of topic events buffered per subscription;
+// events for slow subscribers beyond it are dropped
+const topicBufferSize = 64
+
+var topicNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// TopicEvent is an event a plugin published on one of its topics
+type TopicEvent struct {
+	// Topic is the full name of the topic, "<plugin>/<name>"
+	Topic string
+
+	// Plugin is the name of the plugin that published the event
+	Plugin string
+
+	// Payload is the published value. It is shared by all subscribers, so
+	// it must not be modified.
+	Payload any
+}
+
+// TopicBus is an event bus plugins use to talk to each other and to the
+// TUI. A plugin publishes only on its own topics, which are namespaced by
+// its name, and may subscribe to the topics of any plugin.
+type TopicBus interface {
+	// Publish publishes payload on the plugin's topic name, whose full
+	// name is "<plugin>/<name>". Names are lowercase alphanumeric with
+	// dots, hyphens, or underscores.
+	Publish(name string, payload any) error
+
+	// Subscribe returns the events published on topic, a full topic name.
+	// A topic ending in "*" matches all topics starting with the rest,
+	// e.g. "metrics/*". The channel is closed when ctx is done or the
+	// subscribing plugin is unloaded.
+	Subscribe(ctx context.Context, topic string) <-chan TopicEvent
+}
+
+// TopicName returns the full name of a plugin's topic
+func TopicName(plugin, name string) string {
+	return plugin + "/" + name
+}
+
+// topicBus is the TopicBus of a loaded plugin. Its context is canceled when
+// the plugin is unloaded, ending its subscriptions.
+type topicBus struct {
+	registry *Registry
+	plugin   string
+	ctx      context.Context
+}
+
+func (b *topicBus) Publish(name string, payload any) error {
+	if !topicNamePattern.MatchString(name) {
+		return &PluginError{Name: b.plugin, Err: fmt.Errorf("%w: %q", ErrInvalidTopic, name)}
+	}
+	if b.ctx.Err() != nil {
+		return &PluginError{Name: b.plugin, Err: ErrPluginNotLoaded}
+	}
+	b.registry.topics.Publish(TopicPublished, TopicEvent{
+		Topic:   TopicName(b.plugin, name),
+		Plugin:  b.plugin,
+		Payload: payload,
+	})
+	return nil
+}
+
+func (b *topicBus) Subscribe(ctx context.Context, topic string) <-chan TopicEvent {
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(b.ctx, cancel)
+	return b.registry.subscribeTopic(ctx, topic, func() {
+		stop()
+		cancel()
+	})
+}
+
+// openTopics returns the TopicBus of the named plugin, which is closed by
+// closeTopics
+func (r *Registry) openTopics(name string) *topicBus {
+	ctx, cancel := context.WithCancel(context.Background())
+	if previous, ok := r.topicScopes.Take(name); ok {
+		previous()
+	}
+	r.topicScopes.Set(name, cancel)
+	return &topicBus{registry: r, plugin: name, ctx: ctx}
+}
+
+// closeTopics ends the subscriptions of the named plugin and stops it from
+// publishing
+func (r *Registry) closeTopics(name string) {
+	if cancel, ok := r.topicScopes.Take(name); ok {
+		cancel()
+	}
+}
+
+// SubscribeTopic returns the events plugins publish on topic, like
+// TopicBus.Subscribe. The channel is closed when ctx is done or the registry
+// shuts down.
+func (r *Registry) SubscribeTopic(ctx context.Context, topic string) <-chan TopicEvent {
+	return r.subscribeTopic(ctx, topic, func() {})
+}
+
+// subscribeTopic forwards the events on topic until ctx is done, then calls
+// done and closes the returned channel
+func (r *Registry) subscribeTopic(ctx context.Context, topic string, done func()) <-chan TopicEvent {
+	prefix, wildcard := strings.CutSuffix(topic, "*")
+	events := r.topics.Subscribe(ctx)
+	out := make(chan TopicEvent, topicBufferSize)
+	go func() {
+		defer close(out)
+		defer done()
+		for event := range events {
+			matches := event.Payload.Topic == topic
+			if wildcard {
+				matches = strings.HasPrefix(event.Payload.Topic, prefix)
+			}
+			if !matches {
+				continue
+			}
+			select {
+			case out <- event.Payload:
+			default:
+				// Drop events for slow subscribers rather than blocking
+				// the bus
+			}
+		}
+	}()
+	return out
+}
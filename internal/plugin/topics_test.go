@@ -0,0 +1,69 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type topicPlugin struct {
+	flakyPlugin
+	topics TopicBus
+}
+
+func (p *topicPlugin) Init(ctx context.Context, pluginCtx PluginContext) error {
+	p.topics = pluginCtx.Topics
+	return nil
+}
+
+func receiveTopicEvent(t *testing.T, events <-chan TopicEvent) TopicEvent {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for a topic event")
+		return TopicEvent{}
+	}
+}
+
+func TestPluginTopics(t *testing.T) {
+	t.Parallel()
+
+	r := NewRegistry()
+	metrics := &topicPlugin{flakyPlugin: flakyPlugin{name: "metrics"}}
+	dashboard := &topicPlugin{flakyPlugin: flakyPlugin{name: "dashboard"}}
+	require.NoError(t, r.LoadPlugin(t.Context(), metrics, PluginContext{}))
+	require.NoError(t, r.LoadPlugin(t.Context(), dashboard, PluginContext{}))
+
+	all := dashboard.topics.Subscribe(t.Context(), "metrics/*")
+	snapshots := r.SubscribeTopic(t.Context(), TopicName("metrics", "snapshot"))
+
+	require.NoError(t, metrics.topics.Publish("reset", nil))
+	require.NoError(t, metrics.topics.Publish("snapshot", 42))
+	require.NoError(t, dashboard.topics.Publish("snapshot", "not metrics"))
+
+	require.Equal(t, TopicEvent{Topic: "metrics/reset", Plugin: "metrics"}, receiveTopicEvent(t, all))
+	require.Equal(t, TopicEvent{Topic: "metrics/snapshot", Plugin: "metrics", Payload: 42}, receiveTopicEvent(t, all))
+	require.Equal(t, TopicEvent{Topic: "metrics/snapshot", Plugin: "metrics", Payload: 42}, receiveTopicEvent(t, snapshots))
+	select {
+	case event := <-snapshots:
+		t.Fatalf("unexpected event on another topic: %+v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	err := metrics.topics.Publish("Snapshot/../x", nil)
+	require.ErrorIs(t, err, ErrInvalidTopic)
+
+	// Unloading a plugin ends its subscriptions and its publishing
+	require.NoError(t, r.UnloadPlugin(t.Context(), "dashboard"))
+	select {
+	case _, ok := <-all:
+		require.False(t, ok, "subscriptions of unloaded plugins must be closed")
+	case <-time.After(time.Second):
+		t.Fatal("subscription was not closed")
+	}
+	require.ErrorIs(t, dashboard.topics.Publish("snapshot", nil), ErrPluginNotLoaded)
+}
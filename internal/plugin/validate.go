@@ -0,0 +1,80 @@
+package plugin
+
+import (
+	"context"
+
+	"github.com/charmbracelet/crush/internal/config"
+)
+
+// Kinds of validated items
+const (
+	KindPlugin = "plugin"
+	KindSkill  = "skill"
+)
+
+// ValidationResult is the outcome of validating a single plugin or skill
+type ValidationResult struct {
+	// Kind is KindPlugin or KindSkill
+	Kind string `json:"kind"`
+
+	// Path is the configured plugin path or the SKILL.md file
+	Path string `json:"path"`
+
+	// Name is the plugin or skill name, if it could be determined
+	Name string `json:"name,omitempty"`
+
+	// Error describes why the item is invalid; it is empty if the item is OK
+	Error string `json:"error,omitempty"`
+}
+
+// OK reports whether the item passed validation
+func (r ValidationResult) OK() bool {
+	return r.Error == ""
+}
+
+// ValidatePath checks that the plugin at path can be loaded: the file is
+// found and allowed, its manifest and SDK version check out, and it exports
+// a Plugin symbol that isn't denied. The plugin is neither initialized nor
+// registered, although opening it runs its package initializers.
+func (l *Loader) ValidatePath(ctx context.Context, path string) ValidationResult {
+	return l.ValidateEntry(ctx, config.PluginEntry{Path: path})
+}
+
+// ValidateEntry validates the plugin described by a configuration entry like
+// ValidatePath, also checking its kind, checksum, and symbol
+func (l *Loader) ValidateEntry(ctx context.Context, entry config.PluginEntry) ValidationResult {
+	result := ValidationResult{Kind: KindPlugin, Path: entry.Path}
+
+	pluginPath, err := l.resolveEntry(ctx, entry)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	p, err := l.openGoPlugin(pluginPath, entry.GetSymbol())
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Name = p.Info().Name
+	return result
+}
+
+// ValidateConfig validates every plugin the configuration would load,
+// including each plugin in the configured plugin directories
+func (l *Loader) ValidateConfig(ctx context.Context, cfg *config.Config) []ValidationResult {
+	var results []ValidationResult
+	for _, entry := range cfg.GetPluginEntries() {
+		results = append(results, l.ValidateEntry(ctx, entry))
+	}
+	for _, dir := range cfg.GetPluginDirs() {
+		paths, err := l.pluginsInDir(dir)
+		if err != nil {
+			results = append(results, ValidationResult{Kind: KindPlugin, Path: dir, Error: err.Error()})
+			continue
+		}
+		for _, path := range paths {
+			results = append(results, l.ValidatePath(ctx, path))
+		}
+	}
+	return results
+}
@@ -0,0 +1,210 @@
+// Package policy implements a built-in plugin that allows or denies
+// permission requests based on rules declared in the configuration.
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/plugin"
+)
+
+// Decision is the outcome of a policy rule
+type Decision string
+
+const (
+	Allow  Decision = "allow"
+	Deny   Decision = "deny"
+	Prompt Decision = "prompt"
+)
+
+// ErrInvalidRule is returned when the configured policy can't be parsed
+var ErrInvalidRule = errors.New("invalid permission policy rule")
+
+// rule is a validated config.PermissionRule
+type rule struct {
+	tool     string
+	action   string
+	path     string
+	decision Decision
+}
+
+// Plugin implements the Crush plugin interface for permission policies
+type Plugin struct {
+	info       plugin.PluginInfo
+	hooks      *plugin.BaseHooks
+	rules      []rule
+	fallback   Decision
+	workingDir string
+}
+
+// NewPlugin validates the policy and returns a plugin that evaluates it.
+func NewPlugin(policy config.PermissionPolicy) (*Plugin, error) {
+	fallback := Prompt
+	if policy.Default != "" {
+		var err error
+		if fallback, err = parseDecision(policy.Default); err != nil {
+			return nil, fmt.Errorf("%w: default: %v", ErrInvalidRule, err)
+		}
+	}
+
+	rules := make([]rule, 0, len(policy.Rules))
+	for i, r := range policy.Rules {
+		parsed, err := parseRule(r)
+		if err != nil {
+			return nil, fmt.Errorf("%w: rule %d: %v", ErrInvalidRule, i+1, err)
+		}
+		rules = append(rules, parsed)
+	}
+
+	p := &Plugin{
+		info: plugin.PluginInfo{
+			Name:        "crush-permission-policy",
+			Version:     "1.0.0",
+			Description: "Allows or denies permission requests using configured rules",
+			Author:      "Crush Team",
+			Homepage:    "https://github.com/charmbracelet/crush",
+			License:     "FSL-1.1-MIT",
+			Tags:        []string{"permissions", "builtin"},
+		},
+		hooks:    plugin.NewBaseHooks(),
+		rules:    rules,
+		fallback: fallback,
+	}
+	p.hooks.PermissionHook = p
+	return p, nil
+}
+
+func parseDecision(s string) (Decision, error) {
+	switch d := Decision(strings.ToLower(s)); d {
+	case Allow, Deny, Prompt:
+		return d, nil
+	default:
+		return "", fmt.Errorf("unknown decision %q (must be allow, deny, or prompt)", s)
+	}
+}
+
+func parseRule(r config.PermissionRule) (rule, error) {
+	decision, err := parseDecision(r.Decision)
+	if err != nil {
+		return rule{}, err
+	}
+	if r.Tool != "" {
+		if _, err := path.Match(r.Tool, ""); err != nil {
+			return rule{}, fmt.Errorf("invalid tool pattern %q: %w", r.Tool, err)
+		}
+	}
+	rulePath := r.Path
+	if rulePath != "" {
+		if rulePath, err = plugin.ExpandPath(rulePath); err != nil {
+			return rule{}, fmt.Errorf("invalid path %q: %w", r.Path, err)
+		}
+		rulePath = filepath.Clean(rulePath)
+	}
+	return rule{
+		tool:     r.Tool,
+		action:   strings.ToLower(r.Action),
+		path:     rulePath,
+		decision: decision,
+	}, nil
+}
+
+// Info returns metadata about the plugin
+func (p *Plugin) Info() plugin.PluginInfo {
+	return p.info
+}
+
+// Init is called when the plugin is loaded
+func (p *Plugin) Init(ctx context.Context, pluginCtx plugin.PluginContext) error {
+	p.workingDir = pluginCtx.WorkingDir
+	return nil
+}
+
+// Hooks returns the hook implementations provided by this plugin
+func (p *Plugin) Hooks() plugin.Hooks {
+	return p.hooks
+}
+
+// Shutdown is called when the application is shutting down
+func (p *Plugin) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// OnPermissionRequest implements plugin.PermissionHook
+func (p *Plugin) OnPermissionRequest(ctx context.Context, req permission.CreatePermissionRequest) (*bool, error) {
+	return p.Evaluate(req).result(), nil
+}
+
+// Evaluate returns the policy's decision for a request. A matching deny rule
+// overrides any allow; otherwise the first matching rule wins, falling back
+// to the default.
+func (p *Plugin) Evaluate(req permission.CreatePermissionRequest) Decision {
+	decision := p.fallback
+	matched := false
+	for _, r := range p.rules {
+		if !p.matches(r, req) {
+			continue
+		}
+		if r.decision == Deny {
+			return Deny
+		}
+		if !matched {
+			decision = r.decision
+			matched = true
+		}
+	}
+	return decision
+}
+
+func (p *Plugin) matches(r rule, req permission.CreatePermissionRequest) bool {
+	if r.tool != "" {
+		if ok, _ := path.Match(r.tool, req.ToolName); !ok {
+			return false
+		}
+	}
+	if r.action != "" && !strings.Contains(strings.ToLower(req.Action), r.action) {
+		return false
+	}
+	if r.path != "" && !p.underPath(r.path, req.Path) {
+		return false
+	}
+	return true
+}
+
+// underPath reports whether target is prefix or inside it. Relative paths
+// are resolved against the working directory.
+func (p *Plugin) underPath(prefix, target string) bool {
+	if target == "" {
+		return false
+	}
+	if !filepath.IsAbs(prefix) {
+		prefix = filepath.Join(p.workingDir, prefix)
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(p.workingDir, target)
+	}
+	rel, err := filepath.Rel(prefix, target)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func (d Decision) result() *bool {
+	switch d {
+	case Allow:
+		allow := true
+		return &allow
+	case Deny:
+		deny := false
+		return &deny
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,69 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/plugin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluate(t *testing.T) {
+	t.Parallel()
+
+	p, err := NewPlugin(config.PermissionPolicy{
+		Default: "deny",
+		Rules: []config.PermissionRule{
+			{Tool: "view", Decision: "allow"},
+			{Tool: "mcp_*", Action: "read", Decision: "allow"},
+			{Path: "/repo/secrets", Decision: "deny"},
+			{Tool: "bash", Decision: "prompt"},
+			{Path: "/repo", Decision: "allow"},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, p.Init(t.Context(), plugin.PluginContext{WorkingDir: "/repo"}))
+
+	tests := []struct {
+		name string
+		req  permission.CreatePermissionRequest
+		want Decision
+	}{
+		{"first match allows", permission.CreatePermissionRequest{ToolName: "view", Path: "/etc"}, Allow},
+		{"glob and action", permission.CreatePermissionRequest{ToolName: "mcp_fs", Action: "Read file"}, Allow},
+		{"action mismatch falls to default", permission.CreatePermissionRequest{ToolName: "mcp_fs", Action: "write"}, Deny},
+		{"deny overrides earlier allow", permission.CreatePermissionRequest{ToolName: "view", Path: "/repo/secrets/key"}, Deny},
+		{"relative path resolved", permission.CreatePermissionRequest{ToolName: "edit", Path: "secrets/key"}, Deny},
+		{"prompt", permission.CreatePermissionRequest{ToolName: "bash", Path: "/repo"}, Prompt},
+		{"path prefix", permission.CreatePermissionRequest{ToolName: "edit", Path: "/repo/main.go"}, Allow},
+		{"sibling is not a prefix match", permission.CreatePermissionRequest{ToolName: "edit", Path: "/repository/main.go"}, Deny},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, p.Evaluate(tt.req))
+		})
+	}
+
+	decision, err := p.OnPermissionRequest(t.Context(), permission.CreatePermissionRequest{ToolName: "bash"})
+	require.NoError(t, err)
+	require.Nil(t, decision, "prompt must leave the decision to the user")
+}
+
+func TestNewPluginValidation(t *testing.T) {
+	t.Parallel()
+
+	for name, policy := range map[string]config.PermissionPolicy{
+		"unknown decision": {Rules: []config.PermissionRule{{Tool: "view", Decision: "maybe"}}},
+		"missing decision": {Rules: []config.PermissionRule{{Tool: "view"}}},
+		"bad glob":         {Rules: []config.PermissionRule{{Tool: "[view", Decision: "allow"}}},
+		"bad default":      {Default: "sometimes"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			_, err := NewPlugin(policy)
+			require.ErrorIs(t, err, ErrInvalidRule)
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package skills
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/plugin"
+)
+
+// maxBundleSize limits the total size of the files extracted from a bundle
+const maxBundleSize = 64 << 20
+
+// Archive formats of skill bundles, by file extension
+var bundleFormats = []string{".tar.gz", ".tgz", ".zip"}
+
+// defaultBundleDir returns the directory skill bundles are extracted to
+func defaultBundleDir() string {
+	return filepath.Join(filepath.Dir(config.GlobalConfig()), "skills", "bundles")
+}
+
+// extractBundles extracts each bundle to a directory under dir and returns
+// those directories. Bundles that fail to extract are reported as
+// diagnostics.
+func extractBundles(ctx context.Context, bundles []config.SkillBundle, dir string) ([]string, []Diagnostic) {
+	var dirs []string
+	var diagnostics []Diagnostic
+	for _, bundle := range bundles {
+		extracted, err := extractBundle(ctx, bundle, dir)
+		if err != nil {
+			diagnostics = append(diagnostics, Diagnostic{Path: bundle.Path, Reason: err.Error()})
+			continue
+		}
+		dirs = append(dirs, extracted)
+	}
+	return dirs, diagnostics
+}
+
+// extractBundle extracts bundle to a directory under dir named after the
+// archive's checksum, so an archive is only extracted again when it changes
+func extractBundle(ctx context.Context, bundle config.SkillBundle, dir string) (string, error) {
+	sum := strings.ToLower(bundle.SHA256)
+	format, err := bundleFormat(bundle.Path)
+	if err != nil {
+		return "", err
+	}
+
+	var archive string
+	if plugin.IsRemote(bundle.Path) {
+		// Downloads are only cached by their pinned checksum, so without one
+		// a bundle would be downloaded on every start
+		if sum == "" {
+			return "", errors.New("remote skill bundles must set sha256")
+		}
+		rawURL := bundle.Path
+		if !strings.Contains(rawURL, "#") {
+			rawURL += "#sha256=" + sum
+		}
+		archive, err = plugin.FetchRemote(ctx, rawURL, plugin.FetchOptions{Dir: filepath.Join(dir, "downloads"), Ext: format})
+		if err != nil {
+			return "", err
+		}
+	} else if archive, err = plugin.ExpandPath(bundle.Path); err != nil {
+		return "", err
+	}
+
+	got, err := fileChecksum(archive)
+	if err != nil {
+		return "", fmt.Errorf("failed to read skill bundle: %w", err)
+	}
+	if sum != "" && got != sum {
+		return "", fmt.Errorf("skill bundle checksum mismatch: expected %s, got %s", sum, got)
+	}
+
+	target := filepath.Join(dir, got)
+	if info, err := os.Stat(target); err == nil && info.IsDir() {
+		return target, nil
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create skill bundle directory: %w", err)
+	}
+	tmp, err := os.MkdirTemp(dir, "extract-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create skill bundle directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	if format == ".zip" {
+		err = extractZip(archive, tmp)
+	} else {
+		err = extractTarGz(archive, tmp)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to extract skill bundle: %w", err)
+	}
+
+	// Extractions are renamed into place once complete, so an existing
+	// directory is always a whole bundle
+	if err := os.Rename(tmp, target); err != nil {
+		if info, statErr := os.Stat(target); statErr == nil && info.IsDir() {
+			return target, nil
+		}
+		return "", fmt.Errorf("failed to store skill bundle: %w", err)
+	}
+	return target, nil
+}
+
+// bundleFormat returns the archive extension of path, which may be a URL
+func bundleFormat(path string) (string, error) {
+	name := path
+	if plugin.IsRemote(path) {
+		if u, err := url.Parse(path); err == nil {
+			name = u.Path
+		}
+	}
+	for _, ext := range bundleFormats {
+		if strings.HasSuffix(strings.ToLower(name), ext) {
+			return ext, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported skill bundle format: expected one of %s", strings.Join(bundleFormats, ", "))
+}
+
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func extractTarGz(archive, dest string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	remaining := int64(maxBundleSize)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// Links could point outside the bundle, so only files and
+		// directories are extracted
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := extractDir(dest, header.Name); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := extractFile(dest, header.Name, tr, &remaining); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func extractZip(archive, dest string) error {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	remaining := int64(maxBundleSize)
+	for _, file := range zr.File {
+		switch mode := file.Mode(); {
+		case mode.IsDir():
+			if err := extractDir(dest, file.Name); err != nil {
+				return err
+			}
+		case mode.IsRegular():
+			rc, err := file.Open()
+			if err != nil {
+				return err
+			}
+			err = extractFile(dest, file.Name, rc, &remaining)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// bundlePath returns where the archive entry name is extracted to within
+// dest, refusing names that would end up outside it
+func bundlePath(dest, name string) (string, error) {
+	name = filepath.FromSlash(name)
+	if filepath.IsAbs(name) || !filepath.IsLocal(name) {
+		return "", fmt.Errorf("archive entry %q is outside the bundle", name)
+	}
+	return filepath.Join(dest, name), nil
+}
+
+func extractDir(dest, name string) error {
+	path, err := bundlePath(dest, name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(path, 0o755)
+}
+
+// extractFile writes r to the entry name within dest, counting its size
+// against remaining
+func extractFile(dest, name string, r io.Reader, remaining *int64) error {
+	path, err := bundlePath(dest, name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(f, io.LimitReader(r, *remaining+1))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if *remaining -= n; *remaining < 0 {
+		return fmt.Errorf("skill bundle is larger than %d bytes", maxBundleSize)
+	}
+	return nil
+}
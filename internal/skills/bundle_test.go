@@ -0,0 +1,95 @@
+package skills
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+const bundledSkill = "---\nname: bundled\ndescription: A skill shipped in a bundle\n---\n\n# Bundled\n"
+
+func writeTarGz(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+}
+
+func writeZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+}
+
+func TestSkillBundles(t *testing.T) {
+	t.Parallel()
+
+	src := t.TempDir()
+	cache := t.TempDir()
+	tarball := filepath.Join(src, "team.tar.gz")
+	writeTarGz(t, tarball, map[string]string{"skills/bundled/SKILL.md": bundledSkill})
+	zipped := filepath.Join(src, "team.zip")
+	writeZip(t, zipped, map[string]string{"bundled/SKILL.md": bundledSkill})
+	escaping := filepath.Join(src, "escape.tgz")
+	writeTarGz(t, escaping, map[string]string{"../outside/SKILL.md": bundledSkill})
+	sum, err := fileChecksum(tarball)
+	require.NoError(t, err)
+
+	dirs, diagnostics := extractBundles(t.Context(), []config.SkillBundle{
+		{Path: tarball, SHA256: sum},
+		{Path: zipped},
+		{Path: zipped, SHA256: "deadbeef"},
+		{Path: escaping},
+		{Path: filepath.Join(src, "team.rar")},
+		{Path: "https://example.com/team.tar.gz"},
+		{Path: "http://example.com/team.tar.gz", SHA256: sum},
+	}, cache)
+	require.Len(t, dirs, 2)
+	require.Len(t, diagnostics, 5)
+	require.Contains(t, diagnostics[0].Reason, "checksum mismatch")
+	require.Contains(t, diagnostics[1].Reason, "outside the bundle")
+	require.Contains(t, diagnostics[2].Reason, "unsupported skill bundle format")
+	require.Contains(t, diagnostics[3].Reason, "must set sha256")
+	require.Contains(t, diagnostics[4].Reason, "over https")
+	require.NoFileExists(t, filepath.Join(cache, "outside", "SKILL.md"))
+
+	for _, dir := range dirs {
+		skills, skillDiagnostics, err := discoverSkills([]string{dir}, skillFilter{}, DefaultMaxDepth, "", false)
+		require.NoError(t, err)
+		require.Empty(t, skillDiagnostics)
+		require.Equal(t, []string{"bundled"}, skillNames(skills))
+	}
+
+	// An unchanged archive isn't extracted again
+	marker := filepath.Join(dirs[0], "marker")
+	require.NoError(t, os.WriteFile(marker, nil, 0o644))
+	again, diagnostics := extractBundles(t.Context(), []config.SkillBundle{{Path: tarball}}, cache)
+	require.Empty(t, diagnostics)
+	require.Equal(t, dirs[:1], again)
+	require.FileExists(t, marker)
+}
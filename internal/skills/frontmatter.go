@@ -0,0 +1,113 @@
+package skills
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// unknownFieldPattern matches the errors yaml.v3 reports for unknown keys
+// when decoding with KnownFields
+var unknownFieldPattern = regexp.MustCompile(`^line (\d+): field (\S+) not found in type \S+$`)
+
+// frontmatterKeys are the keys known anywhere in the frontmatter, used to
+// suggest corrections for unknown keys
+var frontmatterKeys = append(yamlKeys(SkillFrontmatter{}), yamlKeys(SkillParameter{})...)
+
+// decodeFrontmatter parses a skill's YAML frontmatter. Unknown keys, which
+// are usually typos, are logged as warnings, or fail the skill if strict is
+// set.
+func decodeFrontmatter(skillPath, data string, strict bool) (SkillFrontmatter, error) {
+	var frontmatter SkillFrontmatter
+	if err := yaml.Unmarshal([]byte(data), &frontmatter); err != nil {
+		return frontmatter, fmt.Errorf("failed to parse frontmatter: %w", err)
+	}
+
+	problems := unknownKeys(data)
+	if len(problems) == 0 {
+		return frontmatter, nil
+	}
+	if strict {
+		return frontmatter, fmt.Errorf("invalid frontmatter: %s", strings.Join(problems, "; "))
+	}
+	for _, problem := range problems {
+		slog.Warn("Skill frontmatter has an unknown key", "path", skillPath, "problem", problem)
+	}
+	return frontmatter, nil
+}
+
+// unknownKeys describes each unknown key in the frontmatter, suggesting the
+// known key it is most likely a typo of
+func unknownKeys(data string) []string {
+	decoder := yaml.NewDecoder(strings.NewReader(data))
+	decoder.KnownFields(true)
+	var typeErr *yaml.TypeError
+	if err := decoder.Decode(&SkillFrontmatter{}); !errors.As(err, &typeErr) {
+		return nil
+	}
+
+	var problems []string
+	for _, msg := range typeErr.Errors {
+		match := unknownFieldPattern.FindStringSubmatch(msg)
+		if match == nil {
+			continue
+		}
+		problem := fmt.Sprintf("line %s: unknown key %q", match[1], match[2])
+		if suggestion := closestKey(match[2]); suggestion != "" {
+			problem += fmt.Sprintf(" (did you mean %q?)", suggestion)
+		}
+		problems = append(problems, problem)
+	}
+	return problems
+}
+
+// closestKey returns the known frontmatter key closest to key, or "" if
+// none is close enough to be a likely typo
+func closestKey(key string) string {
+	best, bestDistance := "", len(key)/2+1
+	for _, known := range frontmatterKeys {
+		if d := editDistance(strings.ToLower(key), known); d < bestDistance {
+			best, bestDistance = known, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// yamlKeys returns the YAML keys of a struct's fields
+func yamlKeys(v any) []string {
+	var keys []string
+	t := reflect.TypeOf(v)
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name != "" && name != "-" {
+			keys = append(keys, name)
+		}
+	}
+	return keys
+}
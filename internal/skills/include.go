@@ -0,0 +1,73 @@
+package skills
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/fsext"
+)
+
+// maxIncludeDepth limits how deeply includes may be nested
+const maxIncludeDepth = 8
+
+// includeDirective matches {{include "path"}} in skill content
+var includeDirective = regexp.MustCompile(`\{\{\s*include\s+"([^"]+)"\s*\}\}`)
+
+// ErrInvalidInclude is returned when a skill's include directive can't be
+// resolved.
+var ErrInvalidInclude = errors.New("invalid include")
+
+// resolveIncludes inlines {{include "path"}} directives in content. Paths are
+// relative to the directory of the including file and must stay under one
+// of roots. stack holds the files currently being included, to detect cycles.
+func resolveIncludes(content, dir string, roots, stack []string) (string, error) {
+	var resolveErr error
+	resolved := includeDirective.ReplaceAllStringFunc(content, func(match string) string {
+		if resolveErr != nil {
+			return match
+		}
+		target := includeDirective.FindStringSubmatch(match)[1]
+		included, err := readInclude(target, dir, roots, stack)
+		if err != nil {
+			resolveErr = err
+			return match
+		}
+		return strings.TrimRight(included, "\n")
+	})
+	return resolved, resolveErr
+}
+
+func readInclude(target, dir string, roots, stack []string) (string, error) {
+	path := target
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	path = resolveSymlinks(path)
+
+	if !slices.ContainsFunc(roots, func(root string) bool {
+		return fsext.HasPrefix(path, resolveSymlinks(root))
+	}) {
+		return "", fmt.Errorf("%w: %s is outside the skill directories", ErrInvalidInclude, target)
+	}
+	if slices.Contains(stack, path) {
+		return "", fmt.Errorf("%w: %s includes itself", ErrInvalidInclude, target)
+	}
+	if len(stack) > maxIncludeDepth {
+		return "", fmt.Errorf("%w: %s exceeds the maximum include depth of %d", ErrInvalidInclude, target, maxIncludeDepth)
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%w: %s does not exist", ErrInvalidInclude, target)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to read %s: %v", ErrInvalidInclude, target, err)
+	}
+
+	return resolveIncludes(string(content), filepath.Dir(path), roots, append(slices.Clip(stack), path))
+}
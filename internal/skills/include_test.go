@@ -0,0 +1,122 @@
+package skills
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeFiles writes files relative to dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+}
+
+func TestResolveIncludes(t *testing.T) {
+	t.Parallel()
+
+	t.Run("nested", func(t *testing.T) {
+		t.Parallel()
+
+		root := t.TempDir()
+		writeFiles(t, root, map[string]string{
+			"_shared/style.md": "Be concise.\n{{include \"tone.md\"}}\n",
+			"_shared/tone.md":  "Be friendly.\n",
+		})
+		dir := filepath.Join(root, "review")
+		resolved, err := resolveIncludes("# Review\n{{ include \"../_shared/style.md\" }}\nDone.", dir, []string{root}, nil)
+		require.NoError(t, err)
+		require.Equal(t, "# Review\nBe concise.\nBe friendly.\nDone.", resolved)
+	})
+
+	t.Run("repeated includes", func(t *testing.T) {
+		t.Parallel()
+
+		root := t.TempDir()
+		writeFiles(t, root, map[string]string{"note.md": "Note."})
+		resolved, err := resolveIncludes("{{include \"note.md\"}} {{include \"note.md\"}}", root, []string{root}, nil)
+		require.NoError(t, err)
+		require.Equal(t, "Note. Note.", resolved, "including a file twice isn't a cycle")
+	})
+
+	for name, tc := range map[string]struct {
+		files   map[string]string
+		content string
+		reason  string
+	}{
+		"cycle": {
+			files:   map[string]string{"a.md": "{{include \"b.md\"}}", "b.md": "{{include \"a.md\"}}"},
+			content: "{{include \"a.md\"}}",
+			reason:  "a.md includes itself",
+		},
+		"self": {
+			files:   map[string]string{"a.md": "{{include \"a.md\"}}"},
+			content: "{{include \"a.md\"}}",
+			reason:  "a.md includes itself",
+		},
+		"missing file": {
+			content: "{{include \"missing.md\"}}",
+			reason:  "missing.md does not exist",
+		},
+		"missing nested file": {
+			files:   map[string]string{"a.md": "{{include \"missing.md\"}}"},
+			content: "{{include \"a.md\"}}",
+			reason:  "missing.md does not exist",
+		},
+		"outside roots": {
+			content: "{{include \"../outside.md\"}}",
+			reason:  "../outside.md is outside the skill directories",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			root := filepath.Join(t.TempDir(), "skills")
+			writeFiles(t, root, tc.files)
+			writeFiles(t, filepath.Dir(root), map[string]string{"outside.md": "secret"})
+			_, err := resolveIncludes(tc.content, root, []string{root}, nil)
+			require.ErrorIs(t, err, ErrInvalidInclude)
+			require.ErrorContains(t, err, tc.reason)
+		})
+	}
+
+	t.Run("too deep", func(t *testing.T) {
+		t.Parallel()
+
+		root := t.TempDir()
+		files := map[string]string{}
+		for i := range maxIncludeDepth + 2 {
+			files[filepath.Join("level", strings.Repeat("n", i+1)+".md")] = "{{include \"" + strings.Repeat("n", i+2) + ".md\"}}"
+		}
+		writeFiles(t, root, files)
+		_, err := resolveIncludes("{{include \"level/n.md\"}}", root, []string{root}, nil)
+		require.ErrorIs(t, err, ErrInvalidInclude)
+		require.ErrorContains(t, err, "exceeds the maximum include depth")
+	})
+}
+
+func TestSkillIncludes(t *testing.T) {
+	t.Parallel()
+
+	base := filepath.Join(t.TempDir(), "skills")
+	writeFiles(t, base, map[string]string{
+		"_shared/style.md": "Be concise.",
+		"review/SKILL.md":  "---\nname: review\ndescription: Reviews code for correctness\n---\n\n{{include \"../_shared/style.md\"}}\n",
+		"broken/SKILL.md":  "---\nname: broken\ndescription: Includes its own SKILL.md file\n---\n\n{{include \"SKILL.md\"}}\n",
+	})
+
+	skill, err := parseSkillMD(filepath.Join(base, "review", "SKILL.md"), []string{base}, false)
+	require.NoError(t, err)
+	require.Contains(t, skill.Content, "Be concise.")
+
+	_, err = parseSkillMD(filepath.Join(base, "broken", "SKILL.md"), []string{base}, false)
+	require.ErrorIs(t, err, ErrInvalidInclude, "a skill including itself is a cycle")
+}
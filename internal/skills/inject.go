@@ -0,0 +1,76 @@
+package skills
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/plugin"
+)
+
+// DefaultInjectMaxBytes is the default budget for the content of
+// auto-injected skills
+const DefaultInjectMaxBytes = 16 * 1024
+
+// injectTruncatedNotice marks a skill whose content was cut to fit the budget
+const injectTruncatedNotice = "\n[truncated]"
+
+// promptInjector prepends the content of auto-injected skills to the system
+// prompt of every agent run
+type promptInjector struct {
+	plugin.NilAgentHook
+	content string
+}
+
+// OnSystemPrompt implements plugin.SystemPromptHook
+func (i *promptInjector) OnSystemPrompt(ctx context.Context, sessionID, prompt string) (string, error) {
+	return i.content + "\n\n" + prompt, nil
+}
+
+// injectMaxBytes returns the configured budget for auto-injected skills
+func injectMaxBytes(cfg *config.Config) int {
+	if cfg != nil && cfg.Options != nil && cfg.Options.SkillsInjectMaxBytes > 0 {
+		return cfg.Options.SkillsInjectMaxBytes
+	}
+	return DefaultInjectMaxBytes
+}
+
+// renderInjected formats the auto-injected skills for the system prompt, in
+// order, until maxBytes of content are used. The skill that crosses the
+// budget is truncated and the rest are left out. It returns an empty string
+// if no skill is auto-injected.
+func renderInjected(skills []Skill, maxBytes int) string {
+	var b strings.Builder
+	budget := maxBytes
+	for _, skill := range skills {
+		if !skill.AutoInject {
+			continue
+		}
+		if budget <= 0 {
+			slog.Warn("Auto-injected skills budget exhausted, skipping skill", "skill", skill.Name, "max_bytes", maxBytes)
+			continue
+		}
+		content := skill.Content
+		if len(content) > budget {
+			content = truncateContent(content, budget) + injectTruncatedNotice
+			slog.Warn("Auto-injected skill truncated", "skill", skill.Name, "max_bytes", maxBytes)
+		}
+		budget -= len(skill.Content)
+		fmt.Fprintf(&b, "<skill name=%q>\n%s\n</skill>\n", skill.Name, content)
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return "<auto_injected_skills>\n" + b.String() + "</auto_injected_skills>"
+}
+
+// truncateContent cuts s to at most n bytes without splitting a rune
+func truncateContent(s string, n int) string {
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
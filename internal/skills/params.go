@@ -0,0 +1,94 @@
+package skills
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/config"
+)
+
+// SkillParameter declares a parameter the model passes when invoking a skill
+type SkillParameter struct {
+	Description string `yaml:"description"`
+
+	// Type is the JSON schema type of the parameter: string (the default),
+	// number, integer, or boolean
+	Type string `yaml:"type,omitempty"`
+
+	// Required parameters must be passed, unless configured with a default
+	Required bool `yaml:"required,omitempty"`
+}
+
+// sectionParam is the built-in parameter used to read a section of a skill
+const sectionParam = "section"
+
+// paramNamePattern matches valid parameter names
+var paramNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+var paramTypes = []string{"string", "number", "integer", "boolean"}
+
+// validateParameters checks the parameters declared in a skill's frontmatter
+func validateParameters(params map[string]SkillParameter) error {
+	for name, param := range params {
+		if !paramNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid parameter name: %q", name)
+		}
+		if name == sectionParam {
+			return fmt.Errorf("parameter name %q is reserved", name)
+		}
+		if param.Type != "" && !slices.Contains(paramTypes, param.Type) {
+			return fmt.Errorf("parameter %q has invalid type %q (must be one of %s)", name, param.Type, strings.Join(paramTypes, ", "))
+		}
+	}
+	return nil
+}
+
+// skillDefaults returns the configured default parameters of skill. Keys
+// that the skill doesn't declare are dropped with a warning.
+func skillDefaults(skill Skill, configured config.SkillDefaults) map[string]any {
+	defaults := map[string]any{}
+	for key, value := range configured[skill.Name] {
+		if _, ok := skill.Parameters[key]; !ok {
+			slog.Warn("Ignoring default for undeclared skill parameter", "skill", skill.Name, "parameter", key)
+			continue
+		}
+		defaults[key] = value
+	}
+	return defaults
+}
+
+// mergeParams returns the defaults overridden by the parameters the model
+// passed
+func mergeParams(defaults, params map[string]any) map[string]any {
+	merged := maps.Clone(defaults)
+	if merged == nil {
+		merged = map[string]any{}
+	}
+	maps.Copy(merged, params)
+	return merged
+}
+
+// missingParams returns the required parameters absent from params, sorted
+func missingParams(declared map[string]SkillParameter, params map[string]any) []string {
+	var missing []string
+	for name, param := range declared {
+		if _, ok := params[name]; param.Required && !ok {
+			missing = append(missing, name)
+		}
+	}
+	slices.Sort(missing)
+	return missing
+}
+
+// formatParams lists params one per line, sorted by name
+func formatParams(params map[string]any) string {
+	var b strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(params)) {
+		fmt.Fprintf(&b, "- %s: %v\n", name, params[name])
+	}
+	return b.String()
+}
@@ -0,0 +1,121 @@
+package skills
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// skillsByName indexes skills by name. Of skills sharing a name, the first
+// is kept.
+func skillsByName(skills []Skill) map[string]Skill {
+	byName := make(map[string]Skill, len(skills))
+	for _, skill := range skills {
+		if _, ok := byName[skill.Name]; !ok {
+			byName[skill.Name] = skill
+		}
+	}
+	return byName
+}
+
+// resolveRequires returns the skills skill requires, directly or through the
+// skills it requires, nearest first. Each skill is listed once, so cycles
+// end, and skills that weren't found are left out.
+func resolveRequires(skill Skill, byName map[string]Skill) []Skill {
+	seen := map[string]bool{skill.Name: true}
+	var required []Skill
+	queue := skill.Requires
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		if dep, ok := byName[name]; ok {
+			required = append(required, dep)
+			queue = append(queue, dep.Requires...)
+		}
+	}
+	return required
+}
+
+// checkRequires warns about skills requiring skills that weren't found and
+// about dependency cycles. The skills are registered regardless.
+func checkRequires(byName map[string]Skill) {
+	for _, skill := range byName {
+		for _, name := range skill.Requires {
+			if _, ok := byName[name]; !ok {
+				slog.Warn("Skill requires a skill that was not found", "skill", skill.Name, "requires", name, "path", skill.Path)
+			}
+		}
+	}
+	if cycle := findRequiresCycle(byName); cycle != nil {
+		slog.Warn("Skills require each other in a cycle", "cycle", strings.Join(cycle, " -> "))
+	}
+}
+
+// findRequiresCycle returns the names along a dependency cycle, starting and
+// ending with the same skill, or nil if there is none
+func findRequiresCycle(byName map[string]Skill) []string {
+	const (
+		visiting = iota + 1
+		visited
+	)
+	state := make(map[string]int, len(byName))
+	var path []string
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		switch state[name] {
+		case visiting:
+			for i, n := range path {
+				if n == name {
+					return append(path[i:len(path):len(path)], name)
+				}
+			}
+		case visited:
+			return nil
+		}
+		skill, ok := byName[name]
+		if !ok {
+			return nil
+		}
+		state[name] = visiting
+		path = append(path, name)
+		for _, dep := range skill.Requires {
+			if cycle := visit(dep); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		return nil
+	}
+	for _, name := range slices.Sorted(maps.Keys(byName)) {
+		if cycle := visit(name); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// formatRequires describes the required skills for a launched skill. Skills
+// registered as tools are referenced so the model reads them only when
+// needed; hidden skills have no tool, so their content is inlined.
+func formatRequires(required []Skill) string {
+	var referenced, inlined strings.Builder
+	for _, skill := range required {
+		if skill.Hidden() {
+			fmt.Fprintf(&inlined, "Required skill %s:\n\n%s\n\n", skill.Name, skill.Content)
+			continue
+		}
+		fmt.Fprintf(&referenced, "- %s (call %s): %s\n", skill.Name, skill.ToolName, skill.Description)
+	}
+	var out string
+	if referenced.Len() > 0 {
+		out += "This skill builds on other skills; call their tools to read them when needed:\n" + referenced.String() + "\n"
+	}
+	return out + inlined.String()
+}
@@ -0,0 +1,137 @@
+package skills
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/crush/internal/agent/tools"
+	"github.com/charmbracelet/crush/internal/csync"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/plugin"
+)
+
+// ErrOutsideSandbox is returned when a file tool tries to access a path
+// outside the directories an active skill is confined to.
+var ErrOutsideSandbox = errors.New("path is outside the active skill's sandbox")
+
+// sandboxedTools maps the file tools confined by the sandbox to the argument
+// holding the path they access.
+var sandboxedTools = map[string]string{
+	tools.ViewToolName: "file_path",
+	tools.GlobToolName: "path",
+	tools.GrepToolName: "path",
+}
+
+// sandbox is a tool and message hook that, once a skill is invoked in a
+// session, confines the view, glob, and grep tools to the skill's directory
+// and the working directory until the next user message.
+type sandbox struct {
+	plugin.NilMessageHook
+	plugin.NilToolHook
+
+	workingDir string
+	skillDirs  map[string]string          // tool name -> skill directory
+	active     *csync.Map[string, string] // session ID -> active skill directory
+}
+
+func newSandbox(workingDir string, skills []Skill) *sandbox {
+	skillDirs := make(map[string]string, len(skills))
+	for _, skill := range skills {
+		skillDirs[skill.ToolName] = skill.FullPath
+	}
+	return &sandbox{
+		workingDir: workingDir,
+		skillDirs:  skillDirs,
+		active:     csync.NewMap[string, string](),
+	}
+}
+
+// tools returns the names of the tools the sandbox hooks into: the skill
+// tools activating it and the file tools it confines
+func (s *sandbox) tools() []string {
+	names := make([]string, 0, len(s.skillDirs)+len(sandboxedTools))
+	for name := range s.skillDirs {
+		names = append(names, name)
+	}
+	for name := range sandboxedTools {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// OnToolExecuteBefore activates a skill when its tool runs and denies file
+// tool calls that escape the active skill's sandbox.
+func (s *sandbox) OnToolExecuteBefore(ctx context.Context, input plugin.ToolExecuteInput) (map[string]any, error) {
+	if dir, ok := s.skillDirs[input.ToolName]; ok {
+		s.active.Set(input.SessionID, dir)
+		return nil, nil
+	}
+
+	skillDir, ok := s.active.Get(input.SessionID)
+	if !ok {
+		return nil, nil
+	}
+	key, ok := sandboxedTools[input.ToolName]
+	if !ok {
+		return nil, nil
+	}
+	path, _ := input.Arguments[key].(string)
+	if path == "" {
+		// The tools default to the working directory
+		return nil, nil
+	}
+
+	if !s.allowed(path, skillDir) {
+		return nil, fmt.Errorf("%w: %s may only access %s or %s, not %s",
+			ErrOutsideSandbox, input.ToolName, skillDir, s.workingDir, path)
+	}
+	return nil, nil
+}
+
+func (s *sandbox) OnToolExecuteAfter(ctx context.Context, input plugin.ToolExecuteInput, result plugin.ToolExecuteResult) (*plugin.ToolExecuteResult, error) {
+	return nil, nil
+}
+
+// OnMessageCreated deactivates the skill when the user sends a new prompt.
+func (s *sandbox) OnMessageCreated(ctx context.Context, msg message.Message) error {
+	if msg.Role == message.User {
+		s.active.Del(msg.SessionID)
+	}
+	return nil
+}
+
+// allowed reports whether path is inside skillDir or the working directory.
+// Relative paths are resolved against the working directory and symlinks are
+// followed where the path exists.
+func (s *sandbox) allowed(path, skillDir string) bool {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.workingDir, path)
+	}
+	path = resolveSymlinks(path)
+	for _, root := range []string{skillDir, s.workingDir} {
+		if root == "" {
+			continue
+		}
+		rel, err := filepath.Rel(resolveSymlinks(root), path)
+		if err != nil {
+			continue
+		}
+		if rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+func resolveSymlinks(path string) string {
+	path = filepath.Clean(path)
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return path
+}
@@ -0,0 +1,85 @@
+package skills
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/agent/tools"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/message"
+	"github.com/charmbracelet/crush/internal/plugin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSandbox(t *testing.T) {
+	t.Parallel()
+
+	workingDir := t.TempDir()
+	skillDir := filepath.Join(t.TempDir(), "skills", "pdf")
+	sb := newSandbox(workingDir, []Skill{{ToolName: "skills_pdf", FullPath: skillDir}})
+
+	view := func(path string) error {
+		_, err := sb.OnToolExecuteBefore(t.Context(), plugin.ToolExecuteInput{
+			ToolName:  "view",
+			SessionID: "s1",
+			Arguments: map[string]any{"file_path": path},
+		})
+		return err
+	}
+
+	// Nothing is confined until a skill is active
+	require.NoError(t, view("/etc/passwd"))
+
+	_, err := sb.OnToolExecuteBefore(t.Context(), plugin.ToolExecuteInput{ToolName: "skills_pdf", SessionID: "s1"})
+	require.NoError(t, err)
+
+	require.NoError(t, view(filepath.Join(skillDir, "reference.md")))
+	require.NoError(t, view("main.go"))
+	require.ErrorIs(t, view("/etc/passwd"), ErrOutsideSandbox)
+	require.ErrorIs(t, view(filepath.Join(skillDir, "..", "..", "secret")), ErrOutsideSandbox)
+	require.ErrorIs(t, view("../outside.txt"), ErrOutsideSandbox)
+
+	_, err = sb.OnToolExecuteBefore(t.Context(), plugin.ToolExecuteInput{
+		ToolName:  "grep",
+		SessionID: "s1",
+		Arguments: map[string]any{"pattern": "x"},
+	})
+	require.NoError(t, err, "grep without a path searches the working directory")
+
+	// Other sessions are unaffected
+	_, err = sb.OnToolExecuteBefore(t.Context(), plugin.ToolExecuteInput{
+		ToolName:  "view",
+		SessionID: "s2",
+		Arguments: map[string]any{"file_path": "/etc/passwd"},
+	})
+	require.NoError(t, err)
+
+	// A new user prompt deactivates the skill
+	require.NoError(t, sb.OnMessageCreated(t.Context(), message.Message{Role: message.User, SessionID: "s1"}))
+	require.NoError(t, view("/etc/passwd"))
+}
+
+func TestSandboxHookScope(t *testing.T) {
+	t.Parallel()
+
+	workingDir := t.TempDir()
+	writeSkill(t, filepath.Join(workingDir, ".crush", "skills"), "pdf", "")
+
+	p := NewPlugin()
+	cfg := &config.Config{Options: &config.Options{SandboxSkills: true}}
+	require.NoError(t, p.Init(t.Context(), plugin.PluginContext{WorkingDir: workingDir, Config: cfg}))
+	skillTool := p.GetTools()[0].Info().Name
+
+	sb := newSandbox(workingDir, p.skills)
+	require.Equal(t, []string{tools.GlobToolName, tools.GrepToolName, skillTool, tools.ViewToolName}, sb.tools())
+
+	hook := p.Hooks().Tool()
+	_, err := hook.OnToolExecuteBefore(t.Context(), plugin.ToolExecuteInput{ToolName: skillTool, SessionID: "s1"})
+	require.NoError(t, err)
+	_, err = hook.OnToolExecuteBefore(t.Context(), plugin.ToolExecuteInput{
+		ToolName:  tools.ViewToolName,
+		SessionID: "s1",
+		Arguments: map[string]any{"file_path": "/etc/passwd"},
+	})
+	require.ErrorIs(t, err, ErrOutsideSandbox)
+}
@@ -0,0 +1,94 @@
+package skills
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// headingPattern matches a Markdown ATX heading
+var headingPattern = regexp.MustCompile(`^(#{1,6})\s+(.+?)\s*#*\s*$`)
+
+// section is a heading of a skill's content along with its body
+type section struct {
+	Title string
+	Level int
+
+	// Body holds the heading line and everything up to the next heading of
+	// the same or a higher level, so it includes subsections
+	Body string
+}
+
+// splitSections splits content into its headings. intro holds any text
+// before the first heading. Headings inside fenced code blocks are ignored.
+func splitSections(content string) (intro string, sections []section) {
+	lines := strings.Split(content, "\n")
+
+	type heading struct {
+		title string
+		level int
+		line  int
+	}
+	var headings []heading
+	inFence := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+		if m := headingPattern.FindStringSubmatch(line); m != nil {
+			headings = append(headings, heading{title: m[2], level: len(m[1]), line: i})
+		}
+	}
+
+	if len(headings) == 0 {
+		return strings.TrimSpace(content), nil
+	}
+	intro = strings.TrimSpace(strings.Join(lines[:headings[0].line], "\n"))
+
+	for i, h := range headings {
+		end := len(lines)
+		for _, next := range headings[i+1:] {
+			if next.level <= h.level {
+				end = next.line
+				break
+			}
+		}
+		sections = append(sections, section{
+			Title: h.title,
+			Level: h.level,
+			Body:  strings.TrimSpace(strings.Join(lines[h.line:end], "\n")),
+		})
+	}
+	return intro, sections
+}
+
+// findSection returns the section whose title matches name, ignoring case
+// and any leading #
+func findSection(sections []section, name string) (section, bool) {
+	name = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(name), "#"))
+	for _, s := range sections {
+		if strings.EqualFold(s.Title, name) {
+			return s, true
+		}
+	}
+	return section{}, false
+}
+
+// tableOfContents lists the section titles, indented by heading level
+func tableOfContents(sections []section) string {
+	minLevel := sections[0].Level
+	for _, s := range sections {
+		minLevel = min(minLevel, s.Level)
+	}
+
+	var b strings.Builder
+	for _, s := range sections {
+		fmt.Fprintf(&b, "%s- %s\n", strings.Repeat("  ", s.Level-minLevel), s.Title)
+	}
+	return b.String()
+}
@@ -4,16 +4,26 @@
 package skills
 
 import (
+	"cmp"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 
 	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/plugin"
-	"gopkg.in/yaml.v3"
+	"github.com/charmbracelet/crush/internal/version"
 )
 
 // SkillFrontmatter represents the YAML frontmatter in SKILL.md files
@@ -23,8 +33,50 @@ type SkillFrontmatter struct {
 	License      string            `yaml:"license,omitempty"`
 	AllowedTools []string          `yaml:"allowed-tools,omitempty"`
 	Metadata     map[string]string `yaml:"metadata,omitempty"`
+
+	// Enabled and Disabled turn a skill off without deleting it. A skill is
+	// disabled when Enabled is false or Disabled is true.
+	Enabled  *bool `yaml:"enabled,omitempty"`
+	Disabled bool  `yaml:"disabled,omitempty"`
+
+	// MinCrushVersion is the oldest Crush version the skill supports. Older
+	// versions skip the skill.
+	MinCrushVersion string `yaml:"min-crush-version,omitempty"`
+
+	// Env lists environment variables the skill needs. The skill is skipped
+	// when any of them is unset or empty.
+	Env []string `yaml:"env,omitempty"`
+
+	// Parameters declares the parameters the model may pass to the skill
+	Parameters map[string]SkillParameter `yaml:"parameters,omitempty"`
+
+	// Requires names skills this skill builds on. They are listed when the
+	// skill is launched.
+	Requires []string `yaml:"requires,omitempty"`
+
+	// AutoInject prepends the skill's content to the system prompt of every
+	// agent run
+	AutoInject bool `yaml:"auto-inject,omitempty"`
 }
 
+// Well-known metadata keys that change how a skill is registered.
+const (
+	// MetadataCategory groups related skills; it is shown in the tool
+	// description and exposed as an annotation.
+	MetadataCategory = "category"
+
+	// MetadataIcon is a short icon (e.g. an emoji) the UI may display next to
+	// the skill. It is exposed as an annotation only.
+	MetadataIcon = "icon"
+
+	// MetadataHidden excludes the skill from registration when set to a true
+	// value (e.g. "true").
+	MetadataHidden = "hidden"
+)
+
+// annotationKeys are the metadata keys surfaced as tool annotations.
+var annotationKeys = []string{MetadataCategory, MetadataIcon}
+
 // Skill represents a parsed skill with its metadata and content
 type Skill struct {
 	Name         string
@@ -36,14 +88,48 @@ type Skill struct {
 	License      string
 	Content      string
 	Path         string
+	Disabled     bool
+	Env          []string
+	Parameters   map[string]SkillParameter
+	Requires     []string
+	AutoInject   bool
+}
+
+// Hidden reports whether the skill's metadata excludes it from registration.
+func (s Skill) Hidden() bool {
+	hidden, _ := strconv.ParseBool(s.Metadata[MetadataHidden])
+	return hidden
+}
+
+// MissingEnv returns the environment variables the skill requires that are
+// unset or empty.
+func (s Skill) MissingEnv() []string {
+	var missing []string
+	for _, name := range s.Env {
+		if os.Getenv(name) == "" {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// Diagnostic describes a skill or skills path that was skipped during
+// discovery
+type Diagnostic struct {
+	// Path is the SKILL.md file or configured skills path
+	Path string
+
+	// Reason explains why it was skipped
+	Reason string
 }
 
 // Plugin implements the Crush plugin interface for skills
 type Plugin struct {
-	info   plugin.PluginInfo
-	hooks  *plugin.BaseHooks
-	skills []Skill
-	tools  []plugin.PluginTool
+	info        plugin.PluginInfo
+	hooks       *plugin.BaseHooks
+	skills      []Skill
+	tools       []plugin.PluginTool
+	diagnostics []Diagnostic
 }
 
 // NewPlugin creates a new skills plugin instance
@@ -54,6 +140,9 @@ func NewPlugin() *Plugin {
 			Version:     "1.0.0",
 			Description: "Implements Anthropic's Agent Skills Specification for Crush",
 			Author:      "Crush Team",
+			Homepage:    "https://github.com/charmbracelet/crush",
+			License:     "FSL-1.1-MIT",
+			Tags:        []string{"skills", "builtin"},
 		},
 		hooks:  plugin.NewBaseHooks(),
 		skills: []Skill{},
@@ -68,19 +157,81 @@ func (p *Plugin) Info() plugin.PluginInfo {
 
 // Init is called when the plugin is loaded
 func (p *Plugin) Init(ctx context.Context, pluginCtx plugin.PluginContext) error {
+	if pluginCtx.Config != nil && pluginCtx.Config.Options != nil && pluginCtx.Config.Options.SafeMode {
+		slog.Info("Safe mode is active, not loading skills")
+		return nil
+	}
+
 	// Get skill discovery paths
-	basePaths := getSkillBasePaths(pluginCtx.WorkingDir)
+	var extraPaths []string
+	var filter skillFilter
+	var eager bool
+	var defaults config.SkillDefaults
+	var bundles []config.SkillBundle
+	var collisions string
+	var projectOnly, strict bool
+	if pluginCtx.Config != nil && pluginCtx.Config.Options != nil {
+		strict = pluginCtx.Config.Options.SkillsStrictFrontmatter
+		collisions = pluginCtx.Config.Options.SkillNameCollisions
+		projectOnly = pluginCtx.Config.Options.SkillsProjectOnly
+		extraPaths = pluginCtx.Config.Options.SkillsPaths
+		bundles = pluginCtx.Config.Options.SkillBundles
+		filter = skillFilter{
+			enabled:  pluginCtx.Config.Options.EnabledSkills,
+			disabled: pluginCtx.Config.Options.DisabledSkills,
+		}
+		eager = pluginCtx.Config.Options.EagerSkills
+		defaults = pluginCtx.Config.Options.SkillDefaults
+	}
+	bundleDirs, diagnostics := extractBundles(ctx, bundles, defaultBundleDir())
+	basePaths, pathDiagnostics := getSkillBasePaths(pluginCtx.WorkingDir, projectOnly, bundleDirs, extraPaths)
+	diagnostics = append(diagnostics, pathDiagnostics...)
 
 	// Discover skills
-	skills, err := discoverSkills(basePaths)
+	skills, skillDiagnostics, err := discoverSkills(basePaths, filter, skillsMaxDepth(pluginCtx.Config), collisions, strict)
 	if err != nil {
 		return fmt.Errorf("failed to discover skills: %w", err)
 	}
 
+	diagnostics = append(diagnostics, skillDiagnostics...)
+
+	// Skills missing required environment variables would fail when used
+	skills = slices.DeleteFunc(skills, func(s Skill) bool {
+		missing := s.MissingEnv()
+		if len(missing) == 0 {
+			return false
+		}
+		diagnostics = append(diagnostics, Diagnostic{
+			Path:   s.Path,
+			Reason: "missing required environment variables: " + strings.Join(missing, ", "),
+		})
+		return true
+	})
+
 	p.skills = skills
+	p.diagnostics = diagnostics
+	byName := skillsByName(skills)
+	checkRequires(byName)
+
+	// Confine file tools to the skill directory while a skill is active,
+	// leaving other tools alone
+	if pluginCtx.Config != nil && pluginCtx.Config.Options != nil && pluginCtx.Config.Options.SandboxSkills {
+		sb := newSandbox(pluginCtx.WorkingDir, skills)
+		p.hooks.ToolHook = plugin.ScopedToolHook(sb.tools(), sb)
+		p.hooks.MessageHook = sb
+	}
+
+	// Put auto-injected skills in front of the agent on every run
+	if injected := renderInjected(skills, injectMaxBytes(pluginCtx.Config)); injected != "" {
+		p.hooks.AgentHook = &promptInjector{content: injected}
+	}
 
 	// Register each skill as a tool
 	for _, skill := range skills {
+		if skill.Hidden() {
+			continue
+		}
+
 		// Capture skill in closure
 		s := skill
 
@@ -88,21 +239,28 @@ func (p *Plugin) Init(ctx context.Context, pluginCtx plugin.PluginContext) error
 			name:        s.ToolName,
 			description: s.Description,
 			skill:       s,
+			eager:       eager,
+			defaults:    skillDefaults(s, defaults),
+			requires:    resolveRequires(s, byName),
 		}
 
 		p.tools = append(p.tools, tool)
 	}
 
-	if len(skills) > 0 {
-		fmt.Fprintf(os.Stderr, "Skills Plugin: Loaded %d skill(s)\n", len(skills))
-		for _, skill := range skills {
-			fmt.Fprintf(os.Stderr, "  - %s: %s\n", skill.ToolName, skill.Description)
-		}
+	for _, tool := range p.tools {
+		slog.Debug("Loaded skill", "tool", tool.Info().Name)
 	}
+	slog.Info("Skills loaded", "count", len(p.tools), "skipped", len(p.diagnostics))
 
 	return nil
 }
 
+// Diagnostics returns the problems found while discovering skills in Init.
+// Affected skills are not registered, but other skills load normally.
+func (p *Plugin) Diagnostics() []Diagnostic {
+	return p.diagnostics
+}
+
 // Hooks returns the hook implementations provided by this plugin
 func (p *Plugin) Hooks() plugin.Hooks {
 	return p.hooks
@@ -123,31 +281,128 @@ type skillTool struct {
 	name        string
 	description string
 	skill       Skill
+
+	// eager returns the full content on the first call instead of a table
+	// of contents
+	eager bool
+
+	// defaults are the configured parameter values, overridden by those
+	// the model passes
+	defaults map[string]any
+
+	// requires are the skills the skill builds on, directly or indirectly
+	requires []Skill
 }
 
 func (t *skillTool) Info() fantasy.ToolInfo {
+	description := t.description
+	if category := t.skill.Metadata[MetadataCategory]; category != "" {
+		description = fmt.Sprintf("[%s] %s", category, description)
+	}
+	parameters := map[string]any{
+		sectionParam: map[string]any{
+			"type":        "string",
+			"description": "Heading of the section to read, as listed in the table of contents. Omit to start the skill.",
+		},
+	}
+	var required []string
+	for name, param := range t.skill.Parameters {
+		parameters[name] = map[string]any{
+			"type":        cmp.Or(param.Type, "string"),
+			"description": param.Description,
+		}
+		if _, ok := t.defaults[name]; param.Required && !ok {
+			required = append(required, name)
+		}
+	}
+	slices.Sort(required)
 	return fantasy.ToolInfo{
 		Name:        t.name,
-		Description: t.description,
-		Parameters:  map[string]any{}, // No parameters needed
+		Description: description,
+		Parameters:  parameters,
+		Required:    required,
+	}
+}
+
+// Annotations implements plugin.AnnotatedTool
+func (t *skillTool) Annotations() map[string]string {
+	annotations := map[string]string{}
+	for _, key := range annotationKeys {
+		if value := t.skill.Metadata[key]; value != "" {
+			annotations[key] = value
+		}
 	}
+	return annotations
 }
 
 func (t *skillTool) Run(ctx context.Context, params fantasy.ToolCall) (fantasy.ToolResponse, error) {
+	var input map[string]any
+	if params.Input != "" {
+		if err := json.Unmarshal([]byte(params.Input), &input); err != nil {
+			return fantasy.NewTextErrorResponse(fmt.Sprintf("invalid parameters: %v", err)), nil
+		}
+	}
+	section, _ := input[sectionParam].(string)
+	delete(input, sectionParam)
+
+	intro, sections := splitSections(t.skill.Content)
+	if section != "" {
+		return t.runSection(sections, section), nil
+	}
+
+	values := mergeParams(t.defaults, input)
+	if missing := missingParams(t.skill.Parameters, values); len(missing) > 0 {
+		return fantasy.NewTextErrorResponse("missing required parameters: " + strings.Join(missing, ", ")), nil
+	}
+
 	// Format the skill content with base directory
-	output := fmt.Sprintf("Launching skill: %s\n\nBase directory for this skill: %s\n\n%s",
+	output := fmt.Sprintf("Launching skill: %s\n\nBase directory for this skill: %s\n\n",
 		t.skill.Name,
 		t.skill.FullPath,
-		t.skill.Content,
 	)
+	// Only names are listed; values may be secrets
+	if len(t.skill.Env) > 0 {
+		output += fmt.Sprintf("Environment variables set for this skill: %s\n\n", strings.Join(t.skill.Env, ", "))
+	}
+	if len(values) > 0 {
+		output += "Parameters for this skill:\n" + formatParams(values) + "\n"
+	}
+	output += formatRequires(t.requires)
+	// Skills with a single section gain nothing from lazy loading
+	if t.eager || len(sections) < 2 {
+		output += t.skill.Content
+		return fantasy.NewTextResponse(output), nil
+	}
+
+	output += t.skill.Description + "\n\n"
+	if intro != "" {
+		output += intro + "\n\n"
+	}
+	output += "Table of contents:\n" + tableOfContents(sections)
+	output += fmt.Sprintf("\nCall %s again with section set to a heading above to read that section.", t.name)
 
 	return fantasy.NewTextResponse(output), nil
 }
 
+// runSection returns the body of the named section
+func (t *skillTool) runSection(sections []section, name string) fantasy.ToolResponse {
+	if len(sections) == 0 {
+		return fantasy.NewTextErrorResponse(fmt.Sprintf("skill %s has no sections; call %s without section to read it", t.skill.Name, t.name))
+	}
+	s, ok := findSection(sections, name)
+	if !ok {
+		return fantasy.NewTextErrorResponse(fmt.Sprintf("section %q not found in skill %s. Available sections:\n%s", name, t.skill.Name, tableOfContents(sections)))
+	}
+	return fantasy.NewTextResponse(s.Body)
+}
+
 func (t *skillTool) ProviderOptions() fantasy.ProviderOptions {
 	return fantasy.ProviderOptions{}
 }
 
+// envNamePattern matches valid environment variable names
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
 // validateSkillName checks if the skill name matches the expected format
 func validateSkillName(name string) bool {
 	match, _ := regexp.MatchString(`^[a-z0-9-]+$`, name)
@@ -170,8 +425,17 @@ func generateToolName(skillPath string) string {
 	return "skills_" + toolName
 }
 
-// parseSkillMD parses a SKILL.md file and returns a Skill struct
-func parseSkillMD(skillPath string) (*Skill, error) {
+// disambiguatedToolName returns the tool name of a skill that lost a tool
+// name conflict: its usual name followed by the first 8 hex digits of the
+// SHA-256 of its directory, e.g. "skills_review_1a2b3c4d"
+func disambiguatedToolName(skill Skill) string {
+	sum := sha256.Sum256([]byte(skill.FullPath))
+	return skill.ToolName + "_" + hex.EncodeToString(sum[:4])
+}
+
+// parseSkillMD parses a SKILL.md file and returns a Skill struct. Include
+// directives in its content are resolved against files under roots.
+func parseSkillMD(skillPath string, roots []string, strict bool) (*Skill, error) {
 	// Read the file
 	content, err := os.ReadFile(skillPath)
 	if err != nil {
@@ -188,9 +452,9 @@ func parseSkillMD(skillPath string) (*Skill, error) {
 	}
 
 	// Parse YAML frontmatter
-	var frontmatter SkillFrontmatter
-	if err := yaml.Unmarshal([]byte(parts[1]), &frontmatter); err != nil {
-		return nil, fmt.Errorf("failed to parse frontmatter: %w", err)
+	frontmatter, err := decodeFrontmatter(skillPath, parts[1], strict)
+	if err != nil {
+		return nil, err
 	}
 
 	// Validate required fields
@@ -203,6 +467,22 @@ func parseSkillMD(skillPath string) (*Skill, error) {
 	if len(frontmatter.Description) < 20 {
 		return nil, fmt.Errorf("skill description must be at least 20 characters")
 	}
+	if err := checkMinVersion(frontmatter.MinCrushVersion, version.Version); err != nil {
+		return nil, err
+	}
+	for _, name := range frontmatter.Env {
+		if !envNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid environment variable name: %q", name)
+		}
+	}
+	if err := validateParameters(frontmatter.Parameters); err != nil {
+		return nil, err
+	}
+	for _, name := range frontmatter.Requires {
+		if !validateSkillName(name) {
+			return nil, fmt.Errorf("invalid required skill name: %q", name)
+		}
+	}
 
 	// Get the skill directory name
 	skillDir := filepath.Dir(skillPath)
@@ -223,6 +503,12 @@ func parseSkillMD(skillPath string) (*Skill, error) {
 		relPath = skillDirName
 	}
 
+	// Inline shared partials
+	body, err := resolveIncludes(parts[2], skillDir, roots, []string{resolveSymlinks(skillPath)})
+	if err != nil {
+		return nil, err
+	}
+
 	// Create skill object
 	skill := &Skill{
 		Name:         frontmatter.Name,
@@ -232,90 +518,198 @@ func parseSkillMD(skillPath string) (*Skill, error) {
 		AllowedTools: frontmatter.AllowedTools,
 		Metadata:     frontmatter.Metadata,
 		License:      frontmatter.License,
-		Content:      strings.TrimSpace(parts[2]),
+		Content:      strings.TrimSpace(body),
 		Path:         skillPath,
+		Disabled:     frontmatter.Disabled || (frontmatter.Enabled != nil && !*frontmatter.Enabled),
+		Env:          frontmatter.Env,
+		Parameters:   frontmatter.Parameters,
+		Requires:     frontmatter.Requires,
+		AutoInject:   frontmatter.AutoInject,
 	}
 
 	return skill, nil
 }
 
-// discoverSkills scans directories for SKILL.md files
-func discoverSkills(basePaths []string) ([]Skill, error) {
-	var allSkills []Skill
-	seenToolNames := make(map[string]string) // toolName -> skillPath
+// discoveredSkill is a skill along with the precedence of the base path it
+// was found in
+type discoveredSkill struct {
+	skill    Skill
+	priority int
+	basePath string
+}
+
+// skillFilter selects the discovered skills to load by name
+type skillFilter struct {
+	enabled  []string // if set, only these skills are loaded
+	disabled []string // never loaded, even if enabled
+}
+
+// allows reports whether the filter lets the named skill load
+func (f skillFilter) allows(name string) bool {
+	if slices.Contains(f.disabled, name) {
+		return false
+	}
+	return len(f.enabled) == 0 || slices.Contains(f.enabled, name)
+}
+
+// discoverSkills scans directories for SKILL.md files. Skills disabled in
+// their frontmatter or by filter are validated but skipped.
+//
+// basePaths are in precedence order (low to high). When two skills share a
+// tool name, the one from the higher-precedence base path wins; within the
+// same base path the first in lexical order wins. The result is sorted by
+// tool name.
+//
+// collisions is the skill_name_collisions option. With
+// config.SkillCollisionsKeepBoth the skills that lose a tool name conflict
+// are kept under a name suffixed with a hash of their directory instead.
+// With config.SkillCollisionsError discovery fails with
+// ErrDuplicateSkillName if two skills declare the same name; otherwise such
+// skills are logged as warnings.
+//
+// Each base path is searched at most maxDepth directories deep. Skills that
+// fail to parse or lose a tool name conflict are reported as diagnostics.
+// With strict, skills with unknown frontmatter keys fail to parse.
+func discoverSkills(basePaths []string, filter skillFilter, maxDepth int, collisions string, strict bool) ([]Skill, []Diagnostic, error) {
+	discovered := make(map[string]discoveredSkill) // toolName -> skill
+	pathsByName := make(map[string][]string)
+	var diagnostics []Diagnostic
+
+	for priority, basePath := range basePaths {
+		// The same directory may be listed twice, e.g. when running in $HOME
+		if slices.Contains(basePaths[:priority], basePath) {
+			continue
+		}
 
-	for _, basePath := range basePaths {
 		// Check if directory exists
 		if _, err := os.Stat(basePath); os.IsNotExist(err) {
 			continue // Skip missing directories
 		}
 
-		// Walk the directory tree looking for SKILL.md files
-		err := filepath.WalkDir(basePath, func(path string, d os.DirEntry, err error) error {
-			if err != nil {
-				return nil // Skip errors, continue walking
+		for _, path := range findSkillFiles(basePath, maxDepth) {
+			skill, parseErr := parseSkillMD(path, basePaths, strict)
+			if parseErr != nil {
+				diagnostics = append(diagnostics, Diagnostic{Path: path, Reason: parseErr.Error()})
+				continue // Continue despite parse error
 			}
 
-			// Check if this is a SKILL.md file
-			if !d.IsDir() && d.Name() == "SKILL.md" {
-				skill, parseErr := parseSkillMD(path)
-				if parseErr != nil {
-					fmt.Fprintf(os.Stderr, "Warning: Failed to parse skill at %s: %v\n", path, parseErr)
-					return nil // Continue walking despite parse error
-				}
+			if skill.Disabled || !filter.allows(skill.Name) {
+				slog.Info("Skipping disabled skill", "name", skill.Name, "path", path)
+				continue
+			}
 
-				// Check for duplicate tool names
-				if existingPath, exists := seenToolNames[skill.ToolName]; exists {
-					fmt.Fprintf(os.Stderr, "Warning: Duplicate tool name '%s' for skills at %s and %s. Using the later one.\n",
-						skill.ToolName, existingPath, path)
-					// Remove the old skill
-					for i, s := range allSkills {
-						if s.ToolName == skill.ToolName {
-							allSkills = append(allSkills[:i], allSkills[i+1:]...)
-							break
-						}
+			pathsByName[skill.Name] = append(pathsByName[skill.Name], skill.Path)
+			candidate := discoveredSkill{skill: *skill, priority: priority, basePath: basePath}
+			if existing, exists := discovered[skill.ToolName]; exists {
+				winner, loser, reason := existing, candidate, "it was found first in the same directory"
+				if candidate.priority > existing.priority {
+					winner, loser = candidate, existing
+					reason = fmt.Sprintf("%s takes precedence over %s", candidate.basePath, existing.basePath)
+				}
+				if collisions == config.SkillCollisionsKeepBoth {
+					renamed := loser
+					renamed.skill.ToolName = disambiguatedToolName(loser.skill)
+					if _, taken := discovered[renamed.skill.ToolName]; !taken {
+						slog.Info("Renamed skill with duplicate tool name", "path", loser.skill.Path, "tool", renamed.skill.ToolName, "conflicts_with", winner.skill.Path)
+						discovered[skill.ToolName] = winner
+						discovered[renamed.skill.ToolName] = renamed
+						continue
 					}
 				}
-
-				seenToolNames[skill.ToolName] = path
-				allSkills = append(allSkills, *skill)
+				diagnostics = append(diagnostics, Diagnostic{
+					Path:   loser.skill.Path,
+					Reason: fmt.Sprintf("duplicate tool name %q; using %s because %s", skill.ToolName, winner.skill.Path, reason),
+				})
+				candidate = winner
 			}
+			discovered[skill.ToolName] = candidate
+		}
+	}
 
-			return nil
-		})
+	if err := checkDuplicateNames(pathsByName, collisions == config.SkillCollisionsError); err != nil {
+		return nil, diagnostics, err
+	}
 
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: Error walking directory %s: %v\n", basePath, err)
-		}
+	allSkills := make([]Skill, 0, len(discovered))
+	for _, d := range discovered {
+		allSkills = append(allSkills, d.skill)
 	}
+	slices.SortFunc(allSkills, func(a, b Skill) int {
+		return strings.Compare(a.ToolName, b.ToolName)
+	})
+	return allSkills, diagnostics, nil
+}
 
-	return allSkills, nil
+// ErrDuplicateSkillName is returned when skill_name_collisions is "error" and
+// two skills declare the same name.
+var ErrDuplicateSkillName = errors.New("duplicate skill name")
+
+// checkDuplicateNames reports the skill names declared by more than one
+// skill, with the paths of those skills, as an error if fail is set and as
+// warnings otherwise
+func checkDuplicateNames(pathsByName map[string][]string, fail bool) error {
+	var duplicates []string
+	for _, name := range slices.Sorted(maps.Keys(pathsByName)) {
+		paths := pathsByName[name]
+		if len(paths) < 2 {
+			continue
+		}
+		if !fail {
+			slog.Warn("Several skills declare the same name", "name", name, "paths", paths)
+			continue
+		}
+		duplicates = append(duplicates, fmt.Sprintf("%q in %s", name, strings.Join(paths, ", ")))
+	}
+	if len(duplicates) > 0 {
+		return fmt.Errorf("%w: %s", ErrDuplicateSkillName, strings.Join(duplicates, "; "))
+	}
+	return nil
 }
 
-// getSkillBasePaths returns the paths to search for skills in priority order (low to high)
-func getSkillBasePaths(workingDir string) []string {
+// getSkillBasePaths returns the paths to search for skills in priority order (low to high).
+// With projectOnly, the user's XDG and home directories are left out.
+// Bundle directories hold extracted skill bundles. Extra paths come from
+// configuration and have ~ and environment variables expanded; paths that
+// fail to expand are skipped and reported as diagnostics.
+func getSkillBasePaths(workingDir string, projectOnly bool, bundleDirs, extraPaths []string) ([]string, []Diagnostic) {
 	var paths []string
+	var diagnostics []Diagnostic
+
+	if !projectOnly {
+		// 1. XDG config directory (or ~/.config/crush/skills/)
+		configDir := os.Getenv("XDG_CONFIG_HOME")
+		if configDir == "" {
+			homeDir, err := os.UserHomeDir()
+			if err == nil {
+				configDir = filepath.Join(homeDir, ".config")
+			}
+		}
+		if configDir != "" {
+			paths = append(paths, filepath.Join(configDir, "crush", "skills"))
+		}
 
-	// 1. XDG config directory (or ~/.config/crush/skills/)
-	configDir := os.Getenv("XDG_CONFIG_HOME")
-	if configDir == "" {
+		// 2. Home directory ~/.crush/skills/
 		homeDir, err := os.UserHomeDir()
 		if err == nil {
-			configDir = filepath.Join(homeDir, ".config")
+			paths = append(paths, filepath.Join(homeDir, ".crush", "skills"))
 		}
 	}
-	if configDir != "" {
-		paths = append(paths, filepath.Join(configDir, "crush", "skills"))
-	}
 
-	// 2. Home directory ~/.crush/skills/
-	homeDir, err := os.UserHomeDir()
-	if err == nil {
-		paths = append(paths, filepath.Join(homeDir, ".crush", "skills"))
+	// 3. Extracted skill bundles
+	paths = append(paths, bundleDirs...)
+
+	// 4. Configured extra paths
+	for _, extra := range extraPaths {
+		expanded, err := plugin.ExpandPath(extra)
+		if err != nil {
+			diagnostics = append(diagnostics, Diagnostic{Path: extra, Reason: err.Error()})
+			continue
+		}
+		paths = append(paths, expanded)
 	}
 
-	// 3. Project-local .crush/skills/ (highest priority)
+	// 5. Project-local .crush/skills/ (highest priority)
 	paths = append(paths, filepath.Join(workingDir, ".crush", "skills"))
 
-	return paths
+	return paths, diagnostics
 }
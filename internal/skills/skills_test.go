@@ -0,0 +1,587 @@
+package skills
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/plugin"
+	"github.com/stretchr/testify/require"
+)
+
+func writeSkill(t *testing.T, base, name, extra string) {
+	t.Helper()
+
+	dir := filepath.Join(base, name)
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	content := fmt.Sprintf("---\nname: %s\ndescription: A skill used to test discovery\n%s---\n\n# %s\n", name, extra, name)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "SKILL.md"), []byte(content), 0o644))
+}
+
+func skillNames(skills []Skill) []string {
+	names := make([]string, 0, len(skills))
+	for _, skill := range skills {
+		names = append(names, skill.Name)
+	}
+	return names
+}
+
+func TestDiscoverSkillsDisabled(t *testing.T) {
+	t.Parallel()
+
+	t.Run("frontmatter", func(t *testing.T) {
+		t.Parallel()
+
+		base := filepath.Join(t.TempDir(), "skills")
+		writeSkill(t, base, "active", "")
+		writeSkill(t, base, "explicitly-enabled", "enabled: true\n")
+		writeSkill(t, base, "not-enabled", "enabled: false\n")
+		writeSkill(t, base, "disabled", "disabled: true\n")
+
+		skills, _, err := discoverSkills([]string{base}, skillFilter{}, DefaultMaxDepth, "", false)
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"active", "explicitly-enabled"}, skillNames(skills))
+	})
+
+	t.Run("config", func(t *testing.T) {
+		t.Parallel()
+
+		base := filepath.Join(t.TempDir(), "skills")
+		writeSkill(t, base, "keep", "")
+		writeSkill(t, base, "drop", "")
+
+		skills, _, err := discoverSkills([]string{base}, skillFilter{disabled: []string{"drop"}}, DefaultMaxDepth, "", false)
+		require.NoError(t, err)
+		require.Equal(t, []string{"keep"}, skillNames(skills))
+	})
+
+	t.Run("enabled and disabled", func(t *testing.T) {
+		t.Parallel()
+
+		base := filepath.Join(t.TempDir(), "skills")
+		writeSkill(t, base, "review", "")
+		writeSkill(t, base, "deploy", "")
+		writeSkill(t, base, "noise", "")
+		writeSkill(t, base, "off", "disabled: true\n")
+
+		for _, tt := range []struct {
+			name   string
+			filter skillFilter
+			want   []string
+		}{
+			{"empty allowlist loads all", skillFilter{}, []string{"deploy", "noise", "review"}},
+			{"allowlist only", skillFilter{enabled: []string{"review", "deploy"}}, []string{"deploy", "review"}},
+			{"denylist only", skillFilter{disabled: []string{"noise"}}, []string{"deploy", "review"}},
+			{"denylist wins over allowlist", skillFilter{enabled: []string{"review", "deploy"}, disabled: []string{"deploy"}}, []string{"review"}},
+			{"frontmatter wins over allowlist", skillFilter{enabled: []string{"review", "off"}}, []string{"review"}},
+			{"unknown names match nothing", skillFilter{enabled: []string{"missing"}}, []string{}},
+		} {
+			skills, _, err := discoverSkills([]string{base}, tt.filter, DefaultMaxDepth, "", false)
+			require.NoError(t, err, tt.name)
+			require.Equal(t, tt.want, skillNames(skills), tt.name)
+		}
+	})
+}
+
+func TestDiscoverSkillsPrecedence(t *testing.T) {
+	t.Parallel()
+
+	global := filepath.Join(t.TempDir(), "skills")
+	project := filepath.Join(t.TempDir(), "skills")
+	writeSkill(t, global, "shared", "license: global\n")
+	writeSkill(t, global, "zeta", "")
+	writeSkill(t, project, "shared", "license: project\n")
+	writeSkill(t, project, "alpha", "")
+
+	for _, basePaths := range [][]string{
+		{global, project},
+		{global, project, global},
+	} {
+		skills, _, err := discoverSkills(basePaths, skillFilter{}, DefaultMaxDepth, "", false)
+		require.NoError(t, err)
+		require.Equal(t, []string{"alpha", "shared", "zeta"}, skillNames(skills))
+		require.Equal(t, "project", skills[1].License, "the higher-precedence base path must win")
+	}
+
+	skills, _, err := discoverSkills([]string{project, global}, skillFilter{}, DefaultMaxDepth, "", false)
+	require.NoError(t, err)
+	require.Equal(t, "global", skills[1].License)
+}
+
+func TestDiscoverSkillsKeepBoth(t *testing.T) {
+	t.Parallel()
+
+	global := filepath.Join(t.TempDir(), "skills")
+	project := filepath.Join(t.TempDir(), "skills")
+	writeSkill(t, global, "shared", "license: global\n")
+	writeSkill(t, project, "shared", "license: project\n")
+	// Different relative paths that map to the same tool name
+	writeSkill(t, filepath.Join(project, "tools"), "x-y", "")
+	writeSkill(t, filepath.Join(project, "tools-x"), "y", "")
+
+	skills, diagnostics, err := discoverSkills([]string{global, project}, skillFilter{}, DefaultMaxDepth, "", false)
+	require.NoError(t, err)
+	require.Len(t, skills, 2)
+	require.Len(t, diagnostics, 2)
+
+	skills, diagnostics, err = discoverSkills([]string{global, project}, skillFilter{}, DefaultMaxDepth, config.SkillCollisionsKeepBoth, false)
+	require.NoError(t, err)
+	require.Empty(t, diagnostics)
+
+	byTool := make(map[string]Skill)
+	for _, skill := range skills {
+		byTool[skill.ToolName] = skill
+	}
+	require.Len(t, byTool, 4)
+	require.Equal(t, "project", byTool["skills_shared"].License, "the higher-precedence skill keeps the plain name")
+	globalShared := filepath.Join(global, "shared")
+	renamed, ok := byTool[disambiguatedToolName(Skill{ToolName: "skills_shared", FullPath: globalShared})]
+	require.True(t, ok)
+	require.Equal(t, "global", renamed.License)
+	require.Regexp(t, `^skills_shared_[0-9a-f]{8}$`, renamed.ToolName)
+
+	require.Equal(t, "x-y", byTool["skills_tools_x_y"].Name, "the first in lexical order keeps the plain name")
+	require.Equal(t, "y", byTool[disambiguatedToolName(Skill{ToolName: "skills_tools_x_y", FullPath: filepath.Join(project, "tools-x", "y")})].Name)
+}
+
+func TestDiscoverSkillsDuplicateNames(t *testing.T) {
+	t.Parallel()
+
+	global := filepath.Join(t.TempDir(), "skills")
+	project := filepath.Join(t.TempDir(), "skills")
+	writeSkill(t, global, "shared", "")
+	writeSkill(t, project, "shared", "")
+	// Different tool names, same skill name
+	writeSkill(t, filepath.Join(project, "a"), "review", "")
+	writeSkill(t, filepath.Join(project, "b"), "review", "")
+	writeSkill(t, project, "unique", "")
+
+	skills, _, err := discoverSkills([]string{global, project}, skillFilter{}, DefaultMaxDepth, "", false)
+	require.NoError(t, err, "duplicate names are only warned about by default")
+	require.Len(t, skills, 4)
+
+	_, _, err = discoverSkills([]string{global, project}, skillFilter{}, DefaultMaxDepth, config.SkillCollisionsError, false)
+	require.ErrorIs(t, err, ErrDuplicateSkillName)
+	for _, path := range []string{
+		filepath.Join(project, "a", "review"),
+		filepath.Join(project, "b", "review"),
+		filepath.Join(global, "shared"),
+		filepath.Join(project, "shared"),
+	} {
+		require.ErrorContains(t, err, path)
+	}
+	require.NotContains(t, err.Error(), "unique")
+
+	_, _, err = discoverSkills([]string{global, project}, skillFilter{disabled: []string{"review"}}, DefaultMaxDepth, config.SkillCollisionsError, false)
+	require.ErrorIs(t, err, ErrDuplicateSkillName)
+	require.NotContains(t, err.Error(), "review", "skipped skills don't conflict")
+
+	skills, _, err = discoverSkills([]string{project}, skillFilter{disabled: []string{"review"}}, DefaultMaxDepth, config.SkillCollisionsError, false)
+	require.NoError(t, err)
+	require.Equal(t, []string{"shared", "unique"}, skillNames(skills))
+}
+
+func TestGetSkillBasePaths(t *testing.T) {
+	home := t.TempDir()
+	xdg := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	workingDir := t.TempDir()
+	project := filepath.Join(workingDir, ".crush", "skills")
+
+	paths, diagnostics := getSkillBasePaths(workingDir, false, []string{"/bundle"}, []string{"/extra"})
+	require.Empty(t, diagnostics)
+	require.Equal(t, []string{
+		filepath.Join(xdg, "crush", "skills"),
+		filepath.Join(home, ".crush", "skills"),
+		"/bundle",
+		"/extra",
+		project,
+	}, paths)
+
+	paths, diagnostics = getSkillBasePaths(workingDir, true, nil, nil)
+	require.Empty(t, diagnostics)
+	require.Equal(t, []string{project}, paths)
+
+	// Explicitly configured locations are still searched
+	paths, _ = getSkillBasePaths(workingDir, true, []string{"/bundle"}, []string{"/extra"})
+	require.Equal(t, []string{"/bundle", "/extra", project}, paths)
+}
+
+func TestDiscoverSkillsDiagnostics(t *testing.T) {
+	t.Parallel()
+
+	base := filepath.Join(t.TempDir(), "skills")
+	writeSkill(t, base, "good", "")
+	broken := filepath.Join(base, "broken", "SKILL.md")
+	require.NoError(t, os.MkdirAll(filepath.Dir(broken), 0o755))
+	require.NoError(t, os.WriteFile(broken, []byte("no frontmatter"), 0o644))
+
+	skills, diagnostics, err := discoverSkills([]string{base}, skillFilter{}, DefaultMaxDepth, "", false)
+	require.NoError(t, err)
+	require.Equal(t, []string{"good"}, skillNames(skills))
+	require.Len(t, diagnostics, 1)
+	require.Equal(t, broken, diagnostics[0].Path)
+	require.NotEmpty(t, diagnostics[0].Reason)
+}
+
+func TestDiscoverSkillsUnknownKeys(t *testing.T) {
+	t.Parallel()
+
+	base := filepath.Join(t.TempDir(), "skills")
+	writeSkill(t, base, "typo", "licence: MIT\nparameters:\n  topic:\n    descripton: The topic\n")
+	writeSkill(t, base, "clean", "license: MIT\n")
+
+	skills, diagnostics, err := discoverSkills([]string{base}, skillFilter{}, DefaultMaxDepth, "", false)
+	require.NoError(t, err)
+	require.Equal(t, []string{"clean", "typo"}, skillNames(skills), "unknown keys only warn by default")
+	require.Empty(t, diagnostics)
+
+	skills, diagnostics, err = discoverSkills([]string{base}, skillFilter{}, DefaultMaxDepth, "", true)
+	require.NoError(t, err)
+	require.Equal(t, []string{"clean"}, skillNames(skills))
+	require.Len(t, diagnostics, 1)
+	require.Contains(t, diagnostics[0].Reason, `line 4: unknown key "licence" (did you mean "license"?)`)
+	require.Contains(t, diagnostics[0].Reason, `unknown key "descripton" (did you mean "description"?)`)
+}
+
+func TestClosestKey(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "allowed-tools", closestKey("allowed_tools"))
+	require.Equal(t, "min-crush-version", closestKey("min-crush-verison"))
+	require.Equal(t, "auto-inject", closestKey("Auto-Inject"))
+	require.Empty(t, closestKey("homepage"))
+}
+
+func TestDiscoverSkillsDepth(t *testing.T) {
+	t.Parallel()
+
+	base := filepath.Join(t.TempDir(), "skills")
+	writeSkill(t, base, "shallow", "")
+	writeSkill(t, filepath.Join(base, "a", "b"), "deep", "")
+
+	skills, _, err := discoverSkills([]string{base}, skillFilter{}, DefaultMaxDepth, "", false)
+	require.NoError(t, err)
+	require.Equal(t, []string{"deep", "shallow"}, skillNames(skills))
+
+	skills, _, err = discoverSkills([]string{base}, skillFilter{}, 2, "", false)
+	require.NoError(t, err)
+	require.Equal(t, []string{"shallow"}, skillNames(skills))
+}
+
+func TestDiscoverSkillsSymlinkCycle(t *testing.T) {
+	t.Parallel()
+
+	base := filepath.Join(t.TempDir(), "skills")
+	shared := filepath.Join(t.TempDir(), "shared")
+	writeSkill(t, base, "local", "")
+	writeSkill(t, shared, "linked", "")
+	require.NoError(t, os.Symlink(base, filepath.Join(base, "local", "loop")))
+	require.NoError(t, os.Symlink(shared, filepath.Join(base, "shared")))
+	require.NoError(t, os.Symlink(base, filepath.Join(shared, "back")))
+
+	skills, _, err := discoverSkills([]string{base}, skillFilter{}, DefaultMaxDepth, "", false)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"linked", "local"}, skillNames(skills))
+}
+
+func TestSkillEnv(t *testing.T) {
+	t.Parallel()
+
+	workingDir := t.TempDir()
+	base := filepath.Join(workingDir, ".crush", "skills")
+	writeSkill(t, base, "has-env", "env: [PATH]\n")
+	writeSkill(t, base, "needs-env", "env: [PATH, CRUSH_TEST_UNSET_SKILL_VAR]\n")
+	writeSkill(t, base, "bad-env", "env: [\"NOT-A-NAME\"]\n")
+
+	p := NewPlugin()
+	require.NoError(t, p.Init(t.Context(), plugin.PluginContext{WorkingDir: workingDir}))
+
+	var tool plugin.PluginTool
+	for _, candidate := range p.GetTools() {
+		require.NotEqual(t, "skills_needs_env", candidate.Info().Name)
+		if candidate.Info().Name == "skills_has_env" {
+			tool = candidate
+		}
+	}
+	require.NotNil(t, tool)
+	resp, err := tool.Run(t.Context(), fantasy.ToolCall{})
+	require.NoError(t, err)
+	require.Contains(t, resp.Content, "Environment variables set for this skill: PATH")
+	require.NotContains(t, resp.Content, os.Getenv("PATH"), "values must never be printed")
+
+	reasons := make(map[string]string)
+	for _, d := range p.Diagnostics() {
+		reasons[filepath.Base(filepath.Dir(d.Path))] = d.Reason
+	}
+	require.Equal(t, "missing required environment variables: CRUSH_TEST_UNSET_SKILL_VAR", reasons["needs-env"])
+	require.Contains(t, reasons["bad-env"], "invalid environment variable name")
+}
+
+func TestSkillSections(t *testing.T) {
+	t.Parallel()
+
+	content := "Read this first.\n\n# Guide\n\nOverview.\n\n## Setup\n\nInstall it.\n\n```sh\n# not a heading\n```\n\n## Usage ##\n\nRun it."
+	skill := Skill{Name: "guide", Description: "A skill used to test sections", Content: content}
+	run := func(tool *skillTool, input string) fantasy.ToolResponse {
+		resp, err := tool.Run(t.Context(), fantasy.ToolCall{Input: input})
+		require.NoError(t, err)
+		return resp
+	}
+
+	lazy := &skillTool{name: "skills_guide", skill: skill}
+	resp := run(lazy, "")
+	require.Contains(t, resp.Content, "A skill used to test sections\n\nRead this first.\n\nTable of contents:\n- Guide\n  - Setup\n  - Usage\n")
+	require.NotContains(t, resp.Content, "Install it.")
+
+	resp = run(lazy, `{"section": "## setup"}`)
+	require.False(t, resp.IsError)
+	require.Equal(t, "## Setup\n\nInstall it.\n\n```sh\n# not a heading\n```", resp.Content)
+
+	resp = run(lazy, `{"section": "Guide"}`)
+	require.Contains(t, resp.Content, "Run it.", "sections include their subsections")
+
+	resp = run(lazy, `{"section": "Missing"}`)
+	require.True(t, resp.IsError)
+	require.Contains(t, resp.Content, "- Usage")
+
+	eager := &skillTool{name: "skills_guide", skill: skill, eager: true}
+	require.Contains(t, run(eager, "").Content, content)
+}
+
+func TestSkillParameters(t *testing.T) {
+	t.Parallel()
+
+	workingDir := t.TempDir()
+	base := filepath.Join(workingDir, ".crush", "skills")
+	writeSkill(t, base, "translate", `parameters:
+  language:
+    description: Language to translate to
+    required: true
+  formal:
+    description: Use formal language
+    type: boolean
+    required: true
+  tone:
+    description: Tone of the translation
+`)
+	writeSkill(t, base, "bad-param", "parameters:\n  section:\n    description: Reserved\n")
+
+	cfg := &config.Config{Options: &config.Options{SkillDefaults: config.SkillDefaults{
+		"translate": {"language": "en", "tone": "neutral", "undeclared": "ignored"},
+	}}}
+	p := NewPlugin()
+	require.NoError(t, p.Init(t.Context(), plugin.PluginContext{WorkingDir: workingDir, Config: cfg}))
+	require.Len(t, p.GetTools(), 1)
+	require.Contains(t, p.Diagnostics()[0].Reason, `parameter name "section" is reserved`)
+
+	tool := p.GetTools()[0]
+	info := tool.Info()
+	require.Equal(t, []string{"formal"}, info.Required, "parameters with a default are optional")
+	require.Equal(t, "boolean", info.Parameters["formal"].(map[string]any)["type"])
+
+	run := func(input string) fantasy.ToolResponse {
+		resp, err := tool.Run(t.Context(), fantasy.ToolCall{Input: input})
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := run(`{"formal": true}`)
+	require.False(t, resp.IsError)
+	require.Contains(t, resp.Content, "Parameters for this skill:\n- formal: true\n- language: en\n- tone: neutral\n")
+	require.NotContains(t, resp.Content, "undeclared")
+
+	resp = run(`{"formal": false, "language": "fr"}`)
+	require.Contains(t, resp.Content, "- language: fr\n", "model parameters take precedence over defaults")
+
+	resp = run(`{}`)
+	require.True(t, resp.IsError)
+	require.Equal(t, "missing required parameters: formal", resp.Content)
+}
+
+func TestSkillRequires(t *testing.T) {
+	t.Parallel()
+
+	workingDir := t.TempDir()
+	base := filepath.Join(workingDir, ".crush", "skills")
+	writeSkill(t, base, "deploy", "requires: [build, missing]\n")
+	writeSkill(t, base, "build", "requires: [lint]\n")
+	writeSkill(t, base, "lint", "metadata:\n  hidden: \"true\"\nrequires: [deploy]\n")
+	writeSkill(t, base, "bad-requires", "requires: [Not_A_Name]\n")
+
+	p := NewPlugin()
+	require.NoError(t, p.Init(t.Context(), plugin.PluginContext{WorkingDir: workingDir}))
+	require.Contains(t, p.Diagnostics()[0].Reason, "invalid required skill name")
+	require.Equal(t, []string{"build", "lint", "deploy", "build"}, findRequiresCycle(skillsByName(p.skills)))
+
+	var deploy plugin.PluginTool
+	for _, tool := range p.GetTools() {
+		if tool.Info().Name == "skills_deploy" {
+			deploy = tool
+		}
+	}
+	require.NotNil(t, deploy)
+	resp, err := deploy.Run(t.Context(), fantasy.ToolCall{})
+	require.NoError(t, err)
+	require.Contains(t, resp.Content, "- build (call skills_build): A skill used to test discovery\n")
+	require.Contains(t, resp.Content, "Required skill lint:\n\n# lint\n", "hidden skills are inlined")
+	require.NotContains(t, resp.Content, "missing")
+	require.Equal(t, 1, strings.Count(resp.Content, "Required skill lint"), "cycles end")
+}
+
+func TestSkillAutoInject(t *testing.T) {
+	t.Parallel()
+
+	workingDir := t.TempDir()
+	base := filepath.Join(workingDir, ".crush", "skills")
+	writeSkill(t, base, "style", "auto-inject: true\n")
+	writeSkill(t, base, "tone", "auto-inject: true\nmetadata:\n  hidden: \"true\"\n")
+	writeSkill(t, base, "deploy", "")
+
+	p := NewPlugin()
+	require.NoError(t, p.Init(t.Context(), plugin.PluginContext{WorkingDir: workingDir}))
+	var toolNames []string
+	for _, tool := range p.GetTools() {
+		toolNames = append(toolNames, tool.Info().Name)
+	}
+	require.ElementsMatch(t, []string{"skills_style", "skills_deploy"}, toolNames, "auto-injected skills are still registered unless hidden")
+
+	hook, ok := p.Hooks().Agent().(plugin.SystemPromptHook)
+	require.True(t, ok)
+	prompt, err := hook.OnSystemPrompt(t.Context(), "session", "You are Crush.")
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(prompt, "<auto_injected_skills>\n"))
+	require.True(t, strings.HasSuffix(prompt, "</auto_injected_skills>\n\nYou are Crush."))
+	require.Contains(t, prompt, "<skill name=\"style\">\n# style\n</skill>\n")
+	require.Contains(t, prompt, "<skill name=\"tone\">\n# tone\n</skill>\n")
+	require.NotContains(t, prompt, "deploy")
+
+	// Without auto-injected skills the system prompt is left alone
+	p = NewPlugin()
+	require.NoError(t, p.Init(t.Context(), plugin.PluginContext{WorkingDir: t.TempDir()}))
+	_, ok = p.Hooks().Agent().(plugin.SystemPromptHook)
+	require.False(t, ok)
+}
+
+func TestRenderInjected(t *testing.T) {
+	t.Parallel()
+
+	skills := []Skill{
+		{Name: "first", Content: "12345", AutoInject: true},
+		{Name: "tool-only", Content: "not injected"},
+		{Name: "second", Content: "héllo", AutoInject: true},
+		{Name: "third", Content: "left out", AutoInject: true},
+	}
+	require.Equal(t, `<auto_injected_skills>
+<skill name="first">
+12345
+</skill>
+<skill name="second">
+h
+[truncated]
+</skill>
+</auto_injected_skills>`, renderInjected(skills, 7), "truncation must not split runes")
+	require.Empty(t, renderInjected(skills[1:2], 100))
+}
+
+func TestSafeMode(t *testing.T) {
+	t.Parallel()
+
+	workingDir := t.TempDir()
+	writeSkill(t, filepath.Join(workingDir, ".crush", "skills"), "deploy", "")
+
+	cfg := &config.Config{Options: &config.Options{SafeMode: true}}
+	p := NewPlugin()
+	require.NoError(t, p.Init(t.Context(), plugin.PluginContext{WorkingDir: workingDir, Config: cfg}))
+	require.Empty(t, p.GetTools())
+	require.Empty(t, p.Diagnostics())
+}
+
+func TestCheckMinVersion(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		required string
+		host     string
+		err      error
+		invalid  bool
+	}{
+		{required: "", host: "1.0.0"},
+		{required: "0.10.0", host: "0.10.0"},
+		{required: "0.9.2", host: "v0.10.0"},
+		{required: "v1", host: "1.0.1"},
+		{required: "1.0.0-rc.1", host: "1.0.0"},
+		{required: "1.0.0-rc.2", host: "1.0.0-rc.10"},
+		{required: "0.10.1", host: "0.10.0", err: ErrIncompatibleVersion},
+		{required: "1.0.0", host: "1.0.0-rc.1", err: ErrIncompatibleVersion},
+		{required: "2", host: "1.99.0", err: ErrIncompatibleVersion},
+		{required: "1.0.0", host: "unknown"},
+		{required: "latest", host: "1.0.0", invalid: true},
+		{required: "1.2.3.4", host: "1.0.0", invalid: true},
+		{required: "1.-2", host: "1.0.0", invalid: true},
+	} {
+		err := checkMinVersion(tt.required, tt.host)
+		switch {
+		case tt.err != nil:
+			require.ErrorIs(t, err, tt.err, "%s on %s", tt.required, tt.host)
+		case tt.invalid:
+			require.ErrorContains(t, err, "invalid min-crush-version", tt.required)
+		default:
+			require.NoError(t, err, "%s on %s", tt.required, tt.host)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	base := filepath.Join(t.TempDir(), "skills")
+	writeSkill(t, base, "valid", "")
+	writeSkill(t, base, "bad-version", "min-crush-version: latest\n")
+	require.NoError(t, os.MkdirAll(filepath.Join(base, "broken"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(base, "broken", "SKILL.md"), []byte("no frontmatter"), 0o644))
+
+	cfg := &config.Config{Options: &config.Options{SkillsPaths: []string{base}}}
+	results := make(map[string]string)
+	for _, result := range Validate(t.TempDir(), cfg) {
+		if rel, err := filepath.Rel(base, result.Path); err == nil && !strings.HasPrefix(rel, "..") {
+			results[filepath.Dir(rel)] = result.Error
+		}
+	}
+	require.Len(t, results, 3)
+	require.Empty(t, results["valid"])
+	require.Contains(t, results["broken"], "missing frontmatter")
+	require.Contains(t, results["bad-version"], "invalid min-crush-version")
+}
+
+func TestSkillMetadataAnnotations(t *testing.T) {
+	t.Parallel()
+
+	workingDir := t.TempDir()
+	base := filepath.Join(workingDir, ".crush", "skills")
+	writeSkill(t, base, "review", "metadata:\n  category: review\n  icon: \"R\"\n  owner: platform\n")
+	writeSkill(t, base, "plain", "")
+	writeSkill(t, base, "secret", "metadata:\n  hidden: \"true\"\n")
+
+	p := NewPlugin()
+	require.NoError(t, p.Init(t.Context(), plugin.PluginContext{WorkingDir: workingDir}))
+
+	annotations := map[string]map[string]string{}
+	for _, tool := range p.GetTools() {
+		annotations[tool.Info().Name] = plugin.ToolAnnotations(tool)
+	}
+	require.Equal(t, map[string]map[string]string{
+		"skills_review": {"category": "review", "icon": "R"},
+		"skills_plain":  {},
+	}, annotations, "only meaningful keys are annotations and hidden skills aren't registered")
+	for _, tool := range p.GetTools() {
+		if tool.Info().Name == "skills_review" {
+			require.True(t, strings.HasPrefix(tool.Info().Description, "[review] "))
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package skills
+
+import (
+	"context"
+	"slices"
+
+	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/plugin"
+)
+
+// Validate parses every SKILL.md in the skill discovery locations for
+// workingDir and cfg without registering any tools, reporting a result for
+// each skill found
+func Validate(workingDir string, cfg *config.Config) []plugin.ValidationResult {
+	var extraPaths []string
+	var bundles []config.SkillBundle
+	var projectOnly, strict bool
+	if cfg != nil && cfg.Options != nil {
+		strict = cfg.Options.SkillsStrictFrontmatter
+		extraPaths = cfg.Options.SkillsPaths
+		bundles = cfg.Options.SkillBundles
+		projectOnly = cfg.Options.SkillsProjectOnly
+	}
+	bundleDirs, diagnostics := extractBundles(context.Background(), bundles, defaultBundleDir())
+	basePaths, pathDiagnostics := getSkillBasePaths(workingDir, projectOnly, bundleDirs, extraPaths)
+	diagnostics = append(diagnostics, pathDiagnostics...)
+
+	var results []plugin.ValidationResult
+	for _, d := range diagnostics {
+		results = append(results, plugin.ValidationResult{Kind: plugin.KindSkill, Path: d.Path, Error: d.Reason})
+	}
+	for i, basePath := range basePaths {
+		if slices.Contains(basePaths[:i], basePath) {
+			continue
+		}
+		for _, path := range findSkillFiles(basePath, skillsMaxDepth(cfg)) {
+			result := plugin.ValidationResult{Kind: plugin.KindSkill, Path: path}
+			skill, err := parseSkillMD(path, basePaths, strict)
+			if err != nil {
+				result.Error = err.Error()
+			} else {
+				result.Name = skill.Name
+			}
+			results = append(results, result)
+		}
+	}
+	return results
+}
@@ -0,0 +1,105 @@
+package skills
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrIncompatibleVersion is returned for skills that require a newer Crush.
+var ErrIncompatibleVersion = errors.New("skill requires a newer version of Crush")
+
+// semver is a parsed semantic version
+type semver struct {
+	core       [3]int
+	prerelease string
+}
+
+// parseSemver parses a semantic version such as "1.2.3", "v1.2" or
+// "1.2.3-rc.1+build". Missing minor and patch components are zero, and
+// build metadata is ignored.
+func parseSemver(s string) (semver, error) {
+	var v semver
+	s, _, _ = strings.Cut(strings.TrimPrefix(strings.TrimSpace(s), "v"), "+")
+	s, v.prerelease, _ = strings.Cut(s, "-")
+
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return v, fmt.Errorf("invalid version %q", s)
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid version %q", s)
+		}
+		v.core[i] = n
+	}
+	return v, nil
+}
+
+// compare orders versions by semantic version precedence. A prerelease
+// sorts before the release it precedes.
+func (v semver) compare(other semver) int {
+	for i := range v.core {
+		if c := cmp.Compare(v.core[i], other.core[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case v.prerelease == other.prerelease:
+		return 0
+	case v.prerelease == "":
+		return 1
+	case other.prerelease == "":
+		return -1
+	}
+	return comparePrerelease(v.prerelease, other.prerelease)
+}
+
+// comparePrerelease compares dot-separated prerelease identifiers; numeric
+// identifiers compare numerically and sort before alphanumeric ones.
+func comparePrerelease(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := range min(len(as), len(bs)) {
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		var c int
+		switch {
+		case aErr == nil && bErr == nil:
+			c = cmp.Compare(an, bn)
+		case aErr == nil:
+			c = -1
+		case bErr == nil:
+			c = 1
+		default:
+			c = strings.Compare(as[i], bs[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(as), len(bs))
+}
+
+// checkMinVersion reports whether a skill requiring minVersion can run on
+// the host version. Skills without a minimum always can. Development builds,
+// whose version isn't a semantic version, are assumed to be new enough.
+func checkMinVersion(minVersion, host string) error {
+	if minVersion == "" {
+		return nil
+	}
+	required, err := parseSemver(minVersion)
+	if err != nil {
+		return fmt.Errorf("invalid min-crush-version: %w", err)
+	}
+	current, err := parseSemver(host)
+	if err != nil {
+		return nil
+	}
+	if current.compare(required) < 0 {
+		return fmt.Errorf("%w: requires %s, running %s", ErrIncompatibleVersion, minVersion, host)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package skills
+
+import (
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/charmbracelet/crush/internal/config"
+)
+
+// DefaultMaxDepth is how many directory levels below each base path are
+// searched for skills unless configured otherwise.
+const DefaultMaxDepth = 8
+
+// skillsMaxDepth returns the configured skill discovery depth
+func skillsMaxDepth(cfg *config.Config) int {
+	if cfg != nil && cfg.Options != nil && cfg.Options.SkillsMaxDepth > 0 {
+		return cfg.Options.SkillsMaxDepth
+	}
+	return DefaultMaxDepth
+}
+
+// findSkillFiles returns the SKILL.md files under root in lexical order,
+// descending at most maxDepth directories. Symlinked directories are
+// followed, but each directory is visited only once, so symlink cycles
+// terminate.
+func findSkillFiles(root string, maxDepth int) []string {
+	var files []string
+	visited := make(map[string]bool)
+
+	var walk func(dir string, depth int)
+	walk = func(dir string, depth int) {
+		real, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			return
+		}
+		if visited[real] {
+			slog.Debug("Skipping already visited skill directory", "path", dir)
+			return
+		}
+		visited[real] = true
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return // Skip unreadable directories
+		}
+		for _, entry := range entries {
+			path := filepath.Join(dir, entry.Name())
+			isDir := entry.IsDir()
+			if entry.Type()&fs.ModeSymlink != 0 {
+				info, err := os.Stat(path)
+				if err != nil {
+					continue // Skip dangling links
+				}
+				isDir = info.IsDir()
+			}
+
+			switch {
+			case isDir && depth >= maxDepth:
+				slog.Debug("Skipping skill directory beyond the maximum depth", "path", path, "max_depth", maxDepth)
+			case isDir:
+				walk(path, depth+1)
+			case entry.Name() == "SKILL.md":
+				files = append(files, path)
+			}
+		}
+	}
+	walk(root, 0)
+	return files
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/charmbracelet/crush/internal/app"
 	"github.com/charmbracelet/crush/internal/message"
 	"github.com/charmbracelet/crush/internal/permission"
+	"github.com/charmbracelet/crush/internal/plugin"
 	"github.com/charmbracelet/crush/internal/pubsub"
 	"github.com/charmbracelet/crush/internal/session"
 	"github.com/charmbracelet/crush/internal/tui/components/chat/messages"
@@ -205,6 +206,9 @@ func (m *messageListCmp) Update(msg tea.Msg) (util.Model, tea.Cmd) {
 	case pubsub.Event[message.Message]:
 		cmds = append(cmds, m.handleMessageEvent(msg))
 		return m, tea.Batch(cmds...)
+	case pubsub.Event[plugin.ToolProgress]:
+		m.handleToolProgress(msg.Payload)
+		return m, nil
 
 	case tea.MouseWheelMsg:
 		u, cmd := m.listCmp.Update(msg)
@@ -409,6 +413,19 @@ func (m *messageListCmp) handleToolMessage(msg message.Message) tea.Cmd {
 	return nil
 }
 
+// handleToolProgress shows the output of a running streaming tool.
+func (m *messageListCmp) handleToolProgress(progress plugin.ToolProgress) {
+	if progress.SessionID != m.session.ID {
+		return
+	}
+	items := m.listCmp.Items()
+	if toolCallIndex := m.findToolCallByID(items, progress.ToolCallID); toolCallIndex != NotFound {
+		toolCall := items[toolCallIndex].(messages.ToolCallCmp)
+		toolCall.SetProgress(progress.Output)
+		m.listCmp.UpdateItem(toolCall.ID(), toolCall)
+	}
+}
+
 // findToolCallByID searches for a tool call with the specified ID.
 // Returns the index if found, NotFound otherwise.
 func (m *messageListCmp) findToolCallByID(items []list.Item, toolCallID string) int {
@@ -194,6 +194,9 @@ func (m *messageCmp) renderAssistantMessage() string {
 		content = ""
 	} else if finished && content == "" && finishedData.Reason == message.FinishReasonCanceled {
 		content = "*Canceled*"
+		if finishedData.Details != "" {
+			content = fmt.Sprintf("*Canceled: %s*", finishedData.Details)
+		}
 	} else if finished && content == "" && finishedData.Reason == message.FinishReasonError {
 		errTag := t.S().Base.Padding(0, 1).Background(t.Red).Foreground(t.White).Render("ERROR")
 		truncated := ansi.Truncate(finishedData.Message, m.textWidth()-2-lipgloss.Width(errTag), "...")
@@ -726,6 +726,8 @@ func earlyState(header string, v *toolCallCmp) (string, bool) {
 	case v.result.ToolCallID == "":
 		if v.permissionRequested && !v.permissionGranted {
 			message = t.S().Base.Foreground(t.FgSubtle).Render("Requesting for permission...")
+		} else if v.progress != "" {
+			return joinHeaderBody(header, renderPlainContent(v, lastLines(v.progress, responseContextHeight))), true
 		} else {
 			message = t.S().Base.Foreground(t.FgSubtle).Render("Waiting for tool response...")
 		}
@@ -737,6 +739,12 @@ func earlyState(header string, v *toolCallCmp) (string, bool) {
 	return lipgloss.JoinVertical(lipgloss.Left, header, "", message), true
 }
 
+// lastLines returns the last n lines of content
+func lastLines(content string, n int) string {
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	return strings.Join(lines[max(0, len(lines)-n):], "\n")
+}
+
 func joinHeaderBody(header, body string) string {
 	t := styles.CurrentTheme()
 	if body == "" {
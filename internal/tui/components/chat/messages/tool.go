@@ -34,6 +34,7 @@ type ToolCallCmp interface {
 	GetToolResult() message.ToolResult // Access to tool result data
 	SetToolResult(message.ToolResult)  // Update tool result
 	SetToolCall(message.ToolCall)      // Update tool call
+	SetProgress(string)                // Update output of a running tool
 	SetCancelled()                     // Mark as cancelled
 	ParentMessageID() string           // Get parent message ID
 	Spinning() bool                    // Animation state for pending tools
@@ -56,6 +57,7 @@ type toolCallCmp struct {
 	parentMessageID     string             // ID of the message that initiated this tool call
 	call                message.ToolCall   // The tool call being executed
 	result              message.ToolResult // The result of the tool execution
+	progress            string             // Output of the tool while it runs
 	cancelled           bool               // Whether the tool call was cancelled
 	permissionRequested bool
 	permissionGranted   bool
@@ -80,10 +82,17 @@ func WithToolCallCancelled() ToolCallOption {
 // WithToolCallResult sets the initial tool result
 func WithToolCallResult(result message.ToolResult) ToolCallOption {
 	return func(m *toolCallCmp) {
-		m.result = result
+		m.result = displayed(result)
 	}
 }
 
+// displayed returns result with the content meant for the user, which
+// plugins may have set apart from what the model sees
+func displayed(result message.ToolResult) message.ToolResult {
+	result.Content = result.DisplayText()
+	return result
+}
+
 func WithToolCallNested(isNested bool) ToolCallOption {
 	return func(m *toolCallCmp) {
 		m.isNested = isNested
@@ -664,10 +673,15 @@ func (m *toolCallCmp) ParentMessageID() string {
 
 // SetToolResult updates the tool result and stops the spinning animation
 func (m *toolCallCmp) SetToolResult(result message.ToolResult) {
-	m.result = result
+	m.result = displayed(result)
 	m.spinning = false
 }
 
+// SetProgress updates the output shown while the tool runs
+func (m *toolCallCmp) SetProgress(output string) {
+	m.progress = output
+}
+
 // GetToolCall returns the current tool call data
 func (m *toolCallCmp) GetToolCall() message.ToolCall {
 	return m.call
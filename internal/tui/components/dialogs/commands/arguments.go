@@ -23,6 +23,10 @@ type ShowArgumentsDialogMsg struct {
 	CommandID string
 	Content   string
 	ArgNames  []string
+
+	// Run, if set, is called with the entered values in ArgNames order
+	// instead of sending Content with the arguments substituted
+	Run func(args []string) tea.Cmd
 }
 
 // CloseArgumentsDialogMsg is a message that is sent when the arguments dialog is closed.
@@ -49,10 +53,11 @@ type commandArgumentsDialogCmp struct {
 	commandID  string
 	content    string
 	argNames   []string
+	run        func(args []string) tea.Cmd
 	help       help.Model
 }
 
-func NewCommandArgumentsDialog(commandID, content string, argNames []string) CommandArgumentsDialog {
+func NewCommandArgumentsDialog(commandID, content string, argNames []string, run func(args []string) tea.Cmd) CommandArgumentsDialog {
 	t := styles.CurrentTheme()
 	inputs := make([]textinput.Model, len(argNames))
 
@@ -80,6 +85,7 @@ func NewCommandArgumentsDialog(commandID, content string, argNames []string) Com
 		commandID:  commandID,
 		content:    content,
 		argNames:   argNames,
+		run:        run,
 		focusIndex: 0,
 		width:      60,
 		help:       help.New(),
@@ -101,6 +107,16 @@ func (c *commandArgumentsDialogCmp) Update(msg tea.Msg) (util.Model, tea.Cmd) {
 		switch {
 		case key.Matches(msg, c.keys.Confirm):
 			if c.focusIndex == len(c.inputs)-1 {
+				if c.run != nil {
+					args := make([]string, len(c.inputs))
+					for i, input := range c.inputs {
+						args[i] = input.Value()
+					}
+					return c, tea.Sequence(
+						util.CmdHandler(dialogs.CloseDialogMsg{}),
+						c.run(args),
+					)
+				}
 				content := c.content
 				for i, name := range c.argNames {
 					value := c.inputs[i].Value()
@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"context"
 	"os"
 
 	"github.com/charmbracelet/bubbles/v2/help"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/charmbracelet/crush/internal/agent"
 	"github.com/charmbracelet/crush/internal/config"
+	"github.com/charmbracelet/crush/internal/plugin"
 	"github.com/charmbracelet/crush/internal/tui/components/chat"
 	"github.com/charmbracelet/crush/internal/tui/components/core"
 	"github.com/charmbracelet/crush/internal/tui/components/dialogs"
@@ -51,12 +53,13 @@ type commandDialogCmp struct {
 	wWidth  int // Width of the terminal window
 	wHeight int // Height of the terminal window
 
-	commandList  listModel
-	keyMap       CommandsDialogKeyMap
-	help         help.Model
-	commandType  int       // SystemCommands or UserCommands
-	userCommands []Command // User-defined commands
-	sessionID    string    // Current session ID
+	commandList    listModel
+	keyMap         CommandsDialogKeyMap
+	help           help.Model
+	commandType    int       // SystemCommands or UserCommands
+	userCommands   []Command // User-defined commands
+	pluginCommands []Command // Commands provided by plugins
+	sessionID      string    // Current session ID
 }
 
 type (
@@ -76,7 +79,9 @@ type (
 	}
 )
 
-func NewCommandDialog(sessionID string) CommandsDialog {
+// NewCommandDialog returns the commands dialog. Plugin commands are listed
+// after the built-in commands and run with ctx.
+func NewCommandDialog(ctx context.Context, sessionID string, pluginCommands []plugin.RegisteredCommand) CommandsDialog {
 	keyMap := DefaultCommandsDialogKeyMap()
 	listKeyMap := list.DefaultKeyMap()
 	listKeyMap.Down.SetEnabled(false)
@@ -98,12 +103,13 @@ func NewCommandDialog(sessionID string) CommandsDialog {
 	help := help.New()
 	help.Styles = t.S().Help
 	return &commandDialogCmp{
-		commandList: commandList,
-		width:       defaultWidth,
-		keyMap:      DefaultCommandsDialogKeyMap(),
-		help:        help,
-		commandType: SystemCommands,
-		sessionID:   sessionID,
+		commandList:    commandList,
+		width:          defaultWidth,
+		keyMap:         DefaultCommandsDialogKeyMap(),
+		help:           help,
+		commandType:    SystemCommands,
+		pluginCommands: loadPluginCommands(ctx, sessionID, pluginCommands),
+		sessionID:      sessionID,
 	}
 }
 
@@ -208,7 +214,7 @@ func (c *commandDialogCmp) SetCommandType(commandType int) tea.Cmd {
 
 	var commands []Command
 	if c.commandType == SystemCommands {
-		commands = c.defaultCommands()
+		commands = append(c.defaultCommands(), c.pluginCommands...)
 	} else {
 		commands = c.userCommands
 	}
@@ -0,0 +1,164 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/v2/key"
+	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/crush/internal/agent/tools"
+	"github.com/charmbracelet/crush/internal/plugin"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs"
+	"github.com/charmbracelet/crush/internal/tui/styles"
+	"github.com/charmbracelet/crush/internal/tui/util"
+	"github.com/charmbracelet/lipgloss/v2"
+)
+
+const (
+	PluginCommandPrefix = "plugin:"
+
+	outputDialogID dialogs.DialogID = "command-output"
+)
+
+// loadPluginCommands turns plugin commands into dialog commands. Their
+// handlers run with ctx.
+func loadPluginCommands(ctx context.Context, sessionID string, registered []plugin.RegisteredCommand) []Command {
+	commands := make([]Command, 0, len(registered))
+	for _, rc := range registered {
+		title := rc.Description
+		if title == "" {
+			title = "Run /" + rc.Name
+		}
+		commands = append(commands, Command{
+			ID:          PluginCommandPrefix + rc.Plugin + ":" + rc.Name,
+			Title:       title,
+			Description: rc.Description,
+			Shortcut:    "/" + rc.Name,
+			Handler:     createPluginCommandHandler(ctx, sessionID, rc),
+		})
+	}
+	return commands
+}
+
+func createPluginCommandHandler(ctx context.Context, sessionID string, rc plugin.RegisteredCommand) func(Command) tea.Cmd {
+	return func(cmd Command) tea.Cmd {
+		if len(rc.Args) > 0 {
+			return util.CmdHandler(ShowArgumentsDialogMsg{
+				CommandID: cmd.ID,
+				ArgNames:  rc.Args,
+				Run: func(args []string) tea.Cmd {
+					return runPluginCommand(ctx, sessionID, rc, args)
+				},
+			})
+		}
+		return runPluginCommand(ctx, sessionID, rc, nil)
+	}
+}
+
+// runPluginCommand runs the command's handler in the session and shows its
+// output, if any
+func runPluginCommand(ctx context.Context, sessionID string, rc plugin.RegisteredCommand, args []string) tea.Cmd {
+	return func() tea.Msg {
+		var out bytes.Buffer
+		ctx := context.WithValue(ctx, tools.SessionIDContextKey, sessionID)
+		if err := rc.Handler(ctx, args, &out); err != nil {
+			return util.InfoMsg{
+				Type: util.InfoTypeError,
+				Msg:  fmt.Sprintf("/%s failed: %v", rc.Name, err),
+			}
+		}
+		if strings.TrimSpace(out.String()) == "" {
+			return util.InfoMsg{
+				Type: util.InfoTypeInfo,
+				Msg:  fmt.Sprintf("/%s done", rc.Name),
+			}
+		}
+		return dialogs.OpenDialogMsg{
+			Model: NewCommandOutputDialog("/"+rc.Name, out.String()),
+		}
+	}
+}
+
+// commandOutputDialogCmp shows the output of a plugin command
+type commandOutputDialogCmp struct {
+	wWidth  int
+	wHeight int
+
+	title  string
+	output string
+	close  key.Binding
+}
+
+// NewCommandOutputDialog returns a dialog showing a command's output
+func NewCommandOutputDialog(title, output string) dialogs.DialogModel {
+	return &commandOutputDialogCmp{
+		title:  title,
+		output: strings.TrimRight(output, "\n"),
+		close: key.NewBinding(
+			key.WithKeys("esc", "enter", "q"),
+			key.WithHelp("esc", "close"),
+		),
+	}
+}
+
+func (c *commandOutputDialogCmp) Init() tea.Cmd {
+	return nil
+}
+
+func (c *commandOutputDialogCmp) Update(msg tea.Msg) (util.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		c.wWidth = msg.Width
+		c.wHeight = msg.Height
+	case tea.KeyPressMsg:
+		if key.Matches(msg, c.close) {
+			return c, util.CmdHandler(dialogs.CloseDialogMsg{})
+		}
+	}
+	return c, nil
+}
+
+func (c *commandOutputDialogCmp) View() string {
+	t := styles.CurrentTheme()
+	baseStyle := t.S().Base
+
+	title := lipgloss.NewStyle().
+		Foreground(t.Primary).
+		Bold(true).
+		Padding(0, 1).
+		Render(c.title)
+
+	// Keep the dialog on screen; long output is cut off
+	lines := strings.Split(c.output, "\n")
+	if maxLines := c.wHeight - 8; maxLines > 0 && len(lines) > maxLines {
+		lines = append(lines[:maxLines-1], "…")
+	}
+	output := t.S().Text.
+		Padding(1, 1, 0, 1).
+		Width(c.width() - 4).
+		Render(strings.Join(lines, "\n"))
+
+	help := baseStyle.Foreground(t.FgMuted).Padding(1, 1, 0, 1).Render("esc close")
+
+	return baseStyle.Padding(1, 1, 0, 1).
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(t.BorderFocus).
+		Width(c.width()).
+		Render(lipgloss.JoinVertical(lipgloss.Left, title, output, help))
+}
+
+func (c *commandOutputDialogCmp) width() int {
+	return min(100, max(40, c.wWidth-10))
+}
+
+func (c *commandOutputDialogCmp) Position() (int, int) {
+	row := c.wHeight/2 - lipgloss.Height(c.View())/2
+	col := c.wWidth/2 - c.width()/2
+	return max(row, 0), max(col, 0)
+}
+
+func (c *commandOutputDialogCmp) ID() dialogs.DialogID {
+	return outputDialogID
+}
@@ -0,0 +1,75 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/charmbracelet/crush/internal/plugin"
+	"github.com/charmbracelet/crush/internal/tui/components/dialogs"
+	"github.com/charmbracelet/crush/internal/tui/util"
+	"github.com/stretchr/testify/require"
+)
+
+type ctxKey struct{}
+
+func TestPluginCommands(t *testing.T) {
+	t.Parallel()
+
+	// seen records what the last handler was run with
+	var seen struct {
+		value     any
+		sessionID string
+		args      []string
+	}
+	handler := func(output string, err error) plugin.CommandHandler {
+		return func(ctx context.Context, args []string, out io.Writer) error {
+			seen.value = ctx.Value(ctxKey{})
+			seen.sessionID = plugin.SessionID(ctx)
+			seen.args = args
+			_, _ = io.WriteString(out, output)
+			return err
+		}
+	}
+
+	ctx := context.WithValue(t.Context(), ctxKey{}, "caller")
+	commands := loadPluginCommands(ctx, "session-1", []plugin.RegisteredCommand{
+		{Plugin: "metrics", PluginCommand: plugin.PluginCommand{Name: "metrics", Description: "Show metrics", Handler: handler("3 steps\n", nil)}},
+		{Plugin: "metrics", PluginCommand: plugin.PluginCommand{Name: "reset", Handler: handler("", nil)}},
+		{Plugin: "git", PluginCommand: plugin.PluginCommand{Name: "blame", Args: []string{"file"}, Handler: handler("", errors.New("no such file"))}},
+	})
+	require.Len(t, commands, 3)
+
+	metrics := commands[0]
+	require.Equal(t, PluginCommandPrefix+"metrics:metrics", metrics.ID)
+	require.Equal(t, "Show metrics", metrics.Title)
+	require.Equal(t, "/metrics", metrics.Shortcut)
+	require.Equal(t, "Run /reset", commands[1].Title, "commands without a description get a title")
+
+	t.Run("output", func(t *testing.T) {
+		msg := metrics.Handler(metrics)()
+		open, ok := msg.(dialogs.OpenDialogMsg)
+		require.True(t, ok)
+		require.Equal(t, outputDialogID, open.Model.ID())
+		require.Equal(t, "3 steps", open.Model.(*commandOutputDialogCmp).output)
+		require.Equal(t, "caller", seen.value, "handlers run with the caller's context")
+		require.Equal(t, "session-1", seen.sessionID)
+	})
+
+	t.Run("no output", func(t *testing.T) {
+		msg := commands[1].Handler(commands[1])()
+		require.Equal(t, util.InfoMsg{Type: util.InfoTypeInfo, Msg: "/reset done"}, msg)
+	})
+
+	t.Run("arguments", func(t *testing.T) {
+		blame := commands[2]
+		msg, ok := blame.Handler(blame)().(ShowArgumentsDialogMsg)
+		require.True(t, ok)
+		require.Equal(t, []string{"file"}, msg.ArgNames)
+
+		require.Equal(t, util.InfoMsg{Type: util.InfoTypeError, Msg: "/blame failed: no such file"}, msg.Run([]string{"main.go"})())
+		require.Equal(t, []string{"main.go"}, seen.args)
+		require.Equal(t, "caller", seen.value)
+	})
+}
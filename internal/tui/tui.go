@@ -10,6 +10,7 @@ import (
 
 	"github.com/charmbracelet/bubbles/v2/key"
 	tea "github.com/charmbracelet/bubbletea/v2"
+	"github.com/charmbracelet/crush/internal/agent/tools"
 	"github.com/charmbracelet/crush/internal/app"
 	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/event"
@@ -65,6 +66,8 @@ type appModel struct {
 	status          status.StatusCmp
 	showingFullHelp bool
 
+	// ctx is the context the TUI runs in
+	ctx context.Context
 	app *app.App
 
 	dialog       dialogs.DialogCmp
@@ -100,6 +103,9 @@ func (a appModel) Init() tea.Cmd {
 	if a.QueryVersion {
 		cmds = append(cmds, tea.RequestTerminalVersion)
 	}
+	if n := len(a.app.SkillDiagnostics()); n > 0 {
+		cmds = append(cmds, util.ReportWarn(fmt.Sprintf("%d skill(s) could not be loaded, see the logs for details", n)))
+	}
 
 	return tea.Batch(cmds...)
 }
@@ -162,6 +168,7 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					msg.CommandID,
 					msg.Content,
 					msg.ArgNames,
+					msg.Run,
 				),
 			},
 		)
@@ -223,7 +230,9 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		config.Get().UpdatePreferredModel(msg.ModelType, msg.Model)
 
-		go a.app.UpdateAgentModel(context.TODO())
+		// Let plugins know which session the model change happened in.
+		ctx := context.WithValue(context.TODO(), tools.SessionIDContextKey, a.selectedSessionID)
+		go a.app.UpdateAgentModel(ctx)
 
 		modelTypeName := "large"
 		if msg.ModelType == config.SelectedModelTypeSmall {
@@ -244,16 +253,28 @@ func (a *appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		})
 	// Permissions
 	case pubsub.Event[permission.PermissionNotification]:
+		// Requests are prompted one at a time, so an open dialog is the one
+		// that timed out
+		if msg.Payload.TimedOut && a.dialog.ActiveDialogID() == permissions.PermissionsDialogID {
+			cmds = append(cmds, util.CmdHandler(dialogs.CloseDialogMsg{}))
+			if msg.Payload.Granted {
+				cmds = append(cmds, util.ReportWarn("Permission request timed out and was allowed"))
+			} else {
+				cmds = append(cmds, util.ReportWarn("Permission request timed out and was denied"))
+			}
+		}
+
 		item, ok := a.pages[a.currentPage]
 		if !ok {
-			return a, nil
+			return a, tea.Batch(cmds...)
 		}
 
 		// Forward to view.
 		updated, itemCmd := item.Update(msg)
 		a.pages[a.currentPage] = updated
+		cmds = append(cmds, itemCmd)
 
-		return a, itemCmd
+		return a, tea.Batch(cmds...)
 	case pubsub.Event[permission.PermissionRequest]:
 		return a, util.CmdHandler(dialogs.OpenDialogMsg{
 			Model: permissions.NewPermissionDialogCmp(msg.Payload, &permissions.Options{
@@ -433,7 +454,7 @@ func (a *appModel) handleKeyPressMsg(msg tea.KeyPressMsg) tea.Cmd {
 			return nil
 		}
 		return util.CmdHandler(dialogs.OpenDialogMsg{
-			Model: commands.NewCommandDialog(a.selectedSessionID),
+			Model: commands.NewCommandDialog(a.ctx, a.selectedSessionID, a.app.PluginRegistry.GetPluginCommands()),
 		})
 	case key.Matches(msg, a.keyMap.Sessions):
 		// if the app is not configured show no sessions
@@ -588,13 +609,14 @@ func (a *appModel) View() tea.View {
 }
 
 // New creates and initializes a new TUI application model.
-func New(app *app.App) *appModel {
+func New(ctx context.Context, app *app.App) *appModel {
 	chatPage := chat.New(app)
 	keyMap := DefaultKeyMap()
 	keyMap.pageBindings = chatPage.Bindings()
 
 	model := &appModel{
 		currentPage: chat.ChatPageID,
+		ctx:         ctx,
 		app:         app,
 		status:      status.NewStatusCmp(),
 		loadedPages: make(map[page.PageID]bool),
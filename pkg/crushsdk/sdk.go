@@ -6,8 +6,12 @@ package crushsdk
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
 
 	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/config"
 	"github.com/charmbracelet/crush/internal/plugin"
 )
 
@@ -22,6 +26,25 @@ type (
 	// PluginContext provides plugins with access to application services
 	PluginContext = plugin.PluginContext
 
+	// Services provides access to core application services
+	Services = plugin.Services
+
+	// KVStore is persistent key-value storage scoped to a plugin
+	KVStore = plugin.KVStore
+
+	// TopicBus publishes and subscribes to events on plugin topics
+	TopicBus = plugin.TopicBus
+
+	// TopicEvent is an event published on a plugin topic
+	TopicEvent = plugin.TopicEvent
+
+	// HTTPClient is an HTTP client for plugin tools that retries transient
+	// failures and limits redirects and response sizes
+	HTTPClient = plugin.HTTPClient
+
+	// HTTPClientOptions configures an HTTPClient
+	HTTPClientOptions = plugin.HTTPClientOptions
+
 	// Hooks defines all available hook points
 	Hooks = plugin.Hooks
 
@@ -31,18 +54,59 @@ type (
 	// SessionHook provides hooks for session lifecycle events
 	SessionHook = plugin.SessionHook
 
+	// ContextTruncatedHook lets a session hook observe messages that are no
+	// longer sent to the model
+	ContextTruncatedHook = plugin.ContextTruncatedHook
+
 	// MessageHook provides hooks for message lifecycle events
 	MessageHook = plugin.MessageHook
 
+	// BatchMessageHook lets a message hook receive events in batches
+	BatchMessageHook = plugin.BatchMessageHook
+
 	// PermissionHook provides hooks for permission requests
 	PermissionHook = plugin.PermissionHook
 
+	// PermissionDeniedHook lets a permission hook observe denied requests
+	PermissionDeniedHook = plugin.PermissionDeniedHook
+
 	// ToolHook provides hooks for tool execution
 	ToolHook = plugin.ToolHook
 
+	// RawToolHook lets a tool hook rewrite the raw JSON tool input
+	RawToolHook = plugin.RawToolHook
+
 	// AgentHook provides hooks for agent lifecycle
 	AgentHook = plugin.AgentHook
 
+	// ProviderHook observes provider requests and responses
+	ProviderHook = plugin.ProviderHook
+
+	// ProviderHooks is implemented by Hooks with a ProviderHook
+	ProviderHooks = plugin.ProviderHooks
+
+	// LSPHook observes the state of language server clients
+	LSPHook = plugin.LSPHook
+
+	// LSPHooks is implemented by Hooks with an LSPHook
+	LSPHooks = plugin.LSPHooks
+
+	// LSPState is the state of a language server client
+	LSPState = plugin.LSPState
+
+	// ProviderRequest describes a call to the model provider
+	ProviderRequest = plugin.ProviderRequest
+
+	// ProviderResponse describes the outcome of a provider call
+	ProviderResponse = plugin.ProviderResponse
+
+	// SystemPromptHook lets an agent hook change the system prompt
+	SystemPromptHook = plugin.SystemPromptHook
+
+	// BeforeModelCallHook lets an agent hook change the conversation sent to
+	// the model
+	BeforeModelCallHook = plugin.BeforeModelCallHook
+
 	// ToolExecuteInput contains information about a tool execution
 	ToolExecuteInput = plugin.ToolExecuteInput
 
@@ -58,13 +122,142 @@ type (
 	// AgentFinishInput contains information about an agent finishing
 	AgentFinishInput = plugin.AgentFinishInput
 
+	// AgentRetryInput contains information about a retried provider request
+	AgentRetryInput = plugin.AgentRetryInput
+
+	// ModelType tells AgentHook.OnModelChanged which model changed
+	ModelType = config.SelectedModelType
+
 	// PluginTool defines the interface for custom tools
 	PluginTool = plugin.PluginTool
 
+	// StreamingPluginTool is implemented by tools that report partial
+	// output while they run
+	StreamingPluginTool = plugin.StreamingPluginTool
+
+	// TimedTool is implemented by tools that limit how long a run may take
+	TimedTool = plugin.TimedTool
+
 	// ToolProvider is implemented by plugins that provide custom tools
 	ToolProvider = plugin.ToolProvider
+
+	// CommandProvider is implemented by plugins that provide slash commands
+	CommandProvider = plugin.CommandProvider
+
+	// PluginCommand describes a slash command provided by a plugin
+	PluginCommand = plugin.PluginCommand
+
+	// CommandHandler runs a plugin command
+	CommandHandler = plugin.CommandHandler
+
+	// ToolPermission describes the permission a tool requires before it runs
+	ToolPermission = plugin.ToolPermission
+
+	// ToolErrorKind classifies why a tool execution failed
+	ToolErrorKind = plugin.ToolErrorKind
+
+	// ToolError is the ToolExecuteResult.Error of a tool that returned an
+	// error
+	ToolError = plugin.ToolError
+
+	// HealthChecker is implemented by plugins that report their health
+	HealthChecker = plugin.HealthChecker
+
+	// SessionExporter renders session transcripts
+	SessionExporter = plugin.SessionExporter
+
+	// SubAgentRunner runs the agent on child sessions
+	SubAgentRunner = plugin.SubAgentRunner
+
+	// ToolCaller lists and calls the tools offered to the agent
+	ToolCaller = plugin.ToolCaller
+
+	// PluginEvent is published when a plugin is loaded or unloaded
+	PluginEvent = plugin.PluginEvent
+
+	// PluginEventType identifies a plugin lifecycle change
+	PluginEventType = plugin.PluginEventType
+
+	// No-op hook implementations that can be embedded to implement only
+	// some of a hook's methods
+	NilConfigHook     = plugin.NilConfigHook
+	NilSessionHook    = plugin.NilSessionHook
+	NilMessageHook    = plugin.NilMessageHook
+	NilPermissionHook = plugin.NilPermissionHook
+	NilToolHook       = plugin.NilToolHook
+	NilAgentHook      = plugin.NilAgentHook
+	NilProviderHook   = plugin.NilProviderHook
+	NilLSPHook        = plugin.NilLSPHook
+)
+
+// SDKVersion is the plugin SDK version; declare it as sdk_version in a
+// plugin manifest
+const SDKVersion = plugin.SDKVersion
+
+// Language server client states passed to LSPHook
+const (
+	LSPStateStarting = plugin.LSPStateStarting
+	LSPStateReady    = plugin.LSPStateReady
+	LSPStateError    = plugin.LSPStateError
+	LSPStateDisabled = plugin.LSPStateDisabled
+)
+
+// Model types passed to AgentHook.OnModelChanged
+const (
+	ModelTypeLarge = config.SelectedModelTypeLarge
+	ModelTypeSmall = config.SelectedModelTypeSmall
+)
+
+// Values of deniedBy passed to PermissionDeniedHook
+const (
+	DeniedByUser         = plugin.DeniedByUser
+	DeniedByPluginPrefix = plugin.DeniedByPluginPrefix
+)
+
+// Kinds of tool execution errors
+const (
+	ToolErrorPermission  = plugin.ToolErrorPermission
+	ToolErrorInvalidArgs = plugin.ToolErrorInvalidArgs
+	ToolErrorTimeout     = plugin.ToolErrorTimeout
+	ToolErrorExecution   = plugin.ToolErrorExecution
+	ToolErrorCancelled   = plugin.ToolErrorCancelled
 )
 
+// Plugin lifecycle event types
+const (
+	PluginLoaded      = plugin.PluginLoaded
+	PluginUnloaded    = plugin.PluginUnloaded
+	PluginQuarantined = plugin.PluginQuarantined
+)
+
+// ErrTemporary can be wrapped by a plugin's Init error to signal that the
+// failure is transient and initialization may be retried.
+var ErrTemporary = plugin.ErrTemporary
+
+// ErrToolStreamClosed is returned by a streaming tool's emit callback once
+// RunStream has returned.
+var ErrToolStreamClosed = plugin.ErrToolStreamClosed
+
+// ErrResponseTooLarge is returned by HTTPClient when a response body exceeds
+// HTTPClientOptions.MaxResponseBytes.
+var ErrResponseTooLarge = plugin.ErrResponseTooLarge
+
+// ErrInvalidTopic is returned when publishing on a topic with an invalid
+// name.
+var ErrInvalidTopic = plugin.ErrInvalidTopic
+
+// MaxHookDepth limits how many times hooks may trigger each other through
+// the services before Crush breaks the chain.
+const MaxHookDepth = plugin.MaxHookDepth
+
+// MaxToolCallDepth limits how deeply plugin tools may call other tools
+// through Services.Tools.
+const MaxToolCallDepth = plugin.MaxToolCallDepth
+
+// ErrToolRecursion is returned when a plugin tool calls itself, directly or
+// through other tools, or tool calls are nested too deeply.
+var ErrToolRecursion = plugin.ErrToolRecursion
+
 // Helper functions
 
 // NewBaseHooks creates a BaseHooks struct with all nil implementations.
@@ -73,6 +266,40 @@ func NewBaseHooks() *plugin.BaseHooks {
 	return plugin.NewBaseHooks()
 }
 
+// NewHTTPClient returns an HTTP client for plugin tools. Zero options select
+// defaults: a 30 second timeout per attempt, 5 redirects, 2 retries of
+// network errors and 429 and 5xx responses with exponential backoff, and a
+// 5 MiB response limit.
+func NewHTTPClient(opts HTTPClientOptions) *HTTPClient {
+	return plugin.NewHTTPClient(opts)
+}
+
+// ScopedToolHook returns a ToolHook that calls inner only for tools whose
+// name matches one of tools, so the hook doesn't have to filter by
+// ToolExecuteInput.ToolName itself. Entries may be glob patterns such as
+// "mcp_*".
+func ScopedToolHook(tools []string, inner ToolHook) ToolHook {
+	return plugin.ScopedToolHook(tools, inner)
+}
+
+// ToolErrorKindOf returns the kind of a ToolExecuteResult.Error, or "" if
+// the tool returned no error
+func ToolErrorKindOf(err error) ToolErrorKind {
+	return plugin.ToolErrorKindOf(err)
+}
+
+// PathGuardHook is a permission hook that denies requests for paths outside
+// a set of allowed directories
+type PathGuardHook = plugin.PathGuardHook
+
+// NewPathGuardHook returns a permission hook that denies any request whose
+// path is outside all of allowedPrefixes, resolving symlinks and .. first.
+// Other requests are left to other hooks and the user. Use Check to get the
+// reason a request is denied.
+func NewPathGuardHook(allowedPrefixes []string) *PathGuardHook {
+	return plugin.NewPathGuardHook(allowedPrefixes)
+}
+
 // SimplePlugin provides a base implementation that plugins can embed.
 // It handles the basic plugin lifecycle and allows plugins to focus on
 // implementing their specific hooks and tools.
@@ -83,8 +310,10 @@ type SimplePlugin struct {
 	initialized bool
 }
 
-// NewSimplePlugin creates a new SimplePlugin with the given metadata
+// NewSimplePlugin creates a new SimplePlugin with the given metadata.
+// Optional fields such as Homepage, License, and Tags may be left empty.
 func NewSimplePlugin(info PluginInfo) *SimplePlugin {
+	info.Tags = slices.Clone(info.Tags)
 	return &SimplePlugin{
 		info:  info,
 		hooks: plugin.NewBaseHooks(),
@@ -109,6 +338,12 @@ func (p *SimplePlugin) Shutdown(ctx context.Context) error {
 	return nil
 }
 
+// HealthCheck implements HealthChecker and always reports healthy. Plugins
+// embedding SimplePlugin can override it to report failures.
+func (p *SimplePlugin) HealthCheck(ctx context.Context) error {
+	return nil
+}
+
 // SetHooks allows setting custom hooks
 func (p *SimplePlugin) SetHooks(hooks Hooks) {
 	p.hooks = hooks
@@ -126,19 +361,33 @@ func (p *SimplePlugin) GetTools() []PluginTool {
 
 // SimpleTool provides a helper for creating simple tools
 type SimpleTool struct {
-	info    fantasy.ToolInfo
-	handler func(ctx context.Context, params fantasy.ToolCall) (fantasy.ToolResponse, error)
+	info       fantasy.ToolInfo
+	handler    func(ctx context.Context, params fantasy.ToolCall) (fantasy.ToolResponse, error)
+	permission *ToolPermission
+}
+
+// SimpleToolOption configures a SimpleTool
+type SimpleToolOption func(*SimpleTool)
+
+// WithPermission makes the tool request permission before every run. The
+// user is prompted unless the session or tool is auto-approved.
+func WithPermission(permission ToolPermission) SimpleToolOption {
+	return func(t *SimpleTool) {
+		t.permission = &permission
+	}
 }
 
-// NewSimpleTool creates a new SimpleTool
+// NewSimpleTool creates a new SimpleTool whose handler receives the raw tool
+// call. Most tools are easier to write with NewSimpleToolCtx.
 func NewSimpleTool(
 	name string,
 	description string,
 	parameters map[string]any,
 	required []string,
 	handler func(ctx context.Context, params fantasy.ToolCall) (fantasy.ToolResponse, error),
+	opts ...SimpleToolOption,
 ) *SimpleTool {
-	return &SimpleTool{
+	t := &SimpleTool{
 		info: fantasy.ToolInfo{
 			Name:        name,
 			Description: description,
@@ -147,6 +396,97 @@ func NewSimpleTool(
 		},
 		handler: handler,
 	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// ToolContext is what the handler of a tool created with NewSimpleToolCtx
+// receives for each call
+type ToolContext struct {
+	// Args are the decoded arguments of the call
+	Args map[string]any
+
+	// Input is the call's raw JSON input
+	Input string
+
+	// SessionID is the session of the running agent
+	SessionID string
+
+	// MessageID is the assistant message that made the call
+	MessageID string
+
+	// ToolCallID is the ID of the call
+	ToolCallID string
+
+	// Services are the services the plugin received in its PluginContext.
+	// They are zero if the tool doesn't run through Crush's registry, e.g.
+	// in unit tests.
+	Services Services
+}
+
+// Decode decodes the call's input into v, usually a pointer to a struct
+// with JSON tags matching the tool's parameters
+func (tc ToolContext) Decode(v any) error {
+	if tc.Input == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(tc.Input), v)
+}
+
+// NewSimpleToolCtx creates a new SimpleTool whose handler receives a
+// ToolContext instead of the raw tool call. Calls whose input isn't a JSON
+// object or lacks required parameters are answered with an error response
+// without calling the handler.
+func NewSimpleToolCtx(
+	name string,
+	description string,
+	parameters map[string]any,
+	required []string,
+	handler func(ctx context.Context, tc ToolContext) (fantasy.ToolResponse, error),
+	opts ...SimpleToolOption,
+) *SimpleTool {
+	return NewSimpleTool(name, description, parameters, required, func(ctx context.Context, params fantasy.ToolCall) (fantasy.ToolResponse, error) {
+		tc, err := newToolContext(ctx, params, required)
+		if err != nil {
+			return fantasy.NewTextErrorResponse(err.Error()), nil
+		}
+		return handler(ctx, tc)
+	}, opts...)
+}
+
+// newToolContext decodes and validates a tool call for NewSimpleToolCtx
+func newToolContext(ctx context.Context, params fantasy.ToolCall, required []string) (ToolContext, error) {
+	args := map[string]any{}
+	if params.Input != "" {
+		if err := json.Unmarshal([]byte(params.Input), &args); err != nil || args == nil {
+			return ToolContext{}, fmt.Errorf("invalid input for tool %s: expected a JSON object", params.Name)
+		}
+	}
+	var missing []string
+	for _, name := range required {
+		if _, ok := args[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return ToolContext{}, fmt.Errorf("missing required parameters for tool %s: %v", params.Name, missing)
+	}
+	services, _ := ServicesFromContext(ctx)
+	return ToolContext{
+		Args:       args,
+		Input:      params.Input,
+		SessionID:  SessionID(ctx),
+		MessageID:  MessageID(ctx),
+		ToolCallID: params.ID,
+		Services:   services,
+	}, nil
+}
+
+// Permission returns the permission the tool requires, or nil
+func (t *SimpleTool) Permission() *ToolPermission {
+	return t.permission
 }
 
 func (t *SimpleTool) Info() fantasy.ToolInfo {
@@ -157,6 +497,57 @@ func (t *SimpleTool) Run(ctx context.Context, params fantasy.ToolCall) (fantasy.
 	return t.handler(ctx, params)
 }
 
+// Abort cancels the current agent run for the session the context belongs
+// to, surfacing reason to the user. It must be called with a context received
+// during an agent run (e.g. in a tool's Run); otherwise it does nothing and
+// returns false.
+func Abort(ctx context.Context, reason string) bool {
+	return plugin.Abort(ctx, reason)
+}
+
+// SessionID returns the ID of the session ctx belongs to, as passed to tools
+// and commands. It is empty if ctx doesn't belong to a session.
+func SessionID(ctx context.Context) string {
+	return plugin.SessionID(ctx)
+}
+
+// MessageID returns the ID of the assistant message whose tool call ctx
+// belongs to. It is empty outside tool runs.
+func MessageID(ctx context.Context) string {
+	return plugin.MessageID(ctx)
+}
+
+// ServicesFromContext returns the services of the plugin whose tool ctx was
+// passed to. It reports false outside the runs of plugin tools.
+func ServicesFromContext(ctx context.Context) (Services, bool) {
+	return plugin.ServicesFromContext(ctx)
+}
+
+// DecodeSettings decodes the plugin's settings section into v, leaving v
+// unchanged if there is none. Fields missing from the section keep the values
+// v already has, so v can be filled with defaults first.
+func DecodeSettings(pluginCtx PluginContext, v any) error {
+	if len(pluginCtx.Settings) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(pluginCtx.Settings, v); err != nil {
+		return fmt.Errorf("invalid plugin settings: %w", err)
+	}
+	return nil
+}
+
+// TopicName returns the full name of a plugin's topic, "<plugin>/<name>",
+// for subscribing to it
+func TopicName(pluginName, name string) string {
+	return plugin.TopicName(pluginName, name)
+}
+
+// InHook reports whether ctx was passed to a hook. Service calls made with
+// such a context count towards MaxHookDepth.
+func InHook(ctx context.Context) bool {
+	return plugin.InHook(ctx)
+}
+
 // Permission helpers
 
 // Allow returns a pointer to true for permission hooks
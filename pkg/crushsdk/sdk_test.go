@@ -0,0 +1,62 @@
+package crushsdk
+
+import (
+	"context"
+	"testing"
+
+	"charm.land/fantasy"
+	"github.com/charmbracelet/crush/internal/agent/tools"
+	"github.com/charmbracelet/crush/internal/plugin"
+	"github.com/charmbracelet/crush/internal/session"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSimpleToolCtx(t *testing.T) {
+	t.Parallel()
+
+	var calls []ToolContext
+	p := NewSimplePlugin(PluginInfo{Name: "greeter"})
+	p.AddTool(NewSimpleToolCtx(
+		"greet",
+		"Greets someone",
+		map[string]any{"name": map[string]any{"type": "string"}},
+		[]string{"name"},
+		func(ctx context.Context, tc ToolContext) (fantasy.ToolResponse, error) {
+			calls = append(calls, tc)
+			var input struct {
+				Name string `json:"name"`
+			}
+			if err := tc.Decode(&input); err != nil {
+				return fantasy.ToolResponse{}, err
+			}
+			return fantasy.NewTextResponse("hello " + input.Name), nil
+		},
+	))
+
+	sessions := session.NewService(nil)
+	registry := plugin.NewRegistry()
+	require.NoError(t, registry.LoadPlugin(t.Context(), p, PluginContext{
+		Services: Services{Session: sessions},
+	}))
+	agentTools := registry.GetPluginTools()
+	require.Len(t, agentTools, 1)
+
+	ctx := context.WithValue(t.Context(), tools.SessionIDContextKey, "session-1")
+	ctx = context.WithValue(ctx, tools.MessageIDContextKey, "message-1")
+	resp, err := agentTools[0].Run(ctx, fantasy.ToolCall{ID: "call-1", Name: "greet", Input: `{"name": "crush"}`})
+	require.NoError(t, err)
+	require.Equal(t, "hello crush", resp.Content)
+	require.Len(t, calls, 1)
+	require.Equal(t, map[string]any{"name": "crush"}, calls[0].Args)
+	require.Equal(t, "session-1", calls[0].SessionID)
+	require.Equal(t, "message-1", calls[0].MessageID)
+	require.Equal(t, "call-1", calls[0].ToolCallID)
+	require.NotNil(t, calls[0].Services.Session, "the plugin's services must be passed on")
+
+	for _, input := range []string{`{}`, `["crush"]`, `not json`} {
+		resp, err = agentTools[0].Run(ctx, fantasy.ToolCall{ID: "call-2", Name: "greet", Input: input})
+		require.NoError(t, err)
+		require.True(t, resp.IsError, input)
+	}
+	require.Len(t, calls, 1, "invalid calls must not reach the handler")
+}